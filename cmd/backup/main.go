@@ -0,0 +1,135 @@
+// Command backup writes a JSON snapshot of the database, and restores one.
+//
+//	backup [-db path] [-o file] create
+//	backup [-db path] [-mode replace|merge] restore file
+//
+// create writes to standard output unless -o is given; restore reads
+// standard input when the file is "-".
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"health-monitor/internal/db"
+)
+
+func main() {
+	dbPath := flag.String("db", db.ConfigFromEnv().Path, "path to the SQLite database, defaults to DB_PATH")
+	output := flag.String("o", "", "file to write the backup to, defaults to standard output")
+	mode := flag.String("mode", string(db.RestoreMerge), "how to restore: replace deletes every gauge first, merge adds to them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] create | restore file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(context.Background(), *dbPath, *output, *mode, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "backup:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dbPath, output, mode string, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	switch args[0] {
+	case "create":
+		database, err := open(ctx, dbPath)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+		return create(ctx, db.NewStore(database), output)
+	case "restore":
+		if len(args) < 2 {
+			flag.Usage()
+			return fmt.Errorf("missing backup file")
+		}
+		restoreMode, err := db.ParseRestoreMode(mode)
+		if err != nil {
+			return err
+		}
+		database, err := open(ctx, dbPath)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+		return restore(ctx, db.NewStore(database), args[1], restoreMode)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// open opens the database, bringing its schema up to date so a backup can be
+// restored into a new file
+func open(ctx context.Context, dbPath string) (*sql.DB, error) {
+	database, err := db.Open(db.DefaultConfig(dbPath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Migrate(ctx, database); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
+}
+
+func create(ctx context.Context, store *db.Store, output string) error {
+	backup, err := store.Backup(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := db.WriteBackup(w, backup); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "backed up %d gauges\n", len(backup.Gauges))
+	return nil
+}
+
+func restore(ctx context.Context, store *db.Store, path string, mode db.RestoreMode) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	backup, err := db.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+	result, err := store.Restore(ctx, backup, mode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("restored %d gauges, %d values and %d periods", result.Gauges, result.Values, result.Periods)
+	if result.Skipped > 0 {
+		fmt.Printf("; %d values were already recorded", result.Skipped)
+	}
+	fmt.Println()
+	return nil
+}
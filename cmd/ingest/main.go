@@ -0,0 +1,187 @@
+// Command ingest imports measurements exported by health apps into gauges.
+//
+//	ingest [-db path] -user name [flags] apple export.zip|export.xml
+//	ingest [-db path] -user name [flags] googlefit takeout.zip|Fit|file
+//	ingest [-db path] -user name [flags] fitbit export.zip|dir|file
+//
+// Each record type maps to a gauge of the -user by name; -types picks among
+// the default mappings and -map adds or redirects one. Records are combined
+// into one value per gauge period. The import is made as that user: only
+// the gauges they may see are matched, those -create adds are theirs, and
+// the changes are recorded in the audit log under their name.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/ingest"
+)
+
+// mappingFlags collects repeated -map flags
+type mappingFlags []string
+
+func (m *mappingFlags) String() string     { return strings.Join(*m, ",") }
+func (m *mappingFlags) Set(s string) error { *m = append(*m, s); return nil }
+
+func main() {
+	dbPath := flag.String("db", db.ConfigFromEnv().Path, "path to the SQLite database, defaults to DB_PATH")
+	username := flag.String("user", "", "user whose gauges the records are imported into (required)")
+	types := flag.String("types", "", "comma separated record types to import from the default mappings, defaults to all of them")
+	var maps mappingFlags
+	flag.Var(&maps, "map", "map a record type to a gauge, as type=gauge[:unit[:aggregation]]; may be repeated")
+	create := flag.Bool("create", false, "create a daily gauge for each mapping whose gauge does not exist")
+	timezone := flag.String("tz", "UTC", "time zone of created gauges")
+	since := flag.String("since", "", "only import records dated on or after this day, as 2006-01-02")
+	batch := flag.Int("batch", ingest.DefaultBatchSize, "period values written per transaction")
+	dryRun := flag.Bool("dry-run", false, "show what would be imported without changing anything")
+	weightUnit := flag.String("weight-unit", "kg", "unit a Fitbit account logs weight in")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s -user name [flags] apple|googlefit|fitbit path\n", os.Args[0])
+		flag.PrintDefaults()
+		for _, src := range ingest.Sources {
+			fmt.Fprintf(out, "\nDefault %s mappings:\n", src.Name())
+			for _, m := range src.Mappings() {
+				fmt.Fprintf(out, "  %s=%s:%s:%s\n", strings.TrimPrefix(m.Type, src.TypePrefix()), m.Gauge, m.Unit, m.Aggregation)
+			}
+		}
+	}
+	flag.Parse()
+
+	opts := ingest.Options{Create: *create, Timezone: *timezone, BatchSize: *batch, DryRun: *dryRun}
+	if *since != "" {
+		t, err := time.Parse("2006-01-02", *since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ingest: invalid -since %q\n", *since)
+			os.Exit(2)
+		}
+		opts.Since = t
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *dbPath, *username, *types, maps, *weightUnit, opts, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "ingest:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dbPath, username, types string, maps []string, weightUnit string, opts ingest.Options, args []string) error {
+	if username == "" {
+		flag.Usage()
+		return fmt.Errorf("missing -user")
+	}
+	if len(args) < 2 {
+		flag.Usage()
+		return fmt.Errorf("missing source or file")
+	}
+	src, ok := ingest.SourceByName(args[0])
+	if !ok {
+		flag.Usage()
+		return fmt.Errorf("unknown source %q", args[0])
+	}
+	if _, ok := src.(ingest.Fitbit); ok {
+		src = ingest.Fitbit{WeightUnit: weightUnit}
+	}
+
+	mappings := src.Mappings()
+	if types != "" {
+		var err error
+		if mappings, err = ingest.SelectMappings(strings.Split(types, ","), src.TypePrefix(), src.Mappings()); err != nil {
+			return err
+		}
+	}
+	for _, spec := range maps {
+		m, err := ingest.ParseMapping(spec, src.TypePrefix(), src.Mappings())
+		if err != nil {
+			return err
+		}
+		mappings = withMapping(mappings, m)
+	}
+	opts.Mappings = mappings
+
+	files, closer, err := ingest.OpenExport(args[1], src)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	database, err := db.Open(db.DefaultConfig(dbPath))
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	if _, err := db.Migrate(ctx, database); err != nil {
+		return err
+	}
+	store := db.NewStore(database)
+	user, err := store.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user %q", username)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts.Progress = progressPrinter(os.Stderr, ingest.TotalSize(files))
+	result, err := ingest.Import(auth.WithUser(ctx, user), access.NewStore(store), src, files, opts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	verb := ""
+	if opts.DryRun {
+		verb = "would be "
+	}
+	for _, name := range result.Created {
+		fmt.Printf("gauge %q %screated\n", name, verb)
+	}
+	for _, name := range result.Missing {
+		fmt.Printf("gauge %q does not exist for %s; its records were skipped (use -create)\n", name, user.Username)
+	}
+	fmt.Printf("%d records read, %d matched, %d invalid\n", result.Records, result.Matched, result.Invalid)
+	fmt.Printf("%d period values %simported, %d already imported\n", result.Imported, verb, result.Duplicates)
+	if result.Updated > 0 {
+		fmt.Printf("%d of them %supdated periods imported before\n", result.Updated, verb)
+	}
+	return nil
+}
+
+// withMapping replaces the mapping for m's type, or adds it
+func withMapping(mappings []ingest.Mapping, m ingest.Mapping) []ingest.Mapping {
+	out := make([]ingest.Mapping, 0, len(mappings)+1)
+	for _, existing := range mappings {
+		if existing.Type != m.Type {
+			out = append(out, existing)
+		}
+	}
+	return append(out, m)
+}
+
+// progressPrinter rewrites one status line as the import goes
+func progressPrinter(w io.Writer, size int64) func(ingest.Progress) {
+	return func(p ingest.Progress) {
+		if p.Periods == 0 {
+			percent := 0.0
+			if size > 0 {
+				percent = float64(p.Bytes) / float64(size) * 100
+			}
+			fmt.Fprintf(w, "\rreading %5.1f%%  %d records, %d matched", percent, p.Records, p.Matched)
+			return
+		}
+		fmt.Fprintf(w, "\rwriting %d/%d period values                    ", p.Written, p.Periods)
+	}
+}
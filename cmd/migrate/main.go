@@ -0,0 +1,109 @@
+// Command migrate applies, rolls back and reports database migrations.
+//
+//	migrate [-db path] [-dry-run] up
+//	migrate [-db path] [-dry-run] down [steps]
+//	migrate [-db path] status
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"health-monitor/internal/db"
+)
+
+func main() {
+	dbPath := flag.String("db", db.ConfigFromEnv().Path, "path to the SQLite database, defaults to DB_PATH")
+	dryRun := flag.Bool("dry-run", false, "show what would change without changing anything")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up | down [steps] | status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(context.Background(), *dbPath, *dryRun, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dbPath string, dryRun bool, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	database, err := db.Open(db.DefaultConfig(dbPath))
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	m, err := db.NewMigrator(database)
+	if err != nil {
+		return err
+	}
+	m.DryRun = dryRun
+
+	switch args[0] {
+	case "up":
+		report, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		printReport(report)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid steps %q", args[1])
+			}
+		}
+		report, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		printReport(report)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return nil
+}
+
+func printReport(report *db.MigrationReport) {
+	verb := ""
+	if report.DryRun {
+		verb = "would be "
+	}
+
+	if report.Legacy != "" {
+		fmt.Printf("legacy %s layout %sadopted\n", report.Legacy, verb)
+	}
+	for _, m := range report.Applied {
+		fmt.Printf("%04d_%s %sapplied\n", m.Version, m.Name, verb)
+	}
+	for _, m := range report.RolledBack {
+		fmt.Printf("%04d_%s %srolled back\n", m.Version, m.Name, verb)
+	}
+	if len(report.Applied) == 0 && len(report.RolledBack) == 0 {
+		fmt.Println("nothing to do")
+	}
+}
@@ -1,19 +1,30 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata" // Gauge periods may use any IANA time zone
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	_ "github.com/mattn/go-sqlite3"
 
+	"health-monitor/internal/access"
+	"health-monitor/internal/alert"
+	"health-monitor/internal/audit"
+	"health-monitor/internal/auth"
 	"health-monitor/internal/db"
+	"health-monitor/internal/events"
 	"health-monitor/internal/handlers"
 	"health-monitor/internal/logger"
+	"health-monitor/internal/metrics"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/report"
+	"health-monitor/internal/snapshot"
 	"health-monitor/internal/views/layouts"
 	"health-monitor/internal/views/pages"
+	"health-monitor/internal/webhook"
 )
 
 
@@ -30,21 +41,133 @@ func main() {
 		logger.Debug().Str("port", port).Msg("Using default port")
 	}
 
-	database, err := sql.Open("sqlite3", "health.db")
+	dbConfig := db.ConfigFromEnv()
+	database, err := db.Open(dbConfig)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Error opening database")
 	}
 	defer database.Close()
-	logger.Debug().Msg("Connected to database")
+	logger.Debug().Str("path", dbConfig.Path).Str("driver", db.Driver).Msg("Connected to database")
 
-	queries := db.New(database)
+	// Bring the schema up to date before serving anything
+	migration, err := db.Migrate(context.Background(), database)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Error migrating database")
+	}
+	if migration.Legacy != "" {
+		logger.Info().Str("layout", migration.Legacy).Msg("Adopted legacy database layout")
+	}
+	for _, m := range migration.Applied {
+		logger.Info().Int("version", m.Version).Str("name", m.Name).Msg("Applied migration")
+	}
+
+	queries := db.NewStore(database)
+
+	// Expose gauges, request and query timings to Prometheus
+	promMetrics := metrics.New(queries, database)
+	queries.ObserveQueries(promMetrics.ObserveQuery)
+
+	// Changes made through either handler are pushed to open dashboards
+	// and webhooks, as are the periods the store closes
+	broker := events.NewBroker()
+	queries.OnPeriodClosed(func(gauge db.Gauge, closed db.GaugePeriod) {
+		broker.Publish(events.Closed(gauge, closed))
+	})
+
+	// Deliver gauge events to the webhooks configured on the admin page
+	webhooks := webhook.NewDispatcher(queries, webhook.DefaultConfig)
+	go webhooks.Listen(context.Background(), broker, func(err error) {
+		logger.Error().Err(err).Msg("Failed to queue webhook deliveries")
+	})
+	go webhooks.Run(context.Background(), func(err error) {
+		logger.Error().Err(err).Msg("Failed to deliver webhooks")
+	})
+
+	// Close out finished gauge periods in the background so idle gauges
+	// reset even when nobody is looking at the dashboard
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := queries.RolloverGauges(context.Background(), now); err != nil {
+				logger.Error().Err(err).Msg("Failed to roll over gauge periods")
+			}
+		}
+	}()
+
+	// Copy the database file on a schedule when BACKUP_DIR is set
+	backupConfig, err := snapshot.ConfigFromEnv()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid backup settings")
+	}
+	snapshots := snapshot.NewScheduler(database, backupConfig)
+	if backupConfig.Dir != "" {
+		logger.Info().Str("dir", backupConfig.Dir).Str("schedule", backupConfig.Spec).Msg("Scheduled backups enabled")
+	}
+	go snapshots.Run(context.Background(), func(err error) {
+		logger.Error().Err(err).Msg("Scheduled backup failed")
+	})
+
+	// Check the alert rules every minute, emailing through SMTP_HOST when
+	// it is set
+	smtpConfig, err := notify.SMTPConfigFromEnv()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid SMTP settings")
+	}
+	alerts := alert.NewScheduler(queries, smtpConfig)
+	go alerts.Run(context.Background(), func(err error) {
+		logger.Error().Err(err).Msg("Failed to send alerts")
+	})
+
+	// Email the weekly and monthly digest to REPORT_EMAIL
+	reportConfig, err := report.ConfigFromEnv()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid report settings")
+	}
+	if len(reportConfig.To) > 0 && smtpConfig.Host == "" {
+		logger.Fatal().Msg("REPORT_EMAIL is set but SMTP_HOST is not")
+	}
+	reports := report.NewScheduler(queries, reportConfig, smtpConfig)
+	go reports.Run(context.Background(), func(err error) {
+		logger.Error().Err(err).Msg("Failed to send report")
+	})
+
+	// Sign users in with a session cookie kept in the database
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid session settings")
+	}
+	sessions := auth.NewManager(queries, authConfig)
+	tokens := auth.NewTokens(queries)
+	if n, err := queries.CountUsers(context.Background()); err != nil {
+		logger.Fatal().Err(err).Msg("Error counting users")
+	} else if n == 0 {
+		logger.Warn().Msg("No users yet; create the first admin with: user create-admin <name>")
+	}
 
 	r := chi.NewRouter()
+	r.Use(promMetrics.Middleware)
 
 	// Custom zerolog middleware for request logging
 	r.Use(middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: logger.StdLogger(), NoColor: false}))
 	r.Use(middleware.Recoverer)
 
+	// Everything but signing in needs a signed in user, and the pages that
+	// configure the whole site need an admin. Scripts use the API with an
+	// access token instead of a session, and Prometheus reads the metrics
+	// with METRICS_TOKEN. Changes made with a session must carry its CSRF
+	// token, which the pages send with every request.
+	r.Use(audit.Load)
+	r.Use(sessions.Load)
+	r.Use(tokens.Load)
+	r.Use(auth.Scrape(os.Getenv("METRICS_TOKEN"), promMetrics.Handler()))
+	r.Use(auth.Protect)
+	r.Use(auth.CheckCSRF)
+	r.Use(auth.AdminOnly("/admin/backup", "/admin/webhooks", "/admin/alerts", "/admin/reports", "/admin/audit", auth.MetricsPath))
+
+	// The pages and the API see only the gauges the signed in user may view
+	scoped := access.NewStore(queries)
+
 	// Add custom debug middleware to trace route execution
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -54,8 +177,20 @@ func main() {
 		})
 	})
 
+	// Check API responses against the OpenAPI document while developing
+	if os.Getenv("OPENAPI_VALIDATE") == "true" {
+		r.Use(handlers.APISpec().ValidateResponses("/api/v1", func(r *http.Request, err error) {
+			logger.Warn().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("Response does not match OpenAPI document")
+		}))
+	}
+
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		gauges, err := queries.ListGauges(r.Context())
+		if err := queries.RolloverGauges(r.Context(), time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		gauges, err := scoped.ListGauges(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -69,10 +204,42 @@ func main() {
 		}
 	})
 
+	// Sign in and out
+	handlers.NewAuthHandler(sessions).RegisterRoutes(r)
+
 	// Create gauge handler and register all gauge-related routes
-	gaugeHandler := handlers.NewGaugeHandler(queries)
+	gaugeHandler := handlers.NewGaugeHandler(scoped)
+	gaugeHandler.SetSnapshots(snapshots)
+	gaugeHandler.SetEvents(broker)
 	gaugeHandler.RegisterRoutes(r)
 
+	// Register the JSON API
+	apiHandler := handlers.NewAPIHandler(scoped)
+	apiHandler.SetEvents(broker)
+	apiHandler.RegisterRoutes(r)
+
+	// Households and the gauges shared with them
+	householdHandler := handlers.NewHouseholdHandler(queries, scoped)
+	householdHandler.SetEvents(broker)
+	householdHandler.RegisterRoutes(r)
+
+	// Each user's access tokens for the API
+	handlers.NewTokenHandler(queries, tokens).RegisterRoutes(r)
+
+	// Admin pages for outbound webhooks
+	handlers.NewWebhookHandler(queries, webhooks).RegisterRoutes(r)
+
+	// Admin pages for alert rules and where they are sent
+	handlers.NewAlertHandler(queries, alerts).RegisterRoutes(r)
+
+	// Preview of the digest reports
+	handlers.NewReportHandler(queries, reports, reportConfig.Specs).RegisterRoutes(r)
+
+	// The audit log of every change to the gauges
+	handlers.NewAuditHandler(queries).RegisterRoutes(r)
+
+	r.Handle(auth.MetricsPath, promMetrics.Handler())
+
 	// Add static file server for assets
 	fs := http.FileServer(http.Dir("./static"))
 	r.Handle("/static/*", http.StripPrefix("/static/", fs))
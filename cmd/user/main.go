@@ -0,0 +1,162 @@
+// Command user manages the accounts that may sign in, starting with the
+// first admin.
+//
+//	user [-db path] create-admin name
+//	user [-db path] passwd name
+//	user [-db path] list
+//
+// create-admin and passwd read the password from the first line of
+// standard input. create-admin gives the new admin every gauge that has no
+// owner yet, and passwd signs the user out everywhere.
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+)
+
+func main() {
+	dbPath := flag.String("db", db.ConfigFromEnv().Path, "path to the SQLite database, defaults to DB_PATH")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] create-admin name | passwd name | list\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(context.Background(), *dbPath, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "user:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dbPath string, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	switch args[0] {
+	case "create-admin", "passwd":
+		if len(args) < 2 {
+			flag.Usage()
+			return fmt.Errorf("missing user name")
+		}
+		password, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		database, err := open(ctx, dbPath)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+		if args[0] == "passwd" {
+			return passwd(ctx, db.NewStore(database), args[1], password)
+		}
+		return createAdmin(ctx, db.NewStore(database), args[1], password)
+	case "list":
+		database, err := open(ctx, dbPath)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+		return list(ctx, db.NewStore(database))
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// open opens the database, bringing its schema up to date so the first
+// admin can be created before the server has ever run
+func open(ctx context.Context, dbPath string) (*sql.DB, error) {
+	database, err := db.Open(db.DefaultConfig(dbPath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Migrate(ctx, database); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
+}
+
+// readPassword reads a password from the first line of r, prompting for it
+func readPassword(r io.Reader) (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if err := auth.ValidatePassword(password); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+func createAdmin(ctx context.Context, store *db.Store, name, password string) error {
+	user, err := auth.CreateUser(ctx, store, name, password, true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created admin %s\n", user.Username)
+
+	// Gauges from before there were users, or made by cmd/ingest, belong
+	// to no one until an admin takes them
+	adopted, err := store.AdoptGauges(ctx, sql.NullInt64{Int64: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+	if adopted > 0 {
+		fmt.Printf("%s now owns %d existing gauges\n", user.Username, adopted)
+	}
+	return nil
+}
+
+func passwd(ctx context.Context, store *db.Store, name, password string) error {
+	user, err := store.GetUserByUsername(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user named %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := store.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{PasswordHash: hash, ID: user.ID}); err != nil {
+		return err
+	}
+	if err := store.DeleteUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	fmt.Printf("changed the password of %s\n", user.Username)
+	return nil
+}
+
+func list(ctx context.Context, store *db.Store) error {
+	users, err := store.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		role := "user"
+		if user.IsAdmin {
+			role = "admin"
+		}
+		fmt.Printf("%s\t%s\tcreated %s\n", user.Username, role, user.CreatedAt.Format("2006-01-02"))
+	}
+	return nil
+}
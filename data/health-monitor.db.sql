@@ -1,19 +0,0 @@
-CREATE TABLE gauges (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    name TEXT NOT NULL,
-    description TEXT,
-    target INTEGER NOT NULL,
-    unit TEXT NOT NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE gauge_values (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    gauge_id INTEGER NOT NULL,
-    value INTEGER NOT NULL,
-    week INTEGER NOT NULL,
-    year INTEGER NOT NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (gauge_id) REFERENCES gauges (id) ON DELETE CASCADE
-);
@@ -0,0 +1,103 @@
+// Package access decides what each user may do with each gauge. A gauge
+// belongs to the user who created it and may be shared with one household,
+// whose members get their household role on it.
+package access
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"health-monitor/internal/db"
+)
+
+// Role is what a user may do with a gauge or in a household. Each role may
+// do everything the ones below it may.
+type Role int
+
+const (
+	// None may not see the gauge at all
+	None Role = iota
+	// Viewer may see the gauge and its history
+	Viewer
+	// Editor may also log values and change the gauge's settings
+	Editor
+	// Owner may also delete the gauge and choose who it is shared with
+	Owner
+)
+
+// Roles lists the roles a household member may have, most able first
+var Roles = []Role{Owner, Editor, Viewer}
+
+// ErrForbidden is returned for a change the signed in user may see but not
+// make
+var ErrForbidden = errors.New("you may not make this change")
+
+// String returns the role as it is stored
+func (r Role) String() string {
+	switch r {
+	case Owner:
+		return "owner"
+	case Editor:
+		return "editor"
+	case Viewer:
+		return "viewer"
+	}
+	return "none"
+}
+
+// ParseRole parses a stored role
+func ParseRole(s string) (Role, error) {
+	for _, r := range Roles {
+		if s == r.String() {
+			return r, nil
+		}
+	}
+	return None, fmt.Errorf("unknown role %q", s)
+}
+
+// Members looks up who belongs to a household
+type Members interface {
+	GetHouseholdMember(ctx context.Context, arg db.GetHouseholdMemberParams) (db.HouseholdMember, error)
+}
+
+// HouseholdRole returns a user's role in a household, None if they are not
+// a member
+func HouseholdRole(ctx context.Context, store Members, householdID int64, user db.User) (Role, error) {
+	member, err := store.GetHouseholdMember(ctx, db.GetHouseholdMemberParams{
+		HouseholdID: householdID,
+		UserID:      user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return None, nil
+	}
+	if err != nil {
+		return None, err
+	}
+	return ParseRole(member.Role)
+}
+
+// GaugeRole returns a user's role on a gauge. The owner has every right, as
+// do admins on gauges from before there were owners; a household member has
+// their household role on the gauges shared with it, but at most Editor, so
+// only the gauge's own user may delete it or choose who it is shared with.
+func GaugeRole(ctx context.Context, store Members, gauge db.Gauge, user db.User) (Role, error) {
+	if user.ID == 0 {
+		return None, nil
+	}
+	if gauge.OwnerID.Valid && gauge.OwnerID.Int64 == user.ID {
+		return Owner, nil
+	}
+	if !gauge.OwnerID.Valid && user.IsAdmin {
+		return Owner, nil
+	}
+	if !gauge.HouseholdID.Valid {
+		return None, nil
+	}
+	role, err := HouseholdRole(ctx, store, gauge.HouseholdID.Int64, user)
+	if err != nil {
+		return None, err
+	}
+	return min(role, Editor), nil
+}
@@ -0,0 +1,235 @@
+package access
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"health-monitor/internal/audit"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	scoped := NewStore(store)
+	ctx := context.Background()
+
+	newUser := func(name string, admin bool) (db.User, context.Context) {
+		user, err := store.CreateUser(ctx, db.CreateUserParams{Username: name, PasswordHash: "-", IsAdmin: admin})
+		require.NoError(t, err)
+		return user, auth.WithUser(ctx, user)
+	}
+	alice, asAlice := newUser("alice", true)
+	bob, asBob := newUser("bob", false)
+	carol, asCarol := newUser("carol", false)
+	_, asDave := newUser("dave", false)
+
+	// A gauge from before there were owners, and one of Alice's own
+	legacy := testutil.CreateTestGauge(t, store)
+	gauge, err := scoped.CreateGauge(asAlice, db.CreateGaugeParams{
+		Name: "Water", Target: 8, Unit: "glasses", Icon: "star",
+		Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sql.NullInt64{Int64: alice.ID, Valid: true}, gauge.OwnerID)
+
+	home, err := store.AddHousehold(ctx, db.AddHouseholdParams{Name: "Home", UserID: alice.ID, Role: Owner.String()})
+	require.NoError(t, err)
+	require.NoError(t, store.SetHouseholdMember(ctx, db.SetHouseholdMemberParams{HouseholdID: home.ID, UserID: bob.ID, Role: Editor.String()}))
+	require.NoError(t, store.SetHouseholdMember(ctx, db.SetHouseholdMemberParams{HouseholdID: home.ID, UserID: carol.ID, Role: Viewer.String()}))
+
+	names := func(ctx context.Context) []string {
+		gauges, err := scoped.ListGauges(ctx)
+		require.NoError(t, err)
+		var names []string
+		for _, g := range gauges {
+			names = append(names, g.Name)
+		}
+		return names
+	}
+
+	t.Run("before sharing", func(t *testing.T) {
+		assert.Equal(t, []string{"Test Gauge", "Water"}, names(asAlice))
+		assert.Empty(t, names(asBob))
+		assert.Empty(t, names(ctx))
+
+		_, err := scoped.GetGauge(asBob, gauge.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		_, err = scoped.GetGauge(asBob, legacy.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("only members may be shared with", func(t *testing.T) {
+		require.NoError(t, store.SetHouseholdMember(ctx, db.SetHouseholdMemberParams{HouseholdID: home.ID, UserID: alice.ID, Role: Viewer.String()}))
+		err := scoped.ShareGauge(asAlice, gauge.ID, sql.NullInt64{Int64: home.ID, Valid: true})
+		assert.ErrorIs(t, err, ErrForbidden)
+		require.NoError(t, store.SetHouseholdMember(ctx, db.SetHouseholdMemberParams{HouseholdID: home.ID, UserID: alice.ID, Role: Owner.String()}))
+
+		err = scoped.ShareGauge(asBob, gauge.ID, sql.NullInt64{Int64: home.ID, Valid: true})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	require.NoError(t, scoped.ShareGauge(asAlice, gauge.ID, sql.NullInt64{Int64: home.ID, Valid: true}))
+	gauge, err = scoped.GetGauge(asAlice, gauge.ID)
+	require.NoError(t, err)
+
+	t.Run("roles", func(t *testing.T) {
+		tests := []struct {
+			name string
+			ctx  context.Context
+			role Role
+		}{
+			{"owner", asAlice, Owner},
+			{"editor", asBob, Editor},
+			{"viewer", asCarol, Viewer},
+			{"outsider", asDave, None},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				role, err := scoped.Role(tt.ctx, gauge)
+				require.NoError(t, err)
+				assert.Equal(t, tt.role, role)
+			})
+		}
+	})
+
+	t.Run("editor", func(t *testing.T) {
+		assert.Equal(t, []string{"Water"}, names(asBob))
+		_, err := scoped.RecordGaugeValue(asBob, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, scoped.DeleteGauge(asBob, gauge.ID), ErrForbidden)
+		assert.ErrorIs(t, scoped.ShareGauge(asBob, gauge.ID, sql.NullInt64{}), ErrForbidden)
+	})
+
+	t.Run("viewer", func(t *testing.T) {
+		assert.Equal(t, []string{"Water"}, names(asCarol))
+		_, err := scoped.ListGaugeValues(asCarol, db.ListGaugeValuesParams{GaugeID: gauge.ID, Limit: 10})
+		assert.NoError(t, err)
+		_, err = scoped.RecordGaugeValue(asCarol, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = scoped.ImportGaugeValues(asCarol, []db.ImportedValue{{GaugeID: gauge.ID, Delta: 1}}, true)
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("outsider", func(t *testing.T) {
+		assert.Empty(t, names(asDave))
+		_, err := scoped.GetGaugeHistory(asDave, gauge.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		_, err = scoped.Backup(asDave)
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("stop sharing", func(t *testing.T) {
+		require.NoError(t, scoped.ShareGauge(asAlice, gauge.ID, sql.NullInt64{}))
+		assert.Empty(t, names(asBob))
+	})
+
+	t.Run("first admin adopts unowned gauges", func(t *testing.T) {
+		n, err := store.AdoptGauges(ctx, sql.NullInt64{Int64: alice.ID, Valid: true})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		got, err := scoped.GetGauge(asAlice, legacy.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sql.NullInt64{Int64: alice.ID, Valid: true}, got.OwnerID)
+	})
+}
+
+func TestStore_Audit(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	scoped := NewStore(store)
+	ctx := context.Background()
+	alice, err := store.CreateUser(ctx, db.CreateUserParams{Username: "alice", PasswordHash: "-"})
+	require.NoError(t, err)
+	asAlice := audit.WithRequest(auth.WithUser(ctx, alice), audit.Request{IP: "192.0.2.7", UserAgent: "curl"})
+
+	gauge, err := scoped.CreateGauge(asAlice, db.CreateGaugeParams{
+		Name: "Water", Target: 8, Unit: "glasses", Icon: "star",
+		Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1,
+	})
+	require.NoError(t, err)
+	_, err = scoped.EditGauge(asAlice, db.EditGaugeParams{UpdateGaugeParams: db.UpdateGaugeParams{
+		ID: gauge.ID, Name: "Water", Target: 10, Unit: "glasses", Icon: "star",
+		Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1,
+	}})
+	require.NoError(t, err)
+	_, err = scoped.RecordGaugeValue(asAlice, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2})
+	require.NoError(t, err)
+	_, err = scoped.SetGaugeValue(asAlice, db.SetGaugeValueParams{GaugeID: gauge.ID, Value: 5})
+	require.NoError(t, err)
+	// Setting the value it already has changes nothing, so is not recorded
+	_, err = scoped.SetGaugeValue(asAlice, db.SetGaugeValueParams{GaugeID: gauge.ID, Value: 5})
+	require.NoError(t, err)
+	require.NoError(t, scoped.DeleteGauge(asAlice, gauge.ID))
+
+	entries, err := store.ListAuditEntries(ctx, db.ListAuditEntriesParams{Limit: 10})
+	require.NoError(t, err)
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+		assert.Equal(t, "alice", entry.Username)
+		assert.Equal(t, "192.0.2.7", entry.Ip)
+		assert.Equal(t, "curl", entry.UserAgent)
+		assert.Equal(t, gauge.ID, entry.EntityID)
+		assert.Equal(t, "Water", entry.EntityName)
+	}
+	assert.Equal(t, []string{"delete", "value", "value", "update", "create"}, actions)
+	assert.Equal(t, []audit.Change{{Field: "target", Before: "8", After: "10"}}, audit.Changes(entries[3]))
+	assert.Subset(t, audit.Changes(entries[2]), []audit.Change{
+		{Field: "delta", After: "2"},
+		{Field: "value", Before: "0", After: "2"},
+	})
+	assert.Equal(t, []audit.Change{{Field: "value", Before: "2", After: "5"}}, audit.Changes(entries[1]))
+	assert.Contains(t, audit.Changes(entries[0]), audit.Change{Field: "value", Before: "5"})
+}
+
+func TestStore_AuditFails(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Open(db.DefaultConfig(filepath.Join(t.TempDir(), "health.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	_, err = db.Migrate(ctx, database)
+	require.NoError(t, err)
+	store := db.NewStore(database)
+	scoped := NewStore(store)
+	alice, err := store.CreateUser(ctx, db.CreateUserParams{Username: "alice", PasswordHash: "-"})
+	require.NoError(t, err)
+	asAlice := auth.WithUser(ctx, alice)
+	gauge, err := scoped.CreateGauge(asAlice, db.CreateGaugeParams{
+		Name: "Water", Target: 8, Unit: "glasses", Icon: "star",
+		Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1,
+	})
+	require.NoError(t, err)
+
+	// A change that cannot be recorded is not saved either, so retrying it
+	// does not count it twice
+	_, err = database.ExecContext(ctx, "DROP TABLE audit_log")
+	require.NoError(t, err)
+	_, err = scoped.RecordGaugeValue(asAlice, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2})
+	require.Error(t, err)
+	_, err = scoped.SetGaugeValue(asAlice, db.SetGaugeValueParams{GaugeID: gauge.ID, Value: 5})
+	require.Error(t, err)
+	require.Error(t, scoped.DeleteGauge(asAlice, gauge.ID))
+
+	current, err := store.GetGauge(ctx, gauge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, current.Value)
+	values, err := store.GetGaugeValues(ctx, gauge.ID)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range Roles {
+		got, err := ParseRole(role.String())
+		require.NoError(t, err)
+		assert.Equal(t, role, got)
+	}
+	_, err := ParseRole("none")
+	assert.Error(t, err)
+}
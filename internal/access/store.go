@@ -0,0 +1,380 @@
+package access
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"health-monitor/internal/audit"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+)
+
+// Store is the database as the signed in user of each call's context may
+// use it. Gauges they may not see are not found, and changes they may not
+// make fail with ErrForbidden. Without a signed in user there are no gauges.
+// Every change is recorded in the audit log along with who made it, in the
+// same transaction as the change.
+type Store struct {
+	store *db.Store
+	audit *audit.Log
+}
+
+// NewStore returns store limited to what each request's user may do
+func NewStore(store *db.Store) *Store {
+	return &Store{store: store, audit: audit.NewLog(store)}
+}
+
+// user returns the signed in user, or the zero user, who may do nothing
+func user(ctx context.Context) db.User {
+	u, _ := auth.UserFrom(ctx)
+	return u
+}
+
+// gauge loads a gauge the user has at least the given role on
+func (s *Store) gauge(ctx context.Context, id int64, need Role) (db.Gauge, error) {
+	return loadGauge(ctx, s.store, id, need)
+}
+
+// loadGauge loads a gauge the user has at least the given role on from
+// store, which may be a transaction
+func loadGauge(ctx context.Context, store *db.Store, id int64, need Role) (db.Gauge, error) {
+	gauge, err := store.GetGauge(ctx, id)
+	if err != nil {
+		return gauge, err
+	}
+	role, err := GaugeRole(ctx, store, gauge, user(ctx))
+	if err != nil {
+		return db.Gauge{}, err
+	}
+	if role == None {
+		return db.Gauge{}, sql.ErrNoRows
+	}
+	if role < need {
+		return db.Gauge{}, ErrForbidden
+	}
+	return gauge, nil
+}
+
+// record adds a change to a gauge to the audit log in tx, the change's
+// transaction, so a change that cannot be recorded is not saved either
+func (s *Store) record(ctx context.Context, tx *db.Store, action string, gauge db.Gauge, changes []audit.Change) error {
+	return s.audit.In(tx).Record(ctx, audit.Entry{
+		Action:     action,
+		Entity:     audit.EntityGauge,
+		EntityID:   gauge.ID,
+		EntityName: gauge.Name,
+		Changes:    changes,
+	})
+}
+
+// Role returns the signed in user's role on a gauge
+func (s *Store) Role(ctx context.Context, gauge db.Gauge) (Role, error) {
+	return GaugeRole(ctx, s.store, gauge, user(ctx))
+}
+
+// ListGauges returns the gauges the user owns and those shared with their
+// households
+func (s *Store) ListGauges(ctx context.Context) ([]db.Gauge, error) {
+	u := user(ctx)
+	if u.ID == 0 {
+		return []db.Gauge{}, nil
+	}
+	return s.store.ListUserGauges(ctx, db.ListUserGaugesParams{UserID: u.ID, IsAdmin: u.IsAdmin})
+}
+
+func (s *Store) GetGauge(ctx context.Context, id int64) (db.Gauge, error) {
+	return s.gauge(ctx, id, Viewer)
+}
+
+// CreateGauge creates a gauge owned by the user
+func (s *Store) CreateGauge(ctx context.Context, arg db.CreateGaugeParams) (db.Gauge, error) {
+	u := user(ctx)
+	if u.ID == 0 {
+		return db.Gauge{}, ErrForbidden
+	}
+	arg.OwnerID = sql.NullInt64{Int64: u.ID, Valid: true}
+	var gauge db.Gauge
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		var err error
+		if gauge, err = tx.CreateGauge(ctx, arg); err != nil {
+			return err
+		}
+		return s.record(ctx, tx, audit.ActionCreate, gauge, audit.GaugeCreated(gauge))
+	})
+	return gauge, err
+}
+
+func (s *Store) EditGauge(ctx context.Context, arg db.EditGaugeParams) (db.Gauge, error) {
+	var gauge db.Gauge
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		before, err := loadGauge(ctx, tx, arg.ID, Editor)
+		if err != nil {
+			return err
+		}
+		if gauge, err = tx.EditGauge(ctx, arg); err != nil {
+			return err
+		}
+		return s.changed(ctx, tx, audit.ActionUpdate, before, gauge)
+	})
+	return gauge, err
+}
+
+func (s *Store) DeleteGauge(ctx context.Context, id int64) error {
+	return s.store.InTx(ctx, func(tx *db.Store) error {
+		gauge, err := loadGauge(ctx, tx, id, Owner)
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteGauge(ctx, id); err != nil {
+			return err
+		}
+		return s.record(ctx, tx, audit.ActionDelete, gauge, audit.GaugeDeleted(gauge))
+	})
+}
+
+// changed records the fields that differ between a gauge before and after
+// a change, if any do
+func (s *Store) changed(ctx context.Context, tx *db.Store, action string, before, after db.Gauge) error {
+	changes := audit.GaugeChanges(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	return s.record(ctx, tx, action, after, changes)
+}
+
+// ShareGauge shares a gauge the user owns with one of their households, or
+// stops sharing it when household is not valid. Viewers may not share their
+// gauges with a household.
+func (s *Store) ShareGauge(ctx context.Context, id int64, household sql.NullInt64) error {
+	return s.store.InTx(ctx, func(tx *db.Store) error {
+		before, err := loadGauge(ctx, tx, id, Owner)
+		if err != nil {
+			return err
+		}
+		if household.Valid {
+			role, err := HouseholdRole(ctx, tx, household.Int64, user(ctx))
+			if err != nil {
+				return err
+			}
+			if role < Editor {
+				return ErrForbidden
+			}
+		}
+		err = tx.SetGaugeHousehold(ctx, db.SetGaugeHouseholdParams{HouseholdID: household, ID: id})
+		if err != nil {
+			return err
+		}
+		after := before
+		after.HouseholdID = household
+		return s.changed(ctx, tx, audit.ActionUpdate, before, after)
+	})
+}
+
+// RecordGaugeValue logs a change to a gauge's value. The audit log gets
+// the delta asked for and, for a change dated in an earlier period, which
+// leaves the value alone, its date.
+func (s *Store) RecordGaugeValue(ctx context.Context, arg db.RecordGaugeValueParams) (db.Gauge, error) {
+	var gauge db.Gauge
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		before, err := loadGauge(ctx, tx, arg.GaugeID, Editor)
+		if err != nil {
+			return err
+		}
+		if gauge, err = tx.RecordGaugeValue(ctx, arg); err != nil {
+			return err
+		}
+
+		changes := audit.GaugeChanges(before, gauge)
+		if len(changes) == 0 && arg.Date.IsZero() {
+			return nil
+		}
+		logged := []audit.Change{{Field: "delta", After: strconv.FormatFloat(arg.Delta, 'f', -1, 64)}}
+		if !arg.Date.IsZero() {
+			logged = append(logged, audit.Change{Field: "date", After: arg.Date.UTC().Format(time.RFC3339)})
+		}
+		return s.record(ctx, tx, audit.ActionValue, gauge, append(logged, changes...))
+	})
+	return gauge, err
+}
+
+func (s *Store) SetGaugeValue(ctx context.Context, arg db.SetGaugeValueParams) (db.Gauge, error) {
+	var gauge db.Gauge
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		before, err := loadGauge(ctx, tx, arg.GaugeID, Editor)
+		if err != nil {
+			return err
+		}
+		if gauge, err = tx.SetGaugeValue(ctx, arg); err != nil {
+			return err
+		}
+		return s.changed(ctx, tx, audit.ActionValue, before, gauge)
+	})
+	return gauge, err
+}
+
+func (s *Store) ListGaugeValues(ctx context.Context, arg db.ListGaugeValuesParams) ([]db.GaugeValue, error) {
+	if _, err := s.gauge(ctx, arg.GaugeID, Viewer); err != nil {
+		return nil, err
+	}
+	return s.store.ListGaugeValues(ctx, arg)
+}
+
+func (s *Store) CountGaugeValues(ctx context.Context, arg db.CountGaugeValuesParams) (int64, error) {
+	if _, err := s.gauge(ctx, arg.GaugeID, Viewer); err != nil {
+		return 0, err
+	}
+	return s.store.CountGaugeValues(ctx, arg)
+}
+
+func (s *Store) ListGaugeValuesInRange(ctx context.Context, arg db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error) {
+	if _, err := s.gauge(ctx, arg.GaugeID, Viewer); err != nil {
+		return nil, err
+	}
+	return s.store.ListGaugeValuesInRange(ctx, arg)
+}
+
+func (s *Store) GetGaugeHistory(ctx context.Context, gaugeID int64) ([]db.GetGaugeHistoryRow, error) {
+	if _, err := s.gauge(ctx, gaugeID, Viewer); err != nil {
+		return nil, err
+	}
+	return s.store.GetGaugeHistory(ctx, gaugeID)
+}
+
+// ImportGauges creates imported gauges owned by the user. Only their own
+// gauges count as duplicates.
+func (s *Store) ImportGauges(ctx context.Context, gauges []db.CreateGaugeParams, dryRun bool) (db.ImportResult, error) {
+	u := user(ctx)
+	if u.ID == 0 {
+		return db.ImportResult{}, ErrForbidden
+	}
+	owned := make([]db.CreateGaugeParams, len(gauges))
+	for i, gauge := range gauges {
+		gauge.OwnerID = sql.NullInt64{Int64: u.ID, Valid: true}
+		owned[i] = gauge
+	}
+	if dryRun {
+		return s.store.ImportGauges(ctx, owned, dryRun)
+	}
+
+	// The import does not say which gauges it created, so they are told
+	// apart from the ones the user already had
+	var result db.ImportResult
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		list := db.ListUserGaugesParams{UserID: u.ID, IsAdmin: u.IsAdmin}
+		existing, err := tx.ListUserGauges(ctx, list)
+		if err != nil {
+			return err
+		}
+		had := make(map[int64]bool, len(existing))
+		for _, gauge := range existing {
+			had[gauge.ID] = true
+		}
+		result, err = tx.ImportGauges(ctx, owned, dryRun)
+		if err != nil || result.Imported == 0 {
+			return err
+		}
+		all, err := tx.ListUserGauges(ctx, list)
+		if err != nil {
+			return err
+		}
+		for _, gauge := range all {
+			if had[gauge.ID] {
+				continue
+			}
+			if err := s.record(ctx, tx, audit.ActionCreate, gauge, audit.GaugeCreated(gauge)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// ImportGaugeValues imports values into gauges the user may edit. Each
+// gauge gets one audit entry, with the number of values imported into it.
+func (s *Store) ImportGaugeValues(ctx context.Context, values []db.ImportedValue, dryRun bool) (db.ImportResult, error) {
+	var result db.ImportResult
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		before := make(map[int64]db.Gauge)
+		for _, value := range values {
+			if _, ok := before[value.GaugeID]; ok {
+				continue
+			}
+			gauge, err := loadGauge(ctx, tx, value.GaugeID, Editor)
+			if err != nil {
+				return err
+			}
+			before[value.GaugeID] = gauge
+		}
+		var err error
+		result, err = tx.ImportGaugeValues(ctx, values, dryRun)
+		if err != nil || dryRun {
+			return err
+		}
+
+		skipped := make(map[int]bool, len(result.Duplicates))
+		for _, i := range result.Duplicates {
+			skipped[i] = true
+		}
+		imported := make(map[int64]int)
+		var order []int64
+		for i, value := range values {
+			if skipped[i] {
+				continue
+			}
+			if imported[value.GaugeID] == 0 {
+				order = append(order, value.GaugeID)
+			}
+			imported[value.GaugeID]++
+		}
+		for _, id := range order {
+			after, err := tx.GetGauge(ctx, id)
+			if err != nil {
+				return err
+			}
+			changes := append([]audit.Change{{Field: "imported", After: fmt.Sprintf("%d values", imported[id])}}, audit.GaugeChanges(before[id], after)...)
+			if err := s.record(ctx, tx, audit.ActionValue, after, changes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Backup backs up every gauge, so only an admin may take one
+func (s *Store) Backup(ctx context.Context) (*db.Backup, error) {
+	if !user(ctx).IsAdmin {
+		return nil, ErrForbidden
+	}
+	return s.store.Backup(ctx)
+}
+
+// Restore may replace every gauge, so only an admin may restore
+func (s *Store) Restore(ctx context.Context, backup *db.Backup, mode db.RestoreMode) (db.RestoreResult, error) {
+	if !user(ctx).IsAdmin {
+		return db.RestoreResult{}, ErrForbidden
+	}
+	var result db.RestoreResult
+	err := s.store.InTx(ctx, func(tx *db.Store) error {
+		var err error
+		if result, err = tx.Restore(ctx, backup, mode); err != nil {
+			return err
+		}
+		return s.audit.In(tx).Record(ctx, audit.Entry{
+			Action:     audit.ActionRestore,
+			Entity:     audit.EntityBackup,
+			EntityName: string(mode),
+			Changes: []audit.Change{
+				{Field: "gauges", After: strconv.Itoa(result.Gauges)},
+				{Field: "values", After: strconv.Itoa(result.Values)},
+				{Field: "periods", After: strconv.Itoa(result.Periods)},
+				{Field: "skipped", After: strconv.Itoa(result.Skipped)},
+			},
+		})
+	})
+	return result, err
+}
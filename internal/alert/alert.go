@@ -0,0 +1,173 @@
+// Package alert watches gauges against the alert rules set on the admin
+// page and sends a notification when one is met.
+//
+// A rule fires at most once per occasion: once a day for a gauge behind
+// its target, once a period for a gauge past it, and once for each gap in
+// its entries. Each firing is recorded in alert_history, whose unique key
+// on the rule and occasion is what stops an alert repeating, even across
+// restarts. Alerts that fall in a channel's quiet hours are not recorded,
+// so they go out once the quiet hours end if they still hold.
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/period"
+)
+
+// Rule kinds
+const (
+	// KindBelowPercent fires when a gauge is below a percent of its target
+	// at a time of day, such as below 50% by 18:00
+	KindBelowPercent = "below_percent"
+	// KindOverTarget fires when a gauge goes past its goal in a way more
+	// entries cannot undo: over an at most target, above a range or beyond
+	// an exact target. Being over an at least target meets it, so it never
+	// fires for those.
+	KindOverTarget = "over_target"
+	// KindNoEntry fires when nothing has been entered on a gauge for a
+	// number of days
+	KindNoEntry = "no_entry"
+)
+
+// Kind describes a rule kind for the admin page
+type Kind struct {
+	Name        string
+	Description string
+}
+
+// Kinds are the rule kinds, in the order the admin page lists them
+var Kinds = []Kind{
+	{KindBelowPercent, "Below a percent of the target by a time of day"},
+	{KindOverTarget, "Over the target (at most, range or exact goals)"},
+	{KindNoEntry, "No entry for a number of days"},
+}
+
+// IsKind reports whether s names a rule kind
+func IsKind(s string) bool {
+	for _, k := range Kinds {
+		if k.Name == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Alert is a rule that has been met
+type Alert struct {
+	// Occasion says which day, period or gap the alert is about. A rule
+	// fires once per occasion.
+	Occasion     string
+	Notification notify.Notification
+}
+
+// Evaluate checks a rule against a gauge at now. lastEntry is when the
+// gauge last had a value entered, or when it was created if it never has;
+// only no entry rules use it.
+func Evaluate(rule db.AlertRule, gauge db.Gauge, lastEntry, now time.Time) (Alert, bool) {
+	loc := gaugeLocation(&gauge)
+	local := now.In(loc)
+	n := notify.Notification{Tags: []string{rule.Kind}, Time: now}
+
+	switch rule.Kind {
+	case KindBelowPercent:
+		checkAt, err := ParseClock(rule.CheckAt)
+		if err != nil || minuteOfDay(local) < checkAt {
+			return Alert{}, false
+		}
+		eval := models.EvaluateGoal(&gauge, gauge.Value)
+		if eval.Percent >= rule.Threshold {
+			return Alert{}, false
+		}
+		n.Title = gauge.Name + " is behind"
+		n.Message = fmt.Sprintf("%s is at %s %s, %.0f%% of its target of %s, at %s. The alert is for below %s%% by %s.",
+			gauge.Name, models.FormatValue(&gauge, gauge.Value), gauge.Unit, eval.Percent,
+			models.FormatTarget(&gauge), local.Format("15:04"), formatNumber(rule.Threshold), rule.CheckAt)
+		return Alert{Occasion: "day:" + local.Format(time.DateOnly), Notification: n}, true
+
+	case KindOverTarget:
+		if models.EvaluateGoal(&gauge, gauge.Value).Status != models.StatusAbove {
+			return Alert{}, false
+		}
+		occasion := "period"
+		if gauge.PeriodStart.Valid {
+			occasion = "period:" + gauge.PeriodStart.Time.UTC().Format(time.RFC3339)
+		}
+		n.Title = gauge.Name + " is over its target"
+		n.Message = fmt.Sprintf("%s is at %s %s, over its target of %s %s.",
+			gauge.Name, models.FormatValue(&gauge, gauge.Value), gauge.Unit,
+			models.GoalLabel(models.GaugeGoalType(&gauge)), models.FormatTarget(&gauge))
+		return Alert{Occasion: occasion, Notification: n}, true
+
+	case KindNoEntry:
+		if rule.Threshold <= 0 || lastEntry.IsZero() {
+			return Alert{}, false
+		}
+		gap := now.Sub(lastEntry)
+		if gap < time.Duration(rule.Threshold*float64(24*time.Hour)) {
+			return Alert{}, false
+		}
+		days := int(gap / (24 * time.Hour))
+		n.Title = "Nothing entered for " + gauge.Name
+		n.Message = fmt.Sprintf("Nothing has been entered for %s in %s, since %s.",
+			gauge.Name, plural(days, "day"), lastEntry.In(loc).Format("Mon 2 Jan 15:04"))
+		return Alert{Occasion: "since:" + lastEntry.UTC().Format(time.RFC3339), Notification: n}, true
+	}
+	return Alert{}, false
+}
+
+// ParseClock parses a time of day written as HH:MM into minutes after
+// midnight
+func ParseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if !ok || herr != nil || merr != nil || len(mm) != 2 || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%q is not a time of day such as 18:00", s)
+	}
+	return h*60 + m, nil
+}
+
+// Quiet reports whether t falls in the quiet hours from start to end,
+// which may run past midnight. A blank or empty range is never quiet.
+func Quiet(start, end string, t time.Time) bool {
+	from, err := ParseClock(start)
+	if err != nil {
+		return false
+	}
+	to, err := ParseClock(end)
+	if err != nil || from == to {
+		return false
+	}
+	m := minuteOfDay(t)
+	if from < to {
+		return m >= from && m < to
+	}
+	return m >= from || m < to
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// gaugeLocation is the gauge's time zone, in which times of day are read
+func gaugeLocation(gauge *db.Gauge) *time.Location {
+	return period.New(gauge.Period, gauge.PeriodDays, gauge.WeekStart, gauge.Timezone).Location
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
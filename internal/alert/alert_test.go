@@ -0,0 +1,250 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	start := time.Date(2025, time.March, 10, 4, 0, 0, 0, time.UTC)
+	gauge := db.Gauge{
+		Name:        "Water",
+		Target:      2000,
+		Value:       500,
+		Unit:        "ml",
+		Timezone:    "America/New_York",
+		GoalType:    "at_least",
+		PeriodStart: sql.NullTime{Time: start, Valid: true},
+	}
+	// 18:30 in New York
+	evening := time.Date(2025, time.March, 14, 22, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		rule      db.AlertRule
+		goal      string
+		value     float64
+		lastEntry time.Time
+		now       time.Time
+		occasion  string
+		fires     bool
+	}{
+		{
+			name:     "below percent after the check time",
+			rule:     db.AlertRule{Kind: KindBelowPercent, Threshold: 50, CheckAt: "18:00"},
+			value:    500,
+			now:      evening,
+			occasion: "day:2025-03-14",
+			fires:    true,
+		},
+		{
+			name:  "below percent before the check time in the gauge's zone",
+			rule:  db.AlertRule{Kind: KindBelowPercent, Threshold: 50, CheckAt: "18:00"},
+			value: 500,
+			now:   evening.Add(-time.Hour),
+		},
+		{
+			name:  "at the percent",
+			rule:  db.AlertRule{Kind: KindBelowPercent, Threshold: 50, CheckAt: "18:00"},
+			value: 1000,
+			now:   evening,
+		},
+		{
+			name:     "over an at most target",
+			rule:     db.AlertRule{Kind: KindOverTarget},
+			goal:     "at_most",
+			value:    2500,
+			now:      evening,
+			occasion: "period:2025-03-10T04:00:00Z",
+			fires:    true,
+		},
+		{
+			name:  "at an at most target",
+			rule:  db.AlertRule{Kind: KindOverTarget},
+			goal:  "at_most",
+			value: 2000,
+			now:   evening,
+		},
+		{
+			name:  "over an at least target meets it",
+			rule:  db.AlertRule{Kind: KindOverTarget},
+			value: 2500,
+			now:   evening,
+		},
+		{
+			name:     "above a range",
+			rule:     db.AlertRule{Kind: KindOverTarget},
+			goal:     "range",
+			value:    2500,
+			now:      evening,
+			occasion: "period:2025-03-10T04:00:00Z",
+			fires:    true,
+		},
+		{
+			name:  "below a range is not over it",
+			rule:  db.AlertRule{Kind: KindOverTarget},
+			goal:  "range",
+			value: 500,
+			now:   evening,
+		},
+		{
+			name:     "beyond an exact target",
+			rule:     db.AlertRule{Kind: KindOverTarget},
+			goal:     "exact",
+			value:    2001,
+			now:      evening,
+			occasion: "period:2025-03-10T04:00:00Z",
+			fires:    true,
+		},
+		{
+			name:      "no entry for longer than the days",
+			rule:      db.AlertRule{Kind: KindNoEntry, Threshold: 2},
+			lastEntry: evening.Add(-50 * time.Hour),
+			now:       evening,
+			occasion:  "since:2025-03-12T20:30:00Z",
+			fires:     true,
+		},
+		{
+			name:      "an entry within the days",
+			rule:      db.AlertRule{Kind: KindNoEntry, Threshold: 2},
+			lastEntry: evening.Add(-47 * time.Hour),
+			now:       evening,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := gauge
+			g.Value = tt.value
+			if tt.goal != "" {
+				g.GoalType = tt.goal
+				g.TargetMin = 1000
+			}
+			a, fires := Evaluate(tt.rule, g, tt.lastEntry, tt.now)
+
+			assert.Equal(t, tt.fires, fires)
+			assert.Equal(t, tt.occasion, a.Occasion)
+			if fires {
+				assert.Contains(t, a.Notification.Message, "Water")
+				assert.Equal(t, []string{tt.rule.Kind}, a.Notification.Tags)
+			}
+		})
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, time.March, 14, h, m, 0, 0, time.UTC) }
+
+	assert.True(t, Quiet("22:00", "07:00", at(23, 15)))
+	assert.True(t, Quiet("22:00", "07:00", at(6, 59)))
+	assert.False(t, Quiet("22:00", "07:00", at(7, 0)))
+	assert.True(t, Quiet("12:00", "13:30", at(12, 0)))
+	assert.False(t, Quiet("12:00", "13:30", at(18, 0)))
+	assert.False(t, Quiet("", "", at(3, 0)))
+	assert.False(t, Quiet("09:00", "09:00", at(9, 0)))
+}
+
+func TestParseClock(t *testing.T) {
+	m, err := ParseClock("18:05")
+	require.NoError(t, err)
+	assert.Equal(t, 18*60+5, m)
+
+	for _, s := range []string{"", "18", "24:00", "6:5", "noon"} {
+		_, err := ParseClock(s)
+		assert.Error(t, err, s)
+	}
+}
+
+type fakeNotifier struct {
+	sent []notify.Notification
+	err  error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	f.sent = append(f.sent, n)
+	return f.err
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	gauge := testutil.CreateTestGauge(t, store)
+	_, err := store.SetGaugeValue(ctx, db.SetGaugeValueParams{GaugeID: gauge.ID, Value: 150, Source: db.SourceWeb})
+	require.NoError(t, err)
+
+	channel, err := store.CreateNotificationChannel(ctx, db.CreateNotificationChannelParams{
+		Name: "Phone", Kind: notify.KindNtfy, Target: "https://ntfy.example.com/health", Active: true,
+	})
+	require.NoError(t, err)
+	rule, err := store.CreateAlertRule(ctx, db.CreateAlertRuleParams{
+		GaugeID: gauge.ID, ChannelID: channel.ID, Kind: KindOverTarget, Active: true,
+	})
+	require.NoError(t, err)
+
+	notifier := &fakeNotifier{}
+	now := time.Now()
+	s := NewScheduler(store, notify.SMTPConfig{})
+	s.now = func() time.Time { return now }
+	s.notifier = func(db.NotificationChannel) (notify.Notifier, error) { return notifier, nil }
+
+	t.Run("sends an alert once", func(t *testing.T) {
+		require.NoError(t, s.Check(ctx))
+		require.NoError(t, s.Check(ctx))
+
+		require.Len(t, notifier.sent, 1)
+		assert.Equal(t, "Test Gauge is over its target", notifier.sent[0].Title)
+		history, err := store.ListAlertHistory(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, rule.ID, history[0].RuleID)
+		assert.Empty(t, history[0].Error)
+	})
+
+	t.Run("holds alerts back in quiet hours", func(t *testing.T) {
+		quiet, err := store.CreateNotificationChannel(ctx, db.CreateNotificationChannelParams{
+			Name:       "Night",
+			Kind:       notify.KindNtfy,
+			Target:     "https://ntfy.example.com/night",
+			QuietStart: now.UTC().Add(-time.Hour).Format("15:04"),
+			QuietEnd:   now.UTC().Add(time.Hour).Format("15:04"),
+			Active:     true,
+		})
+		require.NoError(t, err)
+		_, err = store.CreateAlertRule(ctx, db.CreateAlertRuleParams{
+			GaugeID: gauge.ID, ChannelID: quiet.ID, Kind: KindOverTarget, Active: true,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, s.Check(ctx))
+		assert.Len(t, notifier.sent, 1)
+
+		now = now.Add(2 * time.Hour)
+		require.NoError(t, s.Check(ctx))
+		assert.Len(t, notifier.sent, 2)
+	})
+
+	t.Run("records a failed notification", func(t *testing.T) {
+		notifier.err = errors.New("connection refused")
+		_, err := store.CreateAlertRule(ctx, db.CreateAlertRuleParams{
+			GaugeID: gauge.ID, ChannelID: channel.ID, Kind: KindNoEntry, Threshold: 1, Active: true,
+		})
+		require.NoError(t, err)
+		now = now.Add(25 * time.Hour)
+
+		err = s.Check(ctx)
+		assert.ErrorContains(t, err, "connection refused")
+		history, err := store.ListAlertHistory(ctx, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, history)
+		assert.Equal(t, "connection refused", history[0].Error)
+	})
+}
@@ -0,0 +1,172 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/notify"
+)
+
+// checkInterval is how often the rules are checked, which is as fine as a
+// time of day in a rule goes
+const checkInterval = time.Minute
+
+// Store holds the rules, the gauges they watch and the alerts sent
+type Store interface {
+	RolloverGauges(ctx context.Context, now time.Time) error
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	ListAlertRules(ctx context.Context) ([]db.AlertRule, error)
+	ListNotificationChannels(ctx context.Context) ([]db.NotificationChannel, error)
+	GetLastGaugeValueDate(ctx context.Context, gaugeID int64) (time.Time, error)
+	CreateAlertHistory(ctx context.Context, arg db.CreateAlertHistoryParams) (int64, error)
+	SetAlertHistoryError(ctx context.Context, arg db.SetAlertHistoryErrorParams) error
+}
+
+// NotifierFunc returns the notifier for a channel
+type NotifierFunc func(channel db.NotificationChannel) (notify.Notifier, error)
+
+// Scheduler checks the alert rules every minute and sends the alerts due
+type Scheduler struct {
+	store    Store
+	notifier NotifierFunc
+	now      func() time.Time
+}
+
+// NewScheduler returns a scheduler sending email through smtp
+func NewScheduler(store Store, smtp notify.SMTPConfig) *Scheduler {
+	return &Scheduler{
+		store: store,
+		notifier: func(channel db.NotificationChannel) (notify.Notifier, error) {
+			return notify.ForChannel(channel.Kind, channel.Target, smtp)
+		},
+		now: time.Now,
+	}
+}
+
+// Run checks the rules every minute until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.Check(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Test sends a notification through a channel straight away, so it can be
+// checked from the admin page
+func (s *Scheduler) Test(ctx context.Context, channel db.NotificationChannel) error {
+	n, err := s.notifier(channel)
+	if err != nil {
+		return err
+	}
+	return n.Notify(ctx, notify.Notification{
+		Title:   "Test notification",
+		Message: fmt.Sprintf("This is a test of the %q notification channel. Alerts sent here will look like this.", channel.Name),
+		Tags:    []string{"test"},
+		Time:    s.now(),
+	})
+}
+
+// Check evaluates every active rule and sends the alerts that are due and
+// have not been sent before. A channel that cannot be reached is recorded
+// against the alert rather than retried, so one bad channel does not hold
+// up the rest.
+func (s *Scheduler) Check(ctx context.Context) error {
+	now := s.now()
+	// Values are reset when a period ends, so close any that have ended
+	// before judging them
+	if err := s.store.RolloverGauges(ctx, now); err != nil {
+		return err
+	}
+
+	rules, err := s.store.ListAlertRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	gauges, err := s.store.ListGauges(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list gauges: %w", err)
+	}
+	channels, err := s.store.ListNotificationChannels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list notification channels: %w", err)
+	}
+	gaugesByID := make(map[int64]db.Gauge, len(gauges))
+	for _, g := range gauges {
+		gaugesByID[g.ID] = g
+	}
+	channelsByID := make(map[int64]db.NotificationChannel, len(channels))
+	for _, c := range channels {
+		channelsByID[c.ID] = c
+	}
+
+	var errs []error
+	for _, rule := range rules {
+		gauge, ok := gaugesByID[rule.GaugeID]
+		channel, found := channelsByID[rule.ChannelID]
+		if !rule.Active || !ok || !found || !channel.Active {
+			continue
+		}
+		if err := s.check(ctx, rule, gauge, channel, now); err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %d: %w", rule.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) check(ctx context.Context, rule db.AlertRule, gauge db.Gauge, channel db.NotificationChannel, now time.Time) error {
+	var lastEntry time.Time
+	if rule.Kind == KindNoEntry {
+		var err error
+		lastEntry, err = s.store.GetLastGaugeValueDate(ctx, gauge.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			lastEntry = gauge.CreatedAt.Time
+		} else if err != nil {
+			return err
+		}
+	}
+
+	a, ok := Evaluate(rule, gauge, lastEntry, now)
+	if !ok || Quiet(channel.QuietStart, channel.QuietEnd, now.In(gaugeLocation(&gauge))) {
+		return nil
+	}
+
+	// Record the alert before sending it, so it goes out at most once
+	sent, err := s.store.CreateAlertHistory(ctx, db.CreateAlertHistoryParams{
+		RuleID:   rule.ID,
+		Occasion: a.Occasion,
+		Message:  a.Notification.Message,
+		FiredAt:  now,
+	})
+	if err != nil || sent == 0 {
+		return err
+	}
+
+	n, err := s.notifier(channel)
+	if err == nil {
+		err = n.Notify(ctx, a.Notification)
+	}
+	if err != nil {
+		s.store.SetAlertHistoryError(ctx, db.SetAlertHistoryErrorParams{
+			Error:    err.Error(),
+			RuleID:   rule.ID,
+			Occasion: a.Occasion,
+		})
+		return fmt.Errorf("failed to notify %q: %w", channel.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package audit records who changed what, and when, in an append-only log
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+)
+
+// Actions recorded in the log
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionValue   = "value"
+	ActionRestore = "restore"
+)
+
+// Actions lists the actions in the order the audit page offers them
+var Actions = []string{ActionCreate, ActionUpdate, ActionDelete, ActionValue, ActionRestore}
+
+// Entities the log records changes to
+const (
+	EntityGauge  = "gauge"
+	EntityBackup = "backup"
+)
+
+// Change is one field changed by an entry, written as the audit page shows
+// it. A field that was set is empty before, and one that was cleared is
+// empty after.
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Entry is a change to record. The actor and request are taken from the
+// context it is recorded with.
+type Entry struct {
+	Action     string
+	Entity     string
+	EntityID   int64
+	EntityName string
+	Changes    []Change
+}
+
+// Store keeps the log
+type Store interface {
+	CreateAuditEntry(ctx context.Context, arg db.CreateAuditEntryParams) (db.AuditLog, error)
+}
+
+// Log records entries in the audit log
+type Log struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewLog returns a log kept in store
+func NewLog(store Store) *Log {
+	return &Log{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// In returns the log kept in store instead, such as a transaction, so its
+// entries are saved along with the changes they record
+func (l *Log) In(store Store) *Log {
+	return &Log{store: store, now: l.now}
+}
+
+// Record adds an entry made by the signed in user of ctx
+func (l *Log) Record(ctx context.Context, entry Entry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	if entry.Changes == nil {
+		changes = []byte("[]")
+	}
+
+	arg := db.CreateAuditEntryParams{
+		CreatedAt:  l.now().UTC(),
+		Action:     entry.Action,
+		Entity:     entry.Entity,
+		EntityID:   entry.EntityID,
+		EntityName: entry.EntityName,
+		Changes:    string(changes),
+	}
+	if user, ok := auth.UserFrom(ctx); ok {
+		arg.UserID = sql.NullInt64{Int64: user.ID, Valid: true}
+		arg.Username = user.Username
+	}
+	req := RequestFrom(ctx)
+	arg.Ip = req.IP
+	arg.UserAgent = req.UserAgent
+
+	if _, err := l.store.CreateAuditEntry(ctx, arg); err != nil {
+		return fmt.Errorf("failed to record %s of %s %d in the audit log: %w", entry.Action, entry.Entity, entry.EntityID, err)
+	}
+	return nil
+}
+
+// Changes reads the fields an entry changed
+func Changes(entry db.AuditLog) []Change {
+	var changes []Change
+	json.Unmarshal([]byte(entry.Changes), &changes)
+	return changes
+}
+
+// Exported is an entry as the JSON export writes it
+type Exported struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	UserID     *int64    `json:"user_id"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Action     string    `json:"action"`
+	Entity     string    `json:"entity"`
+	EntityID   int64     `json:"entity_id"`
+	EntityName string    `json:"entity_name"`
+	Changes    []Change  `json:"changes"`
+}
+
+// Export returns an entry as the JSON export writes it
+func Export(entry db.AuditLog) Exported {
+	exported := Exported{
+		ID:         entry.ID,
+		Time:       entry.CreatedAt.UTC(),
+		Username:   entry.Username,
+		IP:         entry.Ip,
+		UserAgent:  entry.UserAgent,
+		Action:     entry.Action,
+		Entity:     entry.Entity,
+		EntityID:   entry.EntityID,
+		EntityName: entry.EntityName,
+		Changes:    Changes(entry),
+	}
+	if entry.UserID.Valid {
+		exported.UserID = &entry.UserID.Int64
+	}
+	if exported.Changes == nil {
+		exported.Changes = []Change{}
+	}
+	return exported
+}
+
+// GaugeChanges lists the fields that differ between two versions of a
+// gauge. Bookkeeping such as the version is left out.
+func GaugeChanges(before, after db.Gauge) []Change {
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"name", before.Name, after.Name},
+		{"description", nullString(before.Description), nullString(after.Description)},
+		{"value", number(before.Value), number(after.Value)},
+		{"goal_type", before.GoalType, after.GoalType},
+		{"target", number(before.Target), number(after.Target)},
+		{"target_min", number(before.TargetMin), number(after.TargetMin)},
+		{"unit", before.Unit, after.Unit},
+		{"icon", before.Icon, after.Icon},
+		{"period", before.Period, after.Period},
+		{"period_days", integer(before.PeriodDays), integer(after.PeriodDays)},
+		{"week_start", integer(before.WeekStart), integer(after.WeekStart)},
+		{"timezone", before.Timezone, after.Timezone},
+		{"period_start", nullTime(before.PeriodStart), nullTime(after.PeriodStart)},
+		{"step", number(before.Step), number(after.Step)},
+		{"presets", before.Presets, after.Presets},
+		{"min_value", nullFloat(before.MinValue), nullFloat(after.MinValue)},
+		{"max_value", nullFloat(before.MaxValue), nullFloat(after.MaxValue)},
+		{"decimals", integer(before.Decimals), integer(after.Decimals)},
+		{"owner_id", nullInt(before.OwnerID), nullInt(after.OwnerID)},
+		{"household_id", nullInt(before.HouseholdID), nullInt(after.HouseholdID)},
+	}
+
+	var changes []Change
+	for _, f := range fields {
+		if f.before != f.after {
+			changes = append(changes, Change{Field: f.name, Before: f.before, After: f.after})
+		}
+	}
+	return changes
+}
+
+// GaugeCreated lists the fields a new gauge was created with
+func GaugeCreated(gauge db.Gauge) []Change {
+	changes := GaugeChanges(db.Gauge{}, gauge)
+	for i := range changes {
+		changes[i].Before = ""
+	}
+	return changes
+}
+
+// GaugeDeleted lists the fields a gauge had when it was deleted
+func GaugeDeleted(gauge db.Gauge) []Change {
+	changes := GaugeChanges(db.Gauge{}, gauge)
+	for i := range changes {
+		changes[i].Before, changes[i].After = changes[i].After, ""
+	}
+	return changes
+}
+
+// number writes a float the way it was entered
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func integer(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func nullString(s sql.NullString) string {
+	return s.String
+}
+
+func nullFloat(f sql.NullFloat64) string {
+	if !f.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(f.Float64, 'f', -1, 64)
+}
+
+func nullInt(i sql.NullInt64) string {
+	if !i.Valid {
+		return ""
+	}
+	return strconv.FormatInt(i.Int64, 10)
+}
+
+func nullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
+
+// Request is where a change came from
+type Request struct {
+	IP        string
+	UserAgent string
+}
+
+type requestKey struct{}
+
+// WithRequest returns a context whose changes are recorded as coming from
+// req
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFrom returns where the changes made with ctx come from, or the
+// zero Request outside of a request
+func RequestFrom(ctx context.Context) Request {
+	req, _ := ctx.Value(requestKey{}).(Request)
+	return req
+}
+
+// Load is middleware noting the address and user agent of each request, so
+// the changes it makes can be recorded with them
+func Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ctx := WithRequest(r.Context(), Request{IP: ip, UserAgent: r.UserAgent()})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
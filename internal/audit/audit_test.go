@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	alice, err := store.CreateUser(ctx, db.CreateUserParams{Username: "alice", PasswordHash: "-"})
+	require.NoError(t, err)
+
+	log := NewLog(store)
+	now := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	log.now = func() time.Time { return now }
+
+	var asAlice context.Context
+	req := httptest.NewRequest("POST", "/admin/gauges", nil)
+	req.Header.Set("User-Agent", "Firefox")
+	Load(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asAlice = auth.WithUser(r.Context(), alice)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	changes := []Change{{Field: "target", Before: "8", After: "10"}}
+	require.NoError(t, log.Record(asAlice, Entry{Action: ActionUpdate, Entity: EntityGauge, EntityID: 4, EntityName: "Water", Changes: changes}))
+	require.NoError(t, log.Record(ctx, Entry{Action: ActionDelete, Entity: EntityGauge, EntityID: 4, EntityName: "Water"}))
+
+	entries, err := store.ListAuditEntries(ctx, db.ListAuditEntriesParams{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	got := entries[1]
+	assert.True(t, got.CreatedAt.Equal(now))
+	assert.Equal(t, sql.NullInt64{Int64: alice.ID, Valid: true}, got.UserID)
+	assert.Equal(t, "alice", got.Username)
+	assert.Equal(t, "192.0.2.1", got.Ip)
+	assert.Equal(t, "Firefox", got.UserAgent)
+	assert.Equal(t, changes, Changes(got))
+
+	// Changes made outside a request have no actor
+	assert.False(t, entries[0].UserID.Valid)
+	exported := Export(entries[0])
+	assert.Nil(t, exported.UserID)
+	assert.Equal(t, []Change{}, exported.Changes)
+}
+
+func TestGaugeChanges(t *testing.T) {
+	before := db.Gauge{
+		ID: 4, Name: "Water", Target: 8, Value: 3, Unit: "glasses", Version: 2,
+		MaxValue: sql.NullFloat64{Float64: 20, Valid: true},
+	}
+	after := before
+	after.Target = 10
+	after.Value = 0
+	after.MaxValue = sql.NullFloat64{}
+	after.HouseholdID = sql.NullInt64{Int64: 1, Valid: true}
+	after.Version = 3
+
+	assert.Equal(t, []Change{
+		{Field: "value", Before: "3", After: "0"},
+		{Field: "target", Before: "8", After: "10"},
+		{Field: "max_value", Before: "20"},
+		{Field: "household_id", After: "1"},
+	}, GaugeChanges(before, after))
+	assert.Empty(t, GaugeChanges(before, before))
+
+	created := GaugeCreated(before)
+	assert.Contains(t, created, Change{Field: "name", After: "Water"})
+	assert.Contains(t, created, Change{Field: "value", After: "3"})
+	deleted := GaugeDeleted(before)
+	assert.Contains(t, deleted, Change{Field: "target", Before: "8"})
+}
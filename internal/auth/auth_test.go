@@ -0,0 +1,433 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+
+	user, err := CreateUser(ctx, store, " alice ", "correct horse", true)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.True(t, user.IsAdmin)
+	assert.NotContains(t, user.PasswordHash, "correct horse")
+	assert.True(t, CheckPassword(user.PasswordHash, "correct horse"))
+	assert.False(t, CheckPassword(user.PasswordHash, "wrong horse"))
+
+	_, err = CreateUser(ctx, store, "Alice", "another password", false)
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = CreateUser(ctx, store, "bob", "short", false)
+	assert.ErrorContains(t, err, "at least 8 characters")
+	_, err = CreateUser(ctx, store, "bob smith", "long enough", false)
+	assert.ErrorContains(t, err, "username must be")
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	_, err := CreateUser(ctx, store, "alice", "correct horse", false)
+	require.NoError(t, err)
+
+	m := NewManager(store, Config{TTL: time.Hour})
+	now := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	_, err = m.Authenticate(ctx, "alice", "wrong horse")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = m.Authenticate(ctx, "nobody", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	user, err := m.Authenticate(ctx, "ALICE", "correct horse")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, m.Login(w, httptest.NewRequest("POST", "/login", nil), user))
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, CookieName, cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	// The token is stored only as its hash
+	_, err = store.GetSession(ctx, cookie.Value)
+	assert.Error(t, err)
+	session, err := store.GetSession(ctx, hashToken(cookie.Value))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, session.UserID)
+	assert.Equal(t, "192.0.2.1", session.Ip)
+
+	load := func() (db.User, bool, *httptest.ResponseRecorder) {
+		var got db.User
+		var ok bool
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		m.Load(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok = UserFrom(r.Context())
+		})).ServeHTTP(w, req)
+		return got, ok, w
+	}
+
+	got, ok, _ := load()
+	require.True(t, ok)
+	assert.Equal(t, "alice", got.Username)
+
+	// Using the session keeps it alive past its first expiry
+	now = now.Add(50 * time.Minute)
+	_, ok, _ = load()
+	require.True(t, ok)
+	now = now.Add(50 * time.Minute)
+	_, ok, _ = load()
+	require.True(t, ok)
+
+	// Left unused, it expires and its cookie is cleared
+	now = now.Add(2 * time.Hour)
+	_, ok, w = load()
+	assert.False(t, ok)
+	assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+
+	t.Run("logout ends the session", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		require.NoError(t, m.Login(w, httptest.NewRequest("POST", "/login", nil), user))
+		cookie = w.Result().Cookies()[0]
+		_, ok, _ := load()
+		require.True(t, ok)
+
+		req := httptest.NewRequest("POST", "/logout", nil)
+		req.AddCookie(cookie)
+		require.NoError(t, m.Logout(httptest.NewRecorder(), req))
+		_, ok, _ = load()
+		assert.False(t, ok)
+	})
+}
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	user, err := CreateUser(ctx, store, "alice", "correct horse", false)
+	require.NoError(t, err)
+
+	tokens := NewTokens(store)
+	now := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	tokens.now = func() time.Time { return now }
+
+	token, secret, err := tokens.Create(ctx, CreateTokenParams{
+		UserID: user.ID,
+		Name:   "Scale",
+		Scopes: []string{ScopeValuesWrite},
+		TTL:    24 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, token.Prefix))
+	assert.NotContains(t, token.TokenHash, secret)
+	assert.False(t, token.LastUsedAt.Valid)
+
+	serve := func(path, authorization string) (*httptest.ResponseRecorder, db.User) {
+		var got db.User
+		req := httptest.NewRequest("POST", path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		tokens.Load(RequireScope(ScopeValuesWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = UserFrom(r.Context())
+		}))).ServeHTTP(w, req)
+		return w, got
+	}
+
+	t.Run("signs in the api", func(t *testing.T) {
+		w, got := serve("/api/v1/gauges/1/values", "Bearer "+secret)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice", got.Username)
+
+		used, err := store.GetAccessTokenByHash(ctx, hashToken(secret))
+		require.NoError(t, err)
+		assert.True(t, used.LastUsedAt.Time.Equal(now))
+	})
+
+	t.Run("only the api", func(t *testing.T) {
+		w, got := serve("/admin/gauges/1/increment", "Bearer "+secret)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Zero(t, got.ID)
+	})
+
+	t.Run("rejects unknown tokens", func(t *testing.T) {
+		w, _ := serve("/api/v1/gauges/1/values", "Bearer hm_nope")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w, _ = serve("/api/v1/gauges/1/values", "Basic YWxpY2U6aG9yc2U=")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("checks scopes", func(t *testing.T) {
+		_, reader, err := tokens.Create(ctx, CreateTokenParams{UserID: user.ID, Name: "Reader", Scopes: []string{ScopeGaugesRead}})
+		require.NoError(t, err)
+		w, _ := serve("/api/v1/gauges/1/values", "Bearer "+reader)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "values:write")
+
+		// A session has every scope its user may use
+		assert.True(t, HasScope(ctx, ScopeGaugesWrite))
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		now = now.Add(24 * time.Hour)
+		w, _ := serve("/api/v1/gauges/1/values", "Bearer "+secret)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestParseScopes(t *testing.T) {
+	assert.Equal(t, []string{ScopeGaugesRead, ScopeValuesWrite}, ParseScopes("gauges:read, values:write,gauges:read,admin"))
+	assert.Empty(t, ParseScopes(""))
+}
+
+func TestCSRF(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	user, err := CreateUser(ctx, store, "alice", "correct horse", false)
+	require.NoError(t, err)
+	m := NewManager(store, Config{TTL: time.Hour})
+	tokens := NewTokens(store)
+
+	login := func() (*http.Cookie, string) {
+		w := httptest.NewRecorder()
+		require.NoError(t, m.Login(w, httptest.NewRequest("POST", "/login", nil), user))
+		cookie := w.Result().Cookies()[0]
+		return cookie, csrfToken(cookie.Value)
+	}
+	cookie, token := login()
+	other, otherToken := login()
+	assert.NotEqual(t, token, otherToken)
+
+	handler := m.Load(tokens.Load(CheckCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r.Context())))
+	}))))
+	serve := func(req *http.Request, cookie *http.Cookie) *httptest.ResponseRecorder {
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	form := func(method, path, token string) *http.Request {
+		req := httptest.NewRequest(method, path, strings.NewReader(CSRFField+"="+token))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	t.Run("pages get the session's token", func(t *testing.T) {
+		w := serve(httptest.NewRequest("GET", "/", nil), cookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, token, w.Body.String())
+	})
+
+	t.Run("changes need it", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(httptest.NewRequest("POST", "/admin/gauges", nil), cookie).Code)
+		assert.Equal(t, http.StatusForbidden, serve(form("DELETE", "/admin/gauges/1", ""), cookie).Code)
+		assert.Equal(t, http.StatusForbidden, serve(form("POST", "/admin/gauges", otherToken), cookie).Code)
+		assert.Equal(t, http.StatusForbidden, serve(form("POST", "/admin/gauges", token), other).Code)
+
+		w := serve(httptest.NewRequest("POST", "/api/v1/gauges", nil), cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("as a header or form field", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/admin/gauges/1", nil)
+		req.Header.Set(CSRFHeader, token)
+		assert.Equal(t, http.StatusOK, serve(req, cookie).Code)
+		assert.Equal(t, http.StatusOK, serve(form("POST", "/logout", token), cookie).Code)
+		assert.Equal(t, http.StatusOK, serve(form("POST", "/admin/gauges", otherToken), other).Code)
+	})
+
+	t.Run("from the start of a multipart form", func(t *testing.T) {
+		upload := func(fields ...string) *http.Request {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for i := 0; i < len(fields); i += 2 {
+				require.NoError(t, mw.WriteField(fields[i], fields[i+1]))
+			}
+			require.NoError(t, mw.Close())
+			req := httptest.NewRequest("POST", "/admin/import", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			return req
+		}
+		data := strings.Repeat("x", csrfPeekSize)
+
+		// The handler still gets the whole form
+		uploaded := m.Load(tokens.Load(CheckCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			io.WriteString(w, r.FormValue("data"))
+		}))))
+		req := upload(CSRFField, token, "data", data)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		uploaded.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, data, w.Body.String())
+
+		// A field after a large upload is not looked for
+		assert.Equal(t, http.StatusForbidden, serve(upload("data", data, CSRFField, token), cookie).Code)
+		assert.Equal(t, http.StatusForbidden, serve(upload(CSRFField, otherToken), cookie).Code)
+	})
+
+	t.Run("not without a session", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(httptest.NewRequest("POST", "/login", nil), nil).Code)
+
+		_, secret, err := tokens.Create(ctx, CreateTokenParams{UserID: user.ID, Name: "Scale", Scopes: []string{ScopeValuesWrite}})
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/api/v1/gauges/1/values", nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		assert.Equal(t, http.StatusOK, serve(req, cookie).Code)
+	})
+}
+
+func TestProtect(t *testing.T) {
+	handler := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   http.Header
+		code     int
+		location string
+	}{
+		{name: "signing in is open", method: "POST", path: "/login", code: http.StatusOK},
+		{name: "static files are open", method: "GET", path: "/static/app.css", code: http.StatusOK},
+		{name: "api document is open", method: "GET", path: "/api/openapi.json", code: http.StatusOK},
+		{name: "metrics need signing in", method: "GET", path: "/metrics", code: http.StatusSeeOther, location: "/login?next=%2Fmetrics"},
+		{name: "dashboard redirects", method: "GET", path: "/", code: http.StatusSeeOther, location: "/login"},
+		{name: "api reads are refused", method: "GET", path: "/api/v1/gauges", code: http.StatusUnauthorized},
+		{name: "admin redirects", method: "GET", path: "/admin/gauges/new", code: http.StatusSeeOther, location: "/login?next=%2Fadmin%2Fgauges%2Fnew"},
+		{name: "admin root redirects", method: "GET", path: "/admin", code: http.StatusSeeOther, location: "/login?next=%2Fadmin"},
+		{name: "api writes are refused", method: "DELETE", path: "/api/v1/gauges/1", code: http.StatusUnauthorized},
+		{name: "forms redirect back", method: "POST", path: "/gauges/1/increment", header: http.Header{"Referer": {"http://example.com/"}}, code: http.StatusSeeOther, location: "/login"},
+		{name: "htmx is told where to go", method: "DELETE", path: "/admin/gauges/1", header: http.Header{"Hx-Request": {"true"}, "Hx-Current-Url": {"http://example.com/admin"}}, code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			w := serve(req)
+			assert.Equal(t, tt.code, w.Code)
+			if tt.location != "" {
+				assert.Equal(t, tt.location, w.Header().Get("Location"))
+			}
+		})
+	}
+
+	t.Run("htmx redirect", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/admin/gauges/1", nil)
+		req.Header.Set("HX-Request", "true")
+		req.Header.Set("HX-Current-URL", "http://example.com/admin")
+		assert.Equal(t, "/login?next=%2Fadmin", serve(req).Header().Get("HX-Redirect"))
+	})
+
+	t.Run("api error is json", func(t *testing.T) {
+		w := serve(httptest.NewRequest("POST", "/api/v1/gauges", nil))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.True(t, strings.Contains(w.Body.String(), `"type":"unauthorized"`))
+	})
+
+	t.Run("signed in", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/api/v1/gauges/1", nil)
+		req = req.WithContext(WithUser(req.Context(), db.User{ID: 1, Username: "alice"}))
+		assert.Equal(t, http.StatusOK, serve(req).Code)
+	})
+}
+
+func TestScrape(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	serve := func(token, path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		Scrape(token, metrics)(next).ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("s3cret", "/metrics", "Bearer s3cret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "metrics", w.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, serve("s3cret", "/metrics", "Bearer guess").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("s3cret", "/metrics", "Basic s3cret").Code)
+	assert.Equal(t, http.StatusTeapot, serve("s3cret", "/metrics", "").Code, "without a token it is left to the session")
+	assert.Equal(t, http.StatusTeapot, serve("", "/metrics", "Bearer ").Code, "no token set")
+	assert.Equal(t, http.StatusTeapot, serve("s3cret", "/api/v1/gauges", "Bearer s3cret").Code, "only the metrics")
+}
+
+func TestAdminOnly(t *testing.T) {
+	handler := AdminOnly("/admin/backup")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(path string, user db.User) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	alice := db.User{ID: 1, Username: "alice", IsAdmin: true}
+	bob := db.User{ID: 2, Username: "bob"}
+	assert.Equal(t, http.StatusOK, serve("/admin/backup", alice))
+	assert.Equal(t, http.StatusForbidden, serve("/admin/backup", bob))
+	assert.Equal(t, http.StatusForbidden, serve("/admin/backup/restore", bob))
+	assert.Equal(t, http.StatusOK, serve("/admin/backups", bob))
+	assert.Equal(t, http.StatusOK, serve("/admin/gauges/new", bob))
+}
+
+func TestSafeNext(t *testing.T) {
+	assert.Equal(t, "/admin?x=1", SafeNext("/admin?x=1"))
+	assert.Equal(t, "/", SafeNext("https://evil.example.com"))
+	assert.Equal(t, "/", SafeNext("//evil.example.com"))
+	assert.Equal(t, "/", SafeNext(`/\evil.example.com`))
+	assert.Equal(t, "/", SafeNext("/login?next=/admin"))
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("SESSION_TTL", "7d")
+	t.Setenv("COOKIE_SECURE", "true")
+
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, cfg.TTL)
+	assert.True(t, cfg.Secure)
+
+	t.Setenv("SESSION_TTL", "-1h")
+	_, err = ConfigFromEnv()
+	assert.ErrorContains(t, err, "SESSION_TTL")
+}
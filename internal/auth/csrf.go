@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"health-monitor/internal/models"
+)
+
+const (
+	// CSRFHeader carries the CSRF token on HTMX and script requests
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField carries the CSRF token in plain HTML forms
+	CSRFField = "csrf_token"
+)
+
+// csrfPeekSize bounds how much of a multipart body is read looking for the
+// CSRF field
+const csrfPeekSize = 64 << 10
+
+// csrfToken returns the CSRF token of the session with the given cookie
+// token. It is derived from the session's secret, so each session has its
+// own and it cannot be worked out from the database.
+func csrfToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("csrf"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type csrfKey struct{}
+
+// withCSRFToken returns a context carrying the CSRF token of its session
+func withCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfKey{}, token)
+}
+
+// CSRFToken returns the CSRF token pages must send back with every change,
+// or "" without a session
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfKey{}).(string)
+	return token
+}
+
+// CheckCSRF is middleware refusing requests that change something with a
+// session cookie but without its CSRF token, in the X-CSRF-Token header or
+// the csrf_token form field. Multipart forms are only read as far as the
+// field, which they send first, so an upload is left to its handler and the
+// limit it sets. It must come after Manager.Load and
+// Tokens.Load. Requests made with an access token are not checked, since a
+// browser never adds one by itself, and neither are requests without a
+// session, which Protect turns away unless they are signing in.
+func CheckCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		want := CSRFToken(r.Context())
+		if want == "" || viaToken(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		got := r.Header.Get(CSRFHeader)
+		if got == "" {
+			if mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+				got = multipartToken(r, params["boundary"])
+			} else {
+				got = r.PostFormValue(CSRFField)
+			}
+		}
+		if hmac.Equal([]byte(got), []byte(want)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			models.WriteError(w, models.NewForbiddenError("Missing or invalid CSRF token"))
+			return
+		}
+		http.Error(w, "Missing or invalid CSRF token; reload the page and try again", http.StatusForbidden)
+	})
+}
+
+// multipartToken reads the CSRF field from the start of a multipart body
+// without parsing the rest, and puts back what it read for the handler
+func multipartToken(r *http.Request, boundary string) string {
+	var read bytes.Buffer
+	body := r.Body
+	defer func() {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&read, body), body}
+	}()
+
+	mr := multipart.NewReader(io.TeeReader(io.LimitReader(body, csrfPeekSize), &read), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FormName() == CSRFField {
+			token, _ := io.ReadAll(io.LimitReader(part, 256))
+			return string(token)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"health-monitor/internal/models"
+)
+
+// LoginPath is the sign in page
+const LoginPath = "/login"
+
+// Protect is middleware requiring a signed in user for everything apart from
+// signing in and the few public paths, since every gauge now belongs to
+// someone. It must come after Manager.Load. Requests without a user are
+// turned away: the API is answered 401, HTMX is told to go to the sign in
+// page and anything else is redirected there.
+func Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFrom(r.Context()); ok || isPublic(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			models.WriteError(w, models.NewUnauthorizedError("Sign in to use this endpoint"))
+		case r.Header.Get("HX-Request") == "true":
+			w.Header().Set("HX-Redirect", LoginURL(returnPath(r)))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			http.Redirect(w, r, LoginURL(returnPath(r)), http.StatusSeeOther)
+		}
+	})
+}
+
+// publicPaths may be used without signing in; a path ending in a slash
+// covers everything below it
+var publicPaths = []string{LoginPath, "/static/", "/api/openapi.json"}
+
+// isPublic reports whether a path may be used without signing in
+func isPublic(path string) bool {
+	for _, public := range publicPaths {
+		if path == public || (strings.HasSuffix(public, "/") && strings.HasPrefix(path, public)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricsPath is where Prometheus scrapes the metrics
+const MetricsPath = "/metrics"
+
+// Scrape is middleware serving MetricsPath to a scraper that sends token as
+// "Authorization: Bearer", without signing in. The metrics cover every
+// user's gauges, so anyone else must be a signed in admin, which Protect
+// and AdminOnly see to; a request with the wrong token is answered 401.
+// It must come before Protect. An empty token leaves the metrics to admins.
+func Scrape(token string, metrics http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if r.URL.Path != MetricsPath || token == "" || header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scheme, secret, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(secret)), []byte(token)) != 1 {
+				http.Error(w, "Invalid metrics token", http.StatusUnauthorized)
+				return
+			}
+			metrics.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AdminOnly is middleware refusing non-admins the pages under the given
+// prefixes, which configure the whole site rather than one user's gauges.
+// It must come after Protect.
+func AdminOnly(prefixes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, _ := UserFrom(r.Context()); !user.IsAdmin && underAny(r.URL.Path, prefixes) {
+				http.Error(w, "Only an admin may do this", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// underAny reports whether path is one of the prefixes or below one
+func underAny(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// returnPath is where to go back to after signing in: the page asked for,
+// or for a change the page it was made from
+func returnPath(r *http.Request) string {
+	if r.Method == http.MethodGet {
+		return r.URL.RequestURI()
+	}
+	if current := r.Header.Get("HX-Current-URL"); current != "" {
+		if u, err := url.Parse(current); err == nil {
+			return u.RequestURI()
+		}
+	}
+	if u, err := url.Parse(r.Referer()); err == nil && u.Path != "" {
+		return u.RequestURI()
+	}
+	return "/"
+}
+
+// LoginURL returns the sign in page, coming back to next afterwards
+func LoginURL(next string) string {
+	next = SafeNext(next)
+	if next == "/" {
+		return LoginPath
+	}
+	return LoginPath + "?" + url.Values{"next": {next}}.Encode()
+}
+
+// SafeNext returns next if it is a path on this site, and "/" otherwise,
+// so the sign in page cannot be made to send users elsewhere
+func SafeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	if strings.HasPrefix(next, LoginPath) {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,118 @@
+// Package auth signs users in with a password and keeps them signed in
+// with a session cookie.
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"health-monitor/internal/db"
+)
+
+const (
+	// MinPasswordLength is the shortest password a user may choose
+	MinPasswordLength = 8
+	// maxPasswordLength is the most bcrypt hashes; it ignores the rest
+	maxPasswordLength = 72
+)
+
+var (
+	// ErrInvalidLogin is returned for an unknown username or a wrong
+	// password alike, so a failed sign in does not say which it was
+	ErrInvalidLogin = errors.New("invalid username or password")
+	// ErrUsernameTaken is returned when creating a user whose name is in use
+	ErrUsernameTaken = errors.New("username is already taken")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidateUsername checks a username is 1 to 64 letters, digits, dots,
+// dashes or underscores
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return errors.New("username must be 1 to 64 letters, digits, dots, dashes or underscores")
+	}
+	return nil
+}
+
+// ValidatePassword checks a password is long enough to choose, and short
+// enough to hash whole
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+	return nil
+}
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password is the one hashed
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// checkDummy spends as long as CheckPassword does, so signing in as an
+// unknown user takes no less time than with a wrong password
+func checkDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("health-monitor"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// UserStore holds user accounts
+type UserStore interface {
+	GetUserByUsername(ctx context.Context, username string) (db.User, error)
+	CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error)
+}
+
+// CreateUser adds a user after checking the username is free and both it
+// and the password are acceptable
+func CreateUser(ctx context.Context, store UserStore, username, password string, admin bool) (db.User, error) {
+	username = strings.TrimSpace(username)
+	if err := ValidateUsername(username); err != nil {
+		return db.User{}, err
+	}
+	_, err := store.GetUserByUsername(ctx, username)
+	if err == nil {
+		return db.User{}, ErrUsernameTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return db.User{}, err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return db.User{}, err
+	}
+	return store.CreateUser(ctx, db.CreateUserParams{
+		Username:     username,
+		PasswordHash: hash,
+		IsAdmin:      admin,
+	})
+}
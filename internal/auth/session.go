@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+)
+
+const (
+	// CookieName is the cookie holding the session token
+	CookieName = "session"
+	// DefaultTTL is how long a session lasts without being used
+	DefaultTTL = 30 * 24 * time.Hour
+	// touchInterval is how stale a session's last use may be before a
+	// request records it again, sparing a write on every request
+	touchInterval = time.Minute
+)
+
+// Config says how long sessions last and how their cookie is sent
+type Config struct {
+	// TTL is how long a session lasts after it was last used
+	TTL time.Duration
+	// Secure marks the cookie for HTTPS only. It is always set on
+	// requests that arrive over TLS.
+	Secure bool
+}
+
+// DefaultConfig keeps users signed in for 30 days between visits
+var DefaultConfig = Config{TTL: DefaultTTL}
+
+// ConfigFromEnv reads SESSION_TTL, a Go duration or a number of days such
+// as "30d", and COOKIE_SECURE, which should be true behind a proxy that
+// terminates HTTPS
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig
+	if s := os.Getenv("SESSION_TTL"); s != "" {
+		ttl, err := parseTTL(s)
+		if err != nil {
+			return cfg, fmt.Errorf("SESSION_TTL: %w", err)
+		}
+		cfg.TTL = ttl
+	}
+	if s := os.Getenv("COOKIE_SECURE"); s != "" {
+		secure, err := strconv.ParseBool(s)
+		if err != nil {
+			return cfg, fmt.Errorf("COOKIE_SECURE: %q is not true or false", s)
+		}
+		cfg.Secure = secure
+	}
+	return cfg, nil
+}
+
+// parseTTL parses a positive Go duration, allowing a whole number of days
+// as "30d"
+func parseTTL(s string) (time.Duration, error) {
+	var ttl time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		ttl = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		ttl = d
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%q is not a positive duration", s)
+	}
+	return ttl, nil
+}
+
+// Store holds users and their sessions
+type Store interface {
+	UserStore
+	GetUser(ctx context.Context, id int64) (db.User, error)
+	CreateSession(ctx context.Context, arg db.CreateSessionParams) error
+	GetSession(ctx context.Context, id string) (db.Session, error)
+	TouchSession(ctx context.Context, arg db.TouchSessionParams) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error)
+}
+
+// Manager signs users in and out, and loads the user of each request from
+// its session cookie
+type Manager struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+}
+
+// NewManager returns a session manager keeping sessions in store
+func NewManager(store Store, cfg Config) *Manager {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	return &Manager{
+		store: store,
+		cfg:   cfg,
+		now:   time.Now,
+	}
+}
+
+// Authenticate returns the user with a username and password, or
+// ErrInvalidLogin
+func (m *Manager) Authenticate(ctx context.Context, username, password string) (db.User, error) {
+	user, err := m.store.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		checkDummy(password)
+		return db.User{}, ErrInvalidLogin
+	}
+	if err != nil {
+		return db.User{}, err
+	}
+	if !CheckPassword(user.PasswordHash, password) {
+		return db.User{}, ErrInvalidLogin
+	}
+	return user, nil
+}
+
+// Login starts a session for user and sets its cookie. Sessions that have
+// expired are cleared out at the same time.
+func (m *Manager) Login(w http.ResponseWriter, r *http.Request, user db.User) error {
+	now := m.now()
+	if _, err := m.store.DeleteExpiredSessions(r.Context(), now); err != nil {
+		return err
+	}
+
+	token := newToken()
+	expires := now.Add(m.cfg.TTL)
+	err := m.store.CreateSession(r.Context(), db.CreateSessionParams{
+		ID:         hashToken(token),
+		UserID:     user.ID,
+		CreatedAt:  now,
+		ExpiresAt:  expires,
+		LastSeenAt: now,
+		Ip:         clientIP(r),
+		UserAgent:  r.UserAgent(),
+	})
+	if err != nil {
+		return err
+	}
+	m.setCookie(w, r, token, expires)
+	return nil
+}
+
+// Logout ends the request's session and clears its cookie
+func (m *Manager) Logout(w http.ResponseWriter, r *http.Request) error {
+	m.clearCookie(w, r)
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return nil
+	}
+	return m.store.DeleteSession(r.Context(), hashToken(cookie.Value))
+}
+
+// Load is middleware adding the user signed in by the request's session
+// cookie, if any, to its context along with the session's CSRF token. Each
+// use pushes the session's expiry back.
+func (m *Manager) Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok, err := m.user(w, r)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to load session: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			cookie, _ := r.Cookie(CookieName)
+			ctx := withCSRFToken(WithUser(r.Context(), user), csrfToken(cookie.Value))
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// user returns the user signed in by the request's session cookie. A
+// cookie for a session that has ended is cleared.
+func (m *Manager) user(w http.ResponseWriter, r *http.Request) (db.User, bool, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil || cookie.Value == "" {
+		return db.User{}, false, nil
+	}
+	ctx := r.Context()
+	id := hashToken(cookie.Value)
+	session, err := m.store.GetSession(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		m.clearCookie(w, r)
+		return db.User{}, false, nil
+	}
+	if err != nil {
+		return db.User{}, false, err
+	}
+
+	now := m.now()
+	if !now.Before(session.ExpiresAt) {
+		m.clearCookie(w, r)
+		return db.User{}, false, m.store.DeleteSession(ctx, id)
+	}
+	user, err := m.store.GetUser(ctx, session.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		m.clearCookie(w, r)
+		return db.User{}, false, m.store.DeleteSession(ctx, id)
+	}
+	if err != nil {
+		return db.User{}, false, err
+	}
+
+	if now.Sub(session.LastSeenAt) >= touchInterval {
+		expires := now.Add(m.cfg.TTL)
+		err := m.store.TouchSession(ctx, db.TouchSessionParams{
+			LastSeenAt: now,
+			ExpiresAt:  expires,
+			ID:         id,
+		})
+		if err != nil {
+			return db.User{}, false, err
+		}
+		m.setCookie(w, r, cookie.Value, expires)
+	}
+	return user, true, nil
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   m.cfg.Secure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (m *Manager) clearCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.cfg.Secure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// newToken returns a random session token
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// hashToken returns the id a session is stored under: the hex SHA-256 of
+// its token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// clientIP returns the address a request came from, without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type contextKey struct{}
+
+// WithUser returns a context carrying the signed in user
+func WithUser(ctx context.Context, user db.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFrom returns the user signed in for a request, if any
+func UserFrom(ctx context.Context) (db.User, bool) {
+	user, ok := ctx.Value(contextKey{}).(db.User)
+	return user, ok
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+)
+
+// tokenPrefix starts every access token, so a leaked one is easy to
+// recognise
+const tokenPrefix = "hm_"
+
+// Scopes an access token may be given
+const (
+	ScopeGaugesRead  = "gauges:read"
+	ScopeGaugesWrite = "gauges:write"
+	ScopeValuesRead  = "values:read"
+	ScopeValuesWrite = "values:write"
+)
+
+// Scope is a scope with what it allows, as the tokens page lists it
+type Scope struct {
+	Name        string
+	Description string
+}
+
+// Scopes are the scopes a token may have, in the order the tokens page
+// lists them
+var Scopes = []Scope{
+	{ScopeGaugesRead, "See gauges, their history and trends, and export them"},
+	{ScopeGaugesWrite, "Create, change and delete gauges, and import them"},
+	{ScopeValuesRead, "List logged values and export them"},
+	{ScopeValuesWrite, "Log values and import them"},
+}
+
+// ErrInvalidToken is returned for an access token that does not exist or
+// has expired
+var ErrInvalidToken = errors.New("invalid or expired access token")
+
+// ParseScopes reads a scope list as stored with a token, dropping unknown
+// and repeated names
+func ParseScopes(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if IsScope(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// JoinScopes writes a scope list as stored with a token
+func JoinScopes(names []string) string {
+	return strings.Join(names, ",")
+}
+
+// IsScope reports whether name is a scope a token may have
+func IsScope(name string) bool {
+	for _, s := range Scopes {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenStore holds access tokens and the users they belong to
+type TokenStore interface {
+	GetUser(ctx context.Context, id int64) (db.User, error)
+	CreateAccessToken(ctx context.Context, arg db.CreateAccessTokenParams) (db.AccessToken, error)
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (db.AccessToken, error)
+	TouchAccessToken(ctx context.Context, arg db.TouchAccessTokenParams) error
+}
+
+// Tokens issues access tokens and loads the user of each API request
+// bearing one
+type Tokens struct {
+	store TokenStore
+	now   func() time.Time
+}
+
+// NewTokens returns access tokens kept in store
+func NewTokens(store TokenStore) *Tokens {
+	return &Tokens{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// CreateTokenParams describes a new access token. A zero TTL never
+// expires.
+type CreateTokenParams struct {
+	UserID int64
+	Name   string
+	Scopes []string
+	TTL    time.Duration
+}
+
+// Create issues an access token, returning it along with its secret. Only
+// the secret's hash is kept, so this is the only time it can be shown.
+func (t *Tokens) Create(ctx context.Context, arg CreateTokenParams) (db.AccessToken, string, error) {
+	secret := tokenPrefix + newToken()
+	expires := sql.NullTime{}
+	if arg.TTL > 0 {
+		expires = sql.NullTime{Time: t.now().Add(arg.TTL), Valid: true}
+	}
+	token, err := t.store.CreateAccessToken(ctx, db.CreateAccessTokenParams{
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: hashToken(secret),
+		Prefix:    secret[:len(tokenPrefix)+8],
+		Scopes:    JoinScopes(arg.Scopes),
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return token, "", err
+	}
+	return token, secret, nil
+}
+
+// Authenticate returns the token with a secret and the user it belongs to,
+// or ErrInvalidToken. Each use is recorded as the token's last.
+func (t *Tokens) Authenticate(ctx context.Context, secret string) (db.AccessToken, db.User, error) {
+	token, err := t.store.GetAccessTokenByHash(ctx, hashToken(secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, db.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return token, db.User{}, err
+	}
+	now := t.now()
+	if token.ExpiresAt.Valid && !now.Before(token.ExpiresAt.Time) {
+		return token, db.User{}, ErrInvalidToken
+	}
+	user, err := t.store.GetUser(ctx, token.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, user, ErrInvalidToken
+	}
+	if err != nil {
+		return token, user, err
+	}
+
+	if !token.LastUsedAt.Valid || now.Sub(token.LastUsedAt.Time) >= touchInterval {
+		err := t.store.TouchAccessToken(ctx, db.TouchAccessTokenParams{
+			LastUsedAt: sql.NullTime{Time: now, Valid: true},
+			ID:         token.ID,
+		})
+		if err != nil {
+			return token, user, err
+		}
+	}
+	return token, user, nil
+}
+
+// Load is middleware signing in API requests that carry an access token as
+// "Authorization: Bearer", limited to the token's scopes. It must come
+// before Protect. A request with a token that is not valid is answered 401
+// rather than falling back to its session.
+func (t *Tokens) Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		scheme, secret, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || secret == "" {
+			models.WriteError(w, models.NewUnauthorizedError("Authorization must be a Bearer access token"))
+			return
+		}
+
+		token, user, err := t.Authenticate(r.Context(), strings.TrimSpace(secret))
+		if errors.Is(err, ErrInvalidToken) {
+			models.WriteError(w, models.NewUnauthorizedError("Access token is not valid or has expired"))
+			return
+		}
+		if err != nil {
+			models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to check access token: %v", err)))
+			return
+		}
+		ctx := WithScopes(WithUser(r.Context(), user), ParseScopes(token.Scopes))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope is middleware answering 403 to requests made with an access
+// token that lacks scope
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				models.WriteError(w, ScopeError(scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ScopeError is the answer to a request whose token lacks scope
+func ScopeError(scope string) *models.AppError {
+	return models.NewForbiddenError(fmt.Sprintf("Access token does not have the %s scope", scope))
+}
+
+type scopesKey struct{}
+
+// WithScopes returns a context limited to the scopes of the access token
+// it was signed in with
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// viaToken reports whether a request was signed in with an access token
+func viaToken(ctx context.Context) bool {
+	_, ok := ctx.Value(scopesKey{}).([]string)
+	return ok
+}
+
+// HasScope reports whether a request may do what scope allows. Requests
+// signed in without an access token may do anything their user may.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesKey{}).([]string)
+	return !ok || slices.Contains(scopes, scope)
+}
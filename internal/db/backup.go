@@ -0,0 +1,398 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// BackupFormat identifies a backup archive
+const BackupFormat = "health-monitor-backup"
+
+// BackupVersion is the version of the archive layout this build writes.
+// Archives of any version up to it can be restored.
+const BackupVersion = 1
+
+// ErrInvalidBackup is returned when an archive cannot be restored
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Backup is a lossless snapshot of every gauge with its logged values and
+// closed periods
+type Backup struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Schema is the database migration the snapshot was taken at
+	Schema int           `json:"schema"`
+	Gauges []BackupGauge `json:"gauges"`
+}
+
+// BackupGauge is a gauge with every field as stored, and its history
+type BackupGauge struct {
+	Gauge
+	Values  []GaugeValue  `json:"values"`
+	Periods []GaugePeriod `json:"periods"`
+}
+
+// RestoreMode says what happens to the gauges already in the database when a
+// backup is restored
+type RestoreMode string
+
+const (
+	// RestoreReplace deletes every gauge and restores the archive exactly,
+	// keeping its IDs
+	RestoreReplace RestoreMode = "replace"
+	// RestoreMerge adds the archive to the existing gauges. Gauges are
+	// matched by owner and name; values already recorded at the same time and periods
+	// already closed are kept as they are.
+	RestoreMerge RestoreMode = "merge"
+)
+
+// ParseRestoreMode parses a restore mode name
+func ParseRestoreMode(s string) (RestoreMode, error) {
+	switch mode := RestoreMode(s); mode {
+	case RestoreReplace, RestoreMerge:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown restore mode %q", s)
+}
+
+// RestoreResult counts what a restore added
+type RestoreResult struct {
+	Gauges  int `json:"gauges"`
+	Values  int `json:"values"`
+	Periods int `json:"periods"`
+	// Skipped counts values already recorded in a merge
+	Skipped int `json:"skipped"`
+}
+
+// Backup takes a snapshot of the database in one transaction, so it is
+// consistent even while values are being logged
+func (s *Store) Backup(ctx context.Context) (*Backup, error) {
+	backup := &Backup{Format: BackupFormat, Version: BackupVersion, Created: time.Now().UTC()}
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		backup.Gauges = nil
+		if err := q.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&backup.Schema); err != nil {
+			return err
+		}
+
+		gauges, err := q.ListGauges(ctx)
+		if err != nil {
+			return err
+		}
+		for _, gauge := range gauges {
+			entry := BackupGauge{Gauge: gauge}
+			if entry.Values, err = q.ListGaugeValuesInRange(ctx, ListGaugeValuesInRangeParams{
+				GaugeID: gauge.ID,
+				ToDate:  time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC),
+			}); err != nil {
+				return err
+			}
+			if entry.Periods, err = q.GetGaugePeriods(ctx, gauge.ID); err != nil {
+				return err
+			}
+			backup.Gauges = append(backup.Gauges, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// WriteBackup encodes a backup as JSON, one gauge at a time so large
+// histories are streamed rather than built up in memory
+func WriteBackup(w io.Writer, backup *Backup) error {
+	header := *backup
+	header.Gauges = nil
+	head, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// Splice the gauges into the header object in place of its null list
+	head = head[:len(head)-len(`null}`)]
+	if _, err := w.Write(append(head, "[\n"...)); err != nil {
+		return err
+	}
+	for i, gauge := range backup.Gauges {
+		if i > 0 {
+			if _, err := io.WriteString(w, ",\n"); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(gauge)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "\n]}\n")
+	return err
+}
+
+// ReadBackup decodes an archive and checks it can be restored by this build
+func ReadBackup(r io.Reader) (*Backup, error) {
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if err := backup.Validate(); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// Validate checks the archive's format and version, and that every gauge,
+// value and period belongs together
+func (b *Backup) Validate() error {
+	if b.Format != BackupFormat {
+		return fmt.Errorf("%w: not a %s archive", ErrInvalidBackup, BackupFormat)
+	}
+	if b.Version < 1 {
+		return fmt.Errorf("%w: missing version", ErrInvalidBackup)
+	}
+	if b.Version > BackupVersion {
+		return fmt.Errorf("%w: version %d was written by a newer release; this one reads up to version %d", ErrInvalidBackup, b.Version, BackupVersion)
+	}
+
+	ids := make(map[int64]bool, len(b.Gauges))
+	names := make(map[string]bool, len(b.Gauges))
+	for i, gauge := range b.Gauges {
+		if strings.TrimSpace(gauge.Name) == "" {
+			return fmt.Errorf("%w: gauge %d has no name", ErrInvalidBackup, i+1)
+		}
+		// Names are only unique per owner, as a merge matches them
+		name := gaugeKey(gauge.OwnerID, gauge.Name)
+		if ids[gauge.ID] || names[name] {
+			return fmt.Errorf("%w: gauge %q appears more than once", ErrInvalidBackup, gauge.Name)
+		}
+		ids[gauge.ID], names[name] = true, true
+
+		for _, value := range gauge.Values {
+			if value.GaugeID != gauge.ID {
+				return fmt.Errorf("%w: value %d is listed under gauge %q but belongs to gauge %d", ErrInvalidBackup, value.ID, gauge.Name, value.GaugeID)
+			}
+		}
+		for _, p := range gauge.Periods {
+			if p.GaugeID != gauge.ID {
+				return fmt.Errorf("%w: period %d is listed under gauge %q but belongs to gauge %d", ErrInvalidBackup, p.ID, gauge.Name, p.GaugeID)
+			}
+		}
+	}
+	return nil
+}
+
+// Restore loads a backup in one transaction, so a failed restore leaves the
+// database as it was
+func (s *Store) Restore(ctx context.Context, backup *Backup, mode RestoreMode) (RestoreResult, error) {
+	var result RestoreResult
+	if err := backup.Validate(); err != nil {
+		return result, err
+	}
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		result = RestoreResult{}
+		if mode == RestoreReplace {
+			return restoreReplace(ctx, q, backup, &result)
+		}
+		return restoreMerge(ctx, q, backup, time.Now(), &result)
+	})
+	return result, err
+}
+
+// restoreReplace deletes every gauge, along with their values and periods,
+// and inserts the archive's rows with their original IDs
+func restoreReplace(ctx context.Context, q *Queries, backup *Backup, result *RestoreResult) error {
+	if err := q.DeleteAllGauges(ctx); err != nil {
+		return err
+	}
+
+	for _, entry := range backup.Gauges {
+		gauge, err := q.RestoreGauge(ctx, restoreGaugeParams(entry.Gauge, true))
+		if err != nil {
+			return fmt.Errorf("restoring gauge %q: %w", entry.Name, err)
+		}
+		result.Gauges++
+
+		for _, value := range entry.Values {
+			if err := q.RestoreGaugeValue(ctx, restoreValueParams(value, gauge.ID, true)); err != nil {
+				return fmt.Errorf("restoring a value of %q: %w", entry.Name, err)
+			}
+			result.Values++
+		}
+		for _, p := range entry.Periods {
+			if err := q.RestoreGaugePeriod(ctx, restorePeriodParams(p, gauge.ID, true)); err != nil {
+				return fmt.Errorf("restoring a period of %q: %w", entry.Name, err)
+			}
+			result.Periods++
+		}
+	}
+	return nil
+}
+
+// restoreMerge adds the archive to the existing gauges. Archived gauges with
+// a new name are added whole. For gauges that already exist, values missing
+// here are logged the way an import would: those in the active period add to
+// the value, and earlier ones to the period they fall in when it was already
+// closed here. Closed periods missing here are copied as they are, since they
+// already include their values.
+func restoreMerge(ctx context.Context, q *Queries, backup *Backup, now time.Time, result *RestoreResult) error {
+	existing, err := q.ListGauges(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]Gauge, len(existing))
+	for _, gauge := range existing {
+		byName[gaugeKey(gauge.OwnerID, gauge.Name)] = gauge
+	}
+
+	for _, entry := range backup.Gauges {
+		gauge, ok := byName[gaugeKey(entry.OwnerID, entry.Name)]
+		if !ok {
+			gauge, err = q.RestoreGauge(ctx, restoreGaugeParams(entry.Gauge, false))
+			if err != nil {
+				return fmt.Errorf("restoring gauge %q: %w", entry.Name, err)
+			}
+			result.Gauges++
+			for _, value := range entry.Values {
+				if err := q.RestoreGaugeValue(ctx, restoreValueParams(value, gauge.ID, false)); err != nil {
+					return fmt.Errorf("restoring a value of %q: %w", entry.Name, err)
+				}
+				result.Values++
+			}
+			for _, p := range entry.Periods {
+				if err := q.RestoreGaugePeriod(ctx, restorePeriodParams(p, gauge.ID, false)); err != nil {
+					return fmt.Errorf("restoring a period of %q: %w", entry.Name, err)
+				}
+				result.Periods++
+			}
+			continue
+		}
+
+		if err := mergeGauge(ctx, q, gauge, entry, now, result); err != nil {
+			return fmt.Errorf("merging gauge %q: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// mergeGauge adds an archived gauge's history to the existing gauge
+func mergeGauge(ctx context.Context, q *Queries, gauge Gauge, entry BackupGauge, now time.Time, result *RestoreResult) error {
+	gauge, _, err := rolloverGauge(ctx, q, gauge, now)
+	if err != nil {
+		return err
+	}
+	periods, err := q.GetGaugePeriods(ctx, gauge.ID)
+	if err != nil {
+		return err
+	}
+	closed := func(date time.Time) bool {
+		for _, p := range periods {
+			if !date.Before(p.PeriodStart) && date.Before(p.PeriodEnd) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, value := range entry.Values {
+		date := value.Date.UTC()
+		found, err := q.GaugeValueExists(ctx, GaugeValueExistsParams{GaugeID: gauge.ID, Date: date})
+		if err != nil {
+			return err
+		}
+		if found != 0 {
+			result.Skipped++
+			continue
+		}
+
+		switch {
+		case !date.Before(gauge.PeriodStart.Time):
+			if gauge, err = q.AddGaugeValue(ctx, AddGaugeValueParams{ID: gauge.ID, Delta: value.Delta}); err != nil {
+				return err
+			}
+		case closed(date):
+			if err := q.AddGaugePeriodValue(ctx, AddGaugePeriodValueParams{GaugeID: gauge.ID, Delta: value.Delta, Date: date}); err != nil {
+				return err
+			}
+		}
+		if err := q.RestoreGaugeValue(ctx, restoreValueParams(value, gauge.ID, false)); err != nil {
+			return err
+		}
+		result.Values++
+	}
+
+	for _, p := range entry.Periods {
+		if closed(p.PeriodStart) || !p.PeriodStart.Before(gauge.PeriodStart.Time) {
+			continue
+		}
+		if err := q.RestoreGaugePeriod(ctx, restorePeriodParams(p, gauge.ID, false)); err != nil {
+			return err
+		}
+		result.Periods++
+	}
+	return nil
+}
+
+func restoreGaugeParams(g Gauge, keepID bool) RestoreGaugeParams {
+	return RestoreGaugeParams{
+		ID:          sql.NullInt64{Int64: g.ID, Valid: keepID},
+		Name:        g.Name,
+		Description: g.Description,
+		Target:      g.Target,
+		Value:       g.Value,
+		Unit:        g.Unit,
+		Icon:        g.Icon,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+		Period:      g.Period,
+		PeriodDays:  g.PeriodDays,
+		WeekStart:   g.WeekStart,
+		Timezone:    g.Timezone,
+		PeriodStart: g.PeriodStart,
+		GoalType:    g.GoalType,
+		TargetMin:   g.TargetMin,
+		Version:     g.Version,
+		Step:        g.Step,
+		Presets:     g.Presets,
+		MinValue:    g.MinValue,
+		MaxValue:    g.MaxValue,
+		Decimals:    g.Decimals,
+		OwnerID:     g.OwnerID,
+		HouseholdID: g.HouseholdID,
+	}
+}
+
+func restoreValueParams(v GaugeValue, gaugeID int64, keepID bool) RestoreGaugeValueParams {
+	return RestoreGaugeValueParams{
+		ID:      sql.NullInt64{Int64: v.ID, Valid: keepID},
+		GaugeID: gaugeID,
+		Value:   v.Value,
+		Delta:   v.Delta,
+		Source:  v.Source,
+		Date:    v.Date.UTC(),
+	}
+}
+
+func restorePeriodParams(p GaugePeriod, gaugeID int64, keepID bool) RestoreGaugePeriodParams {
+	return RestoreGaugePeriodParams{
+		ID:          sql.NullInt64{Int64: p.ID, Valid: keepID},
+		GaugeID:     gaugeID,
+		PeriodStart: p.PeriodStart.UTC(),
+		PeriodEnd:   p.PeriodEnd.UTC(),
+		Value:       p.Value,
+		Target:      p.Target,
+		ClosedAt:    p.ClosedAt,
+	}
+}
@@ -0,0 +1,215 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedBackup creates a gauge with two logged values and one closed period
+func seedBackup(t *testing.T, store *db.Store) db.Gauge {
+	ctx := context.Background()
+	gauge := testutil.CreateTestGauge(t, store)
+	_, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 3, Source: db.SourceWeb})
+	require.NoError(t, err)
+	gauge, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2, Source: db.SourceAPI})
+	require.NoError(t, err)
+
+	start := gauge.PeriodStart.Time.AddDate(0, 0, -7)
+	require.NoError(t, store.RestoreGaugePeriod(ctx, db.RestoreGaugePeriodParams{
+		GaugeID:     gauge.ID,
+		PeriodStart: start,
+		PeriodEnd:   gauge.PeriodStart.Time,
+		Value:       40,
+		Target:      100,
+		ClosedAt:    sql.NullTime{Time: gauge.PeriodStart.Time, Valid: true},
+	}))
+	return gauge
+}
+
+func TestStore_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	source := testutil.NewTestDB(t)
+	seedBackup(t, source)
+
+	backup, err := source.Backup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, db.BackupFormat, backup.Format)
+	assert.Equal(t, db.BackupVersion, backup.Version)
+	assert.Positive(t, backup.Schema)
+	require.Len(t, backup.Gauges, 1)
+	assert.Len(t, backup.Gauges[0].Values, 2)
+	assert.Len(t, backup.Gauges[0].Periods, 1)
+
+	var archive bytes.Buffer
+	require.NoError(t, db.WriteBackup(&archive, backup))
+
+	t.Run("replace restores the snapshot exactly", func(t *testing.T) {
+		target := testutil.NewTestDB(t)
+		testutil.CreateTestGauge(t, target)
+		testutil.CreateTestGauge(t, target)
+
+		decoded, err := db.ReadBackup(bytes.NewReader(archive.Bytes()))
+		require.NoError(t, err)
+		result, err := target.Restore(ctx, decoded, db.RestoreReplace)
+		require.NoError(t, err)
+		assert.Equal(t, db.RestoreResult{Gauges: 1, Values: 2, Periods: 1}, result)
+
+		gauges, err := target.ListGauges(ctx)
+		require.NoError(t, err)
+		assert.Len(t, gauges, 1, "existing gauges are removed")
+
+		// Backing up the copy gives the same archive apart from its time
+		copied, err := target.Backup(ctx)
+		require.NoError(t, err)
+		copied.Created = backup.Created
+		var again bytes.Buffer
+		require.NoError(t, db.WriteBackup(&again, copied))
+		assert.JSONEq(t, archive.String(), again.String())
+	})
+
+	t.Run("merge adds only what is missing", func(t *testing.T) {
+		target := testutil.NewTestDB(t)
+		gauge := testutil.CreateTestGauge(t, target)
+		_, err := target.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1, Source: db.SourceWeb})
+		require.NoError(t, err)
+		extra := backup.Gauges[0]
+		extra.Name = "Second Gauge"
+		merged := *backup
+		merged.Gauges = append([]db.BackupGauge{extra}, backup.Gauges...)
+		merged.Gauges[0].ID = 99
+
+		values := make([]db.GaugeValue, len(extra.Values))
+		for i, value := range extra.Values {
+			value.GaugeID = 99
+			values[i] = value
+		}
+		merged.Gauges[0].Values = values
+		periods := make([]db.GaugePeriod, len(extra.Periods))
+		for i, p := range extra.Periods {
+			p.GaugeID = 99
+			periods[i] = p
+		}
+		merged.Gauges[0].Periods = periods
+
+		result, err := target.Restore(ctx, &merged, db.RestoreMerge)
+		require.NoError(t, err)
+		assert.Equal(t, db.RestoreResult{Gauges: 1, Values: 4, Periods: 2}, result)
+
+		updated, err := target.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 6.0, updated.Value, "archived values add to the existing gauge")
+
+		// Merging again finds everything already recorded
+		result, err = target.Restore(ctx, &merged, db.RestoreMerge)
+		require.NoError(t, err)
+		assert.Equal(t, db.RestoreResult{Skipped: 4}, result)
+
+		gauges, err := target.ListGauges(ctx)
+		require.NoError(t, err)
+		assert.Len(t, gauges, 2)
+	})
+}
+
+func TestStore_BackupRestore_SameNames(t *testing.T) {
+	ctx := context.Background()
+	newStore := func() (*db.Store, []sql.NullInt64) {
+		store := testutil.NewTestDB(t)
+		var owners []sql.NullInt64
+		for _, name := range []string{"alice", "bob"} {
+			user, err := store.CreateUser(ctx, db.CreateUserParams{Username: name, PasswordHash: "-"})
+			require.NoError(t, err)
+			owners = append(owners, sql.NullInt64{Int64: user.ID, Valid: true})
+		}
+		return store, owners
+	}
+
+	// Both members of a family have a gauge called Water
+	source, owners := newStore()
+	for _, owner := range owners {
+		gauge, err := source.CreateGauge(ctx, db.CreateGaugeParams{
+			Name: "Water", Target: 8, Unit: "glasses", Icon: "star", Period: "day", PeriodDays: 1,
+			WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1, OwnerID: owner,
+		})
+		require.NoError(t, err)
+		_, err = source.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: float64(owner.Int64), Source: db.SourceWeb})
+		require.NoError(t, err)
+	}
+	backup, err := source.Backup(ctx)
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	require.NoError(t, db.WriteBackup(&archive, backup))
+
+	for _, mode := range []db.RestoreMode{db.RestoreReplace, db.RestoreMerge} {
+		t.Run(string(mode), func(t *testing.T) {
+			target, _ := newStore()
+			decoded, err := db.ReadBackup(bytes.NewReader(archive.Bytes()))
+			require.NoError(t, err)
+			result, err := target.Restore(ctx, decoded, mode)
+			require.NoError(t, err)
+			assert.Equal(t, 2, result.Gauges)
+
+			gauges, err := target.ListGauges(ctx)
+			require.NoError(t, err)
+			require.Len(t, gauges, 2)
+			for _, gauge := range gauges {
+				assert.Equal(t, "Water", gauge.Name)
+				assert.Equal(t, float64(gauge.OwnerID.Int64), gauge.Value, "each owner keeps their own values")
+			}
+			assert.NotEqual(t, gauges[0].OwnerID, gauges[1].OwnerID)
+		})
+	}
+}
+
+func TestReadBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		want    string
+	}{
+		{"not json", "gauges", "invalid backup"},
+		{"other format", `{"format":"something-else","version":1}`, "not a health-monitor-backup archive"},
+		{"no version", `{"format":"health-monitor-backup"}`, "missing version"},
+		{"newer version", `{"format":"health-monitor-backup","version":99}`, "version 99 was written by a newer release"},
+		{"unnamed gauge", `{"format":"health-monitor-backup","version":1,"gauges":[{"id":1,"name":" "}]}`, "gauge 1 has no name"},
+		{"repeated gauge", `{"format":"health-monitor-backup","version":1,"gauges":[{"id":1,"name":"Water"},{"id":2,"name":"water"}]}`, "appears more than once"},
+		{"misplaced value", `{"format":"health-monitor-backup","version":1,"gauges":[{"id":1,"name":"A","values":[{"id":5,"gauge_id":2}]}]}`, "value 5 is listed under gauge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.ReadBackup(strings.NewReader(tt.archive))
+			require.ErrorIs(t, err, db.ErrInvalidBackup)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	backup, err := db.ReadBackup(strings.NewReader(`{"format":"health-monitor-backup","version":1,"created":"2025-03-01T00:00:00Z","gauges":[]}`))
+	require.NoError(t, err)
+	assert.Empty(t, backup.Gauges)
+}
+
+func TestRestore_FailureKeepsDatabase(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	gauge := seedBackup(t, store)
+
+	backup, err := store.Backup(ctx)
+	require.NoError(t, err)
+	// Two values with the same ID cannot both be restored
+	backup.Gauges[0].Values = append(backup.Gauges[0].Values, backup.Gauges[0].Values[0])
+
+	_, err = store.Restore(ctx, backup, db.RestoreReplace)
+	require.Error(t, err)
+
+	kept, err := store.GetGauge(ctx, gauge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, kept.Value)
+}
@@ -0,0 +1,37 @@
+//go:build mattn
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Driver is the database/sql driver the binary was built with. This build
+// uses the cgo github.com/mattn/go-sqlite3 driver; leave out -tags mattn for
+// the pure Go modernc.org/sqlite one.
+const Driver = "sqlite3"
+
+// dsn encodes the connection settings in mattn/go-sqlite3's format, which
+// applies them to every new connection
+func dsn(cfg Config) string {
+	q := url.Values{}
+	q.Set("_busy_timeout", fmt.Sprintf("%d", cfg.BusyTimeout.Milliseconds()))
+	q.Set("_foreign_keys", "on")
+	q.Set("_journal_mode", "WAL")
+	q.Set("_txlock", "immediate")
+	return cfg.Path + "?" + q.Encode()
+}
+
+// isBusy reports whether err means another connection held a lock for
+// longer than the busy timeout
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
@@ -0,0 +1,41 @@
+//go:build !mattn
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Driver is the database/sql driver the binary was built with. The pure Go
+// modernc.org/sqlite driver is the default; build with -tags mattn for the
+// cgo github.com/mattn/go-sqlite3 driver.
+const Driver = "sqlite"
+
+// dsn encodes the connection settings in modernc.org/sqlite's format. The
+// pragmas run on every new connection. Times are written in the same
+// format as mattn/go-sqlite3 so a database can move between drivers.
+func dsn(cfg Config) string {
+	q := url.Values{}
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Set("_time_format", "sqlite")
+	q.Set("_txlock", "immediate")
+	return cfg.Path + "?" + q.Encode()
+}
+
+// isBusy reports whether err means another connection held a lock for
+// longer than the busy timeout
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
@@ -0,0 +1,30 @@
+package db
+
+import "context"
+
+// AddHouseholdParams describes a new household and the role of the user
+// starting it
+type AddHouseholdParams struct {
+	Name   string
+	UserID int64
+	Role   string
+}
+
+// AddHousehold creates a household with the user starting it as its first
+// member
+func (s *Store) AddHousehold(ctx context.Context, arg AddHouseholdParams) (Household, error) {
+	var household Household
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+		household, err = q.CreateHousehold(ctx, arg.Name)
+		if err != nil {
+			return err
+		}
+		return q.SetHouseholdMember(ctx, SetHouseholdMemberParams{
+			HouseholdID: household.ID,
+			UserID:      arg.UserID,
+			Role:        arg.Role,
+		})
+	})
+	return household, err
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// SourceImport marks gauge values loaded from an import file
+const SourceImport = "import"
+
+// ImportedValue is a change to a gauge's value read from an import file
+type ImportedValue struct {
+	GaugeID int64
+	Delta   float64
+	Date    time.Time
+	Source  string
+	Mode    ImportMode
+}
+
+// ImportMode says what an imported value stands for, and so what importing
+// it again does
+type ImportMode int
+
+const (
+	// ImportChange values are single changes, such as the rows of a CSV
+	// file. One already recorded for the gauge at the same time is a
+	// duplicate and is skipped.
+	ImportChange ImportMode = iota
+	// ImportTotal values are the sum of the changes in the period starting
+	// at Date, as the health app importers write for totals such as steps.
+	// Importing the period again records the difference from what was
+	// imported at that time before, so a period imported partway is brought
+	// up to date.
+	ImportTotal
+	// ImportReading values are what the period starting at Date is set to,
+	// for readings such as weight that do not add up. The change recorded
+	// is the one from the period's value to the reading, as when a value is
+	// set, and importing the period again sets it again if it has moved.
+	ImportReading
+)
+
+// ImportResult reports what an import did, or would have done in a dry run.
+// Duplicates holds the indexes of the items that were skipped, and Updated
+// those that were imported before and changed what was recorded then.
+type ImportResult struct {
+	Imported   int
+	Duplicates []int
+	Updated    []int
+}
+
+// errDryRun rolls back a dry run's transaction once it has done its work
+var errDryRun = errors.New("dry run")
+
+// ImportGaugeValues records imported values in one transaction. Importing a
+// value already recorded for the same gauge at the same time changes nothing
+// and reports it as a duplicate, so importing a file twice changes nothing;
+// see ImportMode for values that change since. Values in the active period
+// change the gauge's value; earlier ones go to the period they fall in. A
+// dry run reports the same result without keeping any of it.
+func (s *Store) ImportGaugeValues(ctx context.Context, values []ImportedValue, dryRun bool) (ImportResult, error) {
+	var result ImportResult
+	now := time.Now()
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		result = ImportResult{}
+		gauges := make(map[int64]Gauge)
+
+		for i, value := range values {
+			gauge, ok := gauges[value.GaugeID]
+			if !ok {
+				current, err := q.GetGauge(ctx, value.GaugeID)
+				if err != nil {
+					return err
+				}
+				// Periods closed here are not reported, as a dry run
+				// rolls them back again
+				gauge, _, err = rolloverGauge(ctx, q, current, now)
+				if err != nil {
+					return err
+				}
+				gauges[gauge.ID] = gauge
+			}
+
+			date := value.Date.UTC()
+			past := date.Before(gauge.PeriodStart.Time)
+			recorded, err := q.SumGaugeDeltasAt(ctx, SumGaugeDeltasAtParams{GaugeID: gauge.ID, Date: date})
+			if err != nil {
+				return err
+			}
+			var total float64
+			if past {
+				total, err = periodTotal(ctx, q, gauge, date)
+				if err != nil {
+					return err
+				}
+			}
+
+			delta := value.Delta
+			switch value.Mode {
+			case ImportTotal:
+				delta -= recorded.Total
+			case ImportReading:
+				if past {
+					delta -= total
+				} else {
+					delta -= gauge.Value
+				}
+			}
+			if recorded.Found != 0 {
+				if value.Mode == ImportChange || math.Abs(delta) < tolerance {
+					result.Duplicates = append(result.Duplicates, i)
+					continue
+				}
+				result.Updated = append(result.Updated, i)
+			}
+
+			source := value.Source
+			if source == "" {
+				source = SourceImport
+			}
+			if past {
+				err = recordPastDelta(ctx, q, gauge, RecordGaugeValueParams{
+					GaugeID: gauge.ID,
+					Delta:   delta,
+					Source:  source,
+					Date:    date,
+				}, total)
+			} else {
+				// A zero change is still recorded so that importing the
+				// file again finds it as a duplicate
+				if delta != 0 {
+					gauge, err = q.AddGaugeValue(ctx, AddGaugeValueParams{ID: gauge.ID, Delta: delta})
+					if err != nil {
+						return err
+					}
+					gauges[gauge.ID] = gauge
+				}
+				err = q.CreateGaugeValue(ctx, CreateGaugeValueParams{
+					GaugeID: gauge.ID,
+					Value:   gauge.Value,
+					Delta:   delta,
+					Source:  source,
+					Date:    date,
+				})
+			}
+			if err != nil {
+				return err
+			}
+			result.Imported++
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+
+	return result, err
+}
+
+// ImportGauges creates imported gauges in one transaction. A gauge whose name
+// matches an existing gauge of the same owner, ignoring case, is a duplicate
+// and is skipped. A dry run reports the same result without keeping any of
+// it.
+func (s *Store) ImportGauges(ctx context.Context, gauges []CreateGaugeParams, dryRun bool) (ImportResult, error) {
+	var result ImportResult
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		result = ImportResult{}
+		existing, err := q.ListGauges(ctx)
+		if err != nil {
+			return err
+		}
+		names := make(map[string]bool, len(existing))
+		for _, gauge := range existing {
+			names[gaugeKey(gauge.OwnerID, gauge.Name)] = true
+		}
+
+		for i, gauge := range gauges {
+			name := gaugeKey(gauge.OwnerID, gauge.Name)
+			if names[name] {
+				result.Duplicates = append(result.Duplicates, i)
+				continue
+			}
+			if _, err := q.CreateGauge(ctx, gauge); err != nil {
+				return err
+			}
+			names[name] = true
+			result.Imported++
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+
+	return result, err
+}
+
+// gaugeKey identifies a gauge by its owner and name, ignoring case, which is
+// how imports and merged backups match the gauges already here
+func gaugeKey(owner sql.NullInt64, name string) string {
+	return fmt.Sprintf("%d/%t/%s", owner.Int64, owner.Valid, strings.ToLower(name))
+}
@@ -0,0 +1,125 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ImportGaugeValues(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+
+	t.Run("adds values and skips duplicates", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		now := time.Now().UTC().Truncate(time.Second)
+		values := []db.ImportedValue{
+			{GaugeID: gauge.ID, Delta: 2, Date: now.Add(-time.Minute)},
+			{GaugeID: gauge.ID, Delta: 3, Date: now, Source: db.SourceAPI},
+		}
+
+		result, err := store.ImportGaugeValues(ctx, values, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Imported)
+		assert.Empty(t, result.Duplicates)
+
+		updated, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, updated.Value)
+
+		logged, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		require.Len(t, logged, 2)
+		sources := []string{logged[0].Source, logged[1].Source}
+		assert.ElementsMatch(t, []string{db.SourceImport, db.SourceAPI}, sources)
+
+		// Importing the same values again changes nothing
+		result, err = store.ImportGaugeValues(ctx, values, false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.Imported)
+		assert.Equal(t, []int{0, 1}, result.Duplicates)
+
+		updated, err = store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, updated.Value)
+	})
+
+	t.Run("dry run keeps nothing", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		values := []db.ImportedValue{
+			{GaugeID: gauge.ID, Delta: 4, Date: time.Now().UTC()},
+			{GaugeID: gauge.ID, Delta: 4, Date: time.Now().UTC().Add(-time.Second)},
+		}
+
+		result, err := store.ImportGaugeValues(ctx, values, true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Imported)
+
+		updated, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, updated.Value)
+
+		logged, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Empty(t, logged)
+	})
+
+	t.Run("values before the active period leave its value alone", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		date := time.Now().UTC().AddDate(0, 0, -30)
+
+		result, err := store.ImportGaugeValues(ctx, []db.ImportedValue{
+			{GaugeID: gauge.ID, Delta: 7, Date: date},
+		}, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+
+		updated, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, updated.Value)
+
+		logged, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		require.Len(t, logged, 1)
+		assert.Equal(t, 7.0, logged[0].Delta)
+		assert.WithinDuration(t, date, logged[0].Date, time.Millisecond)
+	})
+}
+
+func TestStore_ImportGauges(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	existing := testutil.CreateTestGauge(t, store)
+
+	params := func(name string) db.CreateGaugeParams {
+		return db.CreateGaugeParams{
+			Name: name, Target: 8, Unit: "glasses", Icon: "water",
+			Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC",
+			GoalType: "at_least", Step: 1,
+		}
+	}
+	gauges := []db.CreateGaugeParams{params("Water"), params("water"), params(existing.Name)}
+
+	result, err := store.ImportGauges(ctx, gauges, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, []int{1, 2}, result.Duplicates)
+
+	list, err := store.ListGauges(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1, "a dry run creates no gauges")
+
+	result, err = store.ImportGauges(ctx, gauges, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+
+	list, err = store.ListGauges(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SourceLegacy marks gauge values carried over from a database created
+// before migrations existed
+const SourceLegacy = "legacy"
+
+// Layouts the app used before schema changes went through migrations
+const (
+	// LegacyWeekly stored one gauge_values row per ISO week and year
+	LegacyWeekly = "weekly"
+	// LegacyMeasurements stored timestamped readings in a measurements table.
+	// A database that also kept weekly or dated gauge_values, as when
+	// migrate.go ran over one made by init.go, is reported as
+	// "measurements+weekly" or "measurements+dated".
+	LegacyMeasurements = "measurements"
+	// LegacyDated stored dated gauge_values rows, with or without the later
+	// delta, source, period and goal columns
+	LegacyDated = "dated"
+	// LegacyGaugesOnly had a gauges table and nothing else
+	LegacyGaugesOnly = "gauges"
+)
+
+// legacyTables are the tables a legacy layout may have, in the order they
+// can be dropped without breaking foreign keys
+var legacyTables = []string{"gauge_periods", "gauge_values", "measurements", "gauges"}
+
+// ownedByGauge skips rows of gauges that were deleted while foreign keys
+// went unenforced. Those rows were unreachable, and the new tables would
+// reject them.
+const ownedByGauge = "gauge_id IN (SELECT id FROM legacy_gauges)"
+
+// legacyLayout is an unmigrated database found by detectLegacyLayout. Its
+// tables are set aside under a legacy_ prefix while the first migration
+// creates the new ones, then copied across and dropped.
+type legacyLayout struct {
+	name string
+	// values is the layout of the gauge_values table, LegacyWeekly or
+	// LegacyDated, or empty when there is none
+	values string
+	// tables maps each legacy table that exists to its columns
+	tables map[string]columnSet
+}
+
+type columnSet map[string]bool
+
+// column returns the named column of the legacy table, falling back to
+// expr when the column is missing or NULL
+func (c columnSet) column(name, expr string) string {
+	if !c[name] {
+		return expr
+	}
+	return fmt.Sprintf("COALESCE(%s, %s)", name, expr)
+}
+
+// detectLegacyLayout inspects a database with no recorded migrations,
+// returning nil when it has no gauges table to adopt
+func detectLegacyLayout(ctx context.Context, tx *sql.Tx) (*legacyLayout, error) {
+	layout := &legacyLayout{tables: make(map[string]columnSet)}
+	for _, table := range legacyTables {
+		columns, err := tableColumns(ctx, tx, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) > 0 {
+			layout.tables[table] = columns
+		}
+	}
+
+	if layout.tables["gauges"] == nil {
+		return nil, nil
+	}
+
+	// Both kinds of values are kept when a database has both, and one this
+	// cannot copy is refused before anything is changed
+	values := layout.tables["gauge_values"]
+	switch {
+	case values == nil:
+	case values["week"] && values["year"]:
+		layout.values = LegacyWeekly
+	case values["date"]:
+		layout.values = LegacyDated
+	default:
+		return nil, fmt.Errorf("unrecognised gauge_values table in unmigrated database")
+	}
+
+	switch {
+	case layout.tables["measurements"] != nil && layout.values != "":
+		layout.name = LegacyMeasurements + "+" + layout.values
+	case layout.tables["measurements"] != nil:
+		layout.name = LegacyMeasurements
+	case layout.values != "":
+		layout.name = layout.values
+	default:
+		layout.name = LegacyGaugesOnly
+	}
+	return layout, nil
+}
+
+// setAside renames the legacy tables out of the way of the first migration.
+// Their indexes are dropped because index names would otherwise clash.
+func (l *legacyLayout) setAside(ctx context.Context, tx *sql.Tx) error {
+	for _, table := range legacyTables {
+		if l.tables[table] == nil {
+			continue
+		}
+
+		indexes, err := tableIndexes(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP INDEX %q`, index)); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %q RENAME TO %q`, table, "legacy_"+table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore copies the legacy rows into the freshly created tables, keeping
+// their IDs, and drops the legacy tables
+func (l *legacyLayout) restore(ctx context.Context, tx *sql.Tx) error {
+	statements := []string{l.copyGauges()}
+	switch l.values {
+	case LegacyWeekly:
+		statements = append(statements, l.copyWeeklyValues())
+	case LegacyDated:
+		statements = append(statements, l.copyDatedValues())
+	}
+	if l.tables["measurements"] != nil {
+		statements = append(statements, l.copyMeasurements(l.values == ""))
+	}
+	if l.tables["gauge_periods"] != nil {
+		statements = append(statements, l.copyPeriods())
+	}
+
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+
+	for _, table := range legacyTables {
+		if l.tables[table] == nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %q`, "legacy_"+table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyGauges copies gauges, defaulting every column the layout predates.
+// Gauges without a stored value start at zero with no active period, so the
+// next rollover totals the current period from the copied values.
+func (l *legacyLayout) copyGauges() string {
+	g := l.tables["gauges"]
+	return insertSelect("gauges", "legacy_gauges", [][2]string{
+		{"id", "id"},
+		{"name", "name"},
+		{"description", "description"},
+		{"target", "CAST(target AS REAL)"},
+		{"value", g.column("value", "0")},
+		{"unit", "unit"},
+		{"icon", g.column("icon", "'chart-bar'")},
+		{"created_at", g.column("created_at", "CURRENT_TIMESTAMP")},
+		{"updated_at", g.column("updated_at", "CURRENT_TIMESTAMP")},
+		{"period", g.column("period", "'week'")},
+		{"period_days", g.column("period_days", "7")},
+		{"week_start", g.column("week_start", "1")},
+		{"timezone", g.column("timezone", "'UTC'")},
+		{"period_start", g.column("period_start", "NULL")},
+		{"goal_type", g.column("goal_type", "'at_most'")},
+		{"target_min", g.column("target_min", "0")},
+	})
+}
+
+// copyWeeklyValues dates each weekly row at the Monday of its ISO week.
+// January 4th always falls in week 1, so week 1 starts on the Monday on or
+// before it.
+func (l *legacyLayout) copyWeeklyValues() string {
+	monday := `printf('%04d-01-04', year), 'weekday 0', '-6 days', printf('+%d days', (week - 1) * 7)`
+	return insertSelectWhere("gauge_values", "legacy_gauge_values", [][2]string{
+		{"id", "id"},
+		{"gauge_id", "gauge_id"},
+		{"value", "CAST(value AS REAL)"},
+		{"delta", "CAST(value AS REAL)"},
+		{"source", "'" + SourceLegacy + "'"},
+		{"date", sqliteTime("strftime('%Y-%m-%d %H:%M:%S', " + monday + ")")},
+	}, ownedByGauge)
+}
+
+// copyMeasurements turns each reading into a gauge value logged at its
+// timestamp. Timestamps SQLite cannot parse are kept as they are. The
+// readings keep their IDs unless gauge_values rows were copied too, whose
+// IDs they would clash with.
+func (l *legacyLayout) copyMeasurements(keepIDs bool) string {
+	now := sqliteTime("strftime('%Y-%m-%d %H:%M:%S', 'now')")
+	date := now
+	if l.tables["measurements"]["timestamp"] {
+		date = fmt.Sprintf("COALESCE(%s, timestamp, %s)", sqliteTime("strftime('%Y-%m-%d %H:%M:%S', timestamp)"), now)
+	}
+	columns := [][2]string{
+		{"gauge_id", "gauge_id"},
+		{"value", "value"},
+		{"delta", "value"},
+		{"source", "'" + SourceLegacy + "'"},
+		{"date", date},
+	}
+	if keepIDs {
+		columns = append([][2]string{{"id", "id"}}, columns...)
+	}
+	return insertSelectWhere("gauge_values", "legacy_measurements", columns, ownedByGauge)
+}
+
+// copyDatedValues copies dated values. Rows written before deltas were
+// recorded are taken to be changes of their full value.
+func (l *legacyLayout) copyDatedValues() string {
+	v := l.tables["gauge_values"]
+	return insertSelectWhere("gauge_values", "legacy_gauge_values", [][2]string{
+		{"id", "id"},
+		{"gauge_id", "gauge_id"},
+		{"value", "value"},
+		{"delta", v.column("delta", "value")},
+		{"source", v.column("source", "'"+SourceLegacy+"'")},
+		{"date", "date"},
+	}, ownedByGauge)
+}
+
+func (l *legacyLayout) copyPeriods() string {
+	p := l.tables["gauge_periods"]
+	return insertSelectWhere("gauge_periods", "legacy_gauge_periods", [][2]string{
+		{"id", "id"},
+		{"gauge_id", "gauge_id"},
+		{"period_start", "period_start"},
+		{"period_end", "period_end"},
+		{"value", "value"},
+		{"target", "target"},
+		{"closed_at", p.column("closed_at", "CURRENT_TIMESTAMP")},
+	}, ownedByGauge)
+}
+
+// insertSelect builds an INSERT ... SELECT from pairs of destination column
+// and source expression
+func insertSelect(table, from string, columns [][2]string) string {
+	return insertSelectWhere(table, from, columns, "")
+}
+
+// insertSelectWhere is insertSelect with a WHERE clause
+func insertSelectWhere(table, from string, columns [][2]string, where string) string {
+	names := make([]string, len(columns))
+	exprs := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c[0]
+		exprs[i] = c[1]
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+		table, strings.Join(names, ", "), strings.Join(exprs, ", "), from)
+	if where != "" {
+		stmt += " WHERE " + where
+	}
+	return stmt
+}
+
+// sqliteTime appends the UTC offset the driver writes for time.Time values,
+// so copied dates compare correctly with ones bound as query parameters
+func sqliteTime(expr string) string {
+	return expr + " || '+00:00'"
+}
+
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) (columnSet, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(columnSet)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+func tableIndexes(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, name)
+	}
+	return indexes, rows.Err()
+}
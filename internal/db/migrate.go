@@ -1,53 +1,368 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 )
 
-func Migrate(db *sql.DB) error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS gauges (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT,
-			target REAL NOT NULL,
-			unit TEXT NOT NULL,
-			icon TEXT DEFAULT 'chart-bar'
-		)`,
-		`CREATE TABLE IF NOT EXISTS measurements (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			gauge_id INTEGER NOT NULL,
-			value REAL NOT NULL,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (gauge_id) REFERENCES gauges(id)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_measurements_gauge_id ON measurements(gauge_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_measurements_timestamp ON measurements(timestamp)`,
-	}
-
-	for _, migration := range migrations {
-		_, err := db.Exec(migration)
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change, loaded from a pair of
+// NNNN_name.up.sql and NNNN_name.down.sql files
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the migration's up script so that editing a migration
+// after it has been applied is caught
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrationReport describes what Up or Down changed, or would have changed
+// in a dry run
+type MigrationReport struct {
+	// Legacy names the pre-migration layout that was adopted, if any
+	Legacy     string
+	Applied    []Migration
+	RolledBack []Migration
+	DryRun     bool
+}
+
+// ErrChecksumMismatch is returned when an applied migration no longer
+// matches the embedded file
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// ErrUnknownMigration is returned when the database has a migration this
+// build does not know about, usually because it was migrated by a newer one
+var ErrUnknownMigration = errors.New("unknown migration")
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads migrations from the top level of fsys, ordered by
+// version. Every migration needs an up script; the down script is optional.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
 		if err != nil {
-			return fmt.Errorf("%w\n%s", err, migration)
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
 		}
+		migrations = append(migrations, *m)
 	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, recording each one in the
+// schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// DryRun runs every step in a transaction that is rolled back, so the
+	// report shows what would change without changing anything
+	DryRun bool
+}
+
+// NewMigrator creates a Migrator for the migrations embedded in the binary
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return NewMigratorFor(db, migrations), nil
+}
+
+// NewMigratorFor creates a Migrator for the given migrations
+func NewMigratorFor(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Migrate brings the database up to date, adopting a legacy layout if it
+// finds one. It is run when the server starts.
+func Migrate(ctx context.Context, db *sql.DB) (*MigrationReport, error) {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	return m.Up(ctx)
+}
+
+// Up applies every pending migration in one transaction. A database created
+// before migrations existed has its tables upgraded in place when the first
+// migration runs.
+func (m *Migrator) Up(ctx context.Context) (*MigrationReport, error) {
+	report := &MigrationReport{DryRun: m.DryRun}
+
+	err := m.execTx(ctx, func(tx *sql.Tx) error {
+		if err := createMigrationsTable(ctx, tx); err != nil {
+			return err
+		}
+		applied, err := m.verify(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		var legacy *legacyLayout
+		if len(applied) == 0 {
+			legacy, err = detectLegacyLayout(ctx, tx)
+			if err != nil {
+				return err
+			}
+		}
+		if legacy != nil {
+			report.Legacy = legacy.name
+			if err := legacy.setAside(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
 
-	// Check if icon column exists, if not add it
-	var hasIconColumn bool
-	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('gauges') WHERE name='icon'").Scan(&hasIconColumn)
+			// Legacy data is restored into the first schema so that later
+			// migrations upgrade it like any other data
+			if legacy != nil {
+				if err := legacy.restore(ctx, tx); err != nil {
+					return fmt.Errorf("adopting %s layout: %w", legacy.name, err)
+				}
+				legacy = nil
+			}
+
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+				migration.Version, migration.Name, migration.Checksum(), time.Now().UTC(),
+			)
+			if err != nil {
+				return err
+			}
+			report.Applied = append(report.Applied, migration)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error checking for icon column: %w", err)
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Down rolls back the most recently applied migrations, at most steps of
+// them, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) (*MigrationReport, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1, got %d", steps)
 	}
+	report := &MigrationReport{DryRun: m.DryRun}
 
-	if !hasIconColumn {
-		log.Println("Adding icon column to gauges table...")
-		_, err := db.Exec(`ALTER TABLE gauges ADD COLUMN icon TEXT DEFAULT 'chart-bar'`)
+	err := m.execTx(ctx, func(tx *sql.Tx) error {
+		if err := createMigrationsTable(ctx, tx); err != nil {
+			return err
+		}
+		applied, err := m.verify(ctx, tx)
 		if err != nil {
-			return fmt.Errorf("error adding icon column: %w", err)
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(report.RolledBack) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be rolled back", migration.Version, migration.Name)
+			}
+			if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+			if err != nil {
+				return err
+			}
+			report.RolledBack = append(report.RolledBack, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	// Status never writes, so the transaction is always rolled back
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := createMigrationsTable(ctx, tx); err != nil {
+		return nil, err
+	}
+	applied, err := m.verify(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// verify loads the applied migrations and checks each against the embedded
+// files
+func (m *Migrator) verify(ctx context.Context, tx *sql.Tx) (map[int]appliedMigration, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var record appliedMigration
+		if err := rows.Scan(&version, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for version, record := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d is applied but not embedded", ErrUnknownMigration, version)
+		}
+		if migration.Checksum() != record.checksum {
+			return nil, fmt.Errorf("%w: %d_%s has changed since it was applied", ErrChecksumMismatch, version, migration.Name)
 		}
 	}
 
-	return nil
+	return applied, nil
+}
+
+// execTx runs fn in a transaction, which is rolled back in a dry run
+func (m *Migrator) execTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx error: %v, rollback error: %v", err, rbErr)
+		}
+		return err
+	}
+
+	if m.DryRun {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+func createMigrationsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	return err
 }
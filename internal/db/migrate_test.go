@@ -0,0 +1,326 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openMigrateTestDB(t *testing.T) *sql.DB {
+	db, err := Open(DefaultConfig(":memory:"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	require.NoError(t, err)
+	return n > 0
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_notes.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN notes TEXT;")},
+		"0002_add_notes.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN notes;")},
+		"0001_create_t.up.sql":    {Data: []byte("CREATE TABLE t (id INTEGER);")},
+		"README.md":               {Data: []byte("ignored")},
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_t", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, 2, migrations[1].Version)
+	assert.Equal(t, "ALTER TABLE t DROP COLUMN notes;", migrations[1].Down)
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+	})
+	assert.Error(t, err, "a migration without an up script is rejected")
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("applies pending migrations once", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+
+		report, err := Migrate(ctx, db)
+		require.NoError(t, err)
+		assert.NotEmpty(t, report.Applied)
+		assert.Empty(t, report.Legacy)
+		assert.True(t, tableExists(t, db, "gauges"))
+
+		report, err = Migrate(ctx, db)
+		require.NoError(t, err)
+		assert.Empty(t, report.Applied)
+
+		m, err := NewMigrator(db)
+		require.NoError(t, err)
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		for _, s := range statuses {
+			assert.True(t, s.Applied, "migration %d", s.Version)
+			assert.False(t, s.AppliedAt.IsZero())
+		}
+	})
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		m, err := NewMigrator(db)
+		require.NoError(t, err)
+		m.DryRun = true
+
+		report, err := m.Up(ctx)
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.NotEmpty(t, report.Applied)
+		assert.False(t, tableExists(t, db, "gauges"))
+		assert.False(t, tableExists(t, db, "schema_migrations"))
+	})
+
+	t.Run("down rolls back the latest migrations", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		m, err := NewMigrator(db)
+		require.NoError(t, err)
+		_, err = m.Up(ctx)
+		require.NoError(t, err)
+
+		report, err := m.Down(ctx, 100)
+		require.NoError(t, err)
+		assert.NotEmpty(t, report.RolledBack)
+		assert.False(t, tableExists(t, db, "gauges"))
+
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		for _, s := range statuses {
+			assert.False(t, s.Applied)
+		}
+
+		_, err = m.Down(ctx, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects edited and unknown migrations", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		original := []Migration{{Version: 1, Name: "create_t", Up: "CREATE TABLE t (id INTEGER);", Down: "DROP TABLE t;"}}
+		_, err := NewMigratorFor(db, original).Up(ctx)
+		require.NoError(t, err)
+
+		edited := []Migration{{Version: 1, Name: "create_t", Up: "CREATE TABLE t (id INTEGER, name TEXT);"}}
+		_, err = NewMigratorFor(db, edited).Up(ctx)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+		_, err = NewMigratorFor(db, nil).Up(ctx)
+		assert.ErrorIs(t, err, ErrUnknownMigration)
+	})
+
+	t.Run("failed migration leaves database untouched", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		migrations := []Migration{
+			{Version: 1, Name: "create_t", Up: "CREATE TABLE t (id INTEGER);"},
+			{Version: 2, Name: "broken", Up: "ALTER TABLE missing ADD COLUMN x TEXT;"},
+		}
+		_, err := NewMigratorFor(db, migrations).Up(ctx)
+		assert.Error(t, err)
+		assert.False(t, tableExists(t, db, "t"))
+	})
+}
+
+func TestMigrate_AdoptsLegacyLayouts(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		legacy string
+		schema string
+		// values are the expected deltas, oldest first
+		values []float64
+		// firstDate is the expected date of the oldest value
+		firstDate time.Time
+		source    string
+	}{
+		{
+			name:   "weekly values from init.go",
+			legacy: LegacyWeekly,
+			schema: `
+				CREATE TABLE gauges (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT, target INTEGER NOT NULL, unit TEXT NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+				CREATE TABLE gauge_values (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value INTEGER NOT NULL, week INTEGER NOT NULL, year INTEGER NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE);
+				CREATE INDEX idx_gauge_values_week_year ON gauge_values(week, year);
+				CREATE INDEX idx_gauge_values_gauge_id ON gauge_values(gauge_id);
+				INSERT INTO gauges (id, name, target, unit) VALUES (7, 'Coffee', 14, 'cups');
+				INSERT INTO gauge_values (gauge_id, value, week, year) VALUES (7, 12, 1, 2021), (7, 9, 2, 2021);`,
+			values: []float64{12, 9},
+			// ISO week 1 of 2021 starts on Monday 4 January
+			firstDate: time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC),
+			source:    SourceLegacy,
+		},
+		{
+			name:   "measurements from migrate.go",
+			legacy: LegacyMeasurements,
+			schema: `
+				CREATE TABLE gauges (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT, target REAL NOT NULL, unit TEXT NOT NULL, icon TEXT DEFAULT 'chart-bar');
+				CREATE TABLE measurements (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value REAL NOT NULL, timestamp DATETIME DEFAULT CURRENT_TIMESTAMP, FOREIGN KEY (gauge_id) REFERENCES gauges(id));
+				CREATE INDEX idx_measurements_gauge_id ON measurements(gauge_id);
+				CREATE INDEX idx_measurements_timestamp ON measurements(timestamp);
+				INSERT INTO gauges (id, name, target, unit, icon) VALUES (7, 'Coffee', 14, 'cups', 'coffee');
+				INSERT INTO measurements (gauge_id, value, timestamp) VALUES (7, 2, '2024-05-01 08:30:00'), (7, 1.5, '2024-05-02 09:00:00');
+				-- Left behind by a deleted gauge, foreign keys were never enforced
+				PRAGMA foreign_keys = OFF;
+				INSERT INTO measurements (gauge_id, value, timestamp) VALUES (99, 5, '2024-05-01 08:00:00');
+				PRAGMA foreign_keys = ON;`,
+			values:    []float64{2, 1.5},
+			firstDate: time.Date(2024, time.May, 1, 8, 30, 0, 0, time.UTC),
+			source:    SourceLegacy,
+		},
+		{
+			name:   "weekly values and measurements from migrate.go run over init.go",
+			legacy: LegacyMeasurements + "+" + LegacyWeekly,
+			schema: `
+				CREATE TABLE gauges (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT, target INTEGER NOT NULL, unit TEXT NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+				CREATE TABLE gauge_values (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value INTEGER NOT NULL, week INTEGER NOT NULL, year INTEGER NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE);
+				CREATE TABLE measurements (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value REAL NOT NULL, timestamp DATETIME DEFAULT CURRENT_TIMESTAMP, FOREIGN KEY (gauge_id) REFERENCES gauges(id));
+				CREATE INDEX idx_measurements_gauge_id ON measurements(gauge_id);
+				INSERT INTO gauges (id, name, target, unit) VALUES (7, 'Coffee', 14, 'cups');
+				INSERT INTO gauge_values (gauge_id, value, week, year) VALUES (7, 12, 1, 2021), (7, 9, 2, 2021);
+				INSERT INTO measurements (gauge_id, value, timestamp) VALUES (7, 2, '2024-05-01 08:30:00');`,
+			values:    []float64{12, 9, 2},
+			firstDate: time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC),
+			source:    SourceLegacy,
+		},
+		{
+			name:   "dated values from the original schema.sql",
+			legacy: LegacyDated,
+			schema: `
+				CREATE TABLE gauges (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT, target REAL NOT NULL, value REAL NOT NULL DEFAULT 0, unit TEXT NOT NULL, icon TEXT NOT NULL DEFAULT 'chart-bar', created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+				CREATE TABLE gauge_values (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value REAL NOT NULL, date DATETIME NOT NULL, FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE);
+				INSERT INTO gauges (id, name, target, value, unit) VALUES (7, 'Coffee', 14, 3, 'cups');
+				INSERT INTO gauge_values (gauge_id, value, date) VALUES (7, 4, '2023-02-01 10:00:00+00:00'), (7, 3, '2023-02-02 10:00:00+00:00');`,
+			values:    []float64{4, 3},
+			firstDate: time.Date(2023, time.February, 1, 10, 0, 0, 0, time.UTC),
+			source:    SourceLegacy,
+		},
+		{
+			name:   "dated values with deltas and periods",
+			legacy: LegacyDated,
+			schema: `
+				CREATE TABLE gauges (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, description TEXT, target REAL NOT NULL, value REAL NOT NULL DEFAULT 0, unit TEXT NOT NULL, icon TEXT NOT NULL DEFAULT 'chart-bar', created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP, period TEXT NOT NULL DEFAULT 'week', period_days INTEGER NOT NULL DEFAULT 7, week_start INTEGER NOT NULL DEFAULT 1, timezone TEXT NOT NULL DEFAULT 'UTC', period_start DATETIME);
+				CREATE TABLE gauge_values (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value REAL NOT NULL, delta REAL NOT NULL DEFAULT 0, source TEXT NOT NULL DEFAULT 'web', date DATETIME NOT NULL, FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE);
+				CREATE INDEX idx_gauge_values_gauge_id_date ON gauge_values(gauge_id, date);
+				CREATE TABLE gauge_periods (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, period_start DATETIME NOT NULL, period_end DATETIME NOT NULL, value REAL NOT NULL, target REAL NOT NULL, closed_at DATETIME DEFAULT CURRENT_TIMESTAMP, FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE);
+				CREATE UNIQUE INDEX idx_gauge_periods_gauge_id_period_start ON gauge_periods(gauge_id, period_start);
+				INSERT INTO gauges (id, name, target, value, unit, period, timezone) VALUES (7, 'Coffee', 14, 5, 'cups', 'day', 'Europe/Paris');
+				INSERT INTO gauge_values (gauge_id, value, delta, source, date) VALUES (7, 2, 2, 'api', '2025-01-01 10:00:00+00:00'), (7, 5, 3, 'api', '2025-01-01 12:00:00+00:00');
+				INSERT INTO gauge_periods (gauge_id, period_start, period_end, value, target) VALUES (7, '2024-12-31 00:00:00+00:00', '2025-01-01 00:00:00+00:00', 9, 14);`,
+			values:    []float64{2, 3},
+			firstDate: time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC),
+			source:    "api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openMigrateTestDB(t)
+			_, err := db.Exec(tt.schema)
+			require.NoError(t, err)
+
+			report, err := Migrate(ctx, db)
+			require.NoError(t, err)
+			assert.Equal(t, tt.legacy, report.Legacy)
+			for _, table := range legacyTables {
+				assert.False(t, tableExists(t, db, "legacy_"+table))
+			}
+
+			q := New(db)
+			gauge, err := q.GetGauge(ctx, 7)
+			require.NoError(t, err)
+			assert.Equal(t, "Coffee", gauge.Name)
+			assert.Equal(t, 14.0, gauge.Target)
+			assert.Equal(t, "at_most", gauge.GoalType)
+
+			values, err := q.ListGaugeValuesInRange(ctx, ListGaugeValuesInRangeParams{
+				GaugeID:  gauge.ID,
+				FromDate: tt.firstDate,
+				ToDate:   time.Now().UTC(),
+			})
+			require.NoError(t, err)
+			require.Len(t, values, len(tt.values))
+			for i, v := range values {
+				assert.Equal(t, tt.values[i], v.Delta)
+				assert.Equal(t, tt.source, v.Source)
+			}
+			assert.True(t, tt.firstDate.Equal(values[0].Date), "first value dated %s", values[0].Date)
+
+			// New rows continue after the adopted IDs
+			created, err := q.CreateGauge(ctx, CreateGaugeParams{
+				Name: "Water", Target: 8, Unit: "glasses", Icon: "water",
+				Period: "week", PeriodDays: 7, WeekStart: 1, Timezone: "UTC", GoalType: "at_most",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int64(8), created.ID)
+		})
+	}
+
+	t.Run("keeps gauge settings and closed periods", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		_, err := db.Exec(tests[4].schema)
+		require.NoError(t, err)
+		_, err = Migrate(ctx, db)
+		require.NoError(t, err)
+
+		q := New(db)
+		gauge, err := q.GetGauge(ctx, 7)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, gauge.Value)
+		assert.Equal(t, "day", gauge.Period)
+		assert.Equal(t, "Europe/Paris", gauge.Timezone)
+
+		periods, err := q.ListGaugePeriods(ctx, ListGaugePeriodsParams{GaugeID: 7, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, periods, 1)
+		assert.Equal(t, 9.0, periods[0].Value)
+	})
+
+	t.Run("dry run leaves the legacy tables in place", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		_, err := db.Exec(tests[0].schema)
+		require.NoError(t, err)
+
+		m, err := NewMigrator(db)
+		require.NoError(t, err)
+		m.DryRun = true
+		report, err := m.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, LegacyWeekly, report.Legacy)
+
+		var weeks int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM gauge_values WHERE week > 0`).Scan(&weeks))
+		assert.Equal(t, 2, weeks)
+	})
+
+	t.Run("refuses values it cannot copy", func(t *testing.T) {
+		db := openMigrateTestDB(t)
+		_, err := db.Exec(`
+			CREATE TABLE gauges (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, target REAL NOT NULL, unit TEXT NOT NULL);
+			CREATE TABLE gauge_values (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value REAL NOT NULL);
+			CREATE TABLE measurements (id INTEGER PRIMARY KEY AUTOINCREMENT, gauge_id INTEGER NOT NULL, value REAL NOT NULL);
+			INSERT INTO gauges (id, name, target, unit) VALUES (7, 'Coffee', 14, 'cups');
+			INSERT INTO gauge_values (gauge_id, value) VALUES (7, 12);`)
+		require.NoError(t, err)
+
+		_, err = Migrate(ctx, db)
+		assert.ErrorContains(t, err, "unrecognised gauge_values")
+		var values int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM gauge_values`).Scan(&values))
+		assert.Equal(t, 1, values)
+	})
+}
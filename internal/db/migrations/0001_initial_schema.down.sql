@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS gauge_periods;
+DROP TABLE IF EXISTS gauge_values;
+DROP TABLE IF EXISTS gauges;
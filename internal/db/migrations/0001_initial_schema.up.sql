@@ -0,0 +1,43 @@
+CREATE TABLE gauges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    target REAL NOT NULL,
+    value REAL NOT NULL DEFAULT 0,
+    unit TEXT NOT NULL,
+    icon TEXT NOT NULL DEFAULT 'chart-bar',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    period TEXT NOT NULL DEFAULT 'week',
+    period_days INTEGER NOT NULL DEFAULT 7,
+    week_start INTEGER NOT NULL DEFAULT 1,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    period_start DATETIME,
+    goal_type TEXT NOT NULL DEFAULT 'at_most',
+    target_min REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE gauge_values (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    gauge_id INTEGER NOT NULL,
+    value REAL NOT NULL,
+    delta REAL NOT NULL DEFAULT 0,
+    source TEXT NOT NULL DEFAULT 'web',
+    date DATETIME NOT NULL,
+    FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_gauge_values_gauge_id_date ON gauge_values(gauge_id, date);
+
+CREATE TABLE gauge_periods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    gauge_id INTEGER NOT NULL,
+    period_start DATETIME NOT NULL,
+    period_end DATETIME NOT NULL,
+    value REAL NOT NULL,
+    target REAL NOT NULL,
+    closed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_gauge_periods_gauge_id_period_start ON gauge_periods(gauge_id, period_start);
@@ -0,0 +1 @@
+ALTER TABLE gauges DROP COLUMN version;
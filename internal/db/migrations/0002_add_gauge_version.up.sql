@@ -0,0 +1,2 @@
+-- Incremented on every write so clients can detect concurrent edits
+ALTER TABLE gauges ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
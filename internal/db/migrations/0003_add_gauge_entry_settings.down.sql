@@ -0,0 +1,5 @@
+ALTER TABLE gauges DROP COLUMN decimals;
+ALTER TABLE gauges DROP COLUMN max_value;
+ALTER TABLE gauges DROP COLUMN min_value;
+ALTER TABLE gauges DROP COLUMN presets;
+ALTER TABLE gauges DROP COLUMN step;
@@ -0,0 +1,10 @@
+-- How values are entered: the amount the +/- buttons change the value by,
+-- quick-add amounts, the range of amounts accepted and their decimal places
+ALTER TABLE gauges ADD COLUMN step REAL NOT NULL DEFAULT 1;
+ALTER TABLE gauges ADD COLUMN presets TEXT NOT NULL DEFAULT '';
+ALTER TABLE gauges ADD COLUMN min_value REAL;
+ALTER TABLE gauges ADD COLUMN max_value REAL;
+ALTER TABLE gauges ADD COLUMN decimals INTEGER NOT NULL DEFAULT 0;
+
+-- Existing gauges with fractional targets keep them valid
+UPDATE gauges SET decimals = 2 WHERE target <> ROUND(target) OR target_min <> ROUND(target_min);
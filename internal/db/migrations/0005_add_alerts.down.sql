@@ -0,0 +1,3 @@
+DROP TABLE alert_history;
+DROP TABLE alert_rules;
+DROP TABLE notification_channels;
@@ -0,0 +1,45 @@
+-- Alerts: where notifications go, the rules that send them and a history of
+-- the alerts sent. Each channel may hold its notifications back during quiet
+-- hours, written as HH:MM in the time zone of the gauge alerted on.
+CREATE TABLE notification_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    target TEXT NOT NULL,
+    quiet_start TEXT NOT NULL DEFAULT '',
+    quiet_end TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Threshold is a percent of the target for below_percent rules and a number
+-- of days for no_entry rules. check_at is the HH:MM a below_percent rule is
+-- checked from each day.
+CREATE TABLE alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    gauge_id INTEGER NOT NULL,
+    channel_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    threshold REAL NOT NULL DEFAULT 0,
+    check_at TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (gauge_id) REFERENCES gauges(id) ON DELETE CASCADE,
+    FOREIGN KEY (channel_id) REFERENCES notification_channels(id) ON DELETE CASCADE
+);
+
+-- An alert fires once per rule and occasion, such as a day or a period; the
+-- unique key is what stops it repeating
+CREATE TABLE alert_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rule_id INTEGER NOT NULL,
+    occasion TEXT NOT NULL,
+    message TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    fired_at DATETIME NOT NULL,
+    FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE,
+    UNIQUE (rule_id, occasion)
+);
+
+CREATE INDEX idx_alert_rules_gauge_id ON alert_rules(gauge_id);
+CREATE INDEX idx_alert_history_fired_at ON alert_history(fired_at);
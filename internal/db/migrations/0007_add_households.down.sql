@@ -0,0 +1,6 @@
+DROP INDEX idx_gauges_household_id;
+DROP INDEX idx_gauges_owner_id;
+ALTER TABLE gauges DROP COLUMN household_id;
+ALTER TABLE gauges DROP COLUMN owner_id;
+DROP TABLE household_members;
+DROP TABLE households;
@@ -0,0 +1,29 @@
+-- Gauges belong to the user who created them and may be shared with one
+-- household. Each member of a household has a role there: owner, editor or
+-- viewer.
+CREATE TABLE households (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE household_members (
+    household_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (household_id, user_id),
+    FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE gauges ADD COLUMN owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE gauges ADD COLUMN household_id INTEGER REFERENCES households(id) ON DELETE SET NULL;
+
+-- Gauges from before there were owners go to the first admin. Until there
+-- is one they belong to nobody, and every admin may manage them.
+UPDATE gauges SET owner_id = (SELECT MIN(id) FROM users WHERE is_admin);
+
+CREATE INDEX idx_household_members_user_id ON household_members(user_id);
+CREATE INDEX idx_gauges_owner_id ON gauges(owner_id);
+CREATE INDEX idx_gauges_household_id ON gauges(household_id);
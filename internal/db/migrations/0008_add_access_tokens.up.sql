@@ -0,0 +1,18 @@
+-- Personal access tokens let scripts and devices use the API as a user.
+-- Like sessions, a token is stored only as the SHA-256 of its secret; the
+-- prefix is kept so the user can tell their tokens apart. scopes is a comma
+-- separated list, and a token without expires_at never expires.
+CREATE TABLE access_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_access_tokens_user_id ON access_tokens(user_id);
@@ -0,0 +1,32 @@
+-- The audit log records who changed what: each gauge created, edited,
+-- shared or deleted and each change to a value, with the fields changed as
+-- a JSON array of {field, before, after}. The actor, gauge name and request
+-- are copied in, so entries outlive the users and gauges they mention. It
+-- is append-only; the triggers refuse to change or remove an entry.
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    user_id INTEGER,
+    username TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    entity_name TEXT NOT NULL DEFAULT '',
+    changes TEXT NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+CREATE INDEX idx_audit_log_user_id ON audit_log(user_id);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
@@ -9,7 +9,17 @@ type MockQueries struct {
 	DeleteGaugeFn       func(ctx context.Context, id int64) error
 	GetGaugeFn         func(ctx context.Context, id int64) (Gauge, error)
 	ListGaugesFn     func(ctx context.Context) ([]Gauge, error)
-	UpdateGaugeValueFn func(ctx context.Context, params UpdateGaugeValueParams) error
+	EditGaugeFn        func(ctx context.Context, params EditGaugeParams) (Gauge, error)
+	RecordGaugeValueFn func(ctx context.Context, params RecordGaugeValueParams) (Gauge, error)
+	SetGaugeValueFn    func(ctx context.Context, params SetGaugeValueParams) (Gauge, error)
+	ListGaugeValuesFn  func(ctx context.Context, params ListGaugeValuesParams) ([]GaugeValue, error)
+	CountGaugeValuesFn func(ctx context.Context, params CountGaugeValuesParams) (int64, error)
+	ListGaugeValuesInRangeFn func(ctx context.Context, params ListGaugeValuesInRangeParams) ([]GaugeValue, error)
+	GetGaugeHistoryFn  func(ctx context.Context, gaugeID int64) ([]GetGaugeHistoryRow, error)
+	ImportGaugesFn     func(ctx context.Context, gauges []CreateGaugeParams, dryRun bool) (ImportResult, error)
+	ImportGaugeValuesFn func(ctx context.Context, values []ImportedValue, dryRun bool) (ImportResult, error)
+	BackupFn           func(ctx context.Context) (*Backup, error)
+	RestoreFn          func(ctx context.Context, backup *Backup, mode RestoreMode) (RestoreResult, error)
 }
 
 func (m *MockQueries) CreateGauge(ctx context.Context, params CreateGaugeParams) (Gauge, error) {
@@ -32,6 +42,46 @@ func (m *MockQueries) ListGauges(ctx context.Context) ([]Gauge, error) {
 	return m.ListGaugesFn(ctx)
 }
 
-func (m *MockQueries) UpdateGaugeValue(ctx context.Context, params UpdateGaugeValueParams) error {
-	return m.UpdateGaugeValueFn(ctx, params)
+func (m *MockQueries) EditGauge(ctx context.Context, params EditGaugeParams) (Gauge, error) {
+	return m.EditGaugeFn(ctx, params)
+}
+
+func (m *MockQueries) RecordGaugeValue(ctx context.Context, params RecordGaugeValueParams) (Gauge, error) {
+	return m.RecordGaugeValueFn(ctx, params)
+}
+
+func (m *MockQueries) SetGaugeValue(ctx context.Context, params SetGaugeValueParams) (Gauge, error) {
+	return m.SetGaugeValueFn(ctx, params)
+}
+
+func (m *MockQueries) ListGaugeValues(ctx context.Context, params ListGaugeValuesParams) ([]GaugeValue, error) {
+	return m.ListGaugeValuesFn(ctx, params)
+}
+
+func (m *MockQueries) ListGaugeValuesInRange(ctx context.Context, params ListGaugeValuesInRangeParams) ([]GaugeValue, error) {
+	return m.ListGaugeValuesInRangeFn(ctx, params)
+}
+
+func (m *MockQueries) CountGaugeValues(ctx context.Context, params CountGaugeValuesParams) (int64, error) {
+	return m.CountGaugeValuesFn(ctx, params)
+}
+
+func (m *MockQueries) GetGaugeHistory(ctx context.Context, gaugeID int64) ([]GetGaugeHistoryRow, error) {
+	return m.GetGaugeHistoryFn(ctx, gaugeID)
+}
+
+func (m *MockQueries) ImportGauges(ctx context.Context, gauges []CreateGaugeParams, dryRun bool) (ImportResult, error) {
+	return m.ImportGaugesFn(ctx, gauges, dryRun)
+}
+
+func (m *MockQueries) ImportGaugeValues(ctx context.Context, values []ImportedValue, dryRun bool) (ImportResult, error) {
+	return m.ImportGaugeValuesFn(ctx, values, dryRun)
+}
+
+func (m *MockQueries) Backup(ctx context.Context) (*Backup, error) {
+	return m.BackupFn(ctx)
+}
+
+func (m *MockQueries) Restore(ctx context.Context, backup *Backup, mode RestoreMode) (RestoreResult, error) {
+	return m.RestoreFn(ctx, backup, mode)
 }
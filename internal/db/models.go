@@ -9,21 +9,162 @@ import (
 	"time"
 )
 
+type AccessToken struct {
+	ID         int64        `json:"id"`
+	UserID     int64        `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"token_hash"`
+	Prefix     string       `json:"prefix"`
+	Scopes     string       `json:"scopes"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+}
+
+type AlertHistory struct {
+	ID       int64     `json:"id"`
+	RuleID   int64     `json:"rule_id"`
+	Occasion string    `json:"occasion"`
+	Message  string    `json:"message"`
+	Error    string    `json:"error"`
+	FiredAt  time.Time `json:"fired_at"`
+}
+
+type AlertRule struct {
+	ID        int64     `json:"id"`
+	GaugeID   int64     `json:"gauge_id"`
+	ChannelID int64     `json:"channel_id"`
+	Kind      string    `json:"kind"`
+	Threshold float64   `json:"threshold"`
+	CheckAt   string    `json:"check_at"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditLog struct {
+	ID         int64         `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UserID     sql.NullInt64 `json:"user_id"`
+	Username   string        `json:"username"`
+	Ip         string        `json:"ip"`
+	UserAgent  string        `json:"user_agent"`
+	Action     string        `json:"action"`
+	Entity     string        `json:"entity"`
+	EntityID   int64         `json:"entity_id"`
+	EntityName string        `json:"entity_name"`
+	Changes    string        `json:"changes"`
+}
+
 type Gauge struct {
-	ID          int64          `json:"id"`
-	Name        string         `json:"name"`
-	Description sql.NullString `json:"description"`
-	Target      float64        `json:"target"`
-	Value       float64        `json:"value"`
-	Unit        string         `json:"unit"`
-	Icon        string         `json:"icon"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
-	UpdatedAt   sql.NullTime   `json:"updated_at"`
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Description sql.NullString  `json:"description"`
+	Target      float64         `json:"target"`
+	Value       float64         `json:"value"`
+	Unit        string          `json:"unit"`
+	Icon        string          `json:"icon"`
+	CreatedAt   sql.NullTime    `json:"created_at"`
+	UpdatedAt   sql.NullTime    `json:"updated_at"`
+	Period      string          `json:"period"`
+	PeriodDays  int64           `json:"period_days"`
+	WeekStart   int64           `json:"week_start"`
+	Timezone    string          `json:"timezone"`
+	PeriodStart sql.NullTime    `json:"period_start"`
+	GoalType    string          `json:"goal_type"`
+	TargetMin   float64         `json:"target_min"`
+	Version     int64           `json:"version"`
+	Step        float64         `json:"step"`
+	Presets     string          `json:"presets"`
+	MinValue    sql.NullFloat64 `json:"min_value"`
+	MaxValue    sql.NullFloat64 `json:"max_value"`
+	Decimals    int64           `json:"decimals"`
+	OwnerID     sql.NullInt64   `json:"owner_id"`
+	HouseholdID sql.NullInt64   `json:"household_id"`
+}
+
+type GaugePeriod struct {
+	ID          int64        `json:"id"`
+	GaugeID     int64        `json:"gauge_id"`
+	PeriodStart time.Time    `json:"period_start"`
+	PeriodEnd   time.Time    `json:"period_end"`
+	Value       float64      `json:"value"`
+	Target      float64      `json:"target"`
+	ClosedAt    sql.NullTime `json:"closed_at"`
 }
 
 type GaugeValue struct {
 	ID      int64     `json:"id"`
 	GaugeID int64     `json:"gauge_id"`
 	Value   float64   `json:"value"`
+	Delta   float64   `json:"delta"`
+	Source  string    `json:"source"`
 	Date    time.Time `json:"date"`
 }
+
+type Household struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HouseholdMember struct {
+	HouseholdID int64     `json:"household_id"`
+	UserID      int64     `json:"user_id"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type NotificationChannel struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	Target     string    `json:"target"`
+	QuietStart string    `json:"quiet_start"`
+	QuietEnd   string    `json:"quiet_end"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	IsAdmin      bool      `json:"is_admin"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    string    `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64         `json:"id"`
+	WebhookID      int64         `json:"webhook_id"`
+	Event          string        `json:"event"`
+	Payload        string        `json:"payload"`
+	Status         string        `json:"status"`
+	Attempts       int64         `json:"attempts"`
+	NextAttemptAt  time.Time     `json:"next_attempt_at"`
+	LastAttemptAt  sql.NullTime  `json:"last_attempt_at"`
+	ResponseStatus sql.NullInt64 `json:"response_status"`
+	Error          string        `json:"error"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
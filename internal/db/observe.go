@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// QueryObserver is told how long each query took and whether it failed.
+// Queries are named as in queries.sql; any other SQL is named "other".
+type QueryObserver func(name string, duration time.Duration, err error)
+
+// ObserveQueries reports every query the store runs, in transactions or
+// not, to fn. It is meant to be called once, before the store is used.
+func (s *Store) ObserveQueries(fn QueryObserver) {
+	s.observe = fn
+	s.Queries = New(observedDB{DBTX: s.db, observe: fn})
+}
+
+// txQueries returns the queries of a transaction, observed like the store's
+func (s *Store) txQueries(tx *sql.Tx) *Queries {
+	if s.observe != nil {
+		return New(observedDB{DBTX: tx, observe: s.observe})
+	}
+	return s.Queries.WithTx(tx)
+}
+
+// observedDB times the queries run through it. A query returning rows is
+// timed until its first row is ready, not until the rows are read.
+type observedDB struct {
+	DBTX
+	observe QueryObserver
+}
+
+func (o observedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := o.DBTX.ExecContext(ctx, query, args...)
+	o.observe(queryName(query), time.Since(start), err)
+	return result, err
+}
+
+func (o observedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := o.DBTX.QueryContext(ctx, query, args...)
+	o.observe(queryName(query), time.Since(start), err)
+	return rows, err
+}
+
+func (o observedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := o.DBTX.QueryRowContext(ctx, query, args...)
+	o.observe(queryName(query), time.Since(start), row.Err())
+	return row
+}
+
+// queryName reads the name sqlc puts at the start of each query
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
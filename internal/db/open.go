@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultPath is where the database lives when DB_PATH is not set
+const DefaultPath = "health.db"
+
+// Config describes how to open the database
+type Config struct {
+	// Path is the database file, or ":memory:" for a private in-memory
+	// database
+	Path string
+	// BusyTimeout is how long a connection waits for a lock held by another
+	// before giving up with SQLITE_BUSY
+	BusyTimeout time.Duration
+	// MaxOpenConns caps the connection pool. WAL mode lets readers work
+	// alongside the single writer, so a few connections are enough.
+	MaxOpenConns int
+	// MaxIdleConns is how many connections are kept open between requests
+	MaxIdleConns int
+	// ConnMaxIdleTime closes connections that have sat idle this long
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultConfig returns the settings used by the server, with the database
+// at path
+func DefaultConfig(path string) Config {
+	return Config{
+		Path:            path,
+		BusyTimeout:     5 * time.Second,
+		MaxOpenConns:    8,
+		MaxIdleConns:    4,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+}
+
+// ConfigFromEnv returns the default settings for the database named by
+// DB_PATH
+func ConfigFromEnv() Config {
+	path := os.Getenv("DB_PATH")
+	if path == "" {
+		path = DefaultPath
+	}
+	return DefaultConfig(path)
+}
+
+// Open opens the database described by cfg using the driver selected at
+// build time. Every connection has foreign keys enforced, WAL journaling
+// and a busy timeout, and transactions take the write lock up front so
+// concurrent writers queue on the timeout instead of failing. Open checks
+// the database can be reached but does not migrate it.
+func Open(cfg Config) (*sql.DB, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("database path is required")
+	}
+
+	db, err := sql.Open(Driver, dsn(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	// Each connection to :memory: is a separate empty database
+	if cfg.Path == ":memory:" {
+		cfg.MaxOpenConns = 1
+		cfg.MaxIdleConns = 1
+		cfg.ConnMaxIdleTime = 0
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.BusyTimeout+time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening %s: %w", cfg.Path, err)
+	}
+
+	return db, nil
+}
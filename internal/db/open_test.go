@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_PATH", "")
+	assert.Equal(t, DefaultPath, ConfigFromEnv().Path)
+
+	t.Setenv("DB_PATH", "/data/health.db")
+	cfg := ConfigFromEnv()
+	assert.Equal(t, "/data/health.db", cfg.Path)
+	assert.Positive(t, cfg.BusyTimeout)
+	assert.Positive(t, cfg.MaxOpenConns)
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(DefaultConfig(filepath.Join(t.TempDir(), "health.db")))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Every connection in the pool gets the same settings
+	conns := make([]interface{ Close() error }, 0, 3)
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		conns = append(conns, conn)
+
+		var foreignKeys, busyTimeout int
+		var journalMode string
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys))
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode))
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout))
+		assert.Equal(t, 1, foreignKeys)
+		assert.Equal(t, "wal", journalMode)
+		assert.Equal(t, 5000, busyTimeout)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	t.Run("deleting a gauge cascades to its values", func(t *testing.T) {
+		_, err := Migrate(ctx, db)
+		require.NoError(t, err)
+
+		store := NewStore(db)
+		gauge, err := store.CreateGauge(ctx, CreateGaugeParams{
+			Name: "Water", Target: 8, Unit: "glasses", Icon: "water",
+			Period: "week", PeriodDays: 7, WeekStart: 1, Timezone: "UTC", GoalType: "at_most",
+		})
+		require.NoError(t, err)
+		_, err = store.RecordGaugeValue(ctx, RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2})
+		require.NoError(t, err)
+
+		require.NoError(t, store.DeleteGauge(ctx, gauge.ID))
+
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM gauge_values").Scan(&n))
+		assert.Zero(t, n)
+	})
+
+	t.Run("rejects values for missing gauges", func(t *testing.T) {
+		err := New(db).CreateGaugeValue(ctx, CreateGaugeValueParams{GaugeID: 999, Value: 1, Delta: 1, Source: SourceWeb})
+		assert.Error(t, err)
+	})
+}
+
+func TestOpen_RequiresPath(t *testing.T) {
+	_, err := Open(Config{})
+	assert.Error(t, err)
+}
@@ -6,19 +6,89 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 type Querier interface {
+	AddGaugeValue(ctx context.Context, arg AddGaugeValueParams) (Gauge, error)
+	AddGaugePeriodValue(ctx context.Context, arg AddGaugePeriodValueParams) error
+	AdoptGauges(ctx context.Context, ownerID sql.NullInt64) (int64, error)
+	CountGaugeValues(ctx context.Context, arg CountGaugeValuesParams) (int64, error)
+	CountUsers(ctx context.Context) (int64, error)
+	CreateAccessToken(ctx context.Context, arg CreateAccessTokenParams) (AccessToken, error)
+	CreateAlertHistory(ctx context.Context, arg CreateAlertHistoryParams) (int64, error)
+	CreateAlertRule(ctx context.Context, arg CreateAlertRuleParams) (AlertRule, error)
+	CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) (AuditLog, error)
 	CreateGauge(ctx context.Context, arg CreateGaugeParams) (Gauge, error)
+	CreateGaugePeriod(ctx context.Context, arg CreateGaugePeriodParams) error
 	CreateGaugeValue(ctx context.Context, arg CreateGaugeValueParams) error
+	CreateHousehold(ctx context.Context, name string) (Household, error)
+	CreateNotificationChannel(ctx context.Context, arg CreateNotificationChannelParams) (NotificationChannel, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) error
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	DeleteAccessToken(ctx context.Context, arg DeleteAccessTokenParams) error
+	DeleteAlertRule(ctx context.Context, id int64) error
+	DeleteAllGauges(ctx context.Context) error
+	DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error)
 	DeleteGauge(ctx context.Context, id int64) error
+	DeleteHousehold(ctx context.Context, id int64) error
+	DeleteHouseholdMember(ctx context.Context, arg DeleteHouseholdMemberParams) error
+	DeleteNotificationChannel(ctx context.Context, id int64) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteUserSessions(ctx context.Context, userID int64) error
+	DeleteWebhook(ctx context.Context, id int64) error
+	GaugeValueExists(ctx context.Context, arg GaugeValueExistsParams) (int64, error)
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (AccessToken, error)
 	GetCurrentValue(ctx context.Context, gaugeID int64) (float64, error)
 	GetGauge(ctx context.Context, id int64) (Gauge, error)
 	GetGaugeHistory(ctx context.Context, gaugeID int64) ([]GetGaugeHistoryRow, error)
+	GetGaugePeriods(ctx context.Context, gaugeID int64) ([]GaugePeriod, error)
 	GetGaugeValues(ctx context.Context, gaugeID int64) ([]GaugeValue, error)
+	GetHousehold(ctx context.Context, id int64) (Household, error)
+	GetHouseholdMember(ctx context.Context, arg GetHouseholdMemberParams) (HouseholdMember, error)
+	GetLastGaugeValueDate(ctx context.Context, gaugeID int64) (time.Time, error)
+	GetNotificationChannel(ctx context.Context, id int64) (NotificationChannel, error)
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetUser(ctx context.Context, id int64) (User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	ListAlertHistory(ctx context.Context, limit int64) ([]AlertHistory, error)
+	ListAlertRules(ctx context.Context) ([]AlertRule, error)
+	ListAuditEntries(ctx context.Context, arg ListAuditEntriesParams) ([]AuditLog, error)
+	ListDueWebhookDeliveries(ctx context.Context, arg ListDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListGaugePeriods(ctx context.Context, arg ListGaugePeriodsParams) ([]GaugePeriod, error)
+	ListGaugeValues(ctx context.Context, arg ListGaugeValuesParams) ([]GaugeValue, error)
+	ListGaugeValuesInRange(ctx context.Context, arg ListGaugeValuesInRangeParams) ([]GaugeValue, error)
 	ListGauges(ctx context.Context) ([]Gauge, error)
+	ListHouseholdMembers(ctx context.Context, householdID int64) ([]ListHouseholdMembersRow, error)
+	ListNotificationChannels(ctx context.Context) ([]NotificationChannel, error)
+	ListUserAccessTokens(ctx context.Context, userID int64) ([]AccessToken, error)
+	ListUserGauges(ctx context.Context, arg ListUserGaugesParams) ([]Gauge, error)
+	ListUserHouseholds(ctx context.Context, userID int64) ([]ListUserHouseholdsRow, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	PruneWebhookDeliveries(ctx context.Context, createdAt time.Time) error
+	RestoreGauge(ctx context.Context, arg RestoreGaugeParams) (Gauge, error)
+	RestoreGaugePeriod(ctx context.Context, arg RestoreGaugePeriodParams) error
+	RestoreGaugeValue(ctx context.Context, arg RestoreGaugeValueParams) error
+	RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) error
+	SetAlertHistoryError(ctx context.Context, arg SetAlertHistoryErrorParams) error
+	SetGaugeHousehold(ctx context.Context, arg SetGaugeHouseholdParams) error
+	SetHouseholdMember(ctx context.Context, arg SetHouseholdMemberParams) error
+	StartGaugePeriod(ctx context.Context, arg StartGaugePeriodParams) error
+	SumGaugeDeltas(ctx context.Context, arg SumGaugeDeltasParams) (float64, error)
+	SumGaugeDeltasAt(ctx context.Context, arg SumGaugeDeltasAtParams) (SumGaugeDeltasAtRow, error)
+	TouchAccessToken(ctx context.Context, arg TouchAccessTokenParams) error
+	TouchSession(ctx context.Context, arg TouchSessionParams) error
 	UpdateGauge(ctx context.Context, arg UpdateGaugeParams) error
-	UpdateGaugeValue(ctx context.Context, arg UpdateGaugeValueParams) error
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
+	UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error)
+	UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -5,8 +5,8 @@ SELECT * FROM gauges WHERE id = ? LIMIT 1;
 SELECT * FROM gauges ORDER BY name;
 
 -- name: CreateGauge :one
-INSERT INTO gauges (name, description, target, value, unit, icon)
-VALUES (?, ?, ?, 0, ?, ?)
+INSERT INTO gauges (name, description, target, value, unit, icon, period, period_days, week_start, timezone, goal_type, target_min, step, presets, min_value, max_value, decimals, owner_id)
+VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateGauge :exec
@@ -16,18 +16,42 @@ SET name = ?,
     target = ?,
     unit = ?,
     icon = ?,
+    period = ?,
+    period_days = ?,
+    week_start = ?,
+    timezone = ?,
+    goal_type = ?,
+    target_min = ?,
+    step = ?,
+    presets = ?,
+    min_value = ?,
+    max_value = ?,
+    decimals = ?,
+    version = version + 1,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?;
 
--- name: UpdateGaugeValue :exec
+-- name: AddGaugeValue :one
 UPDATE gauges
-SET value = ?,
+SET value = value + sqlc.arg(delta),
+    version = version + 1,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = ?;
+WHERE id = sqlc.arg(id)
+RETURNING *;
 
 -- name: DeleteGauge :exec
 DELETE FROM gauges WHERE id = ?;
 
+-- name: DeleteAllGauges :exec
+DELETE FROM gauges;
+
+-- name: RestoreGauge :one
+INSERT INTO gauges (id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id)
+VALUES (sqlc.narg(id), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+    (SELECT id FROM users WHERE id = sqlc.narg(owner_id)),
+    (SELECT id FROM households WHERE id = sqlc.narg(household_id)))
+RETURNING *;
+
 -- name: GetCurrentValue :one
 SELECT CAST(COALESCE(
     (SELECT value FROM gauge_values WHERE gauge_id = ? ORDER BY date DESC LIMIT 1),
@@ -35,14 +59,44 @@ SELECT CAST(COALESCE(
 ) AS REAL) as value;
 
 -- name: CreateGaugeValue :exec
-INSERT INTO gauge_values (gauge_id, value, date)
-VALUES (?, CAST(? AS REAL), ?);
+INSERT INTO gauge_values (gauge_id, value, delta, source, date)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: RestoreGaugeValue :exec
+INSERT INTO gauge_values (id, gauge_id, value, delta, source, date)
+VALUES (sqlc.narg(id), ?, ?, ?, ?, ?);
+
+-- name: GaugeValueExists :one
+SELECT EXISTS(
+    SELECT 1 FROM gauge_values WHERE gauge_id = ? AND date = ?
+) AS found;
 
 -- name: GetGaugeValues :many
 SELECT * FROM gauge_values 
 WHERE gauge_id = ?
 ORDER BY date DESC;
 
+-- name: ListGaugeValues :many
+SELECT * FROM gauge_values
+WHERE gauge_id = sqlc.arg(gauge_id)
+  AND date >= sqlc.arg(from_date)
+  AND date < sqlc.arg(to_date)
+ORDER BY date DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: ListGaugeValuesInRange :many
+SELECT * FROM gauge_values
+WHERE gauge_id = sqlc.arg(gauge_id)
+  AND date >= sqlc.arg(from_date)
+  AND date < sqlc.arg(to_date)
+ORDER BY date ASC;
+
+-- name: CountGaugeValues :one
+SELECT COUNT(*) FROM gauge_values
+WHERE gauge_id = sqlc.arg(gauge_id)
+  AND date >= sqlc.arg(from_date)
+  AND date < sqlc.arg(to_date);
+
 -- name: GetGaugeHistory :many
 SELECT strftime('%Y-%m', date) as month,
        CAST(AVG(value) AS REAL) as average_value
@@ -50,3 +104,301 @@ FROM gauge_values
 WHERE gauge_id = ?
 GROUP BY strftime('%Y-%m', date)
 ORDER BY month DESC;
+
+-- name: StartGaugePeriod :exec
+UPDATE gauges
+SET value = ?,
+    period_start = ?,
+    version = version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: SumGaugeDeltas :one
+SELECT CAST(COALESCE(SUM(delta), 0) AS REAL) AS total
+FROM gauge_values
+WHERE gauge_id = sqlc.arg(gauge_id)
+  AND date >= sqlc.arg(period_start)
+  AND date < sqlc.arg(period_end);
+
+-- name: SumGaugeDeltasAt :one
+SELECT COUNT(*) AS found, CAST(COALESCE(SUM(delta), 0) AS REAL) AS total
+FROM gauge_values
+WHERE gauge_id = ? AND date = ?;
+
+-- name: CreateGaugePeriod :exec
+INSERT OR IGNORE INTO gauge_periods (gauge_id, period_start, period_end, value, target)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: AddGaugePeriodValue :exec
+UPDATE gauge_periods
+SET value = value + sqlc.arg(delta)
+WHERE gauge_id = sqlc.arg(gauge_id)
+  AND period_start <= sqlc.arg(date)
+  AND period_end > sqlc.arg(date);
+
+-- name: ListGaugePeriods :many
+SELECT * FROM gauge_periods
+WHERE gauge_id = ?
+ORDER BY period_start DESC
+LIMIT ?;
+
+-- name: GetGaugePeriods :many
+SELECT * FROM gauge_periods
+WHERE gauge_id = ?
+ORDER BY period_start;
+
+-- name: RestoreGaugePeriod :exec
+INSERT OR IGNORE INTO gauge_periods (id, gauge_id, period_start, period_end, value, target, closed_at)
+VALUES (sqlc.narg(id), ?, ?, ?, ?, ?, ?);
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+ORDER BY name, id;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = ? LIMIT 1;
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (name, url, secret, events, active)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET name = ?,
+    url = ?,
+    secret = ?,
+    events = ?,
+    active = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+RETURNING *;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = ?;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = ? LIMIT 1;
+
+-- name: ListDueWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE status = 'pending'
+  AND next_attempt_at <= sqlc.arg(now)
+ORDER BY next_attempt_at, id
+LIMIT sqlc.arg(limit);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE (sqlc.narg(webhook_id) IS NULL OR webhook_id = sqlc.narg(webhook_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = ?,
+    attempts = ?,
+    next_attempt_at = ?,
+    last_attempt_at = ?,
+    response_status = ?,
+    error = ?
+WHERE id = ?;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'pending',
+    next_attempt_at = ?
+WHERE id = ?;
+
+-- name: PruneWebhookDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending'
+  AND created_at < ?;
+
+-- name: ListNotificationChannels :many
+SELECT * FROM notification_channels
+ORDER BY name, id;
+
+-- name: GetNotificationChannel :one
+SELECT * FROM notification_channels WHERE id = ? LIMIT 1;
+
+-- name: CreateNotificationChannel :one
+INSERT INTO notification_channels (name, kind, target, quiet_start, quiet_end, active)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteNotificationChannel :exec
+DELETE FROM notification_channels WHERE id = ?;
+
+-- name: ListAlertRules :many
+SELECT * FROM alert_rules
+ORDER BY gauge_id, id;
+
+-- name: CreateAlertRule :one
+INSERT INTO alert_rules (gauge_id, channel_id, kind, threshold, check_at, active)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteAlertRule :exec
+DELETE FROM alert_rules WHERE id = ?;
+
+-- name: CreateAlertHistory :execrows
+INSERT OR IGNORE INTO alert_history (rule_id, occasion, message, fired_at)
+VALUES (?, ?, ?, ?);
+
+-- name: SetAlertHistoryError :exec
+UPDATE alert_history
+SET error = ?
+WHERE rule_id = ? AND occasion = ?;
+
+-- name: ListAlertHistory :many
+SELECT * FROM alert_history
+ORDER BY fired_at DESC, id DESC
+LIMIT ?;
+
+-- name: GetLastGaugeValueDate :one
+SELECT date FROM gauge_values
+WHERE gauge_id = ?
+ORDER BY date DESC
+LIMIT 1;
+
+-- name: CreateUser :one
+INSERT INTO users (username, password_hash, is_admin)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = ? LIMIT 1;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users WHERE username = ? LIMIT 1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY username;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (id, user_id, created_at, expires_at, last_seen_at, ip, user_agent)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetSession :one
+SELECT * FROM sessions WHERE id = ? LIMIT 1;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = ?,
+    expires_at = ?
+WHERE id = ?;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = ?;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = ?;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at < ?;
+
+-- name: ListUserGauges :many
+SELECT * FROM gauges
+WHERE owner_id = sqlc.arg(user_id)
+   OR (owner_id IS NULL AND sqlc.arg(is_admin))
+   OR household_id IN (SELECT household_id FROM household_members WHERE user_id = sqlc.arg(user_id))
+ORDER BY name;
+
+-- name: SetGaugeHousehold :exec
+UPDATE gauges
+SET household_id = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: AdoptGauges :execrows
+UPDATE gauges SET owner_id = ? WHERE owner_id IS NULL;
+
+-- name: CreateHousehold :one
+INSERT INTO households (name)
+VALUES (?)
+RETURNING *;
+
+-- name: GetHousehold :one
+SELECT * FROM households WHERE id = ? LIMIT 1;
+
+-- name: DeleteHousehold :exec
+DELETE FROM households WHERE id = ?;
+
+-- name: ListUserHouseholds :many
+SELECT households.*, household_members.role
+FROM households
+JOIN household_members ON household_members.household_id = households.id
+WHERE household_members.user_id = ?
+ORDER BY households.name, households.id;
+
+-- name: GetHouseholdMember :one
+SELECT * FROM household_members
+WHERE household_id = ? AND user_id = ?
+LIMIT 1;
+
+-- name: ListHouseholdMembers :many
+SELECT household_members.*, users.username
+FROM household_members
+JOIN users ON users.id = household_members.user_id
+WHERE household_members.household_id = ?
+ORDER BY users.username;
+
+-- name: SetHouseholdMember :exec
+INSERT INTO household_members (household_id, user_id, role)
+VALUES (?, ?, ?)
+ON CONFLICT (household_id, user_id) DO UPDATE SET role = excluded.role;
+
+-- name: DeleteHouseholdMember :exec
+DELETE FROM household_members
+WHERE household_id = ? AND user_id = ?;
+
+-- name: CreateAccessToken :one
+INSERT INTO access_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetAccessTokenByHash :one
+SELECT * FROM access_tokens WHERE token_hash = ? LIMIT 1;
+
+-- name: ListUserAccessTokens :many
+SELECT * FROM access_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC;
+
+-- name: TouchAccessToken :exec
+UPDATE access_tokens SET last_used_at = ? WHERE id = ?;
+
+-- name: DeleteAccessToken :exec
+DELETE FROM access_tokens WHERE id = ? AND user_id = ?;
+
+-- name: CreateAuditEntry :one
+INSERT INTO audit_log (created_at, user_id, username, ip, user_agent, action, entity, entity_id, entity_name, changes)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListAuditEntries :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg(user_id) IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(action) IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(entity) IS NULL OR entity = sqlc.narg(entity))
+  AND (sqlc.narg(entity_id) IS NULL OR entity_id = sqlc.narg(entity_id))
+  AND (sqlc.narg(from_date) IS NULL OR created_at >= sqlc.narg(from_date))
+  AND (sqlc.narg(to_date) IS NULL OR created_at < sqlc.narg(to_date))
+  AND (sqlc.narg(before_id) IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(limit);
@@ -11,18 +11,289 @@ import (
 	"time"
 )
 
+const addGaugeValue = `-- name: AddGaugeValue :one
+UPDATE gauges
+SET value = value + ?,
+    version = version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+RETURNING id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id
+`
+
+type AddGaugeValueParams struct {
+	Delta float64 `json:"delta"`
+	ID    int64   `json:"id"`
+}
+
+func (q *Queries) AddGaugeValue(ctx context.Context, arg AddGaugeValueParams) (Gauge, error) {
+	row := q.db.QueryRowContext(ctx, addGaugeValue, arg.Delta, arg.ID)
+	var i Gauge
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.Target,
+		&i.Value,
+		&i.Unit,
+		&i.Icon,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Period,
+		&i.PeriodDays,
+		&i.WeekStart,
+		&i.Timezone,
+		&i.PeriodStart,
+		&i.GoalType,
+		&i.TargetMin,
+		&i.Version,
+		&i.Step,
+		&i.Presets,
+		&i.MinValue,
+		&i.MaxValue,
+		&i.Decimals,
+		&i.OwnerID,
+		&i.HouseholdID,
+	)
+	return i, err
+}
+
+const addGaugePeriodValue = `-- name: AddGaugePeriodValue :exec
+UPDATE gauge_periods
+SET value = value + ?1
+WHERE gauge_id = ?2
+  AND period_start <= ?3
+  AND period_end > ?3
+`
+
+type AddGaugePeriodValueParams struct {
+	Delta   float64   `json:"delta"`
+	GaugeID int64     `json:"gauge_id"`
+	Date    time.Time `json:"date"`
+}
+
+func (q *Queries) AddGaugePeriodValue(ctx context.Context, arg AddGaugePeriodValueParams) error {
+	_, err := q.db.ExecContext(ctx, addGaugePeriodValue, arg.Delta, arg.GaugeID, arg.Date)
+	return err
+}
+
+const adoptGauges = `-- name: AdoptGauges :execrows
+UPDATE gauges SET owner_id = ? WHERE owner_id IS NULL
+`
+
+func (q *Queries) AdoptGauges(ctx context.Context, ownerID sql.NullInt64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, adoptGauges, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countGaugeValues = `-- name: CountGaugeValues :one
+SELECT COUNT(*) FROM gauge_values
+WHERE gauge_id = ?1
+  AND date >= ?2
+  AND date < ?3
+`
+
+type CountGaugeValuesParams struct {
+	GaugeID  int64     `json:"gauge_id"`
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+}
+
+func (q *Queries) CountGaugeValues(ctx context.Context, arg CountGaugeValuesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countGaugeValues, arg.GaugeID, arg.FromDate, arg.ToDate)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAccessToken = `-- name: CreateAccessToken :one
+INSERT INTO access_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, user_id, name, token_hash, prefix, scopes, created_at, expires_at, last_used_at
+`
+
+type CreateAccessTokenParams struct {
+	UserID    int64        `json:"user_id"`
+	Name      string       `json:"name"`
+	TokenHash string       `json:"token_hash"`
+	Prefix    string       `json:"prefix"`
+	Scopes    string       `json:"scopes"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+}
+
+func (q *Queries) CreateAccessToken(ctx context.Context, arg CreateAccessTokenParams) (AccessToken, error) {
+	row := q.db.QueryRowContext(ctx, createAccessToken,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		arg.Prefix,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	var i AccessToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Prefix,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const createAlertHistory = `-- name: CreateAlertHistory :execrows
+INSERT OR IGNORE INTO alert_history (rule_id, occasion, message, fired_at)
+VALUES (?, ?, ?, ?)
+`
+
+type CreateAlertHistoryParams struct {
+	RuleID   int64     `json:"rule_id"`
+	Occasion string    `json:"occasion"`
+	Message  string    `json:"message"`
+	FiredAt  time.Time `json:"fired_at"`
+}
+
+func (q *Queries) CreateAlertHistory(ctx context.Context, arg CreateAlertHistoryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createAlertHistory,
+		arg.RuleID,
+		arg.Occasion,
+		arg.Message,
+		arg.FiredAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createAlertRule = `-- name: CreateAlertRule :one
+INSERT INTO alert_rules (gauge_id, channel_id, kind, threshold, check_at, active)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, gauge_id, channel_id, kind, threshold, check_at, active, created_at
+`
+
+type CreateAlertRuleParams struct {
+	GaugeID   int64   `json:"gauge_id"`
+	ChannelID int64   `json:"channel_id"`
+	Kind      string  `json:"kind"`
+	Threshold float64 `json:"threshold"`
+	CheckAt   string  `json:"check_at"`
+	Active    bool    `json:"active"`
+}
+
+func (q *Queries) CreateAlertRule(ctx context.Context, arg CreateAlertRuleParams) (AlertRule, error) {
+	row := q.db.QueryRowContext(ctx, createAlertRule,
+		arg.GaugeID,
+		arg.ChannelID,
+		arg.Kind,
+		arg.Threshold,
+		arg.CheckAt,
+		arg.Active,
+	)
+	var i AlertRule
+	err := row.Scan(
+		&i.ID,
+		&i.GaugeID,
+		&i.ChannelID,
+		&i.Kind,
+		&i.Threshold,
+		&i.CheckAt,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createAuditEntry = `-- name: CreateAuditEntry :one
+INSERT INTO audit_log (created_at, user_id, username, ip, user_agent, action, entity, entity_id, entity_name, changes)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, created_at, user_id, username, ip, user_agent, action, entity, entity_id, entity_name, changes
+`
+
+type CreateAuditEntryParams struct {
+	CreatedAt  time.Time     `json:"created_at"`
+	UserID     sql.NullInt64 `json:"user_id"`
+	Username   string        `json:"username"`
+	Ip         string        `json:"ip"`
+	UserAgent  string        `json:"user_agent"`
+	Action     string        `json:"action"`
+	Entity     string        `json:"entity"`
+	EntityID   int64         `json:"entity_id"`
+	EntityName string        `json:"entity_name"`
+	Changes    string        `json:"changes"`
+}
+
+func (q *Queries) CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) (AuditLog, error) {
+	row := q.db.QueryRowContext(ctx, createAuditEntry,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Username,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Action,
+		arg.Entity,
+		arg.EntityID,
+		arg.EntityName,
+		arg.Changes,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Username,
+		&i.Ip,
+		&i.UserAgent,
+		&i.Action,
+		&i.Entity,
+		&i.EntityID,
+		&i.EntityName,
+		&i.Changes,
+	)
+	return i, err
+}
+
 const createGauge = `-- name: CreateGauge :one
-INSERT INTO gauges (name, description, target, value, unit, icon)
-VALUES (?, ?, ?, 0, ?, ?)
-RETURNING id, name, description, target, value, unit, icon, created_at, updated_at
+INSERT INTO gauges (name, description, target, value, unit, icon, period, period_days, week_start, timezone, goal_type, target_min, step, presets, min_value, max_value, decimals, owner_id)
+VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id
 `
 
 type CreateGaugeParams struct {
-	Name        string         `json:"name"`
-	Description sql.NullString `json:"description"`
-	Target      float64        `json:"target"`
-	Unit        string         `json:"unit"`
-	Icon        string         `json:"icon"`
+	Name        string          `json:"name"`
+	Description sql.NullString  `json:"description"`
+	Target      float64         `json:"target"`
+	Unit        string          `json:"unit"`
+	Icon        string          `json:"icon"`
+	Period      string          `json:"period"`
+	PeriodDays  int64           `json:"period_days"`
+	WeekStart   int64           `json:"week_start"`
+	Timezone    string          `json:"timezone"`
+	GoalType    string          `json:"goal_type"`
+	TargetMin   float64         `json:"target_min"`
+	Step        float64         `json:"step"`
+	Presets     string          `json:"presets"`
+	MinValue    sql.NullFloat64 `json:"min_value"`
+	MaxValue    sql.NullFloat64 `json:"max_value"`
+	Decimals    int64           `json:"decimals"`
+	OwnerID     sql.NullInt64   `json:"owner_id"`
 }
 
 func (q *Queries) CreateGauge(ctx context.Context, arg CreateGaugeParams) (Gauge, error) {
@@ -32,6 +303,18 @@ func (q *Queries) CreateGauge(ctx context.Context, arg CreateGaugeParams) (Gauge
 		arg.Target,
 		arg.Unit,
 		arg.Icon,
+		arg.Period,
+		arg.PeriodDays,
+		arg.WeekStart,
+		arg.Timezone,
+		arg.GoalType,
+		arg.TargetMin,
+		arg.Step,
+		arg.Presets,
+		arg.MinValue,
+		arg.MaxValue,
+		arg.Decimals,
+		arg.OwnerID,
 	)
 	var i Gauge
 	err := row.Scan(
@@ -44,26 +327,299 @@ func (q *Queries) CreateGauge(ctx context.Context, arg CreateGaugeParams) (Gauge
 		&i.Icon,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Period,
+		&i.PeriodDays,
+		&i.WeekStart,
+		&i.Timezone,
+		&i.PeriodStart,
+		&i.GoalType,
+		&i.TargetMin,
+		&i.Version,
+		&i.Step,
+		&i.Presets,
+		&i.MinValue,
+		&i.MaxValue,
+		&i.Decimals,
+		&i.OwnerID,
+		&i.HouseholdID,
 	)
 	return i, err
 }
 
+const createGaugePeriod = `-- name: CreateGaugePeriod :exec
+INSERT OR IGNORE INTO gauge_periods (gauge_id, period_start, period_end, value, target)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type CreateGaugePeriodParams struct {
+	GaugeID     int64     `json:"gauge_id"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Value       float64   `json:"value"`
+	Target      float64   `json:"target"`
+}
+
+func (q *Queries) CreateGaugePeriod(ctx context.Context, arg CreateGaugePeriodParams) error {
+	_, err := q.db.ExecContext(ctx, createGaugePeriod,
+		arg.GaugeID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.Value,
+		arg.Target,
+	)
+	return err
+}
+
 const createGaugeValue = `-- name: CreateGaugeValue :exec
-INSERT INTO gauge_values (gauge_id, value, date)
-VALUES (?, CAST(? AS REAL), ?)
+INSERT INTO gauge_values (gauge_id, value, delta, source, date)
+VALUES (?, ?, ?, ?, ?)
 `
 
 type CreateGaugeValueParams struct {
 	GaugeID int64     `json:"gauge_id"`
-	Column2 float64   `json:"column_2"`
+	Value   float64   `json:"value"`
+	Delta   float64   `json:"delta"`
+	Source  string    `json:"source"`
 	Date    time.Time `json:"date"`
 }
 
 func (q *Queries) CreateGaugeValue(ctx context.Context, arg CreateGaugeValueParams) error {
-	_, err := q.db.ExecContext(ctx, createGaugeValue, arg.GaugeID, arg.Column2, arg.Date)
+	_, err := q.db.ExecContext(ctx, createGaugeValue,
+		arg.GaugeID,
+		arg.Value,
+		arg.Delta,
+		arg.Source,
+		arg.Date,
+	)
+	return err
+}
+
+const createHousehold = `-- name: CreateHousehold :one
+INSERT INTO households (name)
+VALUES (?)
+RETURNING id, name, created_at
+`
+
+func (q *Queries) CreateHousehold(ctx context.Context, name string) (Household, error) {
+	row := q.db.QueryRowContext(ctx, createHousehold, name)
+	var i Household
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createNotificationChannel = `-- name: CreateNotificationChannel :one
+INSERT INTO notification_channels (name, kind, target, quiet_start, quiet_end, active)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, name, kind, target, quiet_start, quiet_end, active, created_at
+`
+
+type CreateNotificationChannelParams struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Target     string `json:"target"`
+	QuietStart string `json:"quiet_start"`
+	QuietEnd   string `json:"quiet_end"`
+	Active     bool   `json:"active"`
+}
+
+func (q *Queries) CreateNotificationChannel(ctx context.Context, arg CreateNotificationChannelParams) (NotificationChannel, error) {
+	row := q.db.QueryRowContext(ctx, createNotificationChannel,
+		arg.Name,
+		arg.Kind,
+		arg.Target,
+		arg.QuietStart,
+		arg.QuietEnd,
+		arg.Active,
+	)
+	var i NotificationChannel
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Kind,
+		&i.Target,
+		&i.QuietStart,
+		&i.QuietEnd,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (id, user_id, created_at, expires_at, last_seen_at, ip, user_agent)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateSessionParams struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession,
+		arg.ID,
+		arg.UserID,
+		arg.CreatedAt,
+		arg.ExpiresAt,
+		arg.LastSeenAt,
+		arg.Ip,
+		arg.UserAgent,
+	)
+	return err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (username, password_hash, is_admin)
+VALUES (?, ?, ?)
+RETURNING id, username, password_hash, is_admin, created_at, updated_at
+`
+
+type CreateUserParams struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	IsAdmin      bool   `json:"is_admin"`
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser, arg.Username, arg.PasswordHash, arg.IsAdmin)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (name, url, secret, events, active)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id, name, url, secret, events, active, created_at, updated_at
+`
+
+type CreateWebhookParams struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+	Events string `json:"events"`
+	Active bool   `json:"active"`
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.Name,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+		arg.Active,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+VALUES (?, ?, ?, ?)
+RETURNING id, webhook_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, error, created_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID     int64     `json:"webhook_id"`
+	Event         string    `json:"event"`
+	Payload       string    `json:"payload"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.Event,
+		arg.Payload,
+		arg.NextAttemptAt,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.Error,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAccessToken = `-- name: DeleteAccessToken :exec
+DELETE FROM access_tokens WHERE id = ? AND user_id = ?
+`
+
+type DeleteAccessTokenParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteAccessToken(ctx context.Context, arg DeleteAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, deleteAccessToken, arg.ID, arg.UserID)
+	return err
+}
+
+const deleteAlertRule = `-- name: DeleteAlertRule :exec
+DELETE FROM alert_rules WHERE id = ?
+`
+
+func (q *Queries) DeleteAlertRule(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteAlertRule, id)
+	return err
+}
+
+const deleteAllGauges = `-- name: DeleteAllGauges :exec
+DELETE FROM gauges
+`
+
+func (q *Queries) DeleteAllGauges(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllGauges)
 	return err
 }
 
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at < ?
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteGauge = `-- name: DeleteGauge :exec
 DELETE FROM gauges WHERE id = ?
 `
@@ -73,6 +629,105 @@ func (q *Queries) DeleteGauge(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteHousehold = `-- name: DeleteHousehold :exec
+DELETE FROM households WHERE id = ?
+`
+
+func (q *Queries) DeleteHousehold(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteHousehold, id)
+	return err
+}
+
+const deleteHouseholdMember = `-- name: DeleteHouseholdMember :exec
+DELETE FROM household_members
+WHERE household_id = ? AND user_id = ?
+`
+
+type DeleteHouseholdMemberParams struct {
+	HouseholdID int64 `json:"household_id"`
+	UserID      int64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteHouseholdMember(ctx context.Context, arg DeleteHouseholdMemberParams) error {
+	_, err := q.db.ExecContext(ctx, deleteHouseholdMember, arg.HouseholdID, arg.UserID)
+	return err
+}
+
+const deleteNotificationChannel = `-- name: DeleteNotificationChannel :exec
+DELETE FROM notification_channels WHERE id = ?
+`
+
+func (q *Queries) DeleteNotificationChannel(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteNotificationChannel, id)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = ?
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, id)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = ?
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSessions, userID)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = ?
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	return err
+}
+
+const gaugeValueExists = `-- name: GaugeValueExists :one
+SELECT EXISTS(
+    SELECT 1 FROM gauge_values WHERE gauge_id = ? AND date = ?
+) AS found
+`
+
+type GaugeValueExistsParams struct {
+	GaugeID int64     `json:"gauge_id"`
+	Date    time.Time `json:"date"`
+}
+
+func (q *Queries) GaugeValueExists(ctx context.Context, arg GaugeValueExistsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, gaugeValueExists, arg.GaugeID, arg.Date)
+	var found int64
+	err := row.Scan(&found)
+	return found, err
+}
+
+const getAccessTokenByHash = `-- name: GetAccessTokenByHash :one
+SELECT id, user_id, name, token_hash, prefix, scopes, created_at, expires_at, last_used_at FROM access_tokens WHERE token_hash = ? LIMIT 1
+`
+
+func (q *Queries) GetAccessTokenByHash(ctx context.Context, tokenHash string) (AccessToken, error) {
+	row := q.db.QueryRowContext(ctx, getAccessTokenByHash, tokenHash)
+	var i AccessToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Prefix,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
 const getCurrentValue = `-- name: GetCurrentValue :one
 SELECT CAST(COALESCE(
     (SELECT value FROM gauge_values WHERE gauge_id = ? ORDER BY date DESC LIMIT 1),
@@ -88,7 +743,7 @@ func (q *Queries) GetCurrentValue(ctx context.Context, gaugeID int64) (float64,
 }
 
 const getGauge = `-- name: GetGauge :one
-SELECT id, name, description, target, value, unit, icon, created_at, updated_at FROM gauges WHERE id = ? LIMIT 1
+SELECT id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id FROM gauges WHERE id = ? LIMIT 1
 `
 
 func (q *Queries) GetGauge(ctx context.Context, id int64) (Gauge, error) {
@@ -104,6 +759,21 @@ func (q *Queries) GetGauge(ctx context.Context, id int64) (Gauge, error) {
 		&i.Icon,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Period,
+		&i.PeriodDays,
+		&i.WeekStart,
+		&i.Timezone,
+		&i.PeriodStart,
+		&i.GoalType,
+		&i.TargetMin,
+		&i.Version,
+		&i.Step,
+		&i.Presets,
+		&i.MinValue,
+		&i.MaxValue,
+		&i.Decimals,
+		&i.OwnerID,
+		&i.HouseholdID,
 	)
 	return i, err
 }
@@ -145,14 +815,51 @@ func (q *Queries) GetGaugeHistory(ctx context.Context, gaugeID int64) ([]GetGaug
 	return items, nil
 }
 
-const getGaugeValues = `-- name: GetGaugeValues :many
-SELECT id, gauge_id, value, date FROM gauge_values 
+const getGaugePeriods = `-- name: GetGaugePeriods :many
+SELECT id, gauge_id, period_start, period_end, value, target, closed_at FROM gauge_periods
 WHERE gauge_id = ?
-ORDER BY date DESC
+ORDER BY period_start
 `
 
-func (q *Queries) GetGaugeValues(ctx context.Context, gaugeID int64) ([]GaugeValue, error) {
-	rows, err := q.db.QueryContext(ctx, getGaugeValues, gaugeID)
+func (q *Queries) GetGaugePeriods(ctx context.Context, gaugeID int64) ([]GaugePeriod, error) {
+	rows, err := q.db.QueryContext(ctx, getGaugePeriods, gaugeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GaugePeriod{}
+	for rows.Next() {
+		var i GaugePeriod
+		if err := rows.Scan(
+			&i.ID,
+			&i.GaugeID,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.Value,
+			&i.Target,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGaugeValues = `-- name: GetGaugeValues :many
+SELECT id, gauge_id, value, delta, source, date FROM gauge_values 
+WHERE gauge_id = ?
+ORDER BY date DESC
+`
+
+func (q *Queries) GetGaugeValues(ctx context.Context, gaugeID int64) ([]GaugeValue, error) {
+	rows, err := q.db.QueryContext(ctx, getGaugeValues, gaugeID)
 	if err != nil {
 		return nil, err
 	}
@@ -162,9 +869,744 @@ func (q *Queries) GetGaugeValues(ctx context.Context, gaugeID int64) ([]GaugeVal
 		var i GaugeValue
 		if err := rows.Scan(
 			&i.ID,
-			&i.GaugeID,
+			&i.GaugeID,
+			&i.Value,
+			&i.Delta,
+			&i.Source,
+			&i.Date,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getHousehold = `-- name: GetHousehold :one
+SELECT id, name, created_at FROM households WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetHousehold(ctx context.Context, id int64) (Household, error) {
+	row := q.db.QueryRowContext(ctx, getHousehold, id)
+	var i Household
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getHouseholdMember = `-- name: GetHouseholdMember :one
+SELECT household_id, user_id, role, created_at FROM household_members
+WHERE household_id = ? AND user_id = ?
+LIMIT 1
+`
+
+type GetHouseholdMemberParams struct {
+	HouseholdID int64 `json:"household_id"`
+	UserID      int64 `json:"user_id"`
+}
+
+func (q *Queries) GetHouseholdMember(ctx context.Context, arg GetHouseholdMemberParams) (HouseholdMember, error) {
+	row := q.db.QueryRowContext(ctx, getHouseholdMember, arg.HouseholdID, arg.UserID)
+	var i HouseholdMember
+	err := row.Scan(
+		&i.HouseholdID,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLastGaugeValueDate = `-- name: GetLastGaugeValueDate :one
+SELECT date FROM gauge_values
+WHERE gauge_id = ?
+ORDER BY date DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastGaugeValueDate(ctx context.Context, gaugeID int64) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getLastGaugeValueDate, gaugeID)
+	var date time.Time
+	err := row.Scan(&date)
+	return date, err
+}
+
+const getNotificationChannel = `-- name: GetNotificationChannel :one
+SELECT id, name, kind, target, quiet_start, quiet_end, active, created_at FROM notification_channels WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetNotificationChannel(ctx context.Context, id int64) (NotificationChannel, error) {
+	row := q.db.QueryRowContext(ctx, getNotificationChannel, id)
+	var i NotificationChannel
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Kind,
+		&i.Target,
+		&i.QuietStart,
+		&i.QuietEnd,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, user_id, created_at, expires_at, last_seen_at, ip, user_agent FROM sessions WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastSeenAt,
+		&i.Ip,
+		&i.UserAgent,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, username, password_hash, is_admin, created_at, updated_at FROM users WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, username, password_hash, is_admin, created_at, updated_at FROM users WHERE username = ? LIMIT 1
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, name, url, secret, events, active, created_at, updated_at FROM webhooks WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, error, created_at FROM webhook_deliveries WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.Error,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAlertHistory = `-- name: ListAlertHistory :many
+SELECT id, rule_id, occasion, message, error, fired_at FROM alert_history
+ORDER BY fired_at DESC, id DESC
+LIMIT ?
+`
+
+func (q *Queries) ListAlertHistory(ctx context.Context, limit int64) ([]AlertHistory, error) {
+	rows, err := q.db.QueryContext(ctx, listAlertHistory, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AlertHistory{}
+	for rows.Next() {
+		var i AlertHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.RuleID,
+			&i.Occasion,
+			&i.Message,
+			&i.Error,
+			&i.FiredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAlertRules = `-- name: ListAlertRules :many
+SELECT id, gauge_id, channel_id, kind, threshold, check_at, active, created_at FROM alert_rules
+ORDER BY gauge_id, id
+`
+
+func (q *Queries) ListAlertRules(ctx context.Context) ([]AlertRule, error) {
+	rows, err := q.db.QueryContext(ctx, listAlertRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AlertRule{}
+	for rows.Next() {
+		var i AlertRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.GaugeID,
+			&i.ChannelID,
+			&i.Kind,
+			&i.Threshold,
+			&i.CheckAt,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEntries = `-- name: ListAuditEntries :many
+SELECT id, created_at, user_id, username, ip, user_agent, action, entity, entity_id, entity_name, changes FROM audit_log
+WHERE (?1 IS NULL OR user_id = ?1)
+  AND (?2 IS NULL OR action = ?2)
+  AND (?3 IS NULL OR entity = ?3)
+  AND (?4 IS NULL OR entity_id = ?4)
+  AND (?5 IS NULL OR created_at >= ?5)
+  AND (?6 IS NULL OR created_at < ?6)
+  AND (?7 IS NULL OR id < ?7)
+ORDER BY id DESC
+LIMIT ?8
+`
+
+type ListAuditEntriesParams struct {
+	UserID   sql.NullInt64  `json:"user_id"`
+	Action   sql.NullString `json:"action"`
+	Entity   sql.NullString `json:"entity"`
+	EntityID sql.NullInt64  `json:"entity_id"`
+	FromDate sql.NullTime   `json:"from_date"`
+	ToDate   sql.NullTime   `json:"to_date"`
+	BeforeID sql.NullInt64  `json:"before_id"`
+	Limit    int64          `json:"limit"`
+}
+
+func (q *Queries) ListAuditEntries(ctx context.Context, arg ListAuditEntriesParams) ([]AuditLog, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEntries,
+		arg.UserID,
+		arg.Action,
+		arg.Entity,
+		arg.EntityID,
+		arg.FromDate,
+		arg.ToDate,
+		arg.BeforeID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditLog{}
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Username,
+			&i.Ip,
+			&i.UserAgent,
+			&i.Action,
+			&i.Entity,
+			&i.EntityID,
+			&i.EntityName,
+			&i.Changes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDueWebhookDeliveries = `-- name: ListDueWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, error, created_at FROM webhook_deliveries
+WHERE status = 'pending'
+  AND next_attempt_at <= ?1
+ORDER BY next_attempt_at, id
+LIMIT ?2
+`
+
+type ListDueWebhookDeliveriesParams struct {
+	Now   time.Time `json:"now"`
+	Limit int64     `json:"limit"`
+}
+
+func (q *Queries) ListDueWebhookDeliveries(ctx context.Context, arg ListDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listDueWebhookDeliveries, arg.Now, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGaugePeriods = `-- name: ListGaugePeriods :many
+SELECT id, gauge_id, period_start, period_end, value, target, closed_at FROM gauge_periods
+WHERE gauge_id = ?
+ORDER BY period_start DESC
+LIMIT ?
+`
+
+type ListGaugePeriodsParams struct {
+	GaugeID int64 `json:"gauge_id"`
+	Limit   int64 `json:"limit"`
+}
+
+func (q *Queries) ListGaugePeriods(ctx context.Context, arg ListGaugePeriodsParams) ([]GaugePeriod, error) {
+	rows, err := q.db.QueryContext(ctx, listGaugePeriods, arg.GaugeID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GaugePeriod{}
+	for rows.Next() {
+		var i GaugePeriod
+		if err := rows.Scan(
+			&i.ID,
+			&i.GaugeID,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.Value,
+			&i.Target,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGaugeValues = `-- name: ListGaugeValues :many
+SELECT id, gauge_id, value, delta, source, date FROM gauge_values
+WHERE gauge_id = ?1
+  AND date >= ?2
+  AND date < ?3
+ORDER BY date DESC
+LIMIT ?4 OFFSET ?5
+`
+
+type ListGaugeValuesParams struct {
+	GaugeID  int64     `json:"gauge_id"`
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+	Limit    int64     `json:"limit"`
+	Offset   int64     `json:"offset"`
+}
+
+func (q *Queries) ListGaugeValues(ctx context.Context, arg ListGaugeValuesParams) ([]GaugeValue, error) {
+	rows, err := q.db.QueryContext(ctx, listGaugeValues,
+		arg.GaugeID,
+		arg.FromDate,
+		arg.ToDate,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GaugeValue{}
+	for rows.Next() {
+		var i GaugeValue
+		if err := rows.Scan(
+			&i.ID,
+			&i.GaugeID,
+			&i.Value,
+			&i.Delta,
+			&i.Source,
+			&i.Date,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGaugeValuesInRange = `-- name: ListGaugeValuesInRange :many
+SELECT id, gauge_id, value, delta, source, date FROM gauge_values
+WHERE gauge_id = ?1
+  AND date >= ?2
+  AND date < ?3
+ORDER BY date ASC
+`
+
+type ListGaugeValuesInRangeParams struct {
+	GaugeID  int64     `json:"gauge_id"`
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+}
+
+func (q *Queries) ListGaugeValuesInRange(ctx context.Context, arg ListGaugeValuesInRangeParams) ([]GaugeValue, error) {
+	rows, err := q.db.QueryContext(ctx, listGaugeValuesInRange, arg.GaugeID, arg.FromDate, arg.ToDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GaugeValue{}
+	for rows.Next() {
+		var i GaugeValue
+		if err := rows.Scan(
+			&i.ID,
+			&i.GaugeID,
+			&i.Value,
+			&i.Delta,
+			&i.Source,
+			&i.Date,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGauges = `-- name: ListGauges :many
+SELECT id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id FROM gauges ORDER BY name
+`
+
+func (q *Queries) ListGauges(ctx context.Context) ([]Gauge, error) {
+	rows, err := q.db.QueryContext(ctx, listGauges)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Gauge{}
+	for rows.Next() {
+		var i Gauge
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Target,
+			&i.Value,
+			&i.Unit,
+			&i.Icon,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Period,
+			&i.PeriodDays,
+			&i.WeekStart,
+			&i.Timezone,
+			&i.PeriodStart,
+			&i.GoalType,
+			&i.TargetMin,
+			&i.Version,
+			&i.Step,
+			&i.Presets,
+			&i.MinValue,
+			&i.MaxValue,
+			&i.Decimals,
+			&i.OwnerID,
+			&i.HouseholdID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHouseholdMembers = `-- name: ListHouseholdMembers :many
+SELECT household_members.household_id, household_members.user_id, household_members.role, household_members.created_at, users.username
+FROM household_members
+JOIN users ON users.id = household_members.user_id
+WHERE household_members.household_id = ?
+ORDER BY users.username
+`
+
+type ListHouseholdMembersRow struct {
+	HouseholdID int64     `json:"household_id"`
+	UserID      int64     `json:"user_id"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	Username    string    `json:"username"`
+}
+
+func (q *Queries) ListHouseholdMembers(ctx context.Context, householdID int64) ([]ListHouseholdMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listHouseholdMembers, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListHouseholdMembersRow{}
+	for rows.Next() {
+		var i ListHouseholdMembersRow
+		if err := rows.Scan(
+			&i.HouseholdID,
+			&i.UserID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotificationChannels = `-- name: ListNotificationChannels :many
+SELECT id, name, kind, target, quiet_start, quiet_end, active, created_at FROM notification_channels
+ORDER BY name, id
+`
+
+func (q *Queries) ListNotificationChannels(ctx context.Context) ([]NotificationChannel, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationChannels)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []NotificationChannel{}
+	for rows.Next() {
+		var i NotificationChannel
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Kind,
+			&i.Target,
+			&i.QuietStart,
+			&i.QuietEnd,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserAccessTokens = `-- name: ListUserAccessTokens :many
+SELECT id, user_id, name, token_hash, prefix, scopes, created_at, expires_at, last_used_at FROM access_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC
+`
+
+func (q *Queries) ListUserAccessTokens(ctx context.Context, userID int64) ([]AccessToken, error) {
+	rows, err := q.db.QueryContext(ctx, listUserAccessTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AccessToken{}
+	for rows.Next() {
+		var i AccessToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Prefix,
+			&i.Scopes,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserGauges = `-- name: ListUserGauges :many
+SELECT id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id FROM gauges
+WHERE owner_id = ?1
+   OR (owner_id IS NULL AND ?2)
+   OR household_id IN (SELECT household_id FROM household_members WHERE user_id = ?1)
+ORDER BY name
+`
+
+type ListUserGaugesParams struct {
+	UserID  int64 `json:"user_id"`
+	IsAdmin bool  `json:"is_admin"`
+}
+
+func (q *Queries) ListUserGauges(ctx context.Context, arg ListUserGaugesParams) ([]Gauge, error) {
+	rows, err := q.db.QueryContext(ctx, listUserGauges, arg.UserID, arg.IsAdmin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Gauge{}
+	for rows.Next() {
+		var i Gauge
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Target,
 			&i.Value,
-			&i.Date,
+			&i.Unit,
+			&i.Icon,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Period,
+			&i.PeriodDays,
+			&i.WeekStart,
+			&i.Timezone,
+			&i.PeriodStart,
+			&i.GoalType,
+			&i.TargetMin,
+			&i.Version,
+			&i.Step,
+			&i.Presets,
+			&i.MinValue,
+			&i.MaxValue,
+			&i.Decimals,
+			&i.OwnerID,
+			&i.HouseholdID,
 		); err != nil {
 			return nil, err
 		}
@@ -179,27 +1621,152 @@ func (q *Queries) GetGaugeValues(ctx context.Context, gaugeID int64) ([]GaugeVal
 	return items, nil
 }
 
-const listGauges = `-- name: ListGauges :many
-SELECT id, name, description, target, value, unit, icon, created_at, updated_at FROM gauges ORDER BY name
+const listUserHouseholds = `-- name: ListUserHouseholds :many
+SELECT households.id, households.name, households.created_at, household_members.role
+FROM households
+JOIN household_members ON household_members.household_id = households.id
+WHERE household_members.user_id = ?
+ORDER BY households.name, households.id
 `
 
-func (q *Queries) ListGauges(ctx context.Context) ([]Gauge, error) {
-	rows, err := q.db.QueryContext(ctx, listGauges)
+type ListUserHouseholdsRow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      string    `json:"role"`
+}
+
+func (q *Queries) ListUserHouseholds(ctx context.Context, userID int64) ([]ListUserHouseholdsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserHouseholds, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Gauge{}
+	items := []ListUserHouseholdsRow{}
 	for rows.Next() {
-		var i Gauge
+		var i ListUserHouseholdsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
-			&i.Description,
-			&i.Target,
-			&i.Value,
-			&i.Unit,
-			&i.Icon,
+			&i.CreatedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, username, password_hash, is_admin, created_at, updated_at FROM users
+ORDER BY username
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.PasswordHash,
+			&i.IsAdmin,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, error, created_at FROM webhook_deliveries
+WHERE (?1 IS NULL OR webhook_id = ?1)
+ORDER BY id DESC
+LIMIT ?2
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID sql.NullInt64 `json:"webhook_id"`
+	Limit     int64         `json:"limit"`
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, name, url, secret, events, active, created_at, updated_at FROM webhooks
+ORDER BY name, id
+`
+
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Webhook{}
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Active,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 		); err != nil {
@@ -216,6 +1783,327 @@ func (q *Queries) ListGauges(ctx context.Context) ([]Gauge, error) {
 	return items, nil
 }
 
+const pruneWebhookDeliveries = `-- name: PruneWebhookDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending'
+  AND created_at < ?
+`
+
+func (q *Queries) PruneWebhookDeliveries(ctx context.Context, createdAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, pruneWebhookDeliveries, createdAt)
+	return err
+}
+
+const restoreGauge = `-- name: RestoreGauge :one
+INSERT INTO gauges (id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+    (SELECT id FROM users WHERE id = ?),
+    (SELECT id FROM households WHERE id = ?))
+RETURNING id, name, description, target, value, unit, icon, created_at, updated_at, period, period_days, week_start, timezone, period_start, goal_type, target_min, version, step, presets, min_value, max_value, decimals, owner_id, household_id
+`
+
+type RestoreGaugeParams struct {
+	ID          sql.NullInt64   `json:"id"`
+	Name        string          `json:"name"`
+	Description sql.NullString  `json:"description"`
+	Target      float64         `json:"target"`
+	Value       float64         `json:"value"`
+	Unit        string          `json:"unit"`
+	Icon        string          `json:"icon"`
+	CreatedAt   sql.NullTime    `json:"created_at"`
+	UpdatedAt   sql.NullTime    `json:"updated_at"`
+	Period      string          `json:"period"`
+	PeriodDays  int64           `json:"period_days"`
+	WeekStart   int64           `json:"week_start"`
+	Timezone    string          `json:"timezone"`
+	PeriodStart sql.NullTime    `json:"period_start"`
+	GoalType    string          `json:"goal_type"`
+	TargetMin   float64         `json:"target_min"`
+	Version     int64           `json:"version"`
+	Step        float64         `json:"step"`
+	Presets     string          `json:"presets"`
+	MinValue    sql.NullFloat64 `json:"min_value"`
+	MaxValue    sql.NullFloat64 `json:"max_value"`
+	Decimals    int64           `json:"decimals"`
+	OwnerID     sql.NullInt64   `json:"owner_id"`
+	HouseholdID sql.NullInt64   `json:"household_id"`
+}
+
+func (q *Queries) RestoreGauge(ctx context.Context, arg RestoreGaugeParams) (Gauge, error) {
+	row := q.db.QueryRowContext(ctx, restoreGauge,
+		arg.ID,
+		arg.Name,
+		arg.Description,
+		arg.Target,
+		arg.Value,
+		arg.Unit,
+		arg.Icon,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Period,
+		arg.PeriodDays,
+		arg.WeekStart,
+		arg.Timezone,
+		arg.PeriodStart,
+		arg.GoalType,
+		arg.TargetMin,
+		arg.Version,
+		arg.Step,
+		arg.Presets,
+		arg.MinValue,
+		arg.MaxValue,
+		arg.Decimals,
+		arg.OwnerID,
+		arg.HouseholdID,
+	)
+	var i Gauge
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.Target,
+		&i.Value,
+		&i.Unit,
+		&i.Icon,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Period,
+		&i.PeriodDays,
+		&i.WeekStart,
+		&i.Timezone,
+		&i.PeriodStart,
+		&i.GoalType,
+		&i.TargetMin,
+		&i.Version,
+		&i.Step,
+		&i.Presets,
+		&i.MinValue,
+		&i.MaxValue,
+		&i.Decimals,
+		&i.OwnerID,
+		&i.HouseholdID,
+	)
+	return i, err
+}
+
+const restoreGaugePeriod = `-- name: RestoreGaugePeriod :exec
+INSERT OR IGNORE INTO gauge_periods (id, gauge_id, period_start, period_end, value, target, closed_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+type RestoreGaugePeriodParams struct {
+	ID          sql.NullInt64 `json:"id"`
+	GaugeID     int64         `json:"gauge_id"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	Value       float64       `json:"value"`
+	Target      float64       `json:"target"`
+	ClosedAt    sql.NullTime  `json:"closed_at"`
+}
+
+func (q *Queries) RestoreGaugePeriod(ctx context.Context, arg RestoreGaugePeriodParams) error {
+	_, err := q.db.ExecContext(ctx, restoreGaugePeriod,
+		arg.ID,
+		arg.GaugeID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.Value,
+		arg.Target,
+		arg.ClosedAt,
+	)
+	return err
+}
+
+const restoreGaugeValue = `-- name: RestoreGaugeValue :exec
+INSERT INTO gauge_values (id, gauge_id, value, delta, source, date)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type RestoreGaugeValueParams struct {
+	ID      sql.NullInt64 `json:"id"`
+	GaugeID int64         `json:"gauge_id"`
+	Value   float64       `json:"value"`
+	Delta   float64       `json:"delta"`
+	Source  string        `json:"source"`
+	Date    time.Time     `json:"date"`
+}
+
+func (q *Queries) RestoreGaugeValue(ctx context.Context, arg RestoreGaugeValueParams) error {
+	_, err := q.db.ExecContext(ctx, restoreGaugeValue,
+		arg.ID,
+		arg.GaugeID,
+		arg.Value,
+		arg.Delta,
+		arg.Source,
+		arg.Date,
+	)
+	return err
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'pending',
+    next_attempt_at = ?
+WHERE id = ?
+`
+
+type RetryWebhookDeliveryParams struct {
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	ID            int64     `json:"id"`
+}
+
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, retryWebhookDelivery, arg.NextAttemptAt, arg.ID)
+	return err
+}
+
+const setAlertHistoryError = `-- name: SetAlertHistoryError :exec
+UPDATE alert_history
+SET error = ?
+WHERE rule_id = ? AND occasion = ?
+`
+
+type SetAlertHistoryErrorParams struct {
+	Error    string `json:"error"`
+	RuleID   int64  `json:"rule_id"`
+	Occasion string `json:"occasion"`
+}
+
+func (q *Queries) SetAlertHistoryError(ctx context.Context, arg SetAlertHistoryErrorParams) error {
+	_, err := q.db.ExecContext(ctx, setAlertHistoryError, arg.Error, arg.RuleID, arg.Occasion)
+	return err
+}
+
+const setGaugeHousehold = `-- name: SetGaugeHousehold :exec
+UPDATE gauges
+SET household_id = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type SetGaugeHouseholdParams struct {
+	HouseholdID sql.NullInt64 `json:"household_id"`
+	ID          int64         `json:"id"`
+}
+
+func (q *Queries) SetGaugeHousehold(ctx context.Context, arg SetGaugeHouseholdParams) error {
+	_, err := q.db.ExecContext(ctx, setGaugeHousehold, arg.HouseholdID, arg.ID)
+	return err
+}
+
+const setHouseholdMember = `-- name: SetHouseholdMember :exec
+INSERT INTO household_members (household_id, user_id, role)
+VALUES (?, ?, ?)
+ON CONFLICT (household_id, user_id) DO UPDATE SET role = excluded.role
+`
+
+type SetHouseholdMemberParams struct {
+	HouseholdID int64  `json:"household_id"`
+	UserID      int64  `json:"user_id"`
+	Role        string `json:"role"`
+}
+
+func (q *Queries) SetHouseholdMember(ctx context.Context, arg SetHouseholdMemberParams) error {
+	_, err := q.db.ExecContext(ctx, setHouseholdMember, arg.HouseholdID, arg.UserID, arg.Role)
+	return err
+}
+
+const startGaugePeriod = `-- name: StartGaugePeriod :exec
+UPDATE gauges
+SET value = ?,
+    period_start = ?,
+    version = version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type StartGaugePeriodParams struct {
+	Value       float64      `json:"value"`
+	PeriodStart sql.NullTime `json:"period_start"`
+	ID          int64        `json:"id"`
+}
+
+func (q *Queries) StartGaugePeriod(ctx context.Context, arg StartGaugePeriodParams) error {
+	_, err := q.db.ExecContext(ctx, startGaugePeriod, arg.Value, arg.PeriodStart, arg.ID)
+	return err
+}
+
+const sumGaugeDeltas = `-- name: SumGaugeDeltas :one
+SELECT CAST(COALESCE(SUM(delta), 0) AS REAL) AS total
+FROM gauge_values
+WHERE gauge_id = ?1
+  AND date >= ?2
+  AND date < ?3
+`
+
+type SumGaugeDeltasParams struct {
+	GaugeID     int64     `json:"gauge_id"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+func (q *Queries) SumGaugeDeltas(ctx context.Context, arg SumGaugeDeltasParams) (float64, error) {
+	row := q.db.QueryRowContext(ctx, sumGaugeDeltas, arg.GaugeID, arg.PeriodStart, arg.PeriodEnd)
+	var total float64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const sumGaugeDeltasAt = `-- name: SumGaugeDeltasAt :one
+SELECT COUNT(*) AS found, CAST(COALESCE(SUM(delta), 0) AS REAL) AS total
+FROM gauge_values
+WHERE gauge_id = ? AND date = ?
+`
+
+type SumGaugeDeltasAtParams struct {
+	GaugeID int64     `json:"gauge_id"`
+	Date    time.Time `json:"date"`
+}
+
+type SumGaugeDeltasAtRow struct {
+	Found int64   `json:"found"`
+	Total float64 `json:"total"`
+}
+
+func (q *Queries) SumGaugeDeltasAt(ctx context.Context, arg SumGaugeDeltasAtParams) (SumGaugeDeltasAtRow, error) {
+	row := q.db.QueryRowContext(ctx, sumGaugeDeltasAt, arg.GaugeID, arg.Date)
+	var i SumGaugeDeltasAtRow
+	err := row.Scan(&i.Found, &i.Total)
+	return i, err
+}
+
+const touchAccessToken = `-- name: TouchAccessToken :exec
+UPDATE access_tokens SET last_used_at = ? WHERE id = ?
+`
+
+type TouchAccessTokenParams struct {
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	ID         int64        `json:"id"`
+}
+
+func (q *Queries) TouchAccessToken(ctx context.Context, arg TouchAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, touchAccessToken, arg.LastUsedAt, arg.ID)
+	return err
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = ?,
+    expires_at = ?
+WHERE id = ?
+`
+
+type TouchSessionParams struct {
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	ID         string    `json:"id"`
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchSession, arg.LastSeenAt, arg.ExpiresAt, arg.ID)
+	return err
+}
+
 const updateGauge = `-- name: UpdateGauge :exec
 UPDATE gauges
 SET name = ?,
@@ -223,17 +2111,40 @@ SET name = ?,
     target = ?,
     unit = ?,
     icon = ?,
+    period = ?,
+    period_days = ?,
+    week_start = ?,
+    timezone = ?,
+    goal_type = ?,
+    target_min = ?,
+    step = ?,
+    presets = ?,
+    min_value = ?,
+    max_value = ?,
+    decimals = ?,
+    version = version + 1,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
 `
 
 type UpdateGaugeParams struct {
-	Name        string         `json:"name"`
-	Description sql.NullString `json:"description"`
-	Target      float64        `json:"target"`
-	Unit        string         `json:"unit"`
-	Icon        string         `json:"icon"`
-	ID          int64          `json:"id"`
+	Name        string          `json:"name"`
+	Description sql.NullString  `json:"description"`
+	Target      float64         `json:"target"`
+	Unit        string          `json:"unit"`
+	Icon        string          `json:"icon"`
+	Period      string          `json:"period"`
+	PeriodDays  int64           `json:"period_days"`
+	WeekStart   int64           `json:"week_start"`
+	Timezone    string          `json:"timezone"`
+	GoalType    string          `json:"goal_type"`
+	TargetMin   float64         `json:"target_min"`
+	Step        float64         `json:"step"`
+	Presets     string          `json:"presets"`
+	MinValue    sql.NullFloat64 `json:"min_value"`
+	MaxValue    sql.NullFloat64 `json:"max_value"`
+	Decimals    int64           `json:"decimals"`
+	ID          int64           `json:"id"`
 }
 
 func (q *Queries) UpdateGauge(ctx context.Context, arg UpdateGaugeParams) error {
@@ -243,24 +2154,113 @@ func (q *Queries) UpdateGauge(ctx context.Context, arg UpdateGaugeParams) error
 		arg.Target,
 		arg.Unit,
 		arg.Icon,
+		arg.Period,
+		arg.PeriodDays,
+		arg.WeekStart,
+		arg.Timezone,
+		arg.GoalType,
+		arg.TargetMin,
+		arg.Step,
+		arg.Presets,
+		arg.MinValue,
+		arg.MaxValue,
+		arg.Decimals,
 		arg.ID,
 	)
 	return err
 }
 
-const updateGaugeValue = `-- name: UpdateGaugeValue :exec
-UPDATE gauges
-SET value = ?,
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
 `
 
-type UpdateGaugeValueParams struct {
-	Value float64 `json:"value"`
-	ID    int64   `json:"id"`
+type UpdateUserPasswordParams struct {
+	PasswordHash string `json:"password_hash"`
+	ID           int64  `json:"id"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.PasswordHash, arg.ID)
+	return err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks
+SET name = ?,
+    url = ?,
+    secret = ?,
+    events = ?,
+    active = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+RETURNING id, name, url, secret, events, active, created_at, updated_at
+`
+
+type UpdateWebhookParams struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+	Events string `json:"events"`
+	Active bool   `json:"active"`
+	ID     int64  `json:"id"`
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhook,
+		arg.Name,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+		arg.Active,
+		arg.ID,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = ?,
+    attempts = ?,
+    next_attempt_at = ?,
+    last_attempt_at = ?,
+    response_status = ?,
+    error = ?
+WHERE id = ?
+`
+
+type UpdateWebhookDeliveryParams struct {
+	Status         string        `json:"status"`
+	Attempts       int64         `json:"attempts"`
+	NextAttemptAt  time.Time     `json:"next_attempt_at"`
+	LastAttemptAt  sql.NullTime  `json:"last_attempt_at"`
+	ResponseStatus sql.NullInt64 `json:"response_status"`
+	Error          string        `json:"error"`
+	ID             int64         `json:"id"`
 }
 
-func (q *Queries) UpdateGaugeValue(ctx context.Context, arg UpdateGaugeValueParams) error {
-	_, err := q.db.ExecContext(ctx, updateGaugeValue, arg.Value, arg.ID)
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebhookDelivery,
+		arg.Status,
+		arg.Attempts,
+		arg.NextAttemptAt,
+		arg.LastAttemptAt,
+		arg.ResponseStatus,
+		arg.Error,
+		arg.ID,
+	)
 	return err
 }
@@ -3,21 +3,17 @@ package db
 import (
 	"context"
 	"database/sql"
-	"os"
 	"testing"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 )
 
 func setupTestDB(t *testing.T) (*Queries, func()) {
-	db, err := sql.Open("sqlite3", ":memory:")
+	db, err := Open(DefaultConfig(":memory:"))
 	require.NoError(t, err)
 
-	// Load schema
-	schema, err := os.ReadFile("schema.sql")
-	require.NoError(t, err)
-	_, err = db.Exec(string(schema))
+	_, err = Migrate(context.Background(), db)
 	require.NoError(t, err)
 
 	q := New(db)
@@ -35,4 +31,66 @@ func TestListGauges_Empty(t *testing.T) {
 	require.Len(t, gauges, 0)
 }
 
+func TestListGaugeValuesInRange(t *testing.T) {
+	q, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	gauge, err := q.CreateGauge(ctx, CreateGaugeParams{
+		Name: "Water", Target: 8, Unit: "glasses", Icon: "water",
+		Period: "week", PeriodDays: 7, WeekStart: 1, Timezone: "UTC",
+	})
+	require.NoError(t, err)
+
+	day := func(d int) time.Time { return time.Date(2025, time.March, d, 12, 0, 0, 0, time.UTC) }
+	for _, d := range []int{5, 1, 10, 3} {
+		require.NoError(t, q.CreateGaugeValue(ctx, CreateGaugeValueParams{
+			GaugeID: gauge.ID, Value: float64(d), Delta: float64(d), Source: "web", Date: day(d),
+		}))
+	}
+
+	values, err := q.ListGaugeValuesInRange(ctx, ListGaugeValuesInRangeParams{
+		GaugeID:  gauge.ID,
+		FromDate: day(3),
+		ToDate:   day(10),
+	})
+	require.NoError(t, err)
+
+	// Oldest first, end of the range excluded
+	require.Len(t, values, 2)
+	require.Equal(t, 3.0, values[0].Delta)
+	require.Equal(t, 5.0, values[1].Delta)
+}
+
 // Add more DB tests for edge cases and with inserted data
+
+func TestAuditLog_AppendOnly(t *testing.T) {
+	q, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	for i, action := range []string{"create", "value", "delete"} {
+		_, err := q.CreateAuditEntry(ctx, CreateAuditEntryParams{
+			CreatedAt: now.Add(time.Duration(i) * time.Hour),
+			Action:    action,
+			Entity:    "gauge",
+			EntityID:  1,
+			Changes:   "[]",
+		})
+		require.NoError(t, err)
+	}
+
+	entries, err := q.ListAuditEntries(ctx, ListAuditEntriesParams{
+		FromDate: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "delete", entries[0].Action)
+
+	_, err = q.db.ExecContext(ctx, `UPDATE audit_log SET action = 'create'`)
+	require.ErrorContains(t, err, "append-only")
+	_, err = q.db.ExecContext(ctx, `DELETE FROM audit_log`)
+	require.ErrorContains(t, err, "append-only")
+}
@@ -0,0 +1,528 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"health-monitor/internal/period"
+)
+
+// Sources recorded alongside each gauge value change
+const (
+	SourceWeb = "web"
+	SourceAPI = "api"
+)
+
+// Store wraps Queries with operations that need to run in a transaction
+type Store struct {
+	*Queries
+	db      *sql.DB
+	observe QueryObserver
+	closed  PeriodClosedFunc
+	// tx is set on the store InTx hands its function, whose operations all
+	// join one transaction
+	tx *txState
+}
+
+// txState is what a store in a transaction holds back until it commits
+type txState struct {
+	closed []closedPeriods
+}
+
+// closedPeriods are the periods one rollover archived
+type closedPeriods struct {
+	gauge  Gauge
+	closed []GaugePeriod
+}
+
+// NewStore creates a new Store backed by the given database
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		Queries: New(db),
+		db:      db,
+	}
+}
+
+// ErrVersionConflict is returned when a gauge has changed since the caller
+// read the version it is updating
+var ErrVersionConflict = errors.New("gauge has been modified by another request")
+
+// maxTxAttempts is how many times a transaction is tried when it keeps
+// finding the database locked
+const maxTxAttempts = 5
+
+// execTx runs fn inside a transaction, rolling back if it returns an error.
+// Transactions take the write lock when they begin, so a busy database is
+// retried from the start after a short pause rather than reported. In a
+// store from InTx, fn runs in a savepoint of the transaction already open.
+func (s *Store) execTx(ctx context.Context, fn func(*Queries) error) error {
+	if s.tx != nil {
+		return s.savepoint(ctx, fn)
+	}
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = s.tryTx(ctx, fn)
+		if !isBusy(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+	return err
+}
+
+func (s *Store) tryTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(s.txQueries(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx error: %v, rollback error: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InTx runs fn with a store whose operations all join one transaction, so
+// they and anything written alongside them, such as the audit log, are saved
+// together or not at all. A busy database retries fn from the start. The
+// periods the operations close are reported once the transaction commits.
+func (s *Store) InTx(ctx context.Context, fn func(*Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	var tx *Store
+	err := s.execTx(ctx, func(q *Queries) error {
+		tx = &Store{Queries: q, db: s.db, observe: s.observe, closed: s.closed, tx: &txState{}}
+		return fn(tx)
+	})
+	if err == nil {
+		for _, c := range tx.tx.closed {
+			s.periodsClosed(c.gauge, c.closed)
+		}
+	}
+	return err
+}
+
+// savepoint runs fn in a savepoint of the open transaction, so an operation
+// that fails, or a dry run, undoes its own work and leaves the rest alone
+func (s *Store) savepoint(ctx context.Context, fn func(*Queries) error) error {
+	tx := s.Queries.db
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT store_op"); err != nil {
+		return err
+	}
+	if err := fn(s.Queries); err != nil {
+		// Rolling back to a savepoint keeps it open, so it is released too
+		_, rbErr := tx.ExecContext(ctx, "ROLLBACK TO store_op")
+		if rbErr == nil {
+			_, rbErr = tx.ExecContext(ctx, "RELEASE store_op")
+		}
+		if rbErr != nil {
+			return fmt.Errorf("tx error: %v, rollback error: %v", err, rbErr)
+		}
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "RELEASE store_op")
+	return err
+}
+
+// RecordGaugeValueParams describes a single change to a gauge's value
+type RecordGaugeValueParams struct {
+	GaugeID int64
+	Delta   float64
+	Source  string
+	// Date is when the change happened, defaulting to now. A change dated
+	// before the active period is added to the archived period it falls in
+	// instead of the gauge's value.
+	Date time.Time
+	// ClampAtFloor shrinks a negative delta so the value does not go below
+	// zero, or below the gauge's lowest value when that is higher. The
+	// change recorded is the one actually applied, and nothing is recorded
+	// if that is no change at all.
+	ClampAtFloor bool
+}
+
+// OutOfBoundsError is returned for a change that would take a gauge's value,
+// or the total of the earlier period it is dated in, past the gauge's lowest
+// or highest value. Nothing is changed.
+type OutOfBoundsError struct {
+	// Value is what the change would have made the value
+	Value float64
+	// Bound is the limit it would have passed: the highest value when Above
+	// is set, otherwise the lowest
+	Bound float64
+	Above bool
+}
+
+func (e *OutOfBoundsError) Error() string {
+	if e.Above {
+		return fmt.Sprintf("the value would be %s, over the highest value of %s", formatNumber(e.Value), formatNumber(e.Bound))
+	}
+	return fmt.Sprintf("the value would be %s, under the lowest value of %s", formatNumber(e.Value), formatNumber(e.Bound))
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// tolerance absorbs floating point error when comparing values, such as a
+// value landing exactly on a bound as 0.1 + 0.2 does on 0.3
+const tolerance = 1e-9
+
+// checkBounds refuses a change moving a value from one amount to another
+// past the gauge's bounds. Only the bound the change moves towards is
+// checked, so a value already outside them, such as zero at the start of a
+// period on a gauge whose lowest value is above it, may still move back.
+func checkBounds(gauge Gauge, from, to float64) error {
+	if to > from && gauge.MaxValue.Valid && to > gauge.MaxValue.Float64+tolerance {
+		return &OutOfBoundsError{Value: to, Bound: gauge.MaxValue.Float64, Above: true}
+	}
+	if to < from && gauge.MinValue.Valid && to < gauge.MinValue.Float64-tolerance {
+		return &OutOfBoundsError{Value: to, Bound: gauge.MinValue.Float64}
+	}
+	return nil
+}
+
+// RecordGaugeValue applies a delta to the gauge's value and appends the change
+// to gauge_values in one transaction, returning the updated gauge. The value
+// is updated in SQL and the transaction holds the write lock throughout, so
+// concurrent changes are never lost, nor can they together take the value
+// past the gauge's bounds; a change that would is refused with an
+// OutOfBoundsError.
+func (s *Store) RecordGaugeValue(ctx context.Context, arg RecordGaugeValueParams) (Gauge, error) {
+	now := time.Now()
+	if arg.Date.IsZero() || arg.Date.After(now) {
+		arg.Date = now.UTC()
+	}
+	if arg.Source == "" {
+		arg.Source = SourceWeb
+	}
+
+	var gauge, rolled Gauge
+	var closed []GaugePeriod
+	err := s.execTx(ctx, func(q *Queries) error {
+		current, err := q.GetGauge(ctx, arg.GaugeID)
+		if err != nil {
+			return err
+		}
+
+		// Make sure the change lands in the active period
+		current, closed, err = rolloverGauge(ctx, q, current, now)
+		if err != nil {
+			return err
+		}
+		rolled = current
+
+		if arg.Date.Before(current.PeriodStart.Time) {
+			gauge = current
+			if arg.Delta == 0 {
+				return nil
+			}
+			total, err := periodTotal(ctx, q, current, arg.Date)
+			if err != nil {
+				return err
+			}
+			if err := checkBounds(current, total, total+arg.Delta); err != nil {
+				return err
+			}
+			return recordPastDelta(ctx, q, current, arg, total)
+		}
+
+		delta := arg.Delta
+		if arg.ClampAtFloor {
+			floor := 0.0
+			if current.MinValue.Valid {
+				floor = max(floor, current.MinValue.Float64)
+			}
+			if current.Value+delta < floor {
+				delta = min(floor-current.Value, 0)
+			}
+		}
+		if err := checkBounds(current, current.Value, current.Value+delta); err != nil {
+			return err
+		}
+		gauge, err = recordDelta(ctx, q, current, delta, arg.Source, arg.Date)
+		return err
+	})
+	if err == nil {
+		s.periodsClosed(rolled, closed)
+	}
+
+	return gauge, err
+}
+
+// SetGaugeValueParams describes setting a gauge to an exact value
+type SetGaugeValueParams struct {
+	GaugeID int64
+	Value   float64
+	Source  string
+}
+
+// SetGaugeValue sets the gauge's value for the active period, recording the
+// difference from the current value as the change. It returns the updated
+// gauge, or sql.ErrNoRows if the gauge does not exist.
+func (s *Store) SetGaugeValue(ctx context.Context, arg SetGaugeValueParams) (Gauge, error) {
+	if arg.Source == "" {
+		arg.Source = SourceWeb
+	}
+
+	now := time.Now()
+	var gauge, rolled Gauge
+	var closed []GaugePeriod
+	err := s.execTx(ctx, func(q *Queries) error {
+		current, err := q.GetGauge(ctx, arg.GaugeID)
+		if err != nil {
+			return err
+		}
+		current, closed, err = rolloverGauge(ctx, q, current, now)
+		if err != nil {
+			return err
+		}
+		rolled = current
+
+		gauge, err = recordDelta(ctx, q, current, arg.Value-current.Value, arg.Source, now.UTC())
+		return err
+	})
+	if err == nil {
+		s.periodsClosed(rolled, closed)
+	}
+
+	return gauge, err
+}
+
+// recordDelta adds delta to the gauge's value and logs the change. Nothing is
+// recorded for a zero delta.
+func recordDelta(ctx context.Context, q *Queries, current Gauge, delta float64, source string, date time.Time) (Gauge, error) {
+	if delta == 0 {
+		return current, nil
+	}
+
+	gauge, err := q.AddGaugeValue(ctx, AddGaugeValueParams{
+		ID:    current.ID,
+		Delta: delta,
+	})
+	if err != nil {
+		return gauge, err
+	}
+
+	err = q.CreateGaugeValue(ctx, CreateGaugeValueParams{
+		GaugeID: current.ID,
+		Value:   gauge.Value,
+		Delta:   delta,
+		Source:  source,
+		Date:    date,
+	})
+	return gauge, err
+}
+
+// periodTotal adds up the changes logged in the period a date falls in
+func periodTotal(ctx context.Context, q *Queries, gauge Gauge, date time.Time) (float64, error) {
+	p := period.New(gauge.Period, gauge.PeriodDays, gauge.WeekStart, gauge.Timezone)
+	start, end := p.Bounds(date)
+	return q.SumGaugeDeltas(ctx, SumGaugeDeltasParams{
+		GaugeID:     gauge.ID,
+		PeriodStart: start.UTC(),
+		PeriodEnd:   end.UTC(),
+	})
+}
+
+// recordPastDelta logs a change dated in an earlier period, whose changes so
+// far add up to total. The gauge's value is left alone; the archived period
+// is updated instead, if there is one.
+func recordPastDelta(ctx context.Context, q *Queries, gauge Gauge, arg RecordGaugeValueParams, total float64) error {
+	err := q.CreateGaugeValue(ctx, CreateGaugeValueParams{
+		GaugeID: gauge.ID,
+		Value:   total + arg.Delta,
+		Delta:   arg.Delta,
+		Source:  arg.Source,
+		Date:    arg.Date.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.AddGaugePeriodValue(ctx, AddGaugePeriodValueParams{
+		GaugeID: gauge.ID,
+		Delta:   arg.Delta,
+		Date:    arg.Date.UTC(),
+	})
+}
+
+// EditGaugeParams describes a change to a gauge's settings
+type EditGaugeParams struct {
+	UpdateGaugeParams
+	// Version is the version the caller last saw. The edit is rejected with
+	// ErrVersionConflict if the gauge has moved on; zero skips the check.
+	Version int64
+}
+
+// EditGauge updates a gauge's settings, returning the updated gauge. It
+// returns sql.ErrNoRows if the gauge does not exist.
+func (s *Store) EditGauge(ctx context.Context, arg EditGaugeParams) (Gauge, error) {
+	var gauge Gauge
+	err := s.execTx(ctx, func(q *Queries) error {
+		current, err := q.GetGauge(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+		if arg.Version != 0 && current.Version != arg.Version {
+			return ErrVersionConflict
+		}
+
+		if err := q.UpdateGauge(ctx, arg.UpdateGaugeParams); err != nil {
+			return err
+		}
+
+		gauge, err = q.GetGauge(ctx, arg.ID)
+		return err
+	})
+
+	return gauge, err
+}
+
+// RolloverGauges closes out any gauge whose period has ended, archiving the
+// finished period and resetting the value to the total of the active one
+func (s *Store) RolloverGauges(ctx context.Context, now time.Time) error {
+	gauges, err := s.ListGauges(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, gauge := range gauges {
+		var rolled Gauge
+		var closed []GaugePeriod
+		err := s.execTx(ctx, func(q *Queries) error {
+			// Read the gauge again in the transaction, so a period a
+			// concurrent change has just closed is not reported twice
+			current, err := q.GetGauge(ctx, gauge.ID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			rolled, closed, err = rolloverGauge(ctx, q, current, now)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to roll over gauge %d: %w", gauge.ID, err)
+		}
+		s.periodsClosed(rolled, closed)
+	}
+
+	return nil
+}
+
+// PeriodClosedFunc is told about each period a gauge has moved on from,
+// with the gauge as it starts the active period
+type PeriodClosedFunc func(gauge Gauge, closed GaugePeriod)
+
+// OnPeriodClosed calls fn for every period archived when the store rolls a
+// gauge over, once the rollover is saved. It is meant to be called once,
+// before the store is used.
+func (s *Store) OnPeriodClosed(fn PeriodClosedFunc) {
+	s.closed = fn
+}
+
+func (s *Store) periodsClosed(gauge Gauge, closed []GaugePeriod) {
+	if s.closed == nil || len(closed) == 0 {
+		return
+	}
+	if s.tx != nil {
+		s.tx.closed = append(s.tx.closed, closedPeriods{gauge: gauge, closed: closed})
+		return
+	}
+	for _, p := range closed {
+		s.closed(gauge, p)
+	}
+}
+
+// rolloverGauge moves a gauge into the period containing now. Every period
+// between the one the gauge was last in and the active one is archived, and
+// returned oldest first.
+func rolloverGauge(ctx context.Context, q *Queries, gauge Gauge, now time.Time) (Gauge, []GaugePeriod, error) {
+	p := period.New(gauge.Period, gauge.PeriodDays, gauge.WeekStart, gauge.Timezone)
+	start, end := p.Bounds(now)
+
+	if gauge.PeriodStart.Valid && gauge.PeriodStart.Time.Equal(start) {
+		return gauge, nil, nil
+	}
+
+	var closed []GaugePeriod
+	if gauge.PeriodStart.Valid && gauge.PeriodStart.Time.Before(start) {
+		prevStart, prevEnd := p.Bounds(gauge.PeriodStart.Time)
+		value := gauge.Value
+		for prevStart.Before(start) {
+			archived := CreateGaugePeriodParams{
+				GaugeID:     gauge.ID,
+				PeriodStart: prevStart.UTC(),
+				PeriodEnd:   prevEnd.UTC(),
+				Value:       value,
+				Target:      gauge.Target,
+			}
+			err := q.CreateGaugePeriod(ctx, archived)
+			if err != nil {
+				return gauge, nil, err
+			}
+			closed = append(closed, GaugePeriod{
+				GaugeID:     archived.GaugeID,
+				PeriodStart: archived.PeriodStart,
+				PeriodEnd:   archived.PeriodEnd,
+				Value:       archived.Value,
+				Target:      archived.Target,
+				ClosedAt:    sql.NullTime{Time: now.UTC(), Valid: true},
+			})
+
+			// Periods skipped entirely while the gauge was idle are
+			// archived with whatever was logged into them
+			prevStart, prevEnd = p.Bounds(prevEnd)
+			if !prevStart.Before(start) {
+				break
+			}
+			value, err = q.SumGaugeDeltas(ctx, SumGaugeDeltasParams{
+				GaugeID:     gauge.ID,
+				PeriodStart: prevStart.UTC(),
+				PeriodEnd:   prevEnd.UTC(),
+			})
+			if err != nil {
+				return gauge, nil, err
+			}
+		}
+	}
+
+	value, err := q.SumGaugeDeltas(ctx, SumGaugeDeltasParams{
+		GaugeID:     gauge.ID,
+		PeriodStart: start.UTC(),
+		PeriodEnd:   end.UTC(),
+	})
+	if err != nil {
+		return gauge, nil, err
+	}
+
+	periodStart := sql.NullTime{Time: start.UTC(), Valid: true}
+	err = q.StartGaugePeriod(ctx, StartGaugePeriodParams{
+		ID:          gauge.ID,
+		Value:       value,
+		PeriodStart: periodStart,
+	})
+	if err != nil {
+		return gauge, nil, err
+	}
+
+	gauge.Value = value
+	gauge.PeriodStart = periodStart
+	return gauge, closed, nil
+}
@@ -0,0 +1,366 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/period"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_RecordGaugeValue(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+
+	t.Run("updates value and appends entries", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+
+		updated, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{
+			GaugeID: gauge.ID,
+			Delta:   1,
+			Source:  db.SourceWeb,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, updated.Value)
+
+		updated, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{
+			GaugeID: gauge.ID,
+			Delta:   2.5,
+			Source:  db.SourceAPI,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3.5, updated.Value)
+
+		values, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+
+		// Entries are ordered newest first
+		assert.Equal(t, 3.5, values[0].Value)
+		assert.Equal(t, 2.5, values[0].Delta)
+		assert.Equal(t, db.SourceAPI, values[0].Source)
+		assert.Equal(t, 1.0, values[1].Value)
+		assert.Equal(t, 1.0, values[1].Delta)
+		assert.Equal(t, db.SourceWeb, values[1].Source)
+	})
+
+	t.Run("missing gauge rolls back", func(t *testing.T) {
+		_, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{
+			GaugeID: 9999,
+			Delta:   1,
+		})
+		assert.Error(t, err)
+
+		values, err := store.GetGaugeValues(ctx, 9999)
+		require.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("clamps at zero", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		_, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1.5})
+		require.NoError(t, err)
+
+		updated, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: -2, ClampAtFloor: true})
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, updated.Value)
+
+		// Nothing is left to take away, so nothing is recorded
+		_, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: -1, ClampAtFloor: true})
+		require.NoError(t, err)
+
+		values, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, -1.5, values[0].Delta)
+	})
+
+	t.Run("keeps the value within its bounds", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		err := store.UpdateGauge(ctx, db.UpdateGaugeParams{
+			ID: gauge.ID, Name: gauge.Name, Target: gauge.Target, Unit: gauge.Unit, Icon: gauge.Icon,
+			Period: gauge.Period, PeriodDays: gauge.PeriodDays, WeekStart: gauge.WeekStart, Timezone: gauge.Timezone,
+			GoalType: gauge.GoalType, Step: 1, Presets: gauge.Presets,
+			MinValue: sql.NullFloat64{Float64: 2, Valid: true}, MaxValue: sql.NullFloat64{Float64: 10, Valid: true},
+		})
+		require.NoError(t, err)
+
+		// Zero is under the lowest value, but may still go up towards it
+		_, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+		require.NoError(t, err)
+		_, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 6})
+		require.NoError(t, err)
+
+		var bounds *db.OutOfBoundsError
+		_, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 6})
+		require.ErrorAs(t, err, &bounds, "repeated amounts may not pass the highest value")
+		assert.Equal(t, db.OutOfBoundsError{Value: 13, Bound: 10, Above: true}, *bounds)
+		_, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: -6})
+		require.ErrorAs(t, err, &bounds)
+		assert.Equal(t, db.OutOfBoundsError{Value: 1, Bound: 2}, *bounds)
+
+		// An earlier period's total is bounded too
+		lastWeek := time.Now().UTC().AddDate(0, 0, -7)
+		_, err = store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 11, Date: lastWeek})
+		require.ErrorAs(t, err, &bounds)
+
+		current, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7.0, current.Value, "refused changes are not made")
+		values, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Len(t, values, 2)
+
+		updated, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: -10, ClampAtFloor: true})
+		require.NoError(t, err)
+		assert.Equal(t, 2.0, updated.Value, "clamped at the lowest value")
+	})
+
+	t.Run("bumps the version", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		updated, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+		require.NoError(t, err)
+		assert.Greater(t, updated.Version, gauge.Version)
+	})
+
+	t.Run("dated in an earlier period", func(t *testing.T) {
+		gauge := testutil.CreateTestGauge(t, store)
+		_, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2})
+		require.NoError(t, err)
+
+		lastWeek := time.Now().UTC().AddDate(0, 0, -7)
+		start, end := period.New(gauge.Period, gauge.PeriodDays, gauge.WeekStart, gauge.Timezone).Bounds(lastWeek)
+		require.NoError(t, store.CreateGaugePeriod(ctx, db.CreateGaugePeriodParams{
+			GaugeID: gauge.ID, PeriodStart: start.UTC(), PeriodEnd: end.UTC(), Value: 5, Target: gauge.Target,
+		}))
+
+		updated, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 3, Date: lastWeek})
+		require.NoError(t, err)
+
+		// The active period is untouched and the archived one is corrected
+		assert.Equal(t, 2.0, updated.Value)
+		periods, err := store.ListGaugePeriods(ctx, db.ListGaugePeriodsParams{GaugeID: gauge.ID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, periods, 1)
+		assert.Equal(t, 8.0, periods[0].Value)
+
+		values, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, 3.0, values[1].Delta)
+		assert.WithinDuration(t, lastWeek, values[1].Date, time.Millisecond)
+	})
+}
+
+func TestStore_SetGaugeValue(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	gauge := testutil.CreateTestGauge(t, store)
+
+	_, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 3})
+	require.NoError(t, err)
+
+	updated, err := store.SetGaugeValue(ctx, db.SetGaugeValueParams{GaugeID: gauge.ID, Value: 72.5})
+	require.NoError(t, err)
+	assert.Equal(t, 72.5, updated.Value)
+
+	// Setting the value it already has records nothing
+	_, err = store.SetGaugeValue(ctx, db.SetGaugeValueParams{GaugeID: gauge.ID, Value: 72.5})
+	require.NoError(t, err)
+
+	values, err := store.GetGaugeValues(ctx, gauge.ID)
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+	assert.Equal(t, 72.5, values[0].Value)
+	assert.Equal(t, 69.5, values[0].Delta)
+	assert.Equal(t, db.SourceWeb, values[0].Source)
+
+	_, err = store.SetGaugeValue(ctx, db.SetGaugeValueParams{GaugeID: 9999, Value: 1})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestStore_EditGauge(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	gauge := testutil.CreateTestGauge(t, store)
+
+	params := db.UpdateGaugeParams{
+		ID: gauge.ID, Name: "Renamed", Target: 50, Unit: gauge.Unit, Icon: gauge.Icon,
+		Period: gauge.Period, PeriodDays: gauge.PeriodDays, WeekStart: gauge.WeekStart,
+		Timezone: gauge.Timezone, GoalType: gauge.GoalType,
+	}
+
+	edited, err := store.EditGauge(ctx, db.EditGaugeParams{UpdateGaugeParams: params, Version: gauge.Version})
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", edited.Name)
+	assert.Equal(t, gauge.Version+1, edited.Version)
+
+	// A second edit from the same stale form is rejected
+	params.Name = "Stale"
+	_, err = store.EditGauge(ctx, db.EditGaugeParams{UpdateGaugeParams: params, Version: gauge.Version})
+	assert.ErrorIs(t, err, db.ErrVersionConflict)
+
+	current, err := store.GetGauge(ctx, gauge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", current.Name)
+
+	// Without a version the edit always applies
+	_, err = store.EditGauge(ctx, db.EditGaugeParams{UpdateGaugeParams: params})
+	require.NoError(t, err)
+
+	params.ID = 9999
+	_, err = store.EditGauge(ctx, db.EditGaugeParams{UpdateGaugeParams: params})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestStore_InTx(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	gauge := testutil.CreateTestGauge(t, store)
+	boom := errors.New("boom")
+	value := func() float64 {
+		current, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		return current.Value
+	}
+
+	t.Run("an error undoes every operation", func(t *testing.T) {
+		err := store.InTx(ctx, func(tx *db.Store) error {
+			_, err := tx.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2})
+			require.NoError(t, err)
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+		assert.Equal(t, 0.0, value())
+
+		values, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("a failed operation undoes only itself", func(t *testing.T) {
+		err := store.InTx(ctx, func(tx *db.Store) error {
+			if _, err := tx.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2}); err != nil {
+				return err
+			}
+			_, err := tx.EditGauge(ctx, db.EditGaugeParams{UpdateGaugeParams: db.UpdateGaugeParams{ID: gauge.ID}, Version: 999})
+			assert.ErrorIs(t, err, db.ErrVersionConflict)
+			_, err = tx.ImportGaugeValues(ctx, []db.ImportedValue{{GaugeID: gauge.ID, Delta: 5, Date: time.Now()}}, true)
+			require.NoError(t, err, "a dry run")
+			_, err = tx.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+			return err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, value())
+	})
+
+	t.Run("closed periods are reported once committed", func(t *testing.T) {
+		var closed []db.GaugePeriod
+		store.OnPeriodClosed(func(g db.Gauge, p db.GaugePeriod) {
+			closed = append(closed, p)
+		})
+		lastWeek := func() {
+			err := store.StartGaugePeriod(ctx, db.StartGaugePeriodParams{
+				ID:          gauge.ID,
+				Value:       3,
+				PeriodStart: sql.NullTime{Time: time.Now().AddDate(0, 0, -14), Valid: true},
+			})
+			require.NoError(t, err)
+		}
+
+		lastWeek()
+		err := store.InTx(ctx, func(tx *db.Store) error {
+			_, err := tx.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+			require.NoError(t, err)
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+		assert.Empty(t, closed, "rolled back")
+
+		err = store.InTx(ctx, func(tx *db.Store) error {
+			_, err := tx.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1})
+			assert.Empty(t, closed, "not yet committed")
+			return err
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, closed)
+	})
+}
+
+func TestStore_RolloverGauges(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+
+	gauge := testutil.CreateTestGauge(t, store)
+
+	// Two weeks ago, last week and this week (weeks start on Monday in UTC)
+	now := time.Date(2025, time.March, 12, 12, 0, 0, 0, time.UTC)
+	thisWeek := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	for _, v := range []struct {
+		delta float64
+		date  time.Time
+	}{
+		{3, thisWeek.AddDate(0, 0, -10)},
+		{5, thisWeek.AddDate(0, 0, -3)},
+		{2, thisWeek.AddDate(0, 0, 1)},
+	} {
+		err := store.CreateGaugeValue(ctx, db.CreateGaugeValueParams{
+			GaugeID: gauge.ID,
+			Value:   v.delta,
+			Delta:   v.delta,
+			Source:  db.SourceWeb,
+			Date:    v.date,
+		})
+		require.NoError(t, err)
+	}
+
+	// Pretend the gauge was last rolled over two weeks ago
+	err := store.StartGaugePeriod(ctx, db.StartGaugePeriodParams{
+		ID:          gauge.ID,
+		Value:       3,
+		PeriodStart: sql.NullTime{Time: thisWeek.AddDate(0, 0, -14), Valid: true},
+	})
+	require.NoError(t, err)
+
+	var closed []db.GaugePeriod
+	store.OnPeriodClosed(func(g db.Gauge, p db.GaugePeriod) {
+		assert.Equal(t, gauge.ID, g.ID)
+		assert.Equal(t, 2.0, g.Value, "reported with the gauge in its active period")
+		closed = append(closed, p)
+	})
+
+	require.NoError(t, store.RolloverGauges(ctx, now))
+
+	updated, err := store.GetGauge(ctx, gauge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, updated.Value)
+	assert.True(t, updated.PeriodStart.Time.Equal(thisWeek))
+
+	periods, err := store.ListGaugePeriods(ctx, db.ListGaugePeriodsParams{GaugeID: gauge.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+	assert.Equal(t, 5.0, periods[0].Value)
+	assert.True(t, periods[0].PeriodStart.Equal(thisWeek.AddDate(0, 0, -7)))
+	assert.Equal(t, 3.0, periods[1].Value)
+	assert.True(t, periods[1].PeriodStart.Equal(thisWeek.AddDate(0, 0, -14)))
+	assert.Equal(t, gauge.Target, periods[1].Target)
+
+	require.Len(t, closed, 2, "each archived period is reported")
+	assert.Equal(t, 3.0, closed[0].Value)
+	assert.True(t, closed[0].PeriodStart.Equal(thisWeek.AddDate(0, 0, -14)))
+	assert.Equal(t, 5.0, closed[1].Value)
+
+	// Rolling over again within the same period changes nothing
+	require.NoError(t, store.RolloverGauges(ctx, now.Add(time.Hour)))
+	periods, err = store.ListGaugePeriods(ctx, db.ListGaugePeriodsParams{GaugeID: gauge.ID, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, periods, 2)
+	assert.Len(t, closed, 2)
+}
@@ -0,0 +1,134 @@
+// Package events carries gauge changes to the dashboards that are open.
+//
+// Handlers publish an event after each change is saved, and every open
+// dashboard holds a subscription through the server-sent events endpoint.
+// Events only live in memory: a dashboard that misses some, because it was
+// disconnected or fell behind, catches up by reloading what it shows.
+package events
+
+import (
+	"sync"
+
+	"health-monitor/internal/db"
+)
+
+// Type says what changed
+type Type string
+
+const (
+	// GaugeChanged is published when a gauge's value changes. It carries
+	// the gauge as saved and its value before the change.
+	GaugeChanged Type = "gauge-changed"
+	// GaugeCreated, GaugeUpdated and GaugeDeleted are published when a
+	// gauge is created, has its settings edited or is deleted. They carry
+	// the gauge, as it was last saved for a deleted one.
+	GaugeCreated Type = "gauge-created"
+	GaugeUpdated Type = "gauge-updated"
+	GaugeDeleted Type = "gauge-deleted"
+	// PeriodClosed is published when a gauge moves into a new period. It
+	// carries the archived period and the gauge as it starts the new one.
+	PeriodClosed Type = "period-closed"
+	// GaugesChanged is published when gauges are imported or restored, so
+	// dashboards reload the whole list
+	GaugesChanged Type = "gauges-changed"
+)
+
+// Event is one change to the gauges
+type Event struct {
+	Type  Type
+	Gauge db.Gauge
+	// Previous is the gauge's value before a GaugeChanged event
+	Previous float64
+	// Period is the period a PeriodClosed event closed
+	Period *db.GaugePeriod
+}
+
+// Changed is the event for a gauge whose value changed from previous
+func Changed(gauge db.Gauge, previous float64) Event {
+	return Event{Type: GaugeChanged, Gauge: gauge, Previous: previous}
+}
+
+// Created is the event for a new gauge
+func Created(gauge db.Gauge) Event {
+	return Event{Type: GaugeCreated, Gauge: gauge}
+}
+
+// Updated is the event for a gauge whose settings were edited
+func Updated(gauge db.Gauge) Event {
+	return Event{Type: GaugeUpdated, Gauge: gauge}
+}
+
+// Deleted is the event for a deleted gauge
+func Deleted(gauge db.Gauge) Event {
+	return Event{Type: GaugeDeleted, Gauge: gauge}
+}
+
+// Closed is the event for a period a gauge has moved on from
+func Closed(gauge db.Gauge, period db.GaugePeriod) Event {
+	return Event{Type: PeriodClosed, Gauge: gauge, Period: &period}
+}
+
+// Reload is the event for changes to many gauges at once
+func Reload() Event {
+	return Event{Type: GaugesChanged}
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind by
+// before it is dropped
+const subscriberBuffer = 32
+
+// Broker fans events out to its subscribers. The zero value is ready to
+// use.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroker returns a broker without subscribers
+func NewBroker() *Broker {
+	return &Broker{}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// and a function ending the subscription. The channel is closed when the
+// subscription ends, including when the subscriber falls too far behind,
+// so a closed channel means events may have been missed.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan Event]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends e to every subscriber without waiting on any of them
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribers counts the current subscriptions
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
@@ -0,0 +1,53 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+)
+
+func TestBroker(t *testing.T) {
+	var b events.Broker
+	first, cancelFirst := b.Subscribe()
+	second, cancelSecond := b.Subscribe()
+	defer cancelSecond()
+	assert.Equal(t, 2, b.Subscribers())
+
+	b.Publish(events.Changed(db.Gauge{ID: 3, Value: 5}, 2))
+	for _, ch := range []<-chan events.Event{first, second} {
+		e := <-ch
+		assert.Equal(t, events.GaugeChanged, e.Type)
+		assert.Equal(t, int64(3), e.Gauge.ID)
+		assert.Equal(t, 2.0, e.Previous)
+	}
+
+	cancelFirst()
+	cancelFirst()
+	_, ok := <-first
+	assert.False(t, ok, "a cancelled subscription is closed")
+	assert.Equal(t, 1, b.Subscribers())
+
+	b.Publish(events.Reload())
+	assert.Equal(t, events.GaugesChanged, (<-second).Type)
+}
+
+func TestBroker_SlowSubscriber(t *testing.T) {
+	b := events.NewBroker()
+	slow, cancel := b.Subscribe()
+	defer cancel()
+
+	for i := 0; i < 100; i++ {
+		b.Publish(events.Reload())
+	}
+	assert.Equal(t, 0, b.Subscribers(), "a subscriber that falls behind is dropped")
+
+	received := 0
+	for range slow {
+		received++
+	}
+	require.Less(t, received, 100)
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/alert"
+	"health-monitor/internal/db"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+)
+
+// alertHistoryLimit is how many alerts the alerts page lists
+const alertHistoryLimit = 50
+
+// AlertStore holds the notification channels, alert rules and the alerts
+// sent
+type AlertStore interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	ListNotificationChannels(ctx context.Context) ([]db.NotificationChannel, error)
+	GetNotificationChannel(ctx context.Context, id int64) (db.NotificationChannel, error)
+	CreateNotificationChannel(ctx context.Context, arg db.CreateNotificationChannelParams) (db.NotificationChannel, error)
+	DeleteNotificationChannel(ctx context.Context, id int64) error
+	ListAlertRules(ctx context.Context) ([]db.AlertRule, error)
+	CreateAlertRule(ctx context.Context, arg db.CreateAlertRuleParams) (db.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id int64) error
+	ListAlertHistory(ctx context.Context, limit int64) ([]db.AlertHistory, error)
+}
+
+// AlertTester sends a test notification through a channel
+type AlertTester interface {
+	Test(ctx context.Context, channel db.NotificationChannel) error
+}
+
+// AlertHandler serves the admin page for alerts
+type AlertHandler struct {
+	store  AlertStore
+	tester AlertTester
+}
+
+func NewAlertHandler(store AlertStore, tester AlertTester) *AlertHandler {
+	return &AlertHandler{
+		store:  store,
+		tester: tester,
+	}
+}
+
+// RegisterRoutes registers the alert admin routes on the provided router
+func (h *AlertHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/alerts", func(r chi.Router) {
+		r.Get("/", h.handleList)
+		r.Post("/channels", h.handleCreateChannel)
+		r.Post("/channels/{id}/delete", h.handleDeleteChannel)
+		r.Post("/channels/{id}/test", h.handleTestChannel)
+		r.Post("/rules", h.handleCreateRule)
+		r.Post("/rules/{id}/delete", h.handleDeleteRule)
+	})
+}
+
+// handleList renders the rules, channels and recent alerts
+func (h *AlertHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, pages.AlertsView{})
+}
+
+// handleCreateChannel adds a notification channel
+func (h *AlertHandler) handleCreateChannel(w http.ResponseWriter, r *http.Request) {
+	form := readChannelForm(r)
+	if len(form.Errors) > 0 {
+		h.render(w, r, pages.AlertsView{Channel: form})
+		return
+	}
+
+	_, err := h.store.CreateNotificationChannel(r.Context(), db.CreateNotificationChannelParams{
+		Name:       form.Name,
+		Kind:       form.Kind,
+		Target:     form.Target,
+		QuietStart: form.QuietStart,
+		QuietEnd:   form.QuietEnd,
+		Active:     form.Active,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create channel: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/alerts", http.StatusSeeOther)
+}
+
+// handleDeleteChannel removes a channel along with the rules sending to it
+func (h *AlertHandler) handleDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.loadChannel(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteNotificationChannel(r.Context(), channel.ID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete channel: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/alerts", http.StatusSeeOther)
+}
+
+// handleTestChannel sends a test notification and reports how it went
+func (h *AlertHandler) handleTestChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.loadChannel(w, r)
+	if !ok {
+		return
+	}
+	view := pages.AlertsView{Notice: fmt.Sprintf("Sent a test notification to %s.", channel.Name)}
+	if err := h.tester.Test(r.Context(), channel); err != nil {
+		view.Notice = fmt.Sprintf("Could not send to %s: %v", channel.Name, err)
+		view.Failed = true
+	}
+	h.render(w, r, view)
+}
+
+// handleCreateRule adds an alert rule
+func (h *AlertHandler) handleCreateRule(w http.ResponseWriter, r *http.Request) {
+	form, threshold := readRuleForm(r)
+	if len(form.Errors) > 0 {
+		h.render(w, r, pages.AlertsView{Rule: form})
+		return
+	}
+
+	_, err := h.store.CreateAlertRule(r.Context(), db.CreateAlertRuleParams{
+		GaugeID:   form.GaugeID,
+		ChannelID: form.ChannelID,
+		Kind:      form.Kind,
+		Threshold: threshold,
+		CheckAt:   form.CheckAt,
+		Active:    form.Active,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create alert rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/alerts", http.StatusSeeOther)
+}
+
+// handleDeleteRule removes an alert rule along with its history
+func (h *AlertHandler) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid rule ID: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.store.DeleteAlertRule(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete alert rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/alerts", http.StatusSeeOther)
+}
+
+func (h *AlertHandler) loadChannel(w http.ResponseWriter, r *http.Request) (db.NotificationChannel, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid channel ID: %v", err), http.StatusBadRequest)
+		return db.NotificationChannel{}, false
+	}
+
+	channel, err := h.store.GetNotificationChannel(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return channel, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get channel: %v", err), http.StatusInternalServerError)
+		return channel, false
+	}
+	return channel, true
+}
+
+// readChannelForm reads and checks a submitted channel
+func readChannelForm(r *http.Request) pages.ChannelForm {
+	r.ParseForm()
+	form := pages.ChannelForm{
+		Name:       strings.TrimSpace(r.FormValue("name")),
+		Kind:       r.FormValue("kind"),
+		Target:     strings.TrimSpace(r.FormValue("target")),
+		QuietStart: strings.TrimSpace(r.FormValue("quiet_start")),
+		QuietEnd:   strings.TrimSpace(r.FormValue("quiet_end")),
+		Active:     r.FormValue("active") == "true",
+	}
+
+	if form.Name == "" {
+		form.Errors = append(form.Errors, components.FormError{Field: "name", Message: "Name is required"})
+	}
+	if err := notify.CheckTarget(form.Kind, form.Target); err != nil {
+		form.Errors = append(form.Errors, components.FormError{Field: "target", Message: err.Error()})
+	}
+	if form.QuietStart != "" || form.QuietEnd != "" {
+		if _, err := alert.ParseClock(form.QuietStart); err != nil {
+			form.Errors = append(form.Errors, components.FormError{Field: "quiet_start", Message: "Quiet hours need a start time"})
+		}
+		if _, err := alert.ParseClock(form.QuietEnd); err != nil {
+			form.Errors = append(form.Errors, components.FormError{Field: "quiet_end", Message: "Quiet hours need an end time"})
+		}
+	}
+	return form
+}
+
+// readRuleForm reads and checks a submitted rule, returning the threshold
+// as a number
+func readRuleForm(r *http.Request) (pages.RuleForm, float64) {
+	r.ParseForm()
+	form := pages.RuleForm{
+		Kind:      r.FormValue("rule_kind"),
+		Threshold: strings.TrimSpace(r.FormValue("threshold")),
+		CheckAt:   strings.TrimSpace(r.FormValue("check_at")),
+		Active:    r.FormValue("active") == "true",
+	}
+	form.GaugeID, _ = strconv.ParseInt(r.FormValue("gauge_id"), 10, 64)
+	form.ChannelID, _ = strconv.ParseInt(r.FormValue("channel_id"), 10, 64)
+
+	if form.GaugeID == 0 {
+		form.Errors = append(form.Errors, components.FormError{Field: "gauge_id", Message: "Choose a gauge"})
+	}
+	if form.ChannelID == 0 {
+		form.Errors = append(form.Errors, components.FormError{Field: "channel_id", Message: "Choose a channel"})
+	}
+
+	var threshold float64
+	switch form.Kind {
+	case alert.KindBelowPercent, alert.KindNoEntry:
+		var err error
+		threshold, err = strconv.ParseFloat(form.Threshold, 64)
+		if err != nil || threshold <= 0 {
+			form.Errors = append(form.Errors, components.FormError{Field: "threshold", Message: "Threshold must be a number above zero"})
+		}
+		if form.Kind == alert.KindBelowPercent {
+			if _, err := alert.ParseClock(form.CheckAt); err != nil {
+				form.Errors = append(form.Errors, components.FormError{Field: "check_at", Message: "Choose the time of day to check by"})
+			}
+		} else {
+			form.CheckAt = ""
+		}
+	case alert.KindOverTarget:
+		form.CheckAt = ""
+	default:
+		form.Errors = append(form.Errors, components.FormError{Field: "rule_kind", Message: "Choose what to alert on"})
+	}
+	return form, threshold
+}
+
+// render renders the alerts page. Like other form errors it is sent with
+// 200.
+func (h *AlertHandler) render(w http.ResponseWriter, r *http.Request, view pages.AlertsView) {
+	ctx := r.Context()
+	var err error
+	if view.Channels, err = h.store.ListNotificationChannels(ctx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get channels: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if view.Rules, err = h.store.ListAlertRules(ctx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get alert rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if view.Gauges, err = h.store.ListGauges(ctx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get gauges: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if view.History, err = h.store.ListAlertHistory(ctx, alertHistoryLimit); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get alerts: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// A form is only filled in when it was submitted and did not save
+	if view.Channel.Errors == nil {
+		view.Channel = pages.ChannelForm{Kind: notify.KindNtfy, Active: true}
+	}
+	if view.Rule.Errors == nil {
+		view.Rule = pages.RuleForm{Kind: alert.KindBelowPercent, Threshold: "50", CheckAt: "18:00", Active: true}
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = pages.AlertsPage(view).Render(ctx, w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"health-monitor/internal/alert"
+	"health-monitor/internal/db"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTester struct {
+	tested []int64
+	err    error
+}
+
+func (f *fakeTester) Test(ctx context.Context, channel db.NotificationChannel) error {
+	f.tested = append(f.tested, channel.ID)
+	return f.err
+}
+
+func TestAlertHandlers(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	gauge := testutil.CreateTestGauge(t, store)
+	tester := &fakeTester{}
+	router := chi.NewRouter()
+	NewAlertHandler(store, tester).RegisterRoutes(router)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("rejects an invalid channel", func(t *testing.T) {
+		w := post("/admin/alerts/channels", url.Values{
+			"name":        {"Phone"},
+			"kind":        {notify.KindNtfy},
+			"target":      {"ntfy.sh/health"},
+			"quiet_start": {"22:00"},
+		})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "is not an http or https URL")
+		assert.Contains(t, w.Body.String(), "Quiet hours need an end time")
+		channels, err := store.ListNotificationChannels(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, channels)
+	})
+
+	var channel db.NotificationChannel
+	t.Run("creates a channel", func(t *testing.T) {
+		w := post("/admin/alerts/channels", url.Values{
+			"name":        {"Phone"},
+			"kind":        {notify.KindNtfy},
+			"target":      {"https://ntfy.sh/health"},
+			"quiet_start": {"22:00"},
+			"quiet_end":   {"07:00"},
+			"active":      {"true"},
+		})
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		channels, err := store.ListNotificationChannels(ctx)
+		require.NoError(t, err)
+		require.Len(t, channels, 1)
+		channel = channels[0]
+		assert.Equal(t, "22:00", channel.QuietStart)
+		assert.True(t, channel.Active)
+	})
+
+	t.Run("sends a test notification", func(t *testing.T) {
+		w := post("/admin/alerts/channels/"+strconv.FormatInt(channel.ID, 10)+"/test", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Sent a test notification to Phone.")
+
+		tester.err = errors.New("403 Forbidden")
+		w = post("/admin/alerts/channels/"+strconv.FormatInt(channel.ID, 10)+"/test", nil)
+		assert.Contains(t, w.Body.String(), "Could not send to Phone: 403 Forbidden")
+		assert.Equal(t, []int64{channel.ID, channel.ID}, tester.tested)
+	})
+
+	t.Run("rejects a rule without a time of day", func(t *testing.T) {
+		w := post("/admin/alerts/rules", url.Values{
+			"gauge_id":   {strconv.FormatInt(gauge.ID, 10)},
+			"channel_id": {strconv.FormatInt(channel.ID, 10)},
+			"rule_kind":  {alert.KindBelowPercent},
+			"threshold":  {"50"},
+		})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Choose the time of day to check by")
+	})
+
+	var rule db.AlertRule
+	t.Run("creates a rule", func(t *testing.T) {
+		w := post("/admin/alerts/rules", url.Values{
+			"gauge_id":   {strconv.FormatInt(gauge.ID, 10)},
+			"channel_id": {strconv.FormatInt(channel.ID, 10)},
+			"rule_kind":  {alert.KindNoEntry},
+			"threshold":  {"2"},
+			"check_at":   {"18:00"},
+			"active":     {"true"},
+		})
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		rules, err := store.ListAlertRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		rule = rules[0]
+		assert.Equal(t, 2.0, rule.Threshold)
+		assert.Empty(t, rule.CheckAt, "only below percent rules keep a time of day")
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/alerts", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "no entry for 2 days")
+	})
+
+	t.Run("deletes a channel with its rules", func(t *testing.T) {
+		w := post("/admin/alerts/channels/"+strconv.FormatInt(channel.ID, 10)+"/delete", nil)
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		rules, err := store.ListAlertRules(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, rules)
+
+		w = post("/admin/alerts/channels/"+strconv.FormatInt(channel.ID, 10)+"/delete", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultValuesLimit = 50
+	maxValuesLimit     = 500
+)
+
+// APIHandler serves the versioned JSON API
+type APIHandler struct {
+	queries Querier
+	events  Events
+}
+
+func NewAPIHandler(queries Querier) *APIHandler {
+	return &APIHandler{
+		queries: queries,
+	}
+}
+
+// RegisterRoutes registers all JSON API routes on the provided router
+func (h *APIHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/api/openapi.json", h.handleOpenAPI)
+
+	// Requests made with an access token may only do what its scopes allow
+	gaugesRead := auth.RequireScope(auth.ScopeGaugesRead)
+	gaugesWrite := auth.RequireScope(auth.ScopeGaugesWrite)
+	valuesRead := auth.RequireScope(auth.ScopeValuesRead)
+	valuesWrite := auth.RequireScope(auth.ScopeValuesWrite)
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.With(gaugesRead).Get("/gauges", h.handleListGauges)
+		r.With(gaugesWrite).Post("/gauges", h.handleCreateGauge)
+
+		r.Route("/gauges/{id}", func(r chi.Router) {
+			r.With(gaugesRead).Get("/", h.handleGetGauge)
+			r.With(gaugesWrite).Put("/", h.handleUpdateGauge)
+			r.With(gaugesWrite).Delete("/", h.handleDeleteGauge)
+
+			r.With(valuesRead).Get("/values", h.handleListValues)
+			r.With(valuesWrite).Post("/values", h.handleCreateValue)
+			r.With(gaugesRead).Get("/history", h.handleGetHistory)
+			r.With(gaugesRead).Get("/trends", h.handleGetTrends)
+		})
+
+		// The scope export and import need depends on the kind of file
+		r.Get("/export", h.handleExport)
+		r.Post("/import", h.handleImport)
+	})
+}
+
+// handleOpenAPI serves the OpenAPI document describing the API
+func (h *APIHandler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	models.WriteJSON(w, APISpec())
+}
+
+// handleListGauges returns every gauge with its current status
+func (h *APIHandler) handleListGauges(w http.ResponseWriter, r *http.Request) {
+	gauges, err := h.queries.ListGauges(r.Context())
+	if err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to get gauges: %v", err)))
+		return
+	}
+
+	models.WriteJSON(w, models.NewGaugeList(gauges))
+}
+
+// handleGetGauge returns a single gauge, or 304 Not Modified if the client's
+// copy is still current
+func (h *APIHandler) handleGetGauge(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	etag := gaugeETag(gauge)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	models.WriteJSON(w, models.NewGaugeWithValue(&gauge))
+}
+
+// handleCreateGauge creates a gauge from a JSON body
+func (h *APIHandler) handleCreateGauge(w http.ResponseWriter, r *http.Request) {
+	input, appErr := readGaugeInput(r, models.DefaultGaugeInput())
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	gauge, err := h.queries.CreateGauge(r.Context(), input.CreateParams())
+	if err != nil {
+		models.WriteError(w, gaugeAppError(err, 0, "create gauge"))
+		return
+	}
+	publish(h.events, events.Created(gauge))
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/gauges/%d", gauge.ID))
+	w.Header().Set("ETag", gaugeETag(gauge))
+	models.WriteJSONStatus(w, http.StatusCreated, models.NewGaugeWithValue(&gauge))
+}
+
+// handleUpdateGauge replaces a gauge's editable fields. Fields omitted from the
+// body keep their current values. An If-Match header makes the update
+// conditional on the gauge not having changed since the client read it.
+func (h *APIHandler) handleUpdateGauge(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	version, ok := ifMatchVersion(r)
+	if !ok || (version != 0 && version != gauge.Version) {
+		models.WriteError(w, models.NewPreconditionFailedError("Gauge has been modified; fetch it again and retry"))
+		return
+	}
+
+	input, appErr := readGaugeInput(r, models.NewGaugeInput(&gauge))
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	// Omitted fields were filled in from the gauge as loaded, so the update
+	// only applies to that version even without If-Match
+	updated, err := h.queries.EditGauge(r.Context(), db.EditGaugeParams{
+		UpdateGaugeParams: input.UpdateParams(gauge.ID),
+		Version:           gauge.Version,
+	})
+	if errors.Is(err, db.ErrVersionConflict) {
+		if version != 0 {
+			models.WriteError(w, models.NewPreconditionFailedError("Gauge has been modified; fetch it again and retry"))
+		} else {
+			models.WriteError(w, models.NewConflictError("Gauge was modified during the update; retry"))
+		}
+		return
+	}
+	if err != nil {
+		models.WriteError(w, gaugeAppError(err, gauge.ID, "update gauge"))
+		return
+	}
+	publish(h.events, events.Updated(updated))
+
+	w.Header().Set("ETag", gaugeETag(updated))
+	models.WriteJSON(w, models.NewGaugeWithValue(&updated))
+}
+
+// handleDeleteGauge deletes a gauge and its values
+func (h *APIHandler) handleDeleteGauge(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	if err := h.queries.DeleteGauge(r.Context(), gauge.ID); err != nil {
+		models.WriteError(w, gaugeAppError(err, gauge.ID, "delete gauge"))
+		return
+	}
+	publish(h.events, events.Deleted(gauge))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListValues returns a page of a gauge's values, newest first
+func (h *APIHandler) handleListValues(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	params, appErr := parseValuesQuery(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+	params.GaugeID = gauge.ID
+
+	values, err := h.queries.ListGaugeValues(r.Context(), params)
+	if err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to get values: %v", err)))
+		return
+	}
+
+	total, err := h.queries.CountGaugeValues(r.Context(), db.CountGaugeValuesParams{
+		GaugeID:  gauge.ID,
+		FromDate: params.FromDate,
+		ToDate:   params.ToDate,
+	})
+	if err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to count values: %v", err)))
+		return
+	}
+
+	models.WriteJSON(w, models.GaugeValueList{
+		Values: values,
+		Total:  total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	})
+}
+
+// handleCreateValue logs a change to a gauge's value, checked against the
+// gauge's entry settings like an amount logged on its card
+func (h *APIHandler) handleCreateValue(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	var input models.GaugeValueInput
+	if err := models.ReadJSON(r, &input); err != nil {
+		models.WriteError(w, models.NewValidationError(err.Error()))
+		return
+	}
+	if input.Delta == 0 {
+		models.WriteError(w, models.NewValidationError("delta must be a non-zero number"))
+		return
+	}
+	var fieldErrors []models.FieldError
+	if fieldErr := models.ValidateAmount(&gauge, "delta", "delta", input.Delta); fieldErr != nil {
+		fieldErrors = append(fieldErrors, *fieldErr)
+	}
+	date, dateErr := parseEntryDate(&gauge, input.Date, time.Now())
+	if dateErr != nil {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: dateErr.Field, Message: dateErr.Message})
+	}
+	if len(fieldErrors) > 0 {
+		models.WriteError(w, fieldErrorsToAppError(fieldErrors))
+		return
+	}
+
+	updated, err := h.queries.RecordGaugeValue(r.Context(), db.RecordGaugeValueParams{
+		GaugeID: gauge.ID,
+		Delta:   input.Delta,
+		Source:  db.SourceAPI,
+		Date:    date,
+	})
+	if msg := models.OutOfBoundsMessage(&gauge, err); msg != "" {
+		models.WriteError(w, fieldErrorsToAppError([]models.FieldError{{Field: "delta", Message: msg}}))
+		return
+	}
+	if err != nil {
+		models.WriteError(w, gaugeAppError(err, gauge.ID, "record value"))
+		return
+	}
+	publish(h.events, changed(gauge, updated))
+
+	w.Header().Set("ETag", gaugeETag(updated))
+	models.WriteJSONStatus(w, http.StatusCreated, models.NewGaugeWithValue(&updated))
+}
+
+// handleGetHistory returns the gauge's values aggregated by month
+func (h *APIHandler) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	history, err := h.queries.GetGaugeHistory(r.Context(), gauge.ID)
+	if err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to get history: %v", err)))
+		return
+	}
+
+	models.WriteJSON(w, models.NewGaugeHistory(&gauge, history))
+}
+
+// handleGetTrends returns the gauge's values aggregated into buckets
+func (h *APIHandler) handleGetTrends(w http.ResponseWriter, r *http.Request) {
+	gauge, appErr := h.loadGauge(r)
+	if appErr != nil {
+		models.WriteError(w, appErr)
+		return
+	}
+
+	query, fieldErrors := parseTrendQuery(r, &gauge, time.Now())
+	if len(fieldErrors) > 0 {
+		models.WriteError(w, fieldErrorsToAppError(fieldErrors))
+		return
+	}
+
+	trend, err := loadTrend(r.Context(), h.queries, &gauge, query)
+	if err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to get values: %v", err)))
+		return
+	}
+
+	models.WriteJSON(w, trend)
+}
+
+// loadGauge fetches the gauge named by the {id} URL parameter
+func (h *APIHandler) loadGauge(r *http.Request) (db.Gauge, *models.AppError) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return db.Gauge{}, models.NewValidationError("Invalid gauge ID")
+	}
+
+	return h.getGauge(r, id)
+}
+
+// getGauge fetches a gauge by ID
+func (h *APIHandler) getGauge(r *http.Request, id int64) (db.Gauge, *models.AppError) {
+	gauge, err := h.queries.GetGauge(r.Context(), id)
+	if err != nil {
+		return db.Gauge{}, gaugeAppError(err, id, "get gauge")
+	}
+
+	return gauge, nil
+}
+
+// gaugeAppError is the API's answer to a failed gauge query: not found for a
+// gauge that does not exist or is not shared with the user, forbidden for a
+// change they may not make and an internal error otherwise. The id is zero
+// for a gauge being created.
+func gaugeAppError(err error, id int64, doing string) *models.AppError {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.NewNotFoundError(fmt.Sprintf("Gauge %d not found", id))
+	case errors.Is(err, access.ErrForbidden) && id == 0:
+		return models.NewForbiddenError(fmt.Sprintf("You may not %s", doing))
+	case errors.Is(err, access.ErrForbidden):
+		return models.NewForbiddenError(fmt.Sprintf("You may not change gauge %d", id))
+	}
+	return models.NewInternalError(fmt.Sprintf("Failed to %s: %v", doing, err))
+}
+
+// readGaugeInput decodes a gauge from the request body on top of the given
+// defaults and validates it
+func readGaugeInput(r *http.Request, input models.GaugeInput) (models.GaugeInput, *models.AppError) {
+	if err := models.ReadJSON(r, &input); err != nil {
+		return input, models.NewValidationError(err.Error())
+	}
+
+	if fieldErrors := input.Validate(); len(fieldErrors) > 0 {
+		return input, fieldErrorsToAppError(fieldErrors)
+	}
+
+	return input, nil
+}
+
+// fieldErrorsToAppError joins validation messages into a single error
+func fieldErrorsToAppError(fieldErrors []models.FieldError) *models.AppError {
+	messages := make([]string, len(fieldErrors))
+	for i, fieldErr := range fieldErrors {
+		messages[i] = fieldErr.Message
+	}
+	return models.NewValidationError(strings.Join(messages, "; "))
+}
+
+// parseValuesQuery reads the from, to, limit and offset query parameters
+func parseValuesQuery(r *http.Request) (db.ListGaugeValuesParams, *models.AppError) {
+	query := r.URL.Query()
+	params := db.ListGaugeValuesParams{
+		FromDate: time.Time{},
+		ToDate:   time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+		Limit:    defaultValuesLimit,
+	}
+
+	if from := query.Get("from"); from != "" {
+		t, _, err := parseDateParam(from)
+		if err != nil {
+			return params, models.NewValidationError("from must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		}
+		params.FromDate = t
+	}
+
+	if to := query.Get("to"); to != "" {
+		t, dateOnly, err := parseDateParam(to)
+		if err != nil {
+			return params, models.NewValidationError("to must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		}
+		// A bare date includes the whole day
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		params.ToDate = t
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n < 1 || n > maxValuesLimit {
+			return params, models.NewValidationError(fmt.Sprintf("limit must be between 1 and %d", maxValuesLimit))
+		}
+		params.Limit = n
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || n < 0 {
+			return params, models.NewValidationError("offset must be a non-negative integer")
+		}
+		params.Offset = n
+	}
+
+	return params, nil
+}
+
+// parseDateParam parses a YYYY-MM-DD date or an RFC 3339 timestamp as UTC,
+// reporting whether only a date was given
+func parseDateParam(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.UTC(), false, nil
+}
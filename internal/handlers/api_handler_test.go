@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"health-monitor/internal/access"
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+	"health-monitor/internal/transfer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper function to send a request through the router and record the response
+func serveAPI(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, path, nil)
+	} else {
+		r = httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestAPIHandler(t *testing.T) {
+	queries := &db.MockQueries{}
+	handler := NewAPIHandler(queries)
+
+	// Every response must match the OpenAPI document
+	router := chi.NewRouter()
+	router.Use(APISpec().ValidateResponses("/api/v1", func(r *http.Request, err error) {
+		t.Errorf("%s %s: %v", r.Method, r.URL, err)
+	}))
+	handler.RegisterRoutes(router)
+
+	testGauge := db.Gauge{
+		ID:         1,
+		Name:       "Water",
+		Target:     8,
+		Value:      3,
+		Unit:       "glasses",
+		Icon:       "water",
+		Period:     "week",
+		PeriodDays: 7,
+		WeekStart:  1,
+		Timezone:   "UTC",
+		Version:    5,
+	}
+	queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+		if id != testGauge.ID {
+			return db.Gauge{}, sql.ErrNoRows
+		}
+		return testGauge, nil
+	}
+
+	t.Run("List gauges", func(t *testing.T) {
+		queries.ListGaugesFn = func(ctx context.Context) ([]db.Gauge, error) {
+			return []db.Gauge{testGauge}, nil
+		}
+
+		w := serveAPI(router, "GET", "/api/v1/gauges", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var gauges []models.GaugeWithValue
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gauges))
+		require.Len(t, gauges, 1)
+		assert.Equal(t, "Water", gauges[0].Name)
+		assert.Equal(t, 37.5, gauges[0].Status.Percent)
+	})
+
+	t.Run("Get gauge", func(t *testing.T) {
+		t.Run("success", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/gauges/1", "")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `"name":"Water"`)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/gauges/42", "")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			var appErr models.AppError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &appErr))
+			assert.Equal(t, "not_found", appErr.Type)
+		})
+
+		t.Run("invalid id", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/gauges/abc", "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "validation_error")
+		})
+	})
+
+	t.Run("Create gauge", func(t *testing.T) {
+		t.Run("success", func(t *testing.T) {
+			var created db.CreateGaugeParams
+			queries.CreateGaugeFn = func(ctx context.Context, params db.CreateGaugeParams) (db.Gauge, error) {
+				created = params
+				return db.Gauge{ID: 2, Name: params.Name, Target: params.Target, Unit: params.Unit, Icon: params.Icon}, nil
+			}
+
+			w := serveAPI(router, "POST", "/api/v1/gauges", `{"name":"Steps","icon":"steps","unit":"steps","target":10000}`)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Equal(t, "/api/v1/gauges/2", w.Header().Get("Location"))
+			assert.Equal(t, "Steps", created.Name)
+			assert.Equal(t, 10000.0, created.Target)
+			assert.Equal(t, "week", created.Period)
+			assert.Equal(t, "UTC", created.Timezone)
+		})
+
+		t.Run("validation error", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/gauges", `{"name":"","unit":"steps"}`)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Name is required")
+		})
+
+		t.Run("malformed body", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/gauges", `{`)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "validation_error")
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			queries.CreateGaugeFn = func(ctx context.Context, params db.CreateGaugeParams) (db.Gauge, error) {
+				return db.Gauge{}, access.ErrForbidden
+			}
+
+			w := serveAPI(router, "POST", "/api/v1/gauges", `{"name":"Steps","icon":"steps","unit":"steps","target":10000}`)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Contains(t, w.Body.String(), "You may not create gauge")
+		})
+	})
+
+	t.Run("Update gauge", func(t *testing.T) {
+		var updated db.EditGaugeParams
+		queries.EditGaugeFn = func(ctx context.Context, params db.EditGaugeParams) (db.Gauge, error) {
+			updated = params
+			g := testGauge
+			g.Target = params.Target
+			g.Version++
+			return g, nil
+		}
+
+		t.Run("success", func(t *testing.T) {
+			w := serveAPI(router, "PUT", "/api/v1/gauges/1", `{"target":10}`)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, int64(1), updated.ID)
+			assert.Equal(t, 10.0, updated.Target)
+			// Omitted fields keep their current values
+			assert.Equal(t, "Water", updated.Name)
+			assert.Equal(t, "glasses", updated.Unit)
+			// The update only applies to the version the fields came from
+			assert.Equal(t, int64(5), updated.Version)
+			assert.Equal(t, `"6"`, w.Header().Get("ETag"))
+		})
+
+		t.Run("matching If-Match", func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/api/v1/gauges/1", strings.NewReader(`{"target":10}`))
+			r.Header.Set("If-Match", `"5"`)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+
+		t.Run("stale If-Match", func(t *testing.T) {
+			updated = db.EditGaugeParams{}
+			r := httptest.NewRequest("PUT", "/api/v1/gauges/1", strings.NewReader(`{"target":10}`))
+			r.Header.Set("If-Match", `"4"`)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+			assert.Contains(t, w.Body.String(), "precondition_failed")
+			assert.Zero(t, updated.ID, "nothing is written")
+		})
+
+		t.Run("changed during the update", func(t *testing.T) {
+			queries.EditGaugeFn = func(ctx context.Context, params db.EditGaugeParams) (db.Gauge, error) {
+				return db.Gauge{}, db.ErrVersionConflict
+			}
+
+			w := serveAPI(router, "PUT", "/api/v1/gauges/1", `{"target":10}`)
+
+			assert.Equal(t, http.StatusConflict, w.Code)
+		})
+	})
+
+	t.Run("Get gauge ETag", func(t *testing.T) {
+		w := serveAPI(router, "GET", "/api/v1/gauges/1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+
+		r := httptest.NewRequest("GET", "/api/v1/gauges/1", nil)
+		r.Header.Set("If-None-Match", `"5"`)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		r.Header.Set("If-None-Match", `"4"`)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Delete gauge", func(t *testing.T) {
+		var deleted int64
+		queries.DeleteGaugeFn = func(ctx context.Context, id int64) error {
+			deleted = id
+			return nil
+		}
+
+		w := serveAPI(router, "DELETE", "/api/v1/gauges/1", "")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, int64(1), deleted)
+	})
+
+	t.Run("Create value", func(t *testing.T) {
+		t.Run("success", func(t *testing.T) {
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				g := testGauge
+				g.Value += params.Delta
+				return g, nil
+			}
+
+			w := serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":2}`)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Equal(t, 2.0, recorded.Delta)
+			assert.Equal(t, db.SourceAPI, recorded.Source)
+			assert.Contains(t, w.Body.String(), `"value":5`)
+		})
+
+		t.Run("zero delta", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":0}`)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+
+		t.Run("on an earlier day", func(t *testing.T) {
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				return testGauge, nil
+			}
+
+			w := serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":2,"date":"2025-01-02"}`)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.True(t, recorded.Date.Equal(time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)), "dated %s", recorded.Date)
+		})
+
+		t.Run("invalid date", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":2,"date":"yesterday"}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+			w = serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":2,"date":"`+tomorrow+`"}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "future")
+		})
+
+		t.Run("entry settings", func(t *testing.T) {
+			limited := testGauge
+			limited.MaxValue = sql.NullFloat64{Float64: 5, Valid: true}
+			limited.Decimals = 1
+			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+				return limited, nil
+			}
+			defer func() {
+				queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+					if id != testGauge.ID {
+						return db.Gauge{}, sql.ErrNoRows
+					}
+					return testGauge, nil
+				}
+			}()
+			recorded := false
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = true
+				return limited, nil
+			}
+
+			w := serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":6}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "at most 5")
+
+			w = serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":1.25}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "decimal")
+			assert.False(t, recorded)
+
+			w = serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":2.5}`)
+			assert.Equal(t, http.StatusCreated, w.Code)
+
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				return db.Gauge{}, &db.OutOfBoundsError{Value: 7.5, Bound: 5, Above: true}
+			}
+			w = serveAPI(router, "POST", "/api/v1/gauges/1/values", `{"delta":2.5}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "over the highest value of 5")
+		})
+	})
+
+	t.Run("List values", func(t *testing.T) {
+		t.Run("pagination and date range", func(t *testing.T) {
+			var listed db.ListGaugeValuesParams
+			queries.ListGaugeValuesFn = func(ctx context.Context, params db.ListGaugeValuesParams) ([]db.GaugeValue, error) {
+				listed = params
+				return []db.GaugeValue{{ID: 7, GaugeID: 1, Value: 3, Delta: 1, Source: "web"}}, nil
+			}
+			queries.CountGaugeValuesFn = func(ctx context.Context, params db.CountGaugeValuesParams) (int64, error) {
+				return 21, nil
+			}
+
+			w := serveAPI(router, "GET", "/api/v1/gauges/1/values?from=2025-03-01&to=2025-03-31&limit=10&offset=20", "")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, int64(10), listed.Limit)
+			assert.Equal(t, int64(20), listed.Offset)
+			assert.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), listed.FromDate)
+			assert.Equal(t, time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC), listed.ToDate)
+
+			var page models.GaugeValueList
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			assert.Equal(t, int64(21), page.Total)
+			assert.Len(t, page.Values, 1)
+		})
+
+		t.Run("invalid limit", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/gauges/1/values?limit=0", "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+
+		t.Run("invalid date", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/gauges/1/values?from=yesterday", "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	})
+
+	t.Run("History", func(t *testing.T) {
+		queries.GetGaugeHistoryFn = func(ctx context.Context, gaugeID int64) ([]db.GetGaugeHistoryRow, error) {
+			return []db.GetGaugeHistoryRow{{Month: "2025-03", AverageValue: 4.5}}, nil
+		}
+
+		w := serveAPI(router, "GET", "/api/v1/gauges/1/history", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"month":"2025-03"`)
+	})
+	t.Run("Trends", func(t *testing.T) {
+		t.Run("success", func(t *testing.T) {
+			queries.ListGaugeValuesInRangeFn = func(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error) {
+				return []db.GaugeValue{
+					{GaugeID: 1, Delta: 2, Date: time.Date(2025, time.March, 1, 9, 0, 0, 0, time.UTC)},
+					{GaugeID: 1, Delta: 4, Date: time.Date(2025, time.March, 1, 18, 0, 0, 0, time.UTC)},
+				}, nil
+			}
+
+			w := serveAPI(router, "GET", "/api/v1/gauges/1/trends?granularity=day&aggregation=max&from=2025-03-01&to=2025-03-02", "")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var trend models.Trend
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trend))
+			require.Len(t, trend.Points, 2)
+			require.NotNil(t, trend.Points[0].Value)
+			assert.Equal(t, 4.0, *trend.Points[0].Value)
+			assert.Equal(t, int64(2), trend.Points[0].Count)
+			assert.Nil(t, trend.Points[1].Value)
+		})
+
+		t.Run("invalid aggregation", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/gauges/1/trends?aggregation=median", "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Aggregation must be")
+		})
+	})
+	t.Run("Export", func(t *testing.T) {
+		queries.ListGaugesFn = func(ctx context.Context) ([]db.Gauge, error) {
+			return []db.Gauge{testGauge}, nil
+		}
+		queries.ListGaugeValuesInRangeFn = func(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error) {
+			return []db.GaugeValue{
+				{GaugeID: 1, Delta: 2, Source: db.SourceAPI, Date: time.Date(2025, time.March, 1, 9, 0, 0, 0, time.UTC)},
+			}, nil
+		}
+
+		t.Run("values", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/export?gauge_id=1&date_format=date&col_amount=Glasses", "")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Header().Get("Content-Disposition"), "health-monitor-values-")
+			assert.Equal(t, "gauge,date,Glasses,unit,source\nWater,2025-03-01,2,glasses,api\n", w.Body.String())
+		})
+
+		t.Run("unknown gauge", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/export?gauge_id=99", "")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+
+		t.Run("invalid options", func(t *testing.T) {
+			w := serveAPI(router, "GET", "/api/v1/export?kind=people", "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Kind must be values or gauges")
+		})
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		queries.ListGaugesFn = func(ctx context.Context) ([]db.Gauge, error) {
+			return []db.Gauge{testGauge}, nil
+		}
+		var imported []db.ImportedValue
+		var dryRun bool
+		queries.ImportGaugeValuesFn = func(ctx context.Context, values []db.ImportedValue, dry bool) (db.ImportResult, error) {
+			imported, dryRun = values, dry
+			// The second value is already recorded
+			if len(values) < 2 {
+				return db.ImportResult{Imported: len(values)}, nil
+			}
+			return db.ImportResult{Imported: len(values) - 1, Duplicates: []int{1}}, nil
+		}
+		body := "gauge,date,amount\nWater,2025-03-01T09:00:00Z,2\nwater,2025-03-01T10:00:00Z,1\n"
+
+		t.Run("success", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/import", body)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.False(t, dryRun)
+			require.Len(t, imported, 2)
+			assert.Equal(t, int64(1), imported[0].GaugeID)
+			assert.Equal(t, 2.0, imported[0].Delta)
+
+			var report transfer.Report
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, 1, report.Imported)
+			assert.Equal(t, 1, report.Duplicates)
+			assert.Equal(t, transfer.StatusImported, report.Rows[0].Status)
+			assert.Equal(t, transfer.StatusDuplicate, report.Rows[1].Status)
+		})
+
+		t.Run("dry run", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/import?dry_run=true", body)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.True(t, dryRun)
+			assert.Contains(t, w.Body.String(), `"status":"ready"`)
+		})
+
+		t.Run("invalid rows", func(t *testing.T) {
+			imported = nil
+			w := serveAPI(router, "POST", "/api/v1/import", "gauge,date,amount\nSteps,2025-03-01,x\n")
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.True(t, dryRun, "nothing is imported while rows are invalid")
+
+			var report transfer.Report
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, 1, report.Invalid)
+			assert.Len(t, report.Rows[0].Errors, 3)
+		})
+
+		t.Run("missing column", func(t *testing.T) {
+			w := serveAPI(router, "POST", "/api/v1/import", "gauge,amount\nWater,1\n")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `missing the \"date\" column`)
+		})
+	})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+	"health-monitor/internal/openapi"
+	"health-monitor/internal/transfer"
+	"net/http"
+	"sync"
+)
+
+var (
+	apiSpec     *openapi.Document
+	apiSpecOnce sync.Once
+)
+
+// APISpec returns the OpenAPI document describing the JSON API. Schemas are
+// derived from the types the handlers encode so they cannot drift apart.
+func APISpec() *openapi.Document {
+	apiSpecOnce.Do(func() {
+		apiSpec = buildAPISpec()
+	})
+	return apiSpec
+}
+
+func buildAPISpec() *openapi.Document {
+	b := openapi.NewBuilder("Health Monitor API", "1.0.0")
+
+	b.Register("Gauge", db.Gauge{})
+	b.Register("GaugeValue", db.GaugeValue{})
+	b.Register("GaugeStatus", models.GaugeStatus{})
+	b.Register("GaugeWithValue", models.GaugeWithValue{})
+	b.Register("MonthlyValue", models.MonthlyValue{})
+	b.Register("GaugeHistory", models.GaugeHistory{})
+	b.Register("GaugeValueList", models.GaugeValueList{})
+	b.Register("AppError", models.AppError{})
+	b.Register("GaugeInput", models.GaugeInput{},
+		"description", "goal_type", "target_min", "period", "period_days", "week_start", "timezone",
+		"step", "presets", "min_value", "max_value", "decimals")
+	b.Register("GaugeValueInput", models.GaugeValueInput{})
+	b.Register("TrendQuery", models.TrendQuery{})
+	b.Register("TrendPoint", models.TrendPoint{})
+	b.Register("Trend", models.Trend{})
+	b.Register("FieldError", models.FieldError{})
+	b.Register("ImportRow", transfer.Row{})
+	b.Register("ImportReport", transfer.Report{})
+
+	b.Security("bearerAuth", openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "Personal access token from /admin/tokens, limited to its scopes: gauges:read, gauges:write, values:read and values:write",
+	})
+	b.Security("sessionCookie", openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        "session",
+		Description: "Session of a user signed in at /login",
+	})
+
+	gauge := b.Ref(models.GaugeWithValue{})
+	appError := b.Ref(models.AppError{})
+	id := openapi.PathParam("id", "Gauge ID", &openapi.Schema{Type: "integer", Format: "int64"})
+
+	badRequest := openapi.JSONResponse("Invalid request", appError)
+	notFound := openapi.JSONResponse("Gauge not found", appError)
+	forbidden := openapi.JSONResponse("Not allowed for the signed in user or access token", appError)
+	internalError := openapi.JSONResponse("Unexpected server error", appError)
+
+	etag := &openapi.Schema{Type: "string"}
+	withETag := func(r openapi.Response) openapi.Response {
+		return r.WithHeader("ETag", "Current version of the gauge", etag)
+	}
+
+	b.Add(http.MethodGet, "/api/v1/gauges", &openapi.Operation{
+		OperationID: "listGauges",
+		Summary:     "List gauges with their current status",
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("Gauges", openapi.ArrayOf(gauge)),
+			"403": forbidden,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodPost, "/api/v1/gauges", &openapi.Operation{
+		OperationID: "createGauge",
+		Summary:     "Create a gauge",
+		RequestBody: openapi.JSONBody(b.Ref(models.GaugeInput{})),
+		Responses: map[string]openapi.Response{
+			"201": withETag(openapi.JSONResponse("Created gauge", gauge)),
+			"400": badRequest,
+			"403": forbidden,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodGet, "/api/v1/gauges/{id}", &openapi.Operation{
+		OperationID: "getGauge",
+		Summary:     "Get a gauge",
+		Parameters: []openapi.Parameter{
+			id,
+			openapi.HeaderParam("If-None-Match", "ETag of the client's copy", etag),
+		},
+		Responses: map[string]openapi.Response{
+			"200": withETag(openapi.JSONResponse("Gauge", gauge)),
+			"304": withETag(openapi.Response{Description: "Client's copy is current"}),
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodPut, "/api/v1/gauges/{id}", &openapi.Operation{
+		OperationID: "updateGauge",
+		Summary:     "Update a gauge; omitted fields keep their current values",
+		Parameters: []openapi.Parameter{
+			id,
+			openapi.HeaderParam("If-Match", "Only update if the gauge still has this ETag", etag),
+		},
+		RequestBody: openapi.JSONBody(b.Ref(models.GaugeInput{})),
+		Responses: map[string]openapi.Response{
+			"200": withETag(openapi.JSONResponse("Updated gauge", gauge)),
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"409": openapi.JSONResponse("Gauge changed during the update", appError),
+			"412": openapi.JSONResponse("Gauge no longer matches If-Match", appError),
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodDelete, "/api/v1/gauges/{id}", &openapi.Operation{
+		OperationID: "deleteGauge",
+		Summary:     "Delete a gauge and its values",
+		Parameters:  []openapi.Parameter{id},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Gauge deleted"},
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodGet, "/api/v1/gauges/{id}/values", &openapi.Operation{
+		OperationID: "listGaugeValues",
+		Summary:     "List a gauge's values, newest first",
+		Parameters: []openapi.Parameter{
+			id,
+			openapi.QueryParam("from", "Earliest date (YYYY-MM-DD) or RFC 3339 timestamp", &openapi.Schema{Type: "string"}),
+			openapi.QueryParam("to", "Latest date (inclusive) or RFC 3339 timestamp (exclusive)", &openapi.Schema{Type: "string"}),
+			openapi.QueryParam("limit", "Page size", &openapi.Schema{Type: "integer", Minimum: float(1), Maximum: float(maxValuesLimit)}),
+			openapi.QueryParam("offset", "Number of values to skip", &openapi.Schema{Type: "integer", Minimum: float(0)}),
+		},
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("Page of values", b.Ref(models.GaugeValueList{})),
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodPost, "/api/v1/gauges/{id}/values", &openapi.Operation{
+		OperationID: "createGaugeValue",
+		Summary:     "Log a change to a gauge's value",
+		Parameters:  []openapi.Parameter{id},
+		RequestBody: openapi.JSONBody(b.Ref(models.GaugeValueInput{})),
+		Responses: map[string]openapi.Response{
+			"201": withETag(openapi.JSONResponse("Updated gauge", gauge)),
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodGet, "/api/v1/gauges/{id}/history", &openapi.Operation{
+		OperationID: "getGaugeHistory",
+		Summary:     "Get a gauge's monthly averages",
+		Parameters:  []openapi.Parameter{id},
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("History", b.Ref(models.GaugeHistory{})),
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	b.Add(http.MethodGet, "/api/v1/gauges/{id}/trends", &openapi.Operation{
+		OperationID: "getGaugeTrends",
+		Summary:     "Get a gauge's logged amounts aggregated by day, week, month or year",
+		Parameters: []openapi.Parameter{
+			id,
+			openapi.QueryParam("granularity", "Bucket size", &openapi.Schema{Type: "string", Enum: enum(models.Granularities)}),
+			openapi.QueryParam("aggregation", "Function applied to each bucket", &openapi.Schema{Type: "string", Enum: enum(models.Aggregations)}),
+			openapi.QueryParam("from", "First date (YYYY-MM-DD) in the gauge's time zone", &openapi.Schema{Type: "string", Format: "date"}),
+			openapi.QueryParam("to", "Last date (YYYY-MM-DD, inclusive) in the gauge's time zone", &openapi.Schema{Type: "string", Format: "date"}),
+		},
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("Trend", b.Ref(models.Trend{})),
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	csv := map[string]openapi.MediaType{"text/csv": {Schema: &openapi.Schema{Type: "string"}}}
+	transferParams := []openapi.Parameter{
+		openapi.QueryParam("kind", "What the rows of the file describe", &openapi.Schema{Type: "string", Enum: enum(transfer.Kinds)}),
+		openapi.QueryParam("date_format", "Name of a date format (rfc3339, datetime, date, dmy or mdy), or \"custom\" to use date_layout", &openapi.Schema{Type: "string"}),
+		openapi.QueryParam("date_layout", "Go time layout for the date column", &openapi.Schema{Type: "string"}),
+		openapi.QueryParam("unit", "Unit of the amounts in the file, converted to and from each gauge's unit", &openapi.Schema{Type: "string"}),
+	}
+	for _, field := range transfer.ColumnFields() {
+		transferParams = append(transferParams, openapi.QueryParam("col_"+field, "Header of the column holding "+field, &openapi.Schema{Type: "string"}))
+	}
+
+	b.Add(http.MethodGet, "/api/v1/export", &openapi.Operation{
+		OperationID: "exportCSV",
+		Summary:     "Export gauges or their logged values as CSV",
+		Parameters: append([]openapi.Parameter{
+			openapi.QueryParam("gauge_id", "Only export this gauge", &openapi.Schema{Type: "integer", Format: "int64"}),
+		}, transferParams...),
+		Responses: map[string]openapi.Response{
+			"200": {Description: "CSV file", Content: csv},
+			"400": badRequest,
+			"403": forbidden,
+			"404": notFound,
+			"500": internalError,
+		},
+	})
+
+	report := b.Ref(transfer.Report{})
+	b.Add(http.MethodPost, "/api/v1/import", &openapi.Operation{
+		OperationID: "importCSV",
+		Summary:     "Import gauges or logged values from CSV; rows already recorded are skipped",
+		Parameters: append([]openapi.Parameter{
+			openapi.QueryParam("dry_run", "Only report what would be imported", &openapi.Schema{Type: "boolean"}),
+			openapi.QueryParam("gauge", "Name of the gauge every row belongs to, for files without a gauge column", &openapi.Schema{Type: "string"}),
+		}, transferParams...),
+		RequestBody: &openapi.RequestBody{Required: true, Content: csv},
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("Import report", report),
+			"400": badRequest,
+			"403": forbidden,
+			"422": openapi.JSONResponse("Some rows are invalid; nothing was imported", report),
+			"500": internalError,
+		},
+	})
+
+	return b.Document()
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func enum[T ~string](values []T) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
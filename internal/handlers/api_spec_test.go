@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"health-monitor/internal/db"
+	"health-monitor/internal/openapi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPISpec_DocumentsEveryRoute(t *testing.T) {
+	router := chi.NewRouter()
+	NewAPIHandler(&db.MockQueries{}).RegisterRoutes(router)
+
+	spec := APISpec()
+	documented := 0
+	err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/api/v1") {
+			return nil
+		}
+		documented++
+		assert.NotNil(t, spec.Operation(method, route), "%s %s is not documented", method, route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	operations := 0
+	for _, item := range spec.Paths {
+		operations += len(item)
+	}
+	assert.Equal(t, operations, documented, "document describes routes that do not exist")
+}
+
+func TestAPISpec_Served(t *testing.T) {
+	router := chi.NewRouter()
+	NewAPIHandler(&db.MockQueries{}).RegisterRoutes(router)
+
+	r := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/api/v1/gauges/{id}/values")
+	assert.Contains(t, doc.Components.Schemas, "Gauge")
+	assert.Contains(t, doc.Components.Schemas, "GaugeValue")
+	assert.Contains(t, doc.Components.Schemas, "GaugeWithValue")
+	assert.Contains(t, doc.Components.Schemas, "AppError")
+	assert.Equal(t, "bearer", doc.Components.SecuritySchemes["bearerAuth"].Scheme)
+	assert.Contains(t, doc.Security, openapi.SecurityRequirement{"bearerAuth": {}})
+}
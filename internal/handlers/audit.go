@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/audit"
+	"health-monitor/internal/db"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+)
+
+// auditPageSize is how many entries the audit page lists at a time
+const auditPageSize = 100
+
+// auditExportBatch is how many entries the export reads at a time
+const auditExportBatch = 1000
+
+// AuditStore reads the audit log and the users it names
+type AuditStore interface {
+	ListUsers(ctx context.Context) ([]db.User, error)
+	ListAuditEntries(ctx context.Context, arg db.ListAuditEntriesParams) ([]db.AuditLog, error)
+}
+
+// AuditHandler serves the audit log, as a page and as a JSON export
+type AuditHandler struct {
+	store AuditStore
+}
+
+func NewAuditHandler(store AuditStore) *AuditHandler {
+	return &AuditHandler{store: store}
+}
+
+// RegisterRoutes registers the audit log routes on the provided router
+func (h *AuditHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/audit", func(r chi.Router) {
+		r.Get("/", h.handleList)
+		r.Get("/export", h.handleExport)
+	})
+}
+
+// handleList renders the latest entries matching the filter, newest first.
+// ?before= pages back through older entries.
+func (h *AuditHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	users, err := h.store.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get users: %v", err), http.StatusInternalServerError)
+		return
+	}
+	filter, arg, formErrors := readAuditFilter(r)
+	view := pages.AuditView{Filter: filter, Users: users, Errors: formErrors}
+
+	if len(formErrors) == 0 {
+		arg.Limit = auditPageSize + 1
+		entries, err := h.store.ListAuditEntries(r.Context(), arg)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get audit log: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if len(entries) > auditPageSize {
+			entries = entries[:auditPageSize]
+			view.Older = entries[auditPageSize-1].ID
+		}
+		view.Entries = entries
+	}
+
+	// Like other form errors, a bad filter is shown with 200
+	w.Header().Set("Content-Type", "text/html")
+	if err := pages.AuditPage(view).Render(r.Context(), w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleExport downloads every entry matching the filter as a JSON array,
+// newest first
+func (h *AuditHandler) handleExport(w http.ResponseWriter, r *http.Request) {
+	_, arg, formErrors := readAuditFilter(r)
+	if len(formErrors) > 0 {
+		messages := make([]string, len(formErrors))
+		for i, e := range formErrors {
+			messages[i] = e.Message
+		}
+		http.Error(w, strings.Join(messages, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	exported := []audit.Exported{}
+	arg.Limit = auditExportBatch
+	for {
+		entries, err := h.store.ListAuditEntries(r.Context(), arg)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get audit log: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for _, entry := range entries {
+			exported = append(exported, audit.Export(entry))
+		}
+		if len(entries) < auditExportBatch {
+			break
+		}
+		arg.BeforeID = sql.NullInt64{Int64: entries[len(entries)-1].ID, Valid: true}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.json"`)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(exported)
+}
+
+// readAuditFilter reads the filter in the query string. Dates are whole
+// days in the server's time zone, both included.
+func readAuditFilter(r *http.Request) (pages.AuditFilter, db.ListAuditEntriesParams, []components.FormError) {
+	query := r.URL.Query()
+	filter := pages.AuditFilter{
+		Action: query.Get("action"),
+		From:   query.Get("from"),
+		To:     query.Get("to"),
+	}
+	var arg db.ListAuditEntriesParams
+	var formErrors []components.FormError
+
+	if s := query.Get("user"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			formErrors = append(formErrors, components.FormError{Field: "user", Message: "Choose a user from the list"})
+		}
+		filter.UserID = id
+		arg.UserID = sql.NullInt64{Int64: id, Valid: err == nil}
+	}
+	if filter.Action != "" {
+		if !slices.Contains(audit.Actions, filter.Action) {
+			formErrors = append(formErrors, components.FormError{Field: "action", Message: "Choose an action from the list"})
+		}
+		arg.Action = sql.NullString{String: filter.Action, Valid: true}
+	}
+	if s := query.Get("gauge"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			formErrors = append(formErrors, components.FormError{Field: "gauge", Message: "Gauge must be a gauge ID"})
+		}
+		filter.GaugeID = id
+		arg.Entity = sql.NullString{String: audit.EntityGauge, Valid: true}
+		arg.EntityID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	if filter.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", filter.From, time.Local)
+		if err != nil {
+			formErrors = append(formErrors, components.FormError{Field: "from", Message: "From must be a date"})
+		}
+		arg.FromDate = sql.NullTime{Time: from.UTC(), Valid: true}
+	}
+	if filter.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", filter.To, time.Local)
+		if err != nil {
+			formErrors = append(formErrors, components.FormError{Field: "to", Message: "To must be a date"})
+		}
+		arg.ToDate = sql.NullTime{Time: to.AddDate(0, 0, 1).UTC(), Valid: true}
+	}
+	if id, err := strconv.ParseInt(query.Get("before"), 10, 64); err == nil {
+		arg.BeforeID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	return filter, arg, formErrors
+}
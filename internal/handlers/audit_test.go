@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/audit"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditHandler(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	alice, err := store.CreateUser(ctx, db.CreateUserParams{Username: "alice", PasswordHash: "-", IsAdmin: true})
+	require.NoError(t, err)
+	bob, err := store.CreateUser(ctx, db.CreateUserParams{Username: "bob", PasswordHash: "-"})
+	require.NoError(t, err)
+
+	scoped := access.NewStore(store)
+	router := chi.NewRouter()
+	router.Use(audit.Load)
+	NewGaugeHandler(scoped).RegisterRoutes(router)
+	NewAuditHandler(store).RegisterRoutes(router)
+
+	serve := func(user db.User, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("User-Agent", "Firefox")
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	water, err := scoped.CreateGauge(auth.WithUser(ctx, alice), db.CreateGaugeParams{Name: "Water", Target: 8, Unit: "glasses", Icon: "star", Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1})
+	require.NoError(t, err)
+	steps, err := scoped.CreateGauge(auth.WithUser(ctx, bob), db.CreateGaugeParams{Name: "Steps", Target: 8000, Unit: "steps", Icon: "star", Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1000})
+	require.NoError(t, err)
+
+	// Changes made through the pages are recorded with the request
+	require.Equal(t, http.StatusOK, serve(bob, "POST", fmt.Sprintf("/gauges/%d/increment", steps.ID)).Code)
+	require.Equal(t, http.StatusOK, serve(alice, "DELETE", fmt.Sprintf("/admin/gauges/%d", water.ID)).Code)
+
+	t.Run("lists every change", func(t *testing.T) {
+		w := serve(alice, "GET", "/admin/audit")
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Audit log")
+		assert.Contains(t, body, "Firefox")
+		assert.Contains(t, body, "Steps")
+		assert.Contains(t, body, "Water")
+		assert.Contains(t, body, ">delete<")
+	})
+
+	export := func(query string) []audit.Exported {
+		w := serve(alice, "GET", "/admin/audit/export?"+query)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var entries []audit.Exported
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		return entries
+	}
+
+	t.Run("filters", func(t *testing.T) {
+		entries := export("")
+		require.Len(t, entries, 4)
+
+		entries = export(fmt.Sprintf("user=%d", bob.ID))
+		require.Len(t, entries, 2)
+		assert.Equal(t, audit.ActionValue, entries[0].Action)
+		assert.Equal(t, "192.0.2.1", entries[0].IP)
+		assert.Equal(t, "Firefox", entries[0].UserAgent)
+		assert.Contains(t, entries[0].Changes, audit.Change{Field: "value", Before: "0", After: "1000"})
+
+		entries = export(fmt.Sprintf("gauge=%d&action=delete", water.ID))
+		require.Len(t, entries, 1)
+		assert.Equal(t, "Water", entries[0].EntityName)
+		assert.Equal(t, alice.ID, *entries[0].UserID)
+
+		today := time.Now().Format("2006-01-02")
+		assert.Len(t, export("from="+today+"&to="+today), 4)
+		assert.Empty(t, export("to="+time.Now().AddDate(0, 0, -1).Format("2006-01-02")))
+	})
+
+	t.Run("checks the filter", func(t *testing.T) {
+		w := serve(alice, "GET", "/admin/audit?from=yesterday&action=rename")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "From must be a date")
+		assert.Contains(t, w.Body.String(), "Choose an action from the list")
+
+		w = serve(alice, "GET", "/admin/audit/export?gauge=water")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("pages back", func(t *testing.T) {
+		for i := 0; i < auditPageSize; i++ {
+			_, err := scoped.RecordGaugeValue(auth.WithUser(ctx, bob), db.RecordGaugeValueParams{GaugeID: steps.ID, Delta: 1})
+			require.NoError(t, err)
+		}
+		w := serve(alice, "GET", "/admin/audit")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Water")
+		assert.Contains(t, w.Body.String(), "Older")
+
+		entries := export("")
+		require.Len(t, entries, auditPageSize+4)
+		w = serve(alice, "GET", fmt.Sprintf("/admin/audit?before=%d", entries[auditPageSize-1].ID))
+		assert.Contains(t, w.Body.String(), "Water")
+		assert.NotContains(t, w.Body.String(), "Older")
+	})
+}
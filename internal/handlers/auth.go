@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/auth"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+)
+
+// AuthHandler serves the sign in page and signing out
+type AuthHandler struct {
+	sessions *auth.Manager
+}
+
+func NewAuthHandler(sessions *auth.Manager) *AuthHandler {
+	return &AuthHandler{sessions: sessions}
+}
+
+// RegisterRoutes registers the sign in and out routes on the provided router
+func (h *AuthHandler) RegisterRoutes(r chi.Router) {
+	r.Get(auth.LoginPath, h.handleLoginForm)
+	r.Post(auth.LoginPath, h.handleLogin)
+	r.Post("/logout", h.handleLogout)
+}
+
+// handleLoginForm shows the sign in form, or goes straight on for a user
+// who is already signed in
+func (h *AuthHandler) handleLoginForm(w http.ResponseWriter, r *http.Request) {
+	next := auth.SafeNext(r.URL.Query().Get("next"))
+	if _, ok := auth.UserFrom(r.Context()); ok {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	h.render(w, r, pages.LoginView{Next: next})
+}
+
+// handleLogin signs a user in and sends them on to the page they asked for
+func (h *AuthHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	view := pages.LoginView{
+		Username: strings.TrimSpace(r.FormValue("username")),
+		Next:     auth.SafeNext(r.FormValue("next")),
+	}
+	password := r.FormValue("password")
+	if view.Username == "" {
+		view.Errors = append(view.Errors, components.FormError{Field: "username", Message: "Username is required"})
+	}
+	if password == "" {
+		view.Errors = append(view.Errors, components.FormError{Field: "password", Message: "Password is required"})
+	}
+	if len(view.Errors) > 0 {
+		h.render(w, r, view)
+		return
+	}
+
+	user, err := h.sessions.Authenticate(r.Context(), view.Username, password)
+	if errors.Is(err, auth.ErrInvalidLogin) {
+		view.Errors = append(view.Errors, components.FormError{Field: "password", Message: "Invalid username or password"})
+		h.render(w, r, view)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign in: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := h.sessions.Login(w, r, user); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign in: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, view.Next, http.StatusSeeOther)
+}
+
+// handleLogout ends the session and goes back to the dashboard
+func (h *AuthHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.sessions.Logout(w, r); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign out: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// render renders the sign in page. Like other form errors, a failed sign
+// in is sent with 200.
+func (h *AuthHandler) render(w http.ResponseWriter, r *http.Request, view pages.LoginView) {
+	w.Header().Set("Content-Type", "text/html")
+	err := pages.LoginPage(view).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"health-monitor/internal/auth"
+	"health-monitor/internal/testutil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHandlers(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	_, err := auth.CreateUser(context.Background(), store, "alice", "correct horse", true)
+	require.NoError(t, err)
+
+	sessions := auth.NewManager(store, auth.DefaultConfig)
+	router := chi.NewRouter()
+	router.Use(sessions.Load, auth.Protect)
+	NewAuthHandler(sessions).RegisterRoutes(router)
+	router.Get("/admin", func(w http.ResponseWriter, r *http.Request) {
+		user, _ := auth.UserFrom(r.Context())
+		w.Write([]byte("Hello " + user.Username))
+	})
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}, "next": {"/admin"}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("admin needs a user", func(t *testing.T) {
+		w := get("/admin")
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login?next=%2Fadmin", w.Header().Get("Location"))
+	})
+
+	t.Run("shows the form", func(t *testing.T) {
+		w := get("/login?next=/admin")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Sign in - Health Monitor")
+		assert.Contains(t, w.Body.String(), `name="next" value="/admin"`)
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		w := login("alice", "wrong horse")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid username or password")
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("signs in and out", func(t *testing.T) {
+		w := login("alice", "correct horse")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin", w.Header().Get("Location"))
+		cookie := w.Result().Cookies()[0]
+
+		w = get("/admin", cookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Hello alice", w.Body.String())
+
+		w = get("/login", cookie)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+
+		req := httptest.NewRequest("POST", "/logout", nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+
+		w = get("/admin", cookie)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/snapshot"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRestoreSize limits the size of an uploaded backup
+const maxRestoreSize = 100 << 20
+
+// backupFilename names a downloaded backup after the time it was taken
+func backupFilename(now time.Time) string {
+	return fmt.Sprintf("health-monitor-backup-%s.json", now.Format("2006-01-02-150405"))
+}
+
+// handleBackupPage renders the backup and restore page
+func (h *GaugeHandler) handleBackupPage(w http.ResponseWriter, r *http.Request) {
+	h.renderBackup(w, r, db.RestoreMerge, nil, nil)
+}
+
+// handleBackupDownload streams a snapshot of the database as a JSON file
+func (h *GaugeHandler) handleBackupDownload(w http.ResponseWriter, r *http.Request) {
+	backup, err := h.queries.Backup(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to back up: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backupFilename(backup.Created)))
+	// The headers are already sent, so a failure part way through can only
+	// cut the file short, which restoring it will reject
+	db.WriteBackup(w, backup)
+}
+
+// handleRestore restores an uploaded backup in the chosen mode
+func (h *GaugeHandler) handleRestore(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRestoreSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	mode, err := db.ParseRestoreMode(r.FormValue("mode"))
+	if err != nil {
+		h.renderBackup(w, r, db.RestoreMerge, nil, []components.FormError{{Field: "mode", Message: "Choose whether to merge or replace"}})
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		h.renderBackup(w, r, mode, nil, []components.FormError{{Field: "file", Message: "Choose a backup file to restore"}})
+		return
+	}
+	defer file.Close()
+
+	backup, err := db.ReadBackup(file)
+	if errors.Is(err, db.ErrInvalidBackup) {
+		msg := strings.TrimPrefix(err.Error(), db.ErrInvalidBackup.Error()+": ")
+		h.renderBackup(w, r, mode, nil, []components.FormError{{Field: "file", Message: "The backup cannot be restored: " + msg}})
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read backup: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.queries.Restore(r.Context(), backup, mode)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to restore: %v", err), http.StatusInternalServerError)
+		return
+	}
+	publish(h.events, events.Reload())
+
+	h.renderBackup(w, r, mode, &result, nil)
+}
+
+// handleSnapshot takes a scheduled backup straight away. The outcome shows
+// in the status on the backup page.
+func (h *GaugeHandler) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if h.snapshots == nil {
+		http.Error(w, "Scheduled backups are not available", http.StatusNotFound)
+		return
+	}
+	h.snapshots.Backup(r.Context())
+	http.Redirect(w, r, "/admin/backup", http.StatusSeeOther)
+}
+
+// renderBackup renders the backup page. Like other form errors it is sent
+// with 200.
+func (h *GaugeHandler) renderBackup(w http.ResponseWriter, r *http.Request, mode db.RestoreMode, result *db.RestoreResult, formErrors []components.FormError) {
+	var status *snapshot.Status
+	if h.snapshots != nil {
+		s := h.snapshots.Status()
+		status = &s
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err := pages.BackupPage(status, mode, result, formErrors).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
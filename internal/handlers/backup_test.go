@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"health-monitor/internal/db"
+	"health-monitor/internal/snapshot"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupHandlers(t *testing.T) {
+	queries := &db.MockQueries{}
+	router := chi.NewRouter()
+	NewGaugeHandler(queries).RegisterRoutes(router)
+
+	backup := &db.Backup{
+		Format:  db.BackupFormat,
+		Version: db.BackupVersion,
+		Created: time.Date(2025, time.March, 1, 9, 30, 0, 0, time.UTC),
+		Schema:  3,
+		Gauges: []db.BackupGauge{{
+			Gauge:  db.Gauge{ID: 1, Name: "Water", Unit: "l"},
+			Values: []db.GaugeValue{{ID: 1, GaugeID: 1, Value: 1, Delta: 1, Source: db.SourceWeb}},
+		}},
+	}
+	queries.BackupFn = func(ctx context.Context) (*db.Backup, error) {
+		return backup, nil
+	}
+
+	t.Run("page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backup", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `href="/admin/backup/download"`)
+		assert.Contains(t, w.Body.String(), `action="/admin/backup/restore"`)
+	})
+
+	t.Run("download", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backup/download", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="health-monitor-backup-2025-03-01-093000.json"`, w.Header().Get("Content-Disposition"))
+
+		var decoded db.Backup
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+		assert.Equal(t, "Water", decoded.Gauges[0].Name)
+		assert.Len(t, decoded.Gauges[0].Values, 1)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		archive, err := json.Marshal(backup)
+		require.NoError(t, err)
+		var restored *db.Backup
+		var restoredMode db.RestoreMode
+		queries.RestoreFn = func(ctx context.Context, backup *db.Backup, mode db.RestoreMode) (db.RestoreResult, error) {
+			restored, restoredMode = backup, mode
+			return db.RestoreResult{Gauges: 1, Values: 1}, nil
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, createUploadRequest(t, "/admin/backup/restore", map[string]string{"mode": "replace"}, string(archive)))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, db.RestoreReplace, restoredMode)
+		require.NotNil(t, restored)
+		assert.Equal(t, "Water", restored.Gauges[0].Name)
+		assert.Contains(t, w.Body.String(), "Restored 1 gauges, 1 values and 0 periods.")
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		queries.RestoreFn = nil
+		archive := `{"format":"health-monitor-backup","version":99,"gauges":[]}`
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, createUploadRequest(t, "/admin/backup/restore", map[string]string{"mode": "merge"}, archive))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "The backup cannot be restored: version 99 was written by a newer release")
+	})
+
+	t.Run("missing file and mode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, createUploadRequest(t, "/admin/backup/restore", map[string]string{"mode": "merge"}, ""))
+		assert.Contains(t, w.Body.String(), "Choose a backup file to restore")
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, createUploadRequest(t, "/admin/backup/restore", map[string]string{"mode": "overwrite"}, "{}"))
+		assert.Contains(t, w.Body.String(), "Choose whether to merge or replace")
+	})
+}
+
+// mockSnapshots reports a fixed status and counts the backups asked for
+type mockSnapshots struct {
+	status  snapshot.Status
+	backups int
+}
+
+func (m *mockSnapshots) Status() snapshot.Status {
+	return m.status
+}
+
+func (m *mockSnapshots) Backup(ctx context.Context) (snapshot.File, error) {
+	m.backups++
+	return snapshot.File{}, nil
+}
+
+func TestScheduledBackups(t *testing.T) {
+	router := chi.NewRouter()
+	handler := NewGaugeHandler(&db.MockQueries{})
+	handler.RegisterRoutes(router)
+
+	t.Run("not configured", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/backup/snapshot", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	snapshots := &mockSnapshots{status: snapshot.Status{
+		Enabled:   true,
+		Dir:       "/var/backups",
+		Schedule:  "0 3 * * *",
+		Keep:      7,
+		MaxAge:    30 * 24 * time.Hour,
+		LastRun:   time.Date(2025, time.March, 14, 3, 0, 0, 0, time.UTC),
+		LastError: "disk full",
+		Last:      &snapshot.File{Name: "health-20250313-030000.db", Size: 2048},
+		Files:     []snapshot.File{{Name: "health-20250313-030000.db", Size: 2048}},
+	}}
+	handler.SetSnapshots(snapshots)
+
+	t.Run("status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backup", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "keeping the latest 7 for up to 30 days")
+		assert.Contains(t, body, "The backup at 2025-03-14 03:00 failed: disk full")
+		assert.Contains(t, body, "health-20250313-030000.db")
+		assert.Contains(t, body, "2.0 KB, passed its integrity check")
+	})
+
+	t.Run("back up now", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/backup/snapshot", nil))
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin/backup", w.Header().Get("Location"))
+		assert.Equal(t, 1, snapshots.backups)
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"health-monitor/internal/testutil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIncrement_Concurrent hammers the increment route from many clients at
+// once against a real database. Run it with -race.
+func TestIncrement_Concurrent(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	gauge := testutil.CreateTestGauge(t, store)
+
+	router := chi.NewRouter()
+	NewGaugeHandler(store).RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	clients, taps := 16, 25
+	if testing.Short() {
+		clients, taps = 4, 10
+	}
+
+	url := server.URL + "/gauges/" + strconv.FormatInt(gauge.ID, 10) + "/increment"
+	var wg sync.WaitGroup
+	failures := make(chan string, clients*taps)
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < taps; i++ {
+				resp, err := http.Post(url, "application/x-www-form-urlencoded", nil)
+				if err != nil {
+					failures <- err.Error()
+					continue
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					failures <- resp.Status
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(failures)
+
+	for failure := range failures {
+		t.Errorf("increment failed: %s", failure)
+	}
+
+	updated, err := store.GetGauge(context.Background(), gauge.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(clients*taps), updated.Value, "no increment may be lost")
+
+	values, err := store.GetGaugeValues(context.Background(), gauge.ID)
+	require.NoError(t, err)
+	assert.Len(t, values, clients*taps)
+	// Starting the gauge's first period bumps the version as well
+	assert.GreaterOrEqual(t, updated.Version, gauge.Version+int64(clients*taps))
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRF(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	alice, err := auth.CreateUser(ctx, store, "alice", "correct horse", false)
+	require.NoError(t, err)
+
+	sessions := auth.NewManager(store, auth.DefaultConfig)
+	tokens := auth.NewTokens(store)
+	scoped := access.NewStore(store)
+	router := chi.NewRouter()
+	router.Use(sessions.Load, tokens.Load, auth.Protect, auth.CheckCSRF)
+	NewAuthHandler(sessions).RegisterRoutes(router)
+	NewGaugeHandler(scoped).RegisterRoutes(router)
+	NewAPIHandler(scoped).RegisterRoutes(router)
+
+	form := url.Values{"username": {"alice"}, "password": {"correct horse"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	cookie := w.Result().Cookies()[0]
+
+	serve := func(method, path string, form url.Values, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			req.Header.Set(auth.CSRFHeader, header)
+		}
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	gauge := url.Values{"name": {"Water"}, "icon": {"star"}, "unit": {"glasses"}, "target": {"8"}}
+
+	// Every page hands HTMX the token, and the gauge form carries it too
+	w = serve("GET", "/admin/gauges/new", nil, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	match := regexp.MustCompile(`<meta name="csrf-token" content="([0-9a-f]{64})"`).FindStringSubmatch(w.Body.String())
+	require.NotNil(t, match)
+	token := match[1]
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`hx-headers="{&#34;X-CSRF-Token&#34;:&#34;%s&#34;}"`, token))
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`name="csrf_token" value="%s"`, token))
+
+	t.Run("refuses changes without it", func(t *testing.T) {
+		w := serve("POST", "/admin/gauges", gauge, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = serve("POST", "/admin/gauges", gauge, strings.Repeat("0", 64))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		gauges, err := store.ListUserGauges(ctx, db.ListUserGaugesParams{UserID: alice.ID})
+		require.NoError(t, err)
+		assert.Empty(t, gauges)
+	})
+
+	t.Run("accepts HTMX and form requests with it", func(t *testing.T) {
+		w := serve("POST", "/admin/gauges", gauge, token)
+		require.Less(t, w.Code, 400, w.Body.String())
+		gauges, err := store.ListUserGauges(ctx, db.ListUserGaugesParams{UserID: alice.ID})
+		require.NoError(t, err)
+		require.Len(t, gauges, 1)
+
+		changed := url.Values{"name": {"Tea"}, "icon": {"star"}, "unit": {"cups"}, "target": {"4"}, auth.CSRFField: {token}}
+		w = serve("PUT", fmt.Sprintf("/admin/gauges/%d", gauges[0].ID), changed, "")
+		require.Less(t, w.Code, 400, w.Body.String())
+		updated, err := store.GetGauge(ctx, gauges[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Tea", updated.Name)
+
+		w = serve("POST", fmt.Sprintf("/gauges/%d/increment", gauges[0].ID), nil, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = serve("POST", fmt.Sprintf("/gauges/%d/increment", gauges[0].ID), nil, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("answers the api in JSON", func(t *testing.T) {
+		w := serve("POST", "/api/v1/gauges", nil, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "CSRF token")
+
+		_, secret, err := tokens.Create(ctx, auth.CreateTokenParams{UserID: alice.ID, Name: "Script", Scopes: []string{auth.ScopeGaugesWrite}})
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/api/v1/gauges", strings.NewReader(`{"name": "Steps", "target": 8000, "unit": "steps", "icon": "star"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+secret)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("signs out with it", func(t *testing.T) {
+		w := serve("POST", "/logout", nil, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = serve("POST", "/logout", url.Values{auth.CSRFField: {token}}, "")
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+	})
+}
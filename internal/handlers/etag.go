@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"health-monitor/internal/db"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gaugeETag is the entity tag for a gauge. The version is bumped on every
+// write, so the tag changes whenever the gauge does.
+func gaugeETag(gauge db.Gauge) string {
+	return fmt.Sprintf(`"%d"`, gauge.Version)
+}
+
+// parseETag reads a gauge version from an entity tag, ignoring the weak
+// prefix. ok is false if the tag is not one of ours.
+func parseETag(tag string) (version int64, ok bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, false
+	}
+	version, err = strconv.ParseInt(unquoted, 10, 64)
+	return version, err == nil && version > 0
+}
+
+// ifMatchVersion returns the gauge version named by the If-Match header, or
+// zero when the header is absent or "*". Tags that cannot name any version
+// of the gauge fail with a precondition error.
+func ifMatchVersion(r *http.Request) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	return parseETag(header)
+}
+
+// etagMatches reports whether an If-None-Match header lists the given tag
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/views/components"
+)
+
+// heartbeatInterval is how often an idle event stream sends a comment, so
+// proxies and browsers do not close it
+const heartbeatInterval = 15 * time.Second
+
+// reconnectDelay is how long a browser waits before reconnecting a dropped
+// event stream
+const reconnectDelay = 2 * time.Second
+
+// Events carries gauge changes to the dashboards that are open
+type Events interface {
+	Publish(e events.Event)
+	Subscribe() (<-chan events.Event, func())
+}
+
+// SetEvents publishes every change made through the handler to e, and
+// streams e's events to open dashboards at /events
+func (h *GaugeHandler) SetEvents(e Events) {
+	h.events = e
+}
+
+// SetEvents publishes every change made through the API to e
+func (h *APIHandler) SetEvents(e Events) {
+	h.events = e
+}
+
+// publish sends an event if there is anyone to send it to
+func publish(e Events, event events.Event) {
+	if e != nil {
+		e.Publish(event)
+	}
+}
+
+// changed is the event for a change to a gauge, from before as the handler
+// loaded it to after as saved. A change that moved the gauge into a new
+// period is taken to have started that period from zero.
+func changed(before, after db.Gauge) events.Event {
+	previous := before.Value
+	if !before.PeriodStart.Time.Equal(after.PeriodStart.Time) {
+		previous = 0
+	}
+	return events.Changed(after, previous)
+}
+
+// handleEvents streams gauge changes as server-sent events. A gauge's new
+// value is sent as a gauge-{id} event holding its rendered GaugeValue, and
+// changes to the list of gauges as a gauges event. On connecting, which
+// includes reconnecting, the current value of every gauge is sent first so
+// nothing missed while disconnected stays stale. Only changes to the gauges
+// the user may see are sent.
+func (h *GaugeHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		http.Error(w, "Live updates are not available", http.StatusNotFound)
+		return
+	}
+
+	// Subscribe before reading the gauges, so no change falls in between
+	sub, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	gauges, err := h.queries.ListGauges(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get gauges: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+
+	// A gauge that was not listed, such as a new or newly shared one, is
+	// looked up when it is first mentioned
+	visible := make(map[int64]bool, len(gauges))
+	for _, gauge := range gauges {
+		visible[gauge.ID] = true
+	}
+	canSee := func(event events.Event) bool {
+		switch event.Type {
+		case events.GaugesChanged:
+			// Sharing may have changed too, so look everything up again
+			clear(visible)
+			return true
+		case events.GaugeCreated, events.GaugeUpdated:
+			delete(visible, event.Gauge.ID)
+		}
+		seen, ok := visible[event.Gauge.ID]
+		if !ok {
+			_, err := h.queries.GetGauge(r.Context(), event.Gauge.ID)
+			seen = err == nil
+			visible[event.Gauge.ID] = seen
+		}
+		return seen
+	}
+
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay.Milliseconds())
+	for _, gauge := range gauges {
+		if err := writeEvent(w, r, events.Changed(gauge, gauge.Value)); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub:
+			if !ok {
+				// Fell behind; the browser reconnects and catches up
+				return
+			}
+			if !canSee(event) {
+				continue
+			}
+			if err := writeEvent(w, r, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes one event in the text/event-stream format, with each
+// line of its HTML on a data line of its own. Events that change a gauge's
+// value update its card; every other event reloads the list.
+func writeEvent(w http.ResponseWriter, r *http.Request, event events.Event) error {
+	name, data := "gauges", "reload"
+	if event.Type == events.GaugeChanged || event.Type == events.PeriodClosed {
+		var buf bytes.Buffer
+		if err := components.GaugeValue(&event.Gauge, event.Gauge.Value).Render(r.Context(), &buf); err != nil {
+			return err
+		}
+		name, data = fmt.Sprintf("gauge-%d", event.Gauge.ID), buf.String()
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "event: %s\n", name)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&out, "data: %s\n", line)
+	}
+	out.WriteString("\n")
+	_, err := fmt.Fprint(w, out.String())
+	return err
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvent reads the next event from a stream, skipping comments and the
+// retry line
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var name string
+	var data []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, strings.Join(data, "\n")
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	water := db.Gauge{ID: 7, Name: "Water", Target: 2, Unit: "l", Value: 1, Step: 0.25, Decimals: 2, GoalType: "at_least"}
+	queries := &db.MockQueries{
+		ListGaugesFn: func(ctx context.Context) ([]db.Gauge, error) {
+			return []db.Gauge{water}, nil
+		},
+		GetGaugeFn: func(ctx context.Context, id int64) (db.Gauge, error) {
+			return water, nil
+		},
+		RecordGaugeValueFn: func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+			updated := water
+			updated.Value += params.Delta
+			return updated, nil
+		},
+		DeleteGaugeFn: func(ctx context.Context, id int64) error {
+			return nil
+		},
+	}
+	broker := events.NewBroker()
+	handler := NewGaugeHandler(queries)
+	handler.SetEvents(broker)
+	router := chi.NewRouter()
+	handler.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	stream := bufio.NewReader(resp.Body)
+
+	// The current values come first, so a reconnecting dashboard catches up
+	name, data := readEvent(t, stream)
+	assert.Equal(t, "gauge-7", name)
+	assert.Contains(t, data, `id="gauge-value-7"`)
+	assert.Contains(t, data, "1.00")
+
+	post, err := http.Post(server.URL+"/gauges/7/increment", "application/x-www-form-urlencoded", nil)
+	require.NoError(t, err)
+	post.Body.Close()
+	name, data = readEvent(t, stream)
+	assert.Equal(t, "gauge-7", name)
+	assert.Contains(t, data, "1.25")
+
+	del, err := http.NewRequest("DELETE", server.URL+"/admin/gauges/7", nil)
+	require.NoError(t, err)
+	deleted, err := http.DefaultClient.Do(del)
+	require.NoError(t, err)
+	deleted.Body.Close()
+	name, data = readEvent(t, stream)
+	assert.Equal(t, "gauges", name)
+	assert.Equal(t, "reload", data)
+
+	// Closing the stream ends the subscription
+	cancel()
+	assert.Eventually(t, func() bool { return broker.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestEvents_Unavailable(t *testing.T) {
+	router := chi.NewRouter()
+	NewGaugeHandler(&db.MockQueries{}).RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
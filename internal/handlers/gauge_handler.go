@@ -2,13 +2,20 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"health-monitor/internal/access"
 	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/models"
+	"health-monitor/internal/snapshot"
 	"health-monitor/internal/views/components"
 	"health-monitor/internal/views/layouts"
 	"health-monitor/internal/views/pages"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,13 +24,30 @@ type Querier interface {
 	ListGauges(ctx context.Context) ([]db.Gauge, error)
 	GetGauge(ctx context.Context, id int64) (db.Gauge, error)
 	CreateGauge(ctx context.Context, params db.CreateGaugeParams) (db.Gauge, error)
-	UpdateGauge(ctx context.Context, params db.UpdateGaugeParams) error
+	EditGauge(ctx context.Context, params db.EditGaugeParams) (db.Gauge, error)
 	DeleteGauge(ctx context.Context, id int64) error
-	UpdateGaugeValue(ctx context.Context, params db.UpdateGaugeValueParams) error
+	RecordGaugeValue(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error)
+	SetGaugeValue(ctx context.Context, params db.SetGaugeValueParams) (db.Gauge, error)
+	ListGaugeValues(ctx context.Context, params db.ListGaugeValuesParams) ([]db.GaugeValue, error)
+	CountGaugeValues(ctx context.Context, params db.CountGaugeValuesParams) (int64, error)
+	ListGaugeValuesInRange(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error)
+	GetGaugeHistory(ctx context.Context, gaugeID int64) ([]db.GetGaugeHistoryRow, error)
+	ImportGauges(ctx context.Context, gauges []db.CreateGaugeParams, dryRun bool) (db.ImportResult, error)
+	ImportGaugeValues(ctx context.Context, values []db.ImportedValue, dryRun bool) (db.ImportResult, error)
+	Backup(ctx context.Context) (*db.Backup, error)
+	Restore(ctx context.Context, backup *db.Backup, mode db.RestoreMode) (db.RestoreResult, error)
+}
+
+// Snapshots takes and reports the scheduled copies of the database file
+type Snapshots interface {
+	Status() snapshot.Status
+	Backup(ctx context.Context) (snapshot.File, error)
 }
 
 type GaugeHandler struct {
-	queries Querier
+	queries   Querier
+	snapshots Snapshots
+	events    Events
 }
 
 func NewGaugeHandler(queries Querier) *GaugeHandler {
@@ -32,11 +56,30 @@ func NewGaugeHandler(queries Querier) *GaugeHandler {
 	}
 }
 
+// SetSnapshots shows the scheduled backups taken by s on the backup page and
+// lets an admin take one straight away
+func (h *GaugeHandler) SetSnapshots(s Snapshots) {
+	h.snapshots = s
+}
+
 // RegisterRoutes registers all gauge-related routes on the provided router
 func (h *GaugeHandler) RegisterRoutes(r chi.Router) {
 	// Admin dashboard
 	r.Get("/admin", h.handleAdmin)
 
+	// CSV export and import
+	r.Get("/admin/export", h.handleExportForm)
+	r.Get("/admin/export/download", h.handleExportDownload)
+	r.Get("/admin/import", h.handleImportForm)
+	r.Post("/admin/import", h.handleImport)
+	r.Get("/admin/backup", h.handleBackupPage)
+	r.Get("/admin/backup/download", h.handleBackupDownload)
+	r.Post("/admin/backup/restore", h.handleRestore)
+	r.Post("/admin/backup/snapshot", h.handleSnapshot)
+
+	// Live updates for open dashboards
+	r.Get("/events", h.handleEvents)
+
 	// Gauge routes
 	r.Route("/admin/gauges", func(r chi.Router) {
 		// New gauge form
@@ -53,10 +96,13 @@ func (h *GaugeHandler) RegisterRoutes(r chi.Router) {
 		})
 	})
 
-	// Gauge HTMX actions
+	// Gauge pages and HTMX actions
 	r.Route("/gauges/{id}", func(r chi.Router) {
+		r.Get("/trends", h.handleTrends)
 		r.Post("/increment", h.handleIncrementGauge)
 		r.Post("/decrement", h.handleDecrementGauge)
+		r.Post("/value", h.handleSetGaugeValue)
+		r.Post("/log", h.handleLogGaugeAmount)
 	})
 }
 
@@ -86,27 +132,16 @@ func (h *GaugeHandler) handleNewGaugeForm(w http.ResponseWriter, r *http.Request
 	}
 }
 
-// validateGaugeForm validates the form data for gauge creation/updates
-func validateGaugeForm(r *http.Request) (string, string, string, float64, []components.FormError) {
+// validateGaugeForm validates the form data for gauge creation/updates and
+// returns the submitted values
+func validateGaugeForm(r *http.Request) (models.GaugeInput, []components.FormError) {
 	var errors []components.FormError
-	
-	// Validate name
-	name := r.FormValue("name")
-	if name == "" {
-		errors = append(errors, components.FormError{Field: "name", Message: "Name is required"})
-	}
+	input := models.DefaultGaugeInput()
 
-	// Validate icon
-	icon := r.FormValue("icon")
-	if icon == "" {
-		errors = append(errors, components.FormError{Field: "icon", Message: "Icon is required"})
-	}
-
-	// Validate unit
-	unit := r.FormValue("unit")
-	if unit == "" {
-		errors = append(errors, components.FormError{Field: "unit", Message: "Unit is required"})
-	}
+	input.Name = r.FormValue("name")
+	input.Icon = r.FormValue("icon")
+	input.Unit = r.FormValue("unit")
+	input.Description = r.FormValue("description")
 
 	// Validate target
 	targetStr := r.FormValue("target")
@@ -115,8 +150,87 @@ func validateGaugeForm(r *http.Request) (string, string, string, float64, []comp
 		errors = append(errors, components.FormError{Field: "target", Message: "Target must be a valid number"})
 		target = 0
 	}
+	input.Target = target
 
-	return name, icon, unit, target, errors
+	// Goal settings are optional; only ranges need a minimum
+	if goalType := r.FormValue("goal_type"); goalType != "" {
+		input.GoalType = goalType
+	}
+	if input.GoalType == string(models.GoalRange) {
+		targetMin, err := strconv.ParseFloat(r.FormValue("target_min"), 64)
+		if err != nil {
+			errors = append(errors, components.FormError{Field: "target_min", Message: "Minimum must be a valid number"})
+		}
+		input.TargetMin = targetMin
+	}
+
+	// Period settings are optional and keep their defaults when omitted
+	if periodStr := r.FormValue("period"); periodStr != "" {
+		input.Period = periodStr
+	}
+	if daysStr := r.FormValue("period_days"); daysStr != "" {
+		days, err := strconv.ParseInt(daysStr, 10, 64)
+		if err != nil {
+			days = 0
+		}
+		input.PeriodDays = days
+	}
+	if weekStartStr := r.FormValue("week_start"); weekStartStr != "" {
+		weekStart, err := strconv.ParseInt(weekStartStr, 10, 64)
+		if err != nil {
+			weekStart = -1
+		}
+		input.WeekStart = weekStart
+	}
+	if timezone := r.FormValue("timezone"); timezone != "" {
+		input.Timezone = timezone
+	}
+
+	// Entry settings are optional too; blank bounds are no bounds, and
+	// blank decimal places are suggested from the unit
+	if stepStr := r.FormValue("step"); stepStr != "" {
+		step, err := strconv.ParseFloat(stepStr, 64)
+		if err != nil {
+			step = 0
+		}
+		input.Step = step
+	}
+	presets, err := models.ParsePresets(r.FormValue("presets"))
+	if err != nil {
+		errors = append(errors, components.FormError{Field: "presets", Message: "Quick-add amounts must be numbers separated by commas"})
+	}
+	input.Presets = presets
+	if input.MinValue, err = parseOptionalFloat(r.FormValue("min_value")); err != nil {
+		errors = append(errors, components.FormError{Field: "min_value", Message: "Lowest value must be a valid number"})
+	}
+	if input.MaxValue, err = parseOptionalFloat(r.FormValue("max_value")); err != nil {
+		errors = append(errors, components.FormError{Field: "max_value", Message: "Highest value must be a valid number"})
+	}
+	if decimalsStr := r.FormValue("decimals"); decimalsStr != "" {
+		decimals, err := strconv.ParseInt(decimalsStr, 10, 64)
+		if err != nil {
+			decimals = -1
+		}
+		input.Decimals = &decimals
+	}
+
+	for _, fieldErr := range input.Validate() {
+		errors = append(errors, components.FormError{Field: fieldErr.Field, Message: fieldErr.Message})
+	}
+
+	return input, errors
+}
+
+// parseOptionalFloat parses a form value that may be left blank
+func parseOptionalFloat(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
 }
 
 // handleCreateGauge handles the creation of a new gauge
@@ -126,19 +240,13 @@ func (h *GaugeHandler) handleCreateGauge(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	name, icon, unit, target, errors := validateGaugeForm(r)
+	input, errors := validateGaugeForm(r)
 
 	// If there are validation errors, re-render the form
 	if len(errors) > 0 {
 		w.Header().Set("Content-Type", "text/html")
-		// Create a dummy gauge to maintain form values
-		dummyGauge := &db.Gauge{
-			Name: name,
-			Icon: icon,
-			Unit: unit,
-			Target: target,
-		}
-		err := layouts.Base("New Gauge", components.GaugeForm("POST", "/admin/gauges", dummyGauge, errors)).Render(r.Context(), w)
+		gauge := input.Gauge()
+		err := layouts.Base("New Gauge", components.GaugeForm("POST", "/admin/gauges", &gauge, errors)).Render(r.Context(), w)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -146,17 +254,13 @@ func (h *GaugeHandler) handleCreateGauge(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Create the gauge
-	_, err := h.queries.CreateGauge(r.Context(), db.CreateGaugeParams{
-		Name:   name,
-		Icon:   icon,
-		Unit:   unit,
-		Target: target,
-	})
+	gauge, err := h.queries.CreateGauge(r.Context(), input.CreateParams())
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "create gauge")
 		return
 	}
+	publish(h.events, events.Created(gauge))
 
 	// Redirect to admin page after successful creation
 	h.handleAdmin(w, r)
@@ -174,7 +278,7 @@ func (h *GaugeHandler) handleEditGaugeForm(w http.ResponseWriter, r *http.Reques
 	// Get the gauge
 	gauge, err := h.queries.GetGauge(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "get gauge")
 		return
 	}
 
@@ -203,98 +307,148 @@ func (h *GaugeHandler) handleUpdateGauge(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The version the form was loaded at; forms without one always save
+	version, _ := strconv.ParseInt(r.FormValue("version"), 10, 64)
+
 	// Validate form data
-	name, icon, unit, target, errors := validateGaugeForm(r)
+	input, formErrors := validateGaugeForm(r)
 
 	// If there are validation errors, re-render the form
-	if len(errors) > 0 {
-		w.Header().Set("Content-Type", "text/html")
-		// Create a gauge with the current values to maintain form state
-		currentGauge := db.Gauge{
-			ID:     id,
-			Name:   name,
-			Icon:   icon,
-			Unit:   unit,
-			Target: target,
-		}
-		err := layouts.Base("Edit Gauge", components.GaugeForm("PUT", fmt.Sprintf("/admin/gauges/%d", id), &currentGauge, errors)).Render(r.Context(), w)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+	if len(formErrors) > 0 {
+		h.renderEditForm(w, r, id, input, version, formErrors)
 		return
 	}
 
 	// Update the gauge
-	err = h.queries.UpdateGauge(r.Context(), db.UpdateGaugeParams{
-		ID:     id,
-		Name:   name,
-		Icon:   icon,
-		Unit:   unit,
-		Target: target,
+	updated, err := h.queries.EditGauge(r.Context(), db.EditGaugeParams{
+		UpdateGaugeParams: input.UpdateParams(id),
+		Version:           version,
 	})
+	if errors.Is(err, db.ErrVersionConflict) {
+		// Keep what the user typed but move the form to the current version,
+		// so saving again deliberately overwrites the other change
+		current, err := h.queries.GetGauge(r.Context(), id)
+		if err != nil {
+			gaugeError(w, err, "get gauge")
+			return
+		}
+		h.renderEditForm(w, r, id, input, current.Version, []components.FormError{{
+			Field:   "version",
+			Message: "Someone else changed this gauge while you were editing it. Check your changes and save again to replace theirs.",
+		}})
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "update gauge")
 		return
 	}
+	publish(h.events, events.Updated(updated))
 
 	// Redirect to admin page after successful update
 	h.handleAdmin(w, r)
 }
 
+// renderEditForm re-renders the edit form with the submitted input and
+// errors. Like other form errors it is sent with 200 so HTMX swaps it in.
+func (h *GaugeHandler) renderEditForm(w http.ResponseWriter, r *http.Request, id int64, input models.GaugeInput, version int64, formErrors []components.FormError) {
+	gauge := input.Gauge()
+	gauge.ID = id
+	gauge.Version = version
+
+	w.Header().Set("Content-Type", "text/html")
+	err := layouts.Base("Edit Gauge", components.GaugeForm("PUT", fmt.Sprintf("/admin/gauges/%d", id), &gauge, formErrors)).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // handleDeleteGauge handles the deletion of a gauge
 func (h *GaugeHandler) handleDeleteGauge(w http.ResponseWriter, r *http.Request) {
-	// Parse ID from URL
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid gauge ID: %v", err), http.StatusBadRequest)
+	// Load the gauge first so the event can say what was deleted
+	gauge, ok := h.loadGauge(w, r)
+	if !ok {
 		return
 	}
 
 	// Delete the gauge
-	err = h.queries.DeleteGauge(r.Context(), id)
+	err := h.queries.DeleteGauge(r.Context(), gauge.ID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "delete gauge")
 		return
 	}
+	publish(h.events, events.Deleted(gauge))
 
 	// Redirect to admin page after successful deletion
 	h.handleAdmin(w, r)
 }
 
-// handleIncrementGauge handles incrementing a gauge's value
+// handleIncrementGauge adds the gauge's step to its value, or the amount
+// posted by one of its quick-add buttons
 func (h *GaugeHandler) handleIncrementGauge(w http.ResponseWriter, r *http.Request) {
-	// Parse ID from URL
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid gauge ID: %v", err), http.StatusBadRequest)
+	gauge, ok := h.loadGauge(w, r)
+	if !ok {
 		return
 	}
 
-	// Get the current gauge
-	gauge, err := h.queries.GetGauge(r.Context(), id)
+	delta := models.GaugeStep(&gauge)
+	if amountStr := r.FormValue("amount"); amountStr != "" {
+		amount, err := strconv.ParseFloat(amountStr, 64)
+		if err != nil || amount <= 0 {
+			http.Error(w, "Amount must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if fieldErr := models.ValidateAmount(&gauge, "amount", "Amount", amount); fieldErr != nil {
+			http.Error(w, fieldErr.Message, http.StatusBadRequest)
+			return
+		}
+		delta = amount
+	}
+
+	// Increment the value and record the change in one atomic step
+	updatedGauge, err := h.queries.RecordGaugeValue(r.Context(), db.RecordGaugeValueParams{
+		GaugeID: gauge.ID,
+		Delta:   delta,
+		Source:  db.SourceWeb,
+	})
+	if msg := models.OutOfBoundsMessage(&gauge, err); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "increment gauge")
 		return
 	}
+	publish(h.events, changed(gauge, updatedGauge))
 
-	// Increment the value
-	err = h.queries.UpdateGaugeValue(r.Context(), db.UpdateGaugeValueParams{
-		ID:    id,
-		Value: gauge.Value + 1,
-	})
+	// Render just the updated gauge value component
+	w.Header().Set("Content-Type", "text/html")
+	err = components.GaugeValue(&updatedGauge, updatedGauge.Value).Render(r.Context(), w)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to increment gauge: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDecrementGauge subtracts the gauge's step from its value
+func (h *GaugeHandler) handleDecrementGauge(w http.ResponseWriter, r *http.Request) {
+	gauge, ok := h.loadGauge(w, r)
+	if !ok {
 		return
 	}
 
-	// Get the updated gauge
-	updatedGauge, err := h.queries.GetGauge(r.Context(), id)
+	// Decrement the value without going below zero or the gauge's lowest
+	// value, checked in the same atomic step as the change itself
+	updatedGauge, err := h.queries.RecordGaugeValue(r.Context(), db.RecordGaugeValueParams{
+		GaugeID:      gauge.ID,
+		Delta:        -models.GaugeStep(&gauge),
+		Source:       db.SourceWeb,
+		ClampAtFloor: true,
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get updated gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "decrement gauge")
 		return
 	}
+	publish(h.events, changed(gauge, updatedGauge))
 
 	// Render just the updated gauge value component
 	w.Header().Set("Content-Type", "text/html")
@@ -305,8 +459,161 @@ func (h *GaugeHandler) handleIncrementGauge(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-// handleDecrementGauge handles decrementing a gauge's value
-func (h *GaugeHandler) handleDecrementGauge(w http.ResponseWriter, r *http.Request) {
+// handleSetGaugeValue sets the gauge to the exact value entered on its card
+func (h *GaugeHandler) handleSetGaugeValue(w http.ResponseWriter, r *http.Request) {
+	gauge, ok := h.loadGauge(w, r)
+	if !ok {
+		return
+	}
+
+	entry := components.EntryValues{Value: r.FormValue("value")}
+	value, err := strconv.ParseFloat(entry.Value, 64)
+	if err != nil {
+		h.renderEntry(w, r, &gauge, entry, []components.FormError{{Field: "value", Message: "Value must be a valid number"}})
+		return
+	}
+	if fieldErr := models.ValidateValue(&gauge, "value", "Value", value); fieldErr != nil {
+		h.renderEntry(w, r, &gauge, entry, []components.FormError{{Field: fieldErr.Field, Message: fieldErr.Message}})
+		return
+	}
+
+	updatedGauge, err := h.queries.SetGaugeValue(r.Context(), db.SetGaugeValueParams{
+		GaugeID: gauge.ID,
+		Value:   value,
+		Source:  db.SourceWeb,
+	})
+	if err != nil {
+		gaugeError(w, err, "set gauge value")
+		return
+	}
+	publish(h.events, changed(gauge, updatedGauge))
+
+	h.renderEntrySaved(w, r, &updatedGauge)
+}
+
+// handleLogGaugeAmount adds an amount entered on the gauge's card, dated
+// today or on an earlier day in the gauge's time zone
+func (h *GaugeHandler) handleLogGaugeAmount(w http.ResponseWriter, r *http.Request) {
+	gauge, ok := h.loadGauge(w, r)
+	if !ok {
+		return
+	}
+
+	entry := components.EntryValues{Amount: r.FormValue("amount"), Date: r.FormValue("date")}
+	var formErrors []components.FormError
+	amount, err := strconv.ParseFloat(entry.Amount, 64)
+	if err != nil {
+		formErrors = append(formErrors, components.FormError{Field: "amount", Message: "Amount must be a valid number"})
+	} else if fieldErr := models.ValidateAmount(&gauge, "amount", "Amount", amount); fieldErr != nil {
+		formErrors = append(formErrors, components.FormError{Field: fieldErr.Field, Message: fieldErr.Message})
+	}
+	date, dateErr := parseEntryDate(&gauge, entry.Date, time.Now())
+	if dateErr != nil {
+		formErrors = append(formErrors, *dateErr)
+	}
+	if len(formErrors) > 0 {
+		h.renderEntry(w, r, &gauge, entry, formErrors)
+		return
+	}
+
+	updatedGauge, err := h.queries.RecordGaugeValue(r.Context(), db.RecordGaugeValueParams{
+		GaugeID: gauge.ID,
+		Delta:   amount,
+		Source:  db.SourceWeb,
+		Date:    date,
+	})
+	if msg := models.OutOfBoundsMessage(&gauge, err); msg != "" {
+		h.renderEntry(w, r, &gauge, entry, []components.FormError{{Field: "amount", Message: msg}})
+		return
+	}
+	if err != nil {
+		gaugeError(w, err, "log amount")
+		return
+	}
+	publish(h.events, changed(gauge, updatedGauge))
+
+	h.renderEntrySaved(w, r, &updatedGauge)
+}
+
+// parseEntryDate reads the date an amount was logged for. Today, or no date,
+// means now; earlier days are logged at the start of the day in the gauge's
+// time zone so they land in the right period.
+func parseEntryDate(gauge *db.Gauge, dateStr string, now time.Time) (time.Time, *components.FormError) {
+	loc, err := time.LoadLocation(gauge.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	if dateStr == "" {
+		return now, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", dateStr, loc)
+	if err != nil {
+		return time.Time{}, &components.FormError{Field: "date", Message: "Date must be a date (YYYY-MM-DD)"}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if date.After(today) {
+		return time.Time{}, &components.FormError{Field: "date", Message: "Date cannot be in the future"}
+	}
+	if date.Equal(today) {
+		return now, nil
+	}
+	return date, nil
+}
+
+// gaugeError answers a failed gauge query: 404 for a gauge that does not
+// exist or is not shared with the user, 403 for a change they may not make
+// and 500 otherwise, saying what was being done
+func gaugeError(w http.ResponseWriter, err error, doing string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Gauge not found", http.StatusNotFound)
+	case errors.Is(err, access.ErrForbidden):
+		http.Error(w, "You may not change this gauge", http.StatusForbidden)
+	default:
+		http.Error(w, fmt.Sprintf("Failed to %s: %v", doing, err), http.StatusInternalServerError)
+	}
+}
+
+// loadGauge fetches the gauge named in the URL, writing an error response and
+// returning false if it cannot
+func (h *GaugeHandler) loadGauge(w http.ResponseWriter, r *http.Request) (db.Gauge, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid gauge ID: %v", err), http.StatusBadRequest)
+		return db.Gauge{}, false
+	}
+
+	gauge, err := h.queries.GetGauge(r.Context(), id)
+	if err != nil {
+		gaugeError(w, err, "get gauge")
+		return gauge, false
+	}
+	return gauge, true
+}
+
+// renderEntry re-renders a gauge's entry forms with errors. Like other form
+// errors it is sent with 200 so HTMX swaps it in.
+func (h *GaugeHandler) renderEntry(w http.ResponseWriter, r *http.Request, gauge *db.Gauge, entry components.EntryValues, formErrors []components.FormError) {
+	w.Header().Set("Content-Type", "text/html")
+	err := components.GaugeEntry(gauge, entry, formErrors).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// renderEntrySaved resets a gauge's entry forms and updates its value
+func (h *GaugeHandler) renderEntrySaved(w http.ResponseWriter, r *http.Request, gauge *db.Gauge) {
+	w.Header().Set("Content-Type", "text/html")
+	err := components.GaugeEntrySaved(gauge).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleTrends renders a gauge's values aggregated over time
+func (h *GaugeHandler) handleTrends(w http.ResponseWriter, r *http.Request) {
 	// Parse ID from URL
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -315,37 +622,95 @@ func (h *GaugeHandler) handleDecrementGauge(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Get the current gauge
+	// Get the gauge
 	gauge, err := h.queries.GetGauge(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get gauge: %v", err), http.StatusInternalServerError)
+		gaugeError(w, err, "get gauge")
 		return
 	}
 
-	// Only decrement if value is greater than 0
-	if gauge.Value > 0 {
-		err = h.queries.UpdateGaugeValue(r.Context(), db.UpdateGaugeValueParams{
-			ID:    id,
-			Value: gauge.Value - 1,
-		})
+	query, fieldErrors := parseTrendQuery(r, &gauge, time.Now())
+
+	// If the filters are invalid, re-render the page without a chart
+	if len(fieldErrors) > 0 {
+		errors := make([]components.FormError, len(fieldErrors))
+		for i, fieldErr := range fieldErrors {
+			errors[i] = components.FormError{Field: fieldErr.Field, Message: fieldErr.Message}
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		err = pages.TrendsPage(&models.Trend{Gauge: &gauge, Query: query}, errors).Render(r.Context(), w)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to decrement gauge: %v", err), http.StatusInternalServerError)
-			return
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
+		return
 	}
 
-	// Get the updated gauge
-	updatedGauge, err := h.queries.GetGauge(r.Context(), id)
+	trend, err := loadTrend(r.Context(), h.queries, &gauge, query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get updated gauge: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get values: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Render just the updated gauge value component
 	w.Header().Set("Content-Type", "text/html")
-	err = components.GaugeValue(&updatedGauge, updatedGauge.Value).Render(r.Context(), w)
+	err = pages.TrendsPage(trend, nil).Render(r.Context(), w)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+// loadTrend fetches the values a trend query covers and aggregates them
+func loadTrend(ctx context.Context, queries Querier, gauge *db.Gauge, query models.TrendQuery) (*models.Trend, error) {
+	from, to := query.Range(gauge)
+	values, err := queries.ListGaugeValuesInRange(ctx, db.ListGaugeValuesInRangeParams{
+		GaugeID:  gauge.ID,
+		FromDate: from,
+		ToDate:   to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewTrend(gauge, query, values), nil
+}
+
+// parseTrendQuery reads the granularity, aggregation, from and to query
+// parameters on top of the gauge's defaults. When from is omitted it is
+// derived from the chosen granularity.
+func parseTrendQuery(r *http.Request, gauge *db.Gauge, now time.Time) (models.TrendQuery, []models.FieldError) {
+	var errors []models.FieldError
+	values := r.URL.Query()
+	query := models.DefaultTrendQuery(gauge, now)
+
+	if granularity := values.Get("granularity"); granularity != "" {
+		query.Granularity = models.Granularity(granularity)
+	}
+	if aggregation := values.Get("aggregation"); aggregation != "" {
+		query.Aggregation = models.Aggregation(aggregation)
+	}
+
+	if to := values.Get("to"); to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			errors = append(errors, models.FieldError{Field: "to", Message: "End date must be a date (YYYY-MM-DD)"})
+		} else {
+			query.To = t
+		}
+	}
+
+	query.From = query.DefaultFrom()
+	if from := values.Get("from"); from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			errors = append(errors, models.FieldError{Field: "from", Message: "Start date must be a date (YYYY-MM-DD)"})
+		} else {
+			query.From = t
+		}
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, query.Validate()
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"health-monitor/internal/db"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -90,6 +92,59 @@ func TestGaugeHandler(t *testing.T) {
 			assert.Contains(t, w.Body.String(), "errors")
 			assert.Contains(t, w.Body.String(), "required")
 		})
+
+		t.Run("entry settings", func(t *testing.T) {
+			var created db.CreateGaugeParams
+			queries.CreateGaugeFn = func(ctx context.Context, params db.CreateGaugeParams) (db.Gauge, error) {
+				created = params
+				return db.Gauge{ID: 1}, nil
+			}
+
+			r := createFormRequest("POST", "/admin/gauges", map[string]string{
+				"name":      "Water",
+				"icon":      "water",
+				"unit":      "ml",
+				"target":    "2000",
+				"step":      "100",
+				"presets":   "250, +500",
+				"max_value": "1000",
+			})
+			w := httptest.NewRecorder()
+			handler.handleCreateGauge(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, 100.0, created.Step)
+			assert.Equal(t, "250,500", created.Presets)
+			assert.False(t, created.MinValue.Valid)
+			assert.Equal(t, sql.NullFloat64{Float64: 1000, Valid: true}, created.MaxValue)
+			assert.Equal(t, int64(0), created.Decimals)
+		})
+
+		t.Run("entry settings validation error", func(t *testing.T) {
+			queries.CreateGaugeFn = func(ctx context.Context, params db.CreateGaugeParams) (db.Gauge, error) {
+				t.Fatal("gauge should not be created")
+				return db.Gauge{}, nil
+			}
+
+			r := createFormRequest("POST", "/admin/gauges", map[string]string{
+				"name":      "Weight",
+				"icon":      "heart",
+				"unit":      "kg",
+				"target":    "72.25",
+				"step":      "0.5",
+				"presets":   "1, lots",
+				"min_value": "200",
+				"max_value": "30",
+				"decimals":  "1",
+			})
+			w := httptest.NewRecorder()
+			handler.handleCreateGauge(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "Quick-add amounts must be numbers separated by commas")
+			assert.Contains(t, w.Body.String(), "Maximum must be greater than the minimum")
+			assert.Contains(t, w.Body.String(), "Target must have at most 1 decimal place")
+		})
 	})
 
 	t.Run("Update", func(t *testing.T) {
@@ -104,8 +159,10 @@ func TestGaugeHandler(t *testing.T) {
 					Target: 5.0,
 				}, nil
 			}
-			queries.UpdateGaugeFn = func(ctx context.Context, params db.UpdateGaugeParams) error {
-				return nil
+			var edited db.EditGaugeParams
+			queries.EditGaugeFn = func(ctx context.Context, params db.EditGaugeParams) (db.Gauge, error) {
+				edited = params
+				return db.Gauge{ID: params.ID, Name: params.Name, Version: params.Version + 1}, nil
 			}
 			queries.ListGaugesFn = func(ctx context.Context) ([]db.Gauge, error) {
 				return []db.Gauge{}, nil
@@ -113,10 +170,11 @@ func TestGaugeHandler(t *testing.T) {
 
 			// Create test request
 			r := createFormRequest("PUT", "/admin/gauges/1", map[string]string{
-				"name":   "Updated Gauge",
-				"icon":   "updated-icon",
-				"unit":   "updated-unit",
-				"target": "20",
+				"name":    "Updated Gauge",
+				"icon":    "updated-icon",
+				"unit":    "updated-unit",
+				"target":  "20",
+				"version": "3",
 			})
 
 			// Setup chi router context
@@ -133,6 +191,39 @@ func TestGaugeHandler(t *testing.T) {
 			// Check response
 			assert.Equal(t, http.StatusOK, w.Code)
 			assert.Contains(t, w.Body.String(), "html")
+			assert.Equal(t, int64(1), edited.ID)
+			assert.Equal(t, "Updated Gauge", edited.Name)
+			assert.Equal(t, int64(3), edited.Version)
+		})
+
+		t.Run("edited by someone else", func(t *testing.T) {
+			queries.EditGaugeFn = func(ctx context.Context, params db.EditGaugeParams) (db.Gauge, error) {
+				return db.Gauge{}, db.ErrVersionConflict
+			}
+			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+				return db.Gauge{ID: 1, Name: "Their Name", Unit: "units", Target: 5, Version: 4}, nil
+			}
+
+			r := createFormRequest("PUT", "/admin/gauges/1", map[string]string{
+				"name":    "My Name",
+				"icon":    "star",
+				"unit":    "units",
+				"target":  "20",
+				"version": "3",
+			})
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.handleUpdateGauge(w, r)
+
+			// The form comes back with the user's input at the new version
+			assert.Equal(t, http.StatusOK, w.Code)
+			body := w.Body.String()
+			assert.Contains(t, body, "Someone else changed this gauge")
+			assert.Contains(t, body, `value="My Name"`)
+			assert.Contains(t, body, `name="version" value="4"`)
 		})
 	})
 
@@ -199,8 +290,13 @@ func TestGaugeHandler(t *testing.T) {
 					Value: 10,
 				}, nil
 			}
-			queries.UpdateGaugeValueFn = func(ctx context.Context, params db.UpdateGaugeValueParams) error {
-				return nil
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				return db.Gauge{
+					ID:    1,
+					Value: 10 + params.Delta,
+				}, nil
 			}
 
 			// Create test request
@@ -219,12 +315,16 @@ func TestGaugeHandler(t *testing.T) {
 
 			// Check response
 			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, int64(1), recorded.GaugeID)
+			assert.Equal(t, 1.0, recorded.Delta)
+			assert.Equal(t, db.SourceWeb, recorded.Source)
+			assert.Contains(t, w.Body.String(), "11.0")
 		})
 
 		t.Run("error", func(t *testing.T) {
 			// Mock database calls with error
-			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
-				return db.Gauge{}, fmt.Errorf("failed to get gauge")
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				return db.Gauge{}, fmt.Errorf("database is locked")
 			}
 
 			// Create test request
@@ -243,7 +343,58 @@ func TestGaugeHandler(t *testing.T) {
 
 			// Check response
 			assert.Equal(t, http.StatusInternalServerError, w.Code)
-			assert.Contains(t, w.Body.String(), "failed to get gauge")
+			assert.Contains(t, w.Body.String(), "database is locked")
+		})
+
+		t.Run("past the highest value", func(t *testing.T) {
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				return db.Gauge{}, &db.OutOfBoundsError{Value: 11, Bound: 10, Above: true}
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/gauges/1/increment", nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "over the highest value of 10")
+		})
+
+		t.Run("missing gauge", func(t *testing.T) {
+			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+				return db.Gauge{}, sql.ErrNoRows
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/gauges/99/increment", nil))
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+
+		t.Run("step and presets", func(t *testing.T) {
+			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+				return db.Gauge{ID: 1, Unit: "ml", Step: 250, Presets: "250,500", MaxValue: sql.NullFloat64{Float64: 1000, Valid: true}}, nil
+			}
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				return db.Gauge{ID: 1, Value: params.Delta}, nil
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/gauges/1/increment", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, 250.0, recorded.Delta)
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/increment", map[string]string{"amount": "500"}))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, 500.0, recorded.Delta)
+
+			recorded = db.RecordGaugeValueParams{}
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/increment", map[string]string{"amount": "5000"}))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Amount must be at most 1000")
+			assert.Zero(t, recorded.GaugeID)
 		})
 	})
 
@@ -256,8 +407,13 @@ func TestGaugeHandler(t *testing.T) {
 					Value: 10,
 				}, nil
 			}
-			queries.UpdateGaugeValueFn = func(ctx context.Context, params db.UpdateGaugeValueParams) error {
-				return nil
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				return db.Gauge{
+					ID:    1,
+					Value: 10 + params.Delta,
+				}, nil
 			}
 
 			// Create test request
@@ -276,12 +432,17 @@ func TestGaugeHandler(t *testing.T) {
 
 			// Check response
 			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, int64(1), recorded.GaugeID)
+			assert.Equal(t, -1.0, recorded.Delta)
+			assert.True(t, recorded.ClampAtFloor)
+			assert.Equal(t, db.SourceWeb, recorded.Source)
+			assert.Contains(t, w.Body.String(), "9.0")
 		})
 
 		t.Run("error", func(t *testing.T) {
 			// Mock database calls with error
-			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
-				return db.Gauge{}, fmt.Errorf("failed to get gauge")
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				return db.Gauge{}, fmt.Errorf("database is locked")
 			}
 
 			// Create test request
@@ -300,7 +461,181 @@ func TestGaugeHandler(t *testing.T) {
 
 			// Check response
 			assert.Equal(t, http.StatusInternalServerError, w.Code)
-			assert.Contains(t, w.Body.String(), "failed to get gauge")
+			assert.Contains(t, w.Body.String(), "database is locked")
+		})
+
+		t.Run("step", func(t *testing.T) {
+			queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+				return db.Gauge{ID: 1, Value: 80, Step: 0.5, Decimals: 1}, nil
+			}
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				return db.Gauge{ID: 1, Value: 80 + params.Delta, Decimals: 1}, nil
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/gauges/1/decrement", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, -0.5, recorded.Delta)
+			assert.Contains(t, w.Body.String(), "79.5")
+		})
+	})
+
+	t.Run("Set value", func(t *testing.T) {
+		queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+			return db.Gauge{
+				ID:       1,
+				Unit:     "kg",
+				Value:    81.2,
+				Decimals: 1,
+				MinValue: sql.NullFloat64{Float64: 30, Valid: true},
+				MaxValue: sql.NullFloat64{Float64: 300, Valid: true},
+			}, nil
+		}
+
+		t.Run("success", func(t *testing.T) {
+			var set db.SetGaugeValueParams
+			queries.SetGaugeValueFn = func(ctx context.Context, params db.SetGaugeValueParams) (db.Gauge, error) {
+				set = params
+				return db.Gauge{ID: 1, Unit: "kg", Value: params.Value, Decimals: 1}, nil
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/value", map[string]string{"value": "80.4"}))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, db.SetGaugeValueParams{GaugeID: 1, Value: 80.4, Source: db.SourceWeb}, set)
+			assert.Contains(t, w.Body.String(), `id="gauge-entry-1"`)
+			assert.Contains(t, w.Body.String(), `hx-swap-oob="innerHTML:#gauge-value-1"`)
+			assert.Contains(t, w.Body.String(), "80.4")
+		})
+
+		t.Run("validation error", func(t *testing.T) {
+			queries.SetGaugeValueFn = func(ctx context.Context, params db.SetGaugeValueParams) (db.Gauge, error) {
+				t.Fatal("value should not be set")
+				return db.Gauge{}, nil
+			}
+
+			for value, message := range map[string]string{
+				"heavy": "Value must be a valid number",
+				"12":    "Value must be at least 30",
+				"80.45": "Value must have at most 1 decimal place",
+			} {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/value", map[string]string{"value": value}))
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Contains(t, w.Body.String(), message)
+				assert.Contains(t, w.Body.String(), fmt.Sprintf(`value="%s"`, value))
+				assert.NotContains(t, w.Body.String(), "hx-swap-oob")
+			}
+		})
+	})
+
+	t.Run("Log amount", func(t *testing.T) {
+		queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+			return db.Gauge{ID: 1, Unit: "ml", Timezone: "America/New_York", MaxValue: sql.NullFloat64{Float64: 2000, Valid: true}}, nil
+		}
+
+		t.Run("success", func(t *testing.T) {
+			var recorded db.RecordGaugeValueParams
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				recorded = params
+				return db.Gauge{ID: 1, Unit: "ml", Value: 750}, nil
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/log", map[string]string{
+				"amount": "750",
+				"date":   "2024-03-02",
+			}))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, 750.0, recorded.Delta)
+			assert.True(t, recorded.Date.Equal(time.Date(2024, time.March, 2, 5, 0, 0, 0, time.UTC)), recorded.Date)
+			assert.Contains(t, w.Body.String(), `hx-swap-oob="innerHTML:#gauge-value-1"`)
+		})
+
+		t.Run("validation error", func(t *testing.T) {
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				t.Fatal("amount should not be logged")
+				return db.Gauge{}, nil
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/log", map[string]string{
+				"amount": "2500.5",
+				"date":   time.Now().AddDate(0, 0, 3).Format("2006-01-02"),
+			}))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "Amount must be at most 2000")
+			assert.Contains(t, w.Body.String(), "Date cannot be in the future")
+		})
+
+		t.Run("past a bound", func(t *testing.T) {
+			queries.RecordGaugeValueFn = func(ctx context.Context, params db.RecordGaugeValueParams) (db.Gauge, error) {
+				return db.Gauge{}, &db.OutOfBoundsError{Value: 2250, Bound: 2000, Above: true}
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createFormRequest("POST", "/gauges/1/log", map[string]string{
+				"amount": "750",
+				"date":   "2024-03-02",
+			}))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "over the highest value of 2")
+			assert.NotContains(t, w.Body.String(), `hx-swap-oob="innerHTML:#gauge-value-1"`)
+		})
+	})
+
+	t.Run("Trends", func(t *testing.T) {
+		queries.GetGaugeFn = func(ctx context.Context, id int64) (db.Gauge, error) {
+			return db.Gauge{ID: 1, Name: "Water", Target: 8, Unit: "glasses", Period: "week", WeekStart: 1, Timezone: "UTC"}, nil
+		}
+
+		t.Run("success", func(t *testing.T) {
+			var listed db.ListGaugeValuesInRangeParams
+			queries.ListGaugeValuesInRangeFn = func(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error) {
+				listed = params
+				return []db.GaugeValue{
+					{GaugeID: 1, Delta: 3, Date: time.Date(2025, time.March, 4, 9, 0, 0, 0, time.UTC)},
+					{GaugeID: 1, Delta: 9, Date: time.Date(2025, time.March, 12, 9, 0, 0, 0, time.UTC)},
+				}, nil
+			}
+
+			// Create test request
+			r := httptest.NewRequest("GET", "/gauges/1/trends?granularity=week&aggregation=sum&from=2025-03-03&to=2025-03-16", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			// Check response
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), listed.FromDate)
+			assert.Equal(t, time.Date(2025, time.March, 17, 0, 0, 0, 0, time.UTC), listed.ToDate)
+			assert.Contains(t, w.Body.String(), "Weekly Data")
+			assert.Contains(t, w.Body.String(), "3 Mar 2025")
+			assert.Contains(t, w.Body.String(), "10 Mar 2025")
+			assert.Contains(t, w.Body.String(), "Above Target")
+			assert.Contains(t, w.Body.String(), `id="trendData"`)
+		})
+
+		t.Run("validation error", func(t *testing.T) {
+			queries.ListGaugeValuesInRangeFn = func(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error) {
+				t.Fatal("values should not be loaded for an invalid query")
+				return nil, nil
+			}
+
+			r := httptest.NewRequest("GET", "/gauges/1/trends?granularity=hour&from=2025-03-10&to=2025-03-01", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "Granularity must be day, week, month or year")
+			assert.Contains(t, w.Body.String(), "End date must not be before start date")
 		})
 	})
 }
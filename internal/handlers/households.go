@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+)
+
+// HouseholdStore holds the households and who belongs to them
+type HouseholdStore interface {
+	access.Members
+	ListUserHouseholds(ctx context.Context, userID int64) ([]db.ListUserHouseholdsRow, error)
+	ListHouseholdMembers(ctx context.Context, householdID int64) ([]db.ListHouseholdMembersRow, error)
+	AddHousehold(ctx context.Context, arg db.AddHouseholdParams) (db.Household, error)
+	DeleteHousehold(ctx context.Context, id int64) error
+	SetHouseholdMember(ctx context.Context, arg db.SetHouseholdMemberParams) error
+	DeleteHouseholdMember(ctx context.Context, arg db.DeleteHouseholdMemberParams) error
+	GetUserByUsername(ctx context.Context, username string) (db.User, error)
+}
+
+// SharedGauges are the gauges as the signed in user may use them, as
+// access.Store provides
+type SharedGauges interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	GetGauge(ctx context.Context, id int64) (db.Gauge, error)
+	Role(ctx context.Context, gauge db.Gauge) (access.Role, error)
+	ShareGauge(ctx context.Context, id int64, household sql.NullInt64) error
+}
+
+// HouseholdHandler serves the page for managing households and sharing
+// gauges with them
+type HouseholdHandler struct {
+	store  HouseholdStore
+	gauges SharedGauges
+	events Events
+}
+
+func NewHouseholdHandler(store HouseholdStore, gauges SharedGauges) *HouseholdHandler {
+	return &HouseholdHandler{
+		store:  store,
+		gauges: gauges,
+	}
+}
+
+// SetEvents tells open dashboards to reload when sharing changes
+func (h *HouseholdHandler) SetEvents(e Events) {
+	h.events = e
+}
+
+// RegisterRoutes registers the household routes on the provided router
+func (h *HouseholdHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/households", func(r chi.Router) {
+		r.Get("/", h.handleList)
+		r.Post("/", h.handleCreate)
+		r.Post("/{id}/delete", h.handleDelete)
+		r.Post("/{id}/members", h.handleSetMember)
+		r.Post("/{id}/members/{userID}/delete", h.handleRemoveMember)
+		r.Post("/{id}/gauges", h.handleShare)
+		r.Post("/{id}/gauges/{gaugeID}/delete", h.handleUnshare)
+	})
+}
+
+// handleList renders the user's households
+func (h *HouseholdHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, pages.HouseholdsView{})
+}
+
+// handleCreate starts a household with the user as its owner
+func (h *HouseholdHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFrom(r.Context())
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		h.render(w, r, pages.HouseholdsView{
+			Errors: []components.FormError{{Field: "name", Message: "Name is required"}},
+		})
+		return
+	}
+
+	_, err := h.store.AddHousehold(r.Context(), db.AddHouseholdParams{
+		Name:   name,
+		UserID: user.ID,
+		Role:   access.Owner.String(),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create household: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/households", http.StatusSeeOther)
+}
+
+// handleDelete removes a household. Its gauges stay with their owners and
+// are no longer shared.
+func (h *HouseholdHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.loadHousehold(w, r, access.Owner)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteHousehold(r.Context(), id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete household: %v", err), http.StatusInternalServerError)
+		return
+	}
+	publish(h.events, events.Reload())
+	http.Redirect(w, r, "/admin/households", http.StatusSeeOther)
+}
+
+// handleSetMember adds a user to the household by name, or changes the role
+// of one already in it
+func (h *HouseholdHandler) handleSetMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.loadHousehold(w, r, access.Owner)
+	if !ok {
+		return
+	}
+	field := pages.HouseholdField(id, "member")
+	fail := func(message string) {
+		h.render(w, r, pages.HouseholdsView{Errors: []components.FormError{{Field: field, Message: message}}})
+	}
+
+	role, err := access.ParseRole(r.FormValue("role"))
+	if err != nil {
+		fail("Choose a role")
+		return
+	}
+	member, err := h.store.GetUserByUsername(r.Context(), strings.TrimSpace(r.FormValue("username")))
+	if errors.Is(err, sql.ErrNoRows) {
+		fail("No user has that name")
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get user: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if role != access.Owner {
+		if last, err := h.lastOwner(r.Context(), id, member.ID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get members: %v", err), http.StatusInternalServerError)
+			return
+		} else if last {
+			fail("A household needs at least one owner")
+			return
+		}
+	}
+
+	err = h.store.SetHouseholdMember(r.Context(), db.SetHouseholdMemberParams{
+		HouseholdID: id,
+		UserID:      member.ID,
+		Role:        role.String(),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to set member: %v", err), http.StatusInternalServerError)
+		return
+	}
+	publish(h.events, events.Reload())
+	http.Redirect(w, r, "/admin/households", http.StatusSeeOther)
+}
+
+// handleRemoveMember takes a user out of the household. Owners may remove
+// anyone and every member may leave, as long as an owner remains.
+func (h *HouseholdHandler) handleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFrom(r.Context())
+	memberID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid user ID: %v", err), http.StatusBadRequest)
+		return
+	}
+	need := access.Owner
+	if memberID == user.ID {
+		need = access.Viewer
+	}
+	id, ok := h.loadHousehold(w, r, need)
+	if !ok {
+		return
+	}
+
+	last, err := h.lastOwner(r.Context(), id, memberID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get members: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if last {
+		h.render(w, r, pages.HouseholdsView{Errors: []components.FormError{{
+			Field:   pages.HouseholdField(id, "household"),
+			Message: "A household needs at least one owner; make someone else an owner or delete the household",
+		}}})
+		return
+	}
+
+	err = h.store.DeleteHouseholdMember(r.Context(), db.DeleteHouseholdMemberParams{HouseholdID: id, UserID: memberID})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to remove member: %v", err), http.StatusInternalServerError)
+		return
+	}
+	publish(h.events, events.Reload())
+	http.Redirect(w, r, "/admin/households", http.StatusSeeOther)
+}
+
+// handleShare shares one of the user's gauges with the household
+func (h *HouseholdHandler) handleShare(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.loadHousehold(w, r, access.Editor)
+	if !ok {
+		return
+	}
+	gaugeID, err := strconv.ParseInt(r.FormValue("gauge_id"), 10, 64)
+	if err != nil {
+		h.render(w, r, pages.HouseholdsView{Errors: []components.FormError{{
+			Field:   pages.HouseholdField(id, "gauge"),
+			Message: "Choose a gauge to share",
+		}}})
+		return
+	}
+
+	if err := h.gauges.ShareGauge(r.Context(), gaugeID, sql.NullInt64{Int64: id, Valid: true}); err != nil {
+		gaugeError(w, err, "share gauge")
+		return
+	}
+	publish(h.events, events.Reload())
+	http.Redirect(w, r, "/admin/households", http.StatusSeeOther)
+}
+
+// handleUnshare stops sharing one of the user's gauges with the household
+func (h *HouseholdHandler) handleUnshare(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.loadHousehold(w, r, access.Viewer)
+	if !ok {
+		return
+	}
+	gaugeID, err := strconv.ParseInt(chi.URLParam(r, "gaugeID"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid gauge ID: %v", err), http.StatusBadRequest)
+		return
+	}
+	gauge, err := h.gauges.GetGauge(r.Context(), gaugeID)
+	if err == nil && gauge.HouseholdID != (sql.NullInt64{Int64: id, Valid: true}) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		gaugeError(w, err, "get gauge")
+		return
+	}
+
+	if err := h.gauges.ShareGauge(r.Context(), gauge.ID, sql.NullInt64{}); err != nil {
+		gaugeError(w, err, "stop sharing gauge")
+		return
+	}
+	publish(h.events, events.Reload())
+	http.Redirect(w, r, "/admin/households", http.StatusSeeOther)
+}
+
+// loadHousehold reads the household in the URL, writing an error response
+// and returning false unless the user has at least the given role in it.
+// Households the user is not in are not found.
+func (h *HouseholdHandler) loadHousehold(w http.ResponseWriter, r *http.Request, need access.Role) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid household ID: %v", err), http.StatusBadRequest)
+		return 0, false
+	}
+
+	user, _ := auth.UserFrom(r.Context())
+	role, err := access.HouseholdRole(r.Context(), h.store, id, user)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get household: %v", err), http.StatusInternalServerError)
+		return 0, false
+	}
+	if role == access.None {
+		http.Error(w, "Household not found", http.StatusNotFound)
+		return 0, false
+	}
+	if role < need {
+		http.Error(w, "You may not change this household", http.StatusForbidden)
+		return 0, false
+	}
+	return id, true
+}
+
+// lastOwner reports whether a user is the only owner of a household
+func (h *HouseholdHandler) lastOwner(ctx context.Context, householdID, userID int64) (bool, error) {
+	members, err := h.store.ListHouseholdMembers(ctx, householdID)
+	if err != nil {
+		return false, err
+	}
+	owners, isOwner := 0, false
+	for _, member := range members {
+		if member.Role == access.Owner.String() {
+			owners++
+			isOwner = isOwner || member.UserID == userID
+		}
+	}
+	return isOwner && owners == 1, nil
+}
+
+// render renders the households page with the user's households, their
+// members and gauges. Like other form errors they are sent with 200.
+func (h *HouseholdHandler) render(w http.ResponseWriter, r *http.Request, view pages.HouseholdsView) {
+	ctx := r.Context()
+	user, _ := auth.UserFrom(ctx)
+	view.UserID = user.ID
+
+	households, err := h.store.ListUserHouseholds(ctx, user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get households: %v", err), http.StatusInternalServerError)
+		return
+	}
+	gauges, err := h.gauges.ListGauges(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get gauges: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	for _, gauge := range gauges {
+		role, err := h.gauges.Role(ctx, gauge)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get gauges: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if role == access.Owner {
+			view.Shareable = append(view.Shareable, gauge)
+		}
+	}
+	for _, household := range households {
+		role, err := access.ParseRole(household.Role)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get households: %v", err), http.StatusInternalServerError)
+			return
+		}
+		members, err := h.store.ListHouseholdMembers(ctx, household.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get members: %v", err), http.StatusInternalServerError)
+			return
+		}
+		hv := pages.HouseholdView{ID: household.ID, Name: household.Name, Role: role, Members: members}
+		for _, gauge := range gauges {
+			if gauge.HouseholdID.Valid && gauge.HouseholdID.Int64 == household.ID {
+				hv.Gauges = append(hv.Gauges, gauge)
+			}
+		}
+		view.Households = append(view.Households, hv)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := pages.HouseholdsPage(view).Render(ctx, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHouseholdHandler(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	scoped := access.NewStore(store)
+	ctx := context.Background()
+
+	newUser := func(name string) db.User {
+		user, err := store.CreateUser(ctx, db.CreateUserParams{Username: name, PasswordHash: "-"})
+		require.NoError(t, err)
+		return user
+	}
+	alice, bob, dave := newUser("alice"), newUser("bob"), newUser("dave")
+	gauge := testutil.CreateTestGauge(t, store)
+	_, err := store.AdoptGauges(ctx, sql.NullInt64{Int64: alice.ID, Valid: true})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	NewHouseholdHandler(store, scoped).RegisterRoutes(router)
+	NewGaugeHandler(scoped).RegisterRoutes(router)
+
+	post := func(user db.User, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(user db.User, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(alice, "/admin/households", url.Values{"name": {""}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Name is required")
+
+	w = post(alice, "/admin/households", url.Values{"name": {"Home"}})
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	households, err := store.ListUserHouseholds(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Len(t, households, 1)
+	home := households[0]
+	assert.Equal(t, "owner", home.Role)
+	householdPath := fmt.Sprintf("/admin/households/%d", home.ID)
+
+	t.Run("adds members", func(t *testing.T) {
+		w := post(alice, householdPath+"/members", url.Values{"username": {"nobody"}, "role": {"viewer"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "No user has that name")
+
+		w = post(alice, householdPath+"/members", url.Values{"username": {"bob"}, "role": {"editor"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Contains(t, get(bob, "/admin/households").Body.String(), "Home")
+	})
+
+	t.Run("only owners manage members", func(t *testing.T) {
+		w := post(bob, householdPath+"/members", url.Values{"username": {"dave"}, "role": {"owner"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = post(dave, householdPath+"/delete", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("keeps an owner", func(t *testing.T) {
+		w := post(alice, householdPath+"/members", url.Values{"username": {"alice"}, "role": {"viewer"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "A household needs at least one owner")
+		w = post(alice, fmt.Sprintf("%s/members/%d/delete", householdPath, alice.ID), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "A household needs at least one owner")
+	})
+
+	t.Run("shares gauges", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(bob, fmt.Sprintf("/admin/gauges/%d", gauge.ID)).Code)
+
+		w := post(bob, householdPath+"/gauges", url.Values{"gauge_id": {fmt.Sprint(gauge.ID)}})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = post(alice, householdPath+"/gauges", url.Values{"gauge_id": {fmt.Sprint(gauge.ID)}})
+		require.Equal(t, http.StatusSeeOther, w.Code)
+
+		assert.Equal(t, http.StatusOK, get(bob, fmt.Sprintf("/admin/gauges/%d", gauge.ID)).Code)
+		assert.Contains(t, get(bob, "/admin/households").Body.String(), "Test Gauge")
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("/admin/gauges/%d", gauge.ID), nil)
+		req = req.WithContext(auth.WithUser(req.Context(), bob))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("household owners do not own shared gauges", func(t *testing.T) {
+		bobs, err := scoped.CreateGauge(auth.WithUser(ctx, bob), db.CreateGaugeParams{
+			Name: "Bob's Steps", Target: 10000, Unit: "steps", Icon: "star",
+			Period: "day", PeriodDays: 1, WeekStart: 1, Timezone: "UTC", GoalType: "at_least", Step: 1,
+		})
+		require.NoError(t, err)
+		w := post(bob, householdPath+"/gauges", url.Values{"gauge_id": {fmt.Sprint(bobs.ID)}})
+		require.Equal(t, http.StatusSeeOther, w.Code)
+
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("/admin/gauges/%d", bobs.ID), nil)
+		req = req.WithContext(auth.WithUser(req.Context(), alice))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = post(alice, fmt.Sprintf("%s/gauges/%d/delete", householdPath, bobs.ID), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		got, err := store.GetGauge(ctx, bobs.ID)
+		require.NoError(t, err, "not deleted")
+		assert.Equal(t, sql.NullInt64{Int64: home.ID, Valid: true}, got.HouseholdID, "still shared")
+		assert.Equal(t, http.StatusOK, get(alice, fmt.Sprintf("/admin/gauges/%d", bobs.ID)).Code, "but may edit it")
+	})
+
+	t.Run("members leave", func(t *testing.T) {
+		w := post(bob, fmt.Sprintf("%s/members/%d/delete", householdPath, bob.ID), nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, http.StatusNotFound, get(bob, fmt.Sprintf("/admin/gauges/%d", gauge.ID)).Code)
+	})
+
+	t.Run("stops sharing", func(t *testing.T) {
+		w := post(alice, fmt.Sprintf("%s/gauges/%d/delete", householdPath, gauge.ID), nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		got, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.False(t, got.HouseholdID.Valid)
+	})
+}
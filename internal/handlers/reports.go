@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/models"
+	"health-monitor/internal/report"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+)
+
+// ReportMailer emails digest reports
+type ReportMailer interface {
+	Recipients() []string
+	Deliver(ctx context.Context, r *models.Report) error
+}
+
+// ReportHandler serves the digest report preview
+type ReportHandler struct {
+	store     report.Store
+	mailer    ReportMailer
+	schedules map[models.ReportFrequency]string
+	now       func() time.Time
+}
+
+func NewReportHandler(store report.Store, mailer ReportMailer, schedules map[models.ReportFrequency]string) *ReportHandler {
+	return &ReportHandler{
+		store:     store,
+		mailer:    mailer,
+		schedules: schedules,
+		now:       time.Now,
+	}
+}
+
+// RegisterRoutes registers the report admin routes on the provided router
+func (h *ReportHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/reports", func(r chi.Router) {
+		r.Get("/", h.handlePreview)
+		r.Get("/email", h.handleEmail)
+		r.Get("/text", h.handleText)
+		r.Post("/send", h.handleSend)
+	})
+}
+
+// handlePreview renders the report chosen by ?frequency= as it would be
+// sent on ?date=, today unless given
+func (h *ReportHandler) handlePreview(w http.ResponseWriter, r *http.Request) {
+	view, at := h.readReportQuery(r)
+	h.render(w, r, view, at)
+}
+
+// handleEmail serves a report's HTML as it is emailed
+func (h *ReportHandler) handleEmail(w http.ResponseWriter, r *http.Request) {
+	rep, ok := h.build(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if err := components.ReportEmail(rep).Render(r.Context(), w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleText serves a report's plain text as it is emailed
+func (h *ReportHandler) handleText(w http.ResponseWriter, r *http.Request) {
+	rep, ok := h.build(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, report.Text(rep))
+}
+
+// handleSend emails a report to the recipients straight away and reports
+// how it went
+func (h *ReportHandler) handleSend(w http.ResponseWriter, r *http.Request) {
+	view, at := h.readReportQuery(r)
+	if len(view.Errors) > 0 {
+		h.render(w, r, view, at)
+		return
+	}
+	rep, err := report.Build(r.Context(), h.store, view.Frequency, at)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	view.Notice = "Emailed the report to " + strings.Join(h.mailer.Recipients(), ", ") + "."
+	if err := h.mailer.Deliver(r.Context(), rep); err != nil {
+		view.Notice = fmt.Sprintf("Could not email the report: %v", err)
+		view.Failed = true
+	}
+	h.render(w, r, view, at)
+}
+
+// readReportQuery reads which report to show and the day it is shown as
+// sent on
+func (h *ReportHandler) readReportQuery(r *http.Request) (pages.ReportsView, time.Time) {
+	r.ParseForm()
+	view := pages.ReportsView{Frequency: models.ReportWeekly}
+	if s := r.FormValue("frequency"); s != "" {
+		f, err := models.ParseReportFrequency(s)
+		if err != nil {
+			view.Errors = append(view.Errors, components.FormError{Field: "frequency", Message: "Report must be weekly or monthly"})
+		} else {
+			view.Frequency = f
+		}
+	}
+
+	now := h.now()
+	at := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if s := r.FormValue("date"); s != "" {
+		date, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			view.Errors = append(view.Errors, components.FormError{Field: "date", Message: "Date must be a date such as 2025-03-10"})
+		} else {
+			at = date
+		}
+	}
+	view.Date = at.Format(time.DateOnly)
+	return view, at
+}
+
+// build builds the report a query asks for, answering 400 for a bad query
+func (h *ReportHandler) build(w http.ResponseWriter, r *http.Request) (*models.Report, bool) {
+	view, at := h.readReportQuery(r)
+	if len(view.Errors) > 0 {
+		http.Error(w, view.Errors[0].Message, http.StatusBadRequest)
+		return nil, false
+	}
+	rep, err := report.Build(r.Context(), h.store, view.Frequency, at)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build report: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return rep, true
+}
+
+// render renders the preview page. Like other form errors it is sent with
+// 200.
+func (h *ReportHandler) render(w http.ResponseWriter, r *http.Request, view pages.ReportsView, at time.Time) {
+	view.Recipients = h.mailer.Recipients()
+	view.Schedules = h.schedules
+	if len(view.Errors) == 0 {
+		rep, err := report.Build(r.Context(), h.store, view.Frequency, at)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to build report: %v", err), http.StatusInternalServerError)
+			return
+		}
+		view.Report = rep
+		view.Text = report.Text(rep)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err := pages.ReportsPage(view).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
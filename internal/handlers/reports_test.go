@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"health-monitor/internal/models"
+	"health-monitor/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMailer struct {
+	to        []string
+	delivered []*models.Report
+}
+
+func (f *fakeMailer) Recipients() []string { return f.to }
+
+func (f *fakeMailer) Deliver(ctx context.Context, r *models.Report) error {
+	f.delivered = append(f.delivered, r)
+	return nil
+}
+
+func TestReportHandlers(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	testutil.CreateTestGauge(t, store)
+	mailer := &fakeMailer{to: []string{"me@example.com"}}
+	h := NewReportHandler(store, mailer, map[models.ReportFrequency]string{models.ReportWeekly: "0 8 * * 1"})
+	h.now = func() time.Time { return time.Date(2025, time.March, 12, 9, 0, 0, 0, time.UTC) }
+	router := chi.NewRouter()
+	h.RegisterRoutes(router)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	t.Run("previews last week", func(t *testing.T) {
+		w := get("/admin/reports")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Week of 5 Mar 2025")
+		assert.Contains(t, w.Body.String(), "Test Gauge")
+		assert.Contains(t, w.Body.String(), "Reports are emailed to me@example.com")
+	})
+
+	t.Run("previews a month as sent on a date", func(t *testing.T) {
+		w := get("/admin/reports?frequency=monthly&date=2025-01-01")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "December 2024")
+	})
+
+	t.Run("rejects a bad date", func(t *testing.T) {
+		w := get("/admin/reports?date=yesterday")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Date must be a date such as 2025-03-10")
+
+		w = get("/admin/reports/text?frequency=daily")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("serves the email parts", func(t *testing.T) {
+		w := get("/admin/reports/text?frequency=weekly&date=2025-03-10")
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "Week of 3 Mar 2025\n"))
+
+		w = get("/admin/reports/email?frequency=weekly&date=2025-03-10")
+		assert.Contains(t, w.Body.String(), "<title>Health Monitor: Week of 3 Mar 2025</title>")
+	})
+
+	t.Run("emails a report now", func(t *testing.T) {
+		form := url.Values{"frequency": {"monthly"}, "date": {"2025-03-01"}}
+		req := httptest.NewRequest("POST", "/admin/reports/send", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Emailed the report to me@example.com.")
+		require.Len(t, mailer.delivered, 1)
+		assert.Equal(t, "February 2025", mailer.delivered[0].Label)
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+)
+
+// TokenStore lists and revokes a user's access tokens
+type TokenStore interface {
+	ListUserAccessTokens(ctx context.Context, userID int64) ([]db.AccessToken, error)
+	DeleteAccessToken(ctx context.Context, arg db.DeleteAccessTokenParams) error
+}
+
+// TokenIssuer creates access tokens, returning their secret
+type TokenIssuer interface {
+	Create(ctx context.Context, arg auth.CreateTokenParams) (db.AccessToken, string, error)
+}
+
+// TokenHandler serves the page where users manage their access tokens
+type TokenHandler struct {
+	store  TokenStore
+	issuer TokenIssuer
+}
+
+func NewTokenHandler(store TokenStore, issuer TokenIssuer) *TokenHandler {
+	return &TokenHandler{
+		store:  store,
+		issuer: issuer,
+	}
+}
+
+// RegisterRoutes registers the access token routes on the provided router
+func (h *TokenHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/tokens", func(r chi.Router) {
+		r.Get("/", h.handleList)
+		r.Post("/", h.handleCreate)
+		r.Post("/{id}/delete", h.handleDelete)
+	})
+}
+
+// handleList renders the user's tokens and the form for a new one
+func (h *TokenHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, pages.TokensView{Form: newTokenForm()})
+}
+
+// handleCreate issues a token and shows its secret, which is not kept
+func (h *TokenHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	form, formErrors := readTokenForm(r)
+	if len(formErrors) > 0 {
+		h.render(w, r, pages.TokensView{Form: form, Errors: formErrors})
+		return
+	}
+
+	user, _ := auth.UserFrom(r.Context())
+	_, secret, err := h.issuer.Create(r.Context(), auth.CreateTokenParams{
+		UserID: user.ID,
+		Name:   form.Name,
+		Scopes: form.Scopes,
+		TTL:    time.Duration(form.ExpiresDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create token: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.render(w, r, pages.TokensView{Form: newTokenForm(), Created: secret})
+}
+
+// handleDelete revokes one of the user's tokens
+func (h *TokenHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid token ID: %v", err), http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFrom(r.Context())
+	if err := h.store.DeleteAccessToken(r.Context(), db.DeleteAccessTokenParams{ID: id, UserID: user.ID}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke token: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/tokens", http.StatusSeeOther)
+}
+
+// newTokenForm is the form for a new token: reading gauges and logging
+// values, for 90 days
+func newTokenForm() pages.TokenForm {
+	return pages.TokenForm{
+		Scopes:      []string{auth.ScopeGaugesRead, auth.ScopeValuesWrite},
+		ExpiresDays: 90,
+	}
+}
+
+// readTokenForm reads and checks a submitted token
+func readTokenForm(r *http.Request) (pages.TokenForm, []components.FormError) {
+	r.ParseForm()
+	form := pages.TokenForm{
+		Name:   strings.TrimSpace(r.FormValue("name")),
+		Scopes: auth.ParseScopes(strings.Join(r.Form["scopes"], ",")),
+	}
+
+	var formErrors []components.FormError
+	if form.Name == "" {
+		formErrors = append(formErrors, components.FormError{Field: "name", Message: "Name is required"})
+	}
+	days, err := strconv.Atoi(r.FormValue("expires_days"))
+	if err != nil || !slices.Contains(pages.TokenExpiries, days) {
+		formErrors = append(formErrors, components.FormError{Field: "expires_days", Message: "Choose when the token expires"})
+	}
+	form.ExpiresDays = days
+	if len(form.Scopes) == 0 {
+		formErrors = append(formErrors, components.FormError{Field: "scopes", Message: "Choose at least one scope"})
+	}
+	return form, formErrors
+}
+
+// render renders the tokens page. Like other form errors it is sent with
+// 200.
+func (h *TokenHandler) render(w http.ResponseWriter, r *http.Request, view pages.TokensView) {
+	user, _ := auth.UserFrom(r.Context())
+	tokens, err := h.store.ListUserAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get tokens: %v", err), http.StatusInternalServerError)
+		return
+	}
+	view.Tokens = tokens
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := pages.TokensPage(view).Render(r.Context(), w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenHandler(t *testing.T) {
+	store := testutil.NewTestDB(t)
+	ctx := context.Background()
+	alice, err := store.CreateUser(ctx, db.CreateUserParams{Username: "alice", PasswordHash: "-"})
+	require.NoError(t, err)
+	bob, err := store.CreateUser(ctx, db.CreateUserParams{Username: "bob", PasswordHash: "-"})
+	require.NoError(t, err)
+
+	tokens := auth.NewTokens(store)
+	router := chi.NewRouter()
+	router.Use(tokens.Load, auth.Protect)
+	NewTokenHandler(store, tokens).RegisterRoutes(router)
+	NewAPIHandler(access.NewStore(store)).RegisterRoutes(router)
+
+	post := func(user db.User, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	api := func(method, path, secret, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("checks the form", func(t *testing.T) {
+		w := post(alice, "/admin/tokens", url.Values{"expires_days": {"7"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Name is required")
+		assert.Contains(t, w.Body.String(), "Choose when the token expires")
+		assert.Contains(t, w.Body.String(), "Choose at least one scope")
+	})
+
+	w := post(alice, "/admin/tokens", url.Values{
+		"name":         {"Shortcut"},
+		"expires_days": {"30"},
+		"scopes":       {"gauges:read", "gauges:write"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	secret := regexp.MustCompile(`hm_[0-9a-f]{64}`).FindString(w.Body.String())
+	require.NotEmpty(t, secret)
+	assert.Contains(t, w.Body.String(), "Shortcut")
+
+	t.Run("uses the api", func(t *testing.T) {
+		w := api("POST", "/api/v1/gauges", secret, `{"name": "Water", "target": 8, "unit": "glasses", "icon": "star"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = api("GET", "/api/v1/gauges", secret, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Water")
+
+		gauges, err := store.ListUserGauges(ctx, db.ListUserGaugesParams{UserID: alice.ID})
+		require.NoError(t, err)
+		require.Len(t, gauges, 1)
+		w = api("POST", fmt.Sprintf("/api/v1/gauges/%d/values", gauges[0].ID), secret, `{"delta": 1}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "values:write")
+		w = api("GET", "/api/v1/export?kind=values", secret, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = api("GET", "/api/v1/export?kind=gauges", secret, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("others may not revoke it", func(t *testing.T) {
+		list, err := store.ListUserAccessTokens(ctx, alice.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.True(t, list[0].ExpiresAt.Valid)
+		assert.True(t, list[0].LastUsedAt.Valid)
+
+		w := post(bob, fmt.Sprintf("/admin/tokens/%d/delete", list[0].ID), nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, http.StatusOK, api("GET", "/api/v1/gauges", secret, "").Code)
+
+		w = post(alice, fmt.Sprintf("/admin/tokens/%d/delete", list[0].ID), nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, api("GET", "/api/v1/gauges", secret, "").Code)
+	})
+}
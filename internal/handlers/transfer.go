@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/events"
+	"health-monitor/internal/models"
+	"health-monitor/internal/transfer"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportSize limits the size of an uploaded import file
+const maxImportSize = 10 << 20
+
+// parseTransferOptions reads the kind, gauge, date_format, unit and col_<field>
+// parameters shared by the export and import forms and endpoints. A
+// date_format of "custom" takes the layout from date_layout.
+func parseTransferOptions(values url.Values) (transfer.Options, []models.FieldError) {
+	opts := transfer.DefaultOptions()
+	if kind := values.Get("kind"); kind != "" {
+		opts.Kind = transfer.Kind(kind)
+	}
+	opts.Gauge = strings.TrimSpace(values.Get("gauge"))
+	opts.Unit = strings.TrimSpace(values.Get("unit"))
+	if format := values.Get("date_format"); format == "custom" {
+		opts.DateFormat = values.Get("date_layout")
+		if opts.DateFormat == "" {
+			return opts, []models.FieldError{{Field: "date_format", Message: "Enter a layout for the custom date format"}}
+		}
+	} else if format != "" {
+		opts.DateFormat = format
+	}
+
+	for key, vals := range values {
+		field, ok := strings.CutPrefix(key, "col_")
+		if !ok || len(vals) == 0 || strings.TrimSpace(vals[0]) == "" {
+			continue
+		}
+		if opts.Columns == nil {
+			opts.Columns = make(map[string]string)
+		}
+		opts.Columns[field] = strings.TrimSpace(vals[0])
+	}
+
+	return opts, opts.Validate()
+}
+
+// parseExportGauge reads the optional gauge_id parameter limiting an export
+// to one gauge
+func parseExportGauge(values url.Values) (int64, *models.FieldError) {
+	idStr := values.Get("gauge_id")
+	if idStr == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id < 1 {
+		return 0, &models.FieldError{Field: "gauge_id", Message: "Gauge must be a valid gauge ID"}
+	}
+	return id, nil
+}
+
+// exportFilename names a downloaded export after its contents and the day it
+// was made
+func exportFilename(kind transfer.Kind, now time.Time) string {
+	return fmt.Sprintf("health-monitor-%s-%s.csv", kind, now.Format("2006-01-02"))
+}
+
+func toFormErrors(fieldErrors []models.FieldError) []components.FormError {
+	errors := make([]components.FormError, len(fieldErrors))
+	for i, fieldErr := range fieldErrors {
+		errors[i] = components.FormError{Field: fieldErr.Field, Message: fieldErr.Message}
+	}
+	return errors
+}
+
+// handleExportForm renders the export page
+func (h *GaugeHandler) handleExportForm(w http.ResponseWriter, r *http.Request) {
+	h.renderExport(w, r, transfer.DefaultOptions(), 0, nil)
+}
+
+// handleExportDownload sends the CSV file described by the export form, or
+// re-renders the form if its settings are invalid
+func (h *GaugeHandler) handleExportDownload(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts, fieldErrors := parseTransferOptions(query)
+	gaugeID, idErr := parseExportGauge(query)
+	if idErr != nil {
+		fieldErrors = append(fieldErrors, *idErr)
+	}
+	if len(fieldErrors) > 0 {
+		h.renderExport(w, r, opts, gaugeID, toFormErrors(fieldErrors))
+		return
+	}
+
+	// Write to a buffer first so a failure can still be reported as an error
+	var buf bytes.Buffer
+	if err := transfer.Export(r.Context(), h.queries, &buf, opts, gaugeID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(opts.Kind, time.Now())))
+	w.Write(buf.Bytes())
+}
+
+func (h *GaugeHandler) renderExport(w http.ResponseWriter, r *http.Request, opts transfer.Options, gaugeID int64, formErrors []components.FormError) {
+	gauges, err := h.queries.ListGauges(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get gauges: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = pages.ExportPage(gauges, opts, gaugeID, formErrors).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleImportForm renders the import page
+func (h *GaugeHandler) handleImportForm(w http.ResponseWriter, r *http.Request) {
+	h.renderImport(w, r, pages.ImportForm{Options: transfer.DefaultOptions()}, nil, nil)
+}
+
+// handleImport previews an uploaded file, or imports it once the preview is
+// confirmed. The confirmation posts the file's contents back in the data
+// field so it need not be uploaded again.
+func (h *GaugeHandler) handleImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	opts, fieldErrors := parseTransferOptions(r.Form)
+	form := pages.ImportForm{Options: opts, Data: r.FormValue("data")}
+	if file, _, err := r.FormFile("file"); err == nil {
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read file: %v", err), http.StatusBadRequest)
+			return
+		}
+		form.Data = string(data)
+	}
+	if form.Data == "" {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "file", Message: "Choose a CSV file to import"})
+	}
+	if len(fieldErrors) > 0 {
+		h.renderImport(w, r, form, nil, toFormErrors(fieldErrors))
+		return
+	}
+
+	dryRun := r.FormValue("action") != "import"
+	report, err := transfer.Import(r.Context(), h.queries, strings.NewReader(form.Data), opts, dryRun)
+	if errors.Is(err, transfer.ErrInvalidFile) {
+		h.renderImport(w, r, form, nil, []components.FormError{{Field: "file", Message: importFileMessage(err)}})
+		return
+	}
+	if err != nil && !errors.Is(err, transfer.ErrInvalidRows) {
+		gaugeError(w, err, "import")
+		return
+	}
+	if !dryRun && err == nil {
+		publish(h.events, events.Reload())
+	}
+
+	h.renderImport(w, r, form, report, nil)
+}
+
+// importFileMessage turns an error reading a file into a message for the form
+func importFileMessage(err error) string {
+	msg := strings.TrimPrefix(err.Error(), transfer.ErrInvalidFile.Error()+": ")
+	return "The file could not be read: " + msg
+}
+
+// renderImport renders the import page. Like other form errors it is sent
+// with 200.
+func (h *GaugeHandler) renderImport(w http.ResponseWriter, r *http.Request, form pages.ImportForm, report *transfer.Report, formErrors []components.FormError) {
+	gauges, err := h.queries.ListGauges(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get gauges: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = pages.ImportPage(gauges, form, report, formErrors).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleExport returns gauges or their values as CSV, configured by the same
+// query parameters as the export form
+func (h *APIHandler) handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts, fieldErrors := parseTransferOptions(query)
+	gaugeID, idErr := parseExportGauge(query)
+	if idErr != nil {
+		fieldErrors = append(fieldErrors, *idErr)
+	}
+	if len(fieldErrors) > 0 {
+		models.WriteError(w, fieldErrorsToAppError(fieldErrors))
+		return
+	}
+	if scope := transferScope(opts.Kind, false); !auth.HasScope(r.Context(), scope) {
+		models.WriteError(w, auth.ScopeError(scope))
+		return
+	}
+	if gaugeID != 0 {
+		if _, appErr := h.getGauge(r, gaugeID); appErr != nil {
+			models.WriteError(w, appErr)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := transfer.Export(r.Context(), h.queries, &buf, opts, gaugeID); err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to export: %v", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(opts.Kind, time.Now())))
+	w.Write(buf.Bytes())
+}
+
+// handleImport imports a CSV body, configured by the same query parameters
+// as the import form. With dry_run=true it only reports what would happen.
+// If any row is invalid nothing is imported and the report is returned with
+// 422 Unprocessable Entity.
+func (h *APIHandler) handleImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts, fieldErrors := parseTransferOptions(query)
+	if len(fieldErrors) > 0 {
+		models.WriteError(w, fieldErrorsToAppError(fieldErrors))
+		return
+	}
+	if scope := transferScope(opts.Kind, true); !auth.HasScope(r.Context(), scope) {
+		models.WriteError(w, auth.ScopeError(scope))
+		return
+	}
+	dryRun := false
+	if s := query.Get("dry_run"); s != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			models.WriteError(w, models.NewValidationError("dry_run must be true or false"))
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	report, err := transfer.Import(r.Context(), h.queries, body, opts, dryRun)
+	if errors.Is(err, transfer.ErrInvalidFile) {
+		models.WriteError(w, models.NewValidationError(err.Error()))
+		return
+	}
+	if errors.Is(err, transfer.ErrInvalidRows) || (err == nil && report.Invalid > 0) {
+		models.WriteJSONStatus(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if errors.Is(err, access.ErrForbidden) {
+		models.WriteError(w, models.NewForbiddenError("You may not log values on every gauge in the file"))
+		return
+	}
+	if err != nil {
+		models.WriteError(w, models.NewInternalError(fmt.Sprintf("Failed to import: %v", err)))
+		return
+	}
+	if !dryRun {
+		publish(h.events, events.Reload())
+	}
+
+	models.WriteJSON(w, report)
+}
+
+// transferScope is the access token scope needed to export, or import when
+// write is set, a kind of file
+func transferScope(kind transfer.Kind, write bool) string {
+	switch {
+	case kind == transfer.KindGauges && write:
+		return auth.ScopeGaugesWrite
+	case kind == transfer.KindGauges:
+		return auth.ScopeGaugesRead
+	case write:
+		return auth.ScopeValuesWrite
+	}
+	return auth.ScopeValuesRead
+}
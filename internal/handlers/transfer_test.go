@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"health-monitor/internal/db"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper function to create a multipart request with form values and an
+// optional uploaded file
+func createUploadRequest(t *testing.T, path string, formValues map[string]string, file string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for key, value := range formValues {
+		require.NoError(t, mw.WriteField(key, value))
+	}
+	if file != "" {
+		fw, err := mw.CreateFormFile("file", "values.csv")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(file))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest("POST", path, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestTransferHandlers(t *testing.T) {
+	queries := &db.MockQueries{}
+	router := chi.NewRouter()
+	NewGaugeHandler(queries).RegisterRoutes(router)
+
+	gauge := db.Gauge{ID: 1, Name: "Water", Unit: "l", Target: 2, Timezone: "UTC", Decimals: 2}
+	queries.ListGaugesFn = func(ctx context.Context) ([]db.Gauge, error) {
+		return []db.Gauge{gauge}, nil
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		queries.ListGaugeValuesInRangeFn = func(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error) {
+			return []db.GaugeValue{
+				{GaugeID: 1, Delta: 0.25, Source: db.SourceWeb, Date: time.Date(2025, time.March, 1, 9, 30, 0, 0, time.UTC)},
+			}, nil
+		}
+
+		t.Run("form", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/export", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `action="/admin/export/download"`)
+			assert.Contains(t, w.Body.String(), "Water")
+		})
+
+		t.Run("download", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/export/download?date_format=custom&date_layout=02.01.2006+15:04&unit=ml", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, "gauge,date,amount,unit,source\nWater,01.03.2025 09:30,250,ml,web\n", w.Body.String())
+		})
+
+		t.Run("validation error", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/export/download?unit=furlongs&col_date=gauge", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), "Unit must be one of")
+			assert.Contains(t, w.Body.String(), "Columns for gauge and date must be different")
+		})
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		var dryRuns []bool
+		queries.ImportGaugeValuesFn = func(ctx context.Context, values []db.ImportedValue, dryRun bool) (db.ImportResult, error) {
+			dryRuns = append(dryRuns, dryRun)
+			return db.ImportResult{Imported: len(values)}, nil
+		}
+		file := "day,litres\n2025-03-01,1.5\n"
+		form := map[string]string{
+			"action":      "preview",
+			"gauge":       "Water",
+			"date_format": "date",
+			"col_date":    "Day",
+			"col_amount":  "Litres",
+		}
+
+		t.Run("form", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/import", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `enctype="multipart/form-data"`)
+		})
+
+		t.Run("preview", func(t *testing.T) {
+			dryRuns = nil
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createUploadRequest(t, "/admin/import", form, file))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, []bool{true}, dryRuns)
+			body := w.Body.String()
+			assert.Contains(t, body, "Preview")
+			assert.Contains(t, body, "1.5 l")
+			assert.Contains(t, body, "Import 1 rows")
+			assert.Contains(t, body, `name="data"`)
+		})
+
+		t.Run("confirm", func(t *testing.T) {
+			dryRuns = nil
+			confirm := map[string]string{"action": "import", "data": file}
+			for key, value := range form {
+				if key != "action" {
+					confirm[key] = value
+				}
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createUploadRequest(t, "/admin/import", confirm, ""))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, []bool{false}, dryRuns)
+			assert.Contains(t, w.Body.String(), "1 imported")
+		})
+
+		t.Run("invalid rows", func(t *testing.T) {
+			dryRuns = nil
+			invalid := map[string]string{"action": "import"}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createUploadRequest(t, "/admin/import", invalid, "gauge,date,amount\nSteps,2025-03-01T09:00:00Z,1\n"))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, []bool{true}, dryRuns)
+			assert.Contains(t, w.Body.String(), "Gauge &#34;Steps&#34; does not exist")
+			assert.NotContains(t, w.Body.String(), `value="import"`)
+		})
+
+		t.Run("missing file", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createUploadRequest(t, "/admin/import", map[string]string{"action": "preview"}, ""))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "Choose a CSV file to import")
+		})
+
+		t.Run("unreadable file", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, createUploadRequest(t, "/admin/import", map[string]string{"action": "preview"}, "date,amount\n2025-03-01,1\n"))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "The file could not be read: missing the &#34;gauge&#34; column")
+		})
+	})
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/views/components"
+	"health-monitor/internal/views/pages"
+	"health-monitor/internal/webhook"
+)
+
+// webhookLogLimit is how many deliveries the webhooks page lists
+const webhookLogLimit = 100
+
+// WebhookStore holds the webhook endpoints and their delivery log
+type WebhookStore interface {
+	ListWebhooks(ctx context.Context) ([]db.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (db.Webhook, error)
+	CreateWebhook(ctx context.Context, arg db.CreateWebhookParams) (db.Webhook, error)
+	UpdateWebhook(ctx context.Context, arg db.UpdateWebhookParams) (db.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error)
+}
+
+// WebhookRetrier sends a logged delivery again
+type WebhookRetrier interface {
+	Retry(ctx context.Context, id int64) error
+}
+
+// WebhookHandler serves the admin pages for outbound webhooks
+type WebhookHandler struct {
+	store   WebhookStore
+	retrier WebhookRetrier
+}
+
+func NewWebhookHandler(store WebhookStore, retrier WebhookRetrier) *WebhookHandler {
+	return &WebhookHandler{
+		store:   store,
+		retrier: retrier,
+	}
+}
+
+// RegisterRoutes registers the webhook admin routes on the provided router
+func (h *WebhookHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/webhooks", func(r chi.Router) {
+		r.Get("/", h.handleList)
+		r.Post("/", h.handleCreate)
+		r.Get("/{id}", h.handleEdit)
+		r.Post("/{id}", h.handleUpdate)
+		r.Post("/{id}/delete", h.handleDelete)
+		r.Post("/deliveries/{id}/retry", h.handleRetry)
+	})
+}
+
+// handleList renders the endpoints, a form for a new one and the delivery
+// log, filtered to one endpoint by ?webhook=
+func (h *WebhookHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, pages.WebhookForm{Active: true}, nil)
+}
+
+// handleEdit renders the page with an endpoint in the form
+func (h *WebhookHandler) handleEdit(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	h.render(w, r, pages.WebhookForm{
+		ID:     hook.ID,
+		Name:   hook.Name,
+		URL:    hook.Url,
+		Secret: hook.Secret,
+		Events: webhook.ParseEvents(hook.Events),
+		Active: hook.Active,
+	}, nil)
+}
+
+// handleCreate adds an endpoint, generating its secret if none was given
+func (h *WebhookHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	form, formErrors := readWebhookForm(r)
+	if len(formErrors) > 0 {
+		h.render(w, r, form, formErrors)
+		return
+	}
+	if form.Secret == "" {
+		form.Secret = webhook.NewSecret()
+	}
+
+	_, err := h.store.CreateWebhook(r.Context(), db.CreateWebhookParams{
+		Name:   form.Name,
+		Url:    form.URL,
+		Secret: form.Secret,
+		Events: webhook.JoinEvents(form.Events),
+		Active: form.Active,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// handleUpdate saves an endpoint. A blank secret keeps the current one.
+func (h *WebhookHandler) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	form, formErrors := readWebhookForm(r)
+	form.ID = hook.ID
+	if len(formErrors) > 0 {
+		h.render(w, r, form, formErrors)
+		return
+	}
+	if form.Secret == "" {
+		form.Secret = hook.Secret
+	}
+
+	_, err := h.store.UpdateWebhook(r.Context(), db.UpdateWebhookParams{
+		ID:     hook.ID,
+		Name:   form.Name,
+		Url:    form.URL,
+		Secret: form.Secret,
+		Events: webhook.JoinEvents(form.Events),
+		Active: form.Active,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// handleDelete removes an endpoint along with its delivery log
+func (h *WebhookHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteWebhook(r.Context(), hook.ID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// handleRetry queues a logged delivery to be sent again straight away
+func (h *WebhookHandler) handleRetry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid delivery ID: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = h.retrier.Retry(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retry delivery: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+func (h *WebhookHandler) loadWebhook(w http.ResponseWriter, r *http.Request) (db.Webhook, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid webhook ID: %v", err), http.StatusBadRequest)
+		return db.Webhook{}, false
+	}
+
+	hook, err := h.store.GetWebhook(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return hook, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get webhook: %v", err), http.StatusInternalServerError)
+		return hook, false
+	}
+	return hook, true
+}
+
+// readWebhookForm reads and checks a submitted endpoint
+func readWebhookForm(r *http.Request) (pages.WebhookForm, []components.FormError) {
+	r.ParseForm()
+	form := pages.WebhookForm{
+		Name:   strings.TrimSpace(r.FormValue("name")),
+		URL:    strings.TrimSpace(r.FormValue("url")),
+		Secret: strings.TrimSpace(r.FormValue("secret")),
+		Events: webhook.ParseEvents(strings.Join(r.Form["events"], ",")),
+		Active: r.FormValue("active") == "true",
+	}
+
+	var formErrors []components.FormError
+	if form.Name == "" {
+		formErrors = append(formErrors, components.FormError{Field: "name", Message: "Name is required"})
+	}
+	if u, err := url.Parse(form.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		formErrors = append(formErrors, components.FormError{Field: "url", Message: "URL must be an http or https address"})
+	}
+	if len(form.Events) == 0 {
+		formErrors = append(formErrors, components.FormError{Field: "events", Message: "Choose at least one event"})
+	}
+	return form, formErrors
+}
+
+// render renders the webhooks page. Like other form errors it is sent with
+// 200.
+func (h *WebhookHandler) render(w http.ResponseWriter, r *http.Request, form pages.WebhookForm, formErrors []components.FormError) {
+	hooks, err := h.store.ListWebhooks(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get webhooks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log := pages.WebhookLog{}
+	filter := sql.NullInt64{}
+	if id, err := strconv.ParseInt(r.URL.Query().Get("webhook"), 10, 64); err == nil {
+		log.WebhookID = id
+		filter = sql.NullInt64{Int64: id, Valid: true}
+	}
+	log.Deliveries, err = h.store.ListWebhookDeliveries(r.Context(), db.ListWebhookDeliveriesParams{
+		WebhookID: filter,
+		Limit:     webhookLogLimit,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get deliveries: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = pages.WebhooksPage(hooks, form, log, formErrors).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+	"health-monitor/internal/webhook"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRetrier struct {
+	retried []int64
+}
+
+func (f *fakeRetrier) Retry(ctx context.Context, id int64) error {
+	f.retried = append(f.retried, id)
+	return nil
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	retrier := &fakeRetrier{}
+	router := chi.NewRouter()
+	NewWebhookHandler(store, retrier).RegisterRoutes(router)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("rejects an invalid endpoint", func(t *testing.T) {
+		w := post("/admin/webhooks", url.Values{"name": {"Chat"}, "url": {"ftp://example.com"}})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "URL must be an http or https address")
+		assert.Contains(t, w.Body.String(), "Choose at least one event")
+		hooks, err := store.ListWebhooks(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, hooks)
+	})
+
+	var hook db.Webhook
+	t.Run("creates an endpoint with a generated secret", func(t *testing.T) {
+		w := post("/admin/webhooks", url.Values{
+			"name":   {"Chat"},
+			"url":    {"https://example.com/hook"},
+			"events": {webhook.EventTargetCrossed, webhook.EventPeriodClosed},
+			"active": {"true"},
+		})
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		hooks, err := store.ListWebhooks(ctx)
+		require.NoError(t, err)
+		require.Len(t, hooks, 1)
+		hook = hooks[0]
+		assert.Equal(t, "target.crossed,period.closed", hook.Events)
+		assert.True(t, hook.Active)
+		assert.Len(t, hook.Secret, 48)
+	})
+
+	t.Run("keeps the secret when left blank", func(t *testing.T) {
+		w := post("/admin/webhooks/"+strconv.FormatInt(hook.ID, 10), url.Values{
+			"name":   {"Chat"},
+			"url":    {"https://example.com/other"},
+			"events": {webhook.EventGaugeCreated},
+		})
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		updated, err := store.GetWebhook(ctx, hook.ID)
+		require.NoError(t, err)
+		assert.Equal(t, hook.Secret, updated.Secret)
+		assert.Equal(t, "https://example.com/other", updated.Url)
+		assert.False(t, updated.Active)
+	})
+
+	t.Run("lists deliveries", func(t *testing.T) {
+		delivery, err := store.CreateWebhookDelivery(ctx, db.CreateWebhookDeliveryParams{
+			WebhookID: hook.ID,
+			Event:     webhook.EventGaugeCreated,
+			Payload:   "{}",
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/webhooks?webhook="+strconv.FormatInt(hook.ID, 10), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "https://example.com/other")
+		assert.Contains(t, w.Body.String(), "<code>gauge.created</code>")
+
+		w = post("/admin/webhooks/deliveries/"+strconv.FormatInt(delivery.ID, 10)+"/retry", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, []int64{delivery.ID}, retrier.retried)
+	})
+
+	t.Run("deletes an endpoint", func(t *testing.T) {
+		w := post("/admin/webhooks/"+strconv.FormatInt(hook.ID, 10)+"/delete", nil)
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		hooks, err := store.ListWebhooks(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, hooks)
+
+		w = post("/admin/webhooks/"+strconv.FormatInt(hook.ID, 10)+"/delete", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
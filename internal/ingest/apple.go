@@ -0,0 +1,119 @@
+package ingest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"health-monitor/internal/models"
+)
+
+// SourceAppleHealth marks gauge values imported from Apple Health
+const SourceAppleHealth = "apple_health"
+
+// AppleTypePrefix starts the names of Apple Health's quantity record types
+const AppleTypePrefix = "HKQuantityTypeIdentifier"
+
+// appleDateLayout is how dates are written in export.xml
+const appleDateLayout = "2006-01-02 15:04:05 -0700"
+
+// AppleHealthMappings are the record types imported from Apple Health
+// unless others are given
+var AppleHealthMappings = []Mapping{
+	{Type: AppleTypePrefix + "StepCount", Gauge: "Steps", Unit: "steps", Aggregation: AggregateSum, Icon: "bolt", Target: 10000, GoalType: models.GoalAtLeast},
+	{Type: AppleTypePrefix + "DistanceWalkingRunning", Gauge: "Distance", Unit: "km", Aggregation: AggregateSum, Icon: "bolt", Target: 5, GoalType: models.GoalAtLeast},
+	{Type: AppleTypePrefix + "FlightsClimbed", Gauge: "Flights Climbed", Unit: "flights", Aggregation: AggregateSum, Icon: "star", Target: 10, GoalType: models.GoalAtLeast},
+	{Type: AppleTypePrefix + "ActiveEnergyBurned", Gauge: "Active Energy", Unit: "kcal", Aggregation: AggregateSum, Icon: "fire", Target: 500, GoalType: models.GoalAtLeast},
+	{Type: AppleTypePrefix + "DietaryWater", Gauge: "Water", Unit: "l", Aggregation: AggregateSum, Icon: "water", Target: 2, GoalType: models.GoalAtLeast},
+	{Type: AppleTypePrefix + "RestingHeartRate", Gauge: "Resting Heart Rate", Unit: "bpm", Aggregation: AggregateAverage, Icon: "heart", Target: 60, GoalType: models.GoalAtMost},
+	{Type: AppleTypePrefix + "BodyMass", Gauge: "Weight", Unit: "kg", Aggregation: AggregateLast, Icon: "chart-bar", Target: 75, GoalType: models.GoalAtMost},
+}
+
+// AppleHealth reads the export.xml the iPhone Health app exports, on its
+// own or in the export.zip it shares. Only the attributes of each Record
+// element are read, so exports of any size are imported in constant memory
+// apart from one value per gauge period. Records are dated by their
+// startDate.
+type AppleHealth struct{}
+
+func (AppleHealth) Name() string        { return "apple" }
+func (AppleHealth) ValueSource() string { return SourceAppleHealth }
+func (AppleHealth) TypePrefix() string  { return AppleTypePrefix }
+func (AppleHealth) Mappings() []Mapping { return AppleHealthMappings }
+
+// Accepts picks export.xml out of the archive, leaving export_cda.xml and
+// the workout routes
+func (AppleHealth) Accepts(name string) bool {
+	return path.Base(filepath.ToSlash(name)) == "export.xml"
+}
+
+func (AppleHealth) Read(name string, r io.Reader, sink Sink) error {
+	d := xml.NewDecoder(r)
+	root := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !root {
+			if el.Name.Local != "HealthData" {
+				return fmt.Errorf("%w: not an Apple Health export.xml", ErrInvalidExport)
+			}
+			root = true
+			continue
+		}
+		if el.Name.Local != "Record" {
+			continue
+		}
+
+		if typ := attr(el, "type"); !sink.Wants(typ) {
+			sink.Skip()
+		} else if record, err := parseAppleRecord(el); err != nil {
+			sink.Invalid()
+		} else {
+			sink.Add(record)
+		}
+	}
+	if !root {
+		return fmt.Errorf("%w: the file is empty", ErrInvalidExport)
+	}
+	return nil
+}
+
+func parseAppleRecord(el xml.StartElement) (Record, error) {
+	value, err := strconv.ParseFloat(attr(el, "value"), 64)
+	if err != nil {
+		return Record{}, err
+	}
+	date, err := time.Parse(appleDateLayout, attr(el, "startDate"))
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{
+		Type:   attr(el, "type"),
+		Value:  value,
+		Unit:   attr(el, "unit"),
+		Date:   date,
+		Source: attr(el, "sourceName"),
+	}, nil
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
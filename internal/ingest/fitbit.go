@@ -0,0 +1,187 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"health-monitor/internal/models"
+)
+
+// SourceFitbit marks gauge values imported from Fitbit
+const SourceFitbit = "fitbit"
+
+// fitbitDateLayout is how the dateTime of a Fitbit record is written
+const fitbitDateLayout = "01/02/06 15:04:05"
+
+// fitbitUnits are the units of the record types Fitbit exports, apart from
+// weight, which is in the account's unit
+var fitbitUnits = map[string]string{
+	"steps":              "count",
+	"distance":           "cm",
+	"calories":           "kcal",
+	"heart_rate":         "bpm",
+	"resting_heart_rate": "bpm",
+	"weight":             "",
+	"sleep":              "min",
+}
+
+// FitbitMappings are the record types imported from Fitbit unless others
+// are given. Heart rate is read every few seconds, so only the daily resting
+// rate is imported by default; map heart_rate to import the readings.
+var FitbitMappings = []Mapping{
+	{Type: "steps", Gauge: "Steps", Unit: "steps", Aggregation: AggregateSum, Icon: "bolt", Target: 10000, GoalType: models.GoalAtLeast},
+	{Type: "distance", Gauge: "Distance", Unit: "km", Aggregation: AggregateSum, Icon: "bolt", Target: 5, GoalType: models.GoalAtLeast},
+	{Type: "calories", Gauge: "Calories", Unit: "kcal", Aggregation: AggregateSum, Icon: "fire", Target: 2000, GoalType: models.GoalAtLeast},
+	{Type: "resting_heart_rate", Gauge: "Resting Heart Rate", Unit: "bpm", Aggregation: AggregateAverage, Icon: "heart", Target: 60, GoalType: models.GoalAtMost},
+	{Type: "weight", Gauge: "Weight", Unit: "kg", Aggregation: AggregateLast, Icon: "chart-bar", Target: 75, GoalType: models.GoalAtMost},
+	{Type: "sleep", Gauge: "Sleep", Unit: "h", Aggregation: AggregateSum, Icon: "star", Target: 8, GoalType: models.GoalAtLeast},
+}
+
+// Fitbit reads the JSON files of a Fitbit account export, one per type and
+// month or day, named like steps-2025-01-10.json. The type is the file name
+// without the date. Files of types that are not imported are not read.
+// Fitbit writes dates without a time zone, so they are read in the
+// import's.
+type Fitbit struct {
+	// WeightUnit is the unit the account logs weight in, kg unless set
+	WeightUnit string
+}
+
+func (Fitbit) Name() string        { return "fitbit" }
+func (Fitbit) ValueSource() string { return SourceFitbit }
+func (Fitbit) TypePrefix() string  { return "" }
+func (Fitbit) Mappings() []Mapping { return FitbitMappings }
+
+func (Fitbit) Accepts(name string) bool {
+	_, ok := fitbitUnits[fitbitType(name)]
+	return ok && path.Ext(filepath.ToSlash(name)) == ".json"
+}
+
+// fitbitType is the record type of an export file, its name without the
+// directory, date and extension
+func fitbitType(name string) string {
+	base := strings.TrimSuffix(path.Base(filepath.ToSlash(name)), ".json")
+	if i := len(base) - len("-2006-01-02"); i > 0 && base[i] == '-' {
+		if _, err := time.Parse("2006-01-02", base[i+1:]); err == nil {
+			return base[:i]
+		}
+	}
+	if i := len(base) - len("-2006-01"); i > 0 && base[i] == '-' {
+		if _, err := time.Parse("2006-01", base[i+1:]); err == nil {
+			return base[:i]
+		}
+	}
+	return base
+}
+
+// fitbitEntry holds the fields of every record type; each fills in some
+type fitbitEntry struct {
+	DateTime      string          `json:"dateTime"`
+	Value         json.RawMessage `json:"value"`
+	Weight        *float64        `json:"weight"`
+	Date          string          `json:"date"`
+	Time          string          `json:"time"`
+	Source        string          `json:"source"`
+	DateOfSleep   string          `json:"dateOfSleep"`
+	MinutesAsleep *float64        `json:"minutesAsleep"`
+}
+
+func (f Fitbit) Read(name string, r io.Reader, sink Sink) error {
+	typ := fitbitType(name)
+	if _, ok := fitbitUnits[typ]; !ok {
+		return fmt.Errorf("%w: %s is not a Fitbit export file", ErrInvalidExport, path.Base(filepath.ToSlash(name)))
+	}
+	if !sink.Wants(typ) {
+		return nil
+	}
+
+	d := json.NewDecoder(r)
+	if tok, err := d.Token(); err != nil || tok != json.Delim('[') {
+		return fmt.Errorf("%w: not a Fitbit export file", ErrInvalidExport)
+	}
+	for d.More() {
+		var e fitbitEntry
+		if err := d.Decode(&e); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		if record, err := f.record(typ, e, sink.Location()); err != nil {
+			sink.Invalid()
+		} else {
+			sink.Add(record)
+		}
+	}
+	if _, err := d.Token(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	return nil
+}
+
+func (f Fitbit) record(typ string, e fitbitEntry, loc *time.Location) (Record, error) {
+	record := Record{Type: typ, Unit: fitbitUnits[typ]}
+	var err error
+	switch typ {
+	case "weight":
+		if e.Weight == nil {
+			return Record{}, fmt.Errorf("weight has no value")
+		}
+		record.Value, record.Unit, record.Source = *e.Weight, f.WeightUnit, e.Source
+		if record.Unit == "" {
+			record.Unit = "kg"
+		}
+		record.Date, err = time.ParseInLocation(fitbitDateLayout, e.Date+" "+e.Time, loc)
+	case "sleep":
+		if e.MinutesAsleep == nil {
+			return Record{}, fmt.Errorf("sleep has no minutes asleep")
+		}
+		// A night's sleep counts towards the day it ends on
+		record.Value = *e.MinutesAsleep
+		record.Date, err = time.ParseInLocation("2006-01-02", e.DateOfSleep, loc)
+		record.Date = record.Date.Add(12 * time.Hour)
+	default:
+		if record.Value, err = fitbitValue(typ, e.Value); err != nil {
+			return Record{}, err
+		}
+		record.Date, err = time.ParseInLocation(fitbitDateLayout, e.DateTime, loc)
+	}
+	return record, err
+}
+
+// fitbitValue reads a record's value, which is a number written as a
+// string for most types and an object for heart rates
+func fitbitValue(typ string, raw json.RawMessage) (float64, error) {
+	switch typ {
+	case "heart_rate":
+		var v struct {
+			BPM *float64 `json:"bpm"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil || v.BPM == nil {
+			return 0, fmt.Errorf("heart rate has no bpm")
+		}
+		return *v.BPM, nil
+	case "resting_heart_rate":
+		var v struct {
+			Value *float64 `json:"value"`
+		}
+		// Days without a reading have a value of 0
+		if err := json.Unmarshal(raw, &v); err != nil || v.Value == nil || *v.Value == 0 {
+			return 0, fmt.Errorf("resting heart rate has no value")
+		}
+		return *v.Value, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,232 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"health-monitor/internal/models"
+)
+
+// SourceGoogleFit marks gauge values imported from Google Fit
+const SourceGoogleFit = "google_fit"
+
+// googleFitDaily is the daily aggregation CSV in a Takeout Fit export
+const googleFitDaily = "Daily activity metrics.csv"
+
+// googleFitUnits are the units of the data types in the "All data" JSON
+// files, which do not say
+var googleFitUnits = map[string]string{
+	"com.google.step_count.delta":  "count",
+	"com.google.distance.delta":    "m",
+	"com.google.calories.expended": "kcal",
+	"com.google.heart_rate.bpm":    "bpm",
+	"com.google.weight":            "kg",
+	"com.google.active_minutes":    "count",
+}
+
+// GoogleFitMappings are the record types imported from Google Fit unless
+// others are given. Each gauge is fed from both the daily CSV and the raw
+// data points, whichever the export holds; when it holds both, the larger
+// total of a day is used.
+var GoogleFitMappings = []Mapping{
+	{Type: "Step count", Gauge: "Steps", Unit: "steps", Aggregation: AggregateSum, Icon: "bolt", Target: 10000, GoalType: models.GoalAtLeast},
+	{Type: "com.google.step_count.delta", Gauge: "Steps", Unit: "steps", Aggregation: AggregateSum, Icon: "bolt", Target: 10000, GoalType: models.GoalAtLeast},
+	{Type: "Distance", Gauge: "Distance", Unit: "km", Aggregation: AggregateSum, Icon: "bolt", Target: 5, GoalType: models.GoalAtLeast},
+	{Type: "com.google.distance.delta", Gauge: "Distance", Unit: "km", Aggregation: AggregateSum, Icon: "bolt", Target: 5, GoalType: models.GoalAtLeast},
+	{Type: "Calories", Gauge: "Calories", Unit: "kcal", Aggregation: AggregateSum, Icon: "fire", Target: 2000, GoalType: models.GoalAtLeast},
+	{Type: "com.google.calories.expended", Gauge: "Calories", Unit: "kcal", Aggregation: AggregateSum, Icon: "fire", Target: 2000, GoalType: models.GoalAtLeast},
+	{Type: "Move Minutes count", Gauge: "Move Minutes", Unit: "min", Aggregation: AggregateSum, Icon: "star", Target: 30, GoalType: models.GoalAtLeast},
+	{Type: "com.google.active_minutes", Gauge: "Move Minutes", Unit: "min", Aggregation: AggregateSum, Icon: "star", Target: 30, GoalType: models.GoalAtLeast},
+	{Type: "Average heart rate", Gauge: "Heart Rate", Unit: "bpm", Aggregation: AggregateAverage, Icon: "heart", Target: 80, GoalType: models.GoalAtMost},
+	{Type: "com.google.heart_rate.bpm", Gauge: "Heart Rate", Unit: "bpm", Aggregation: AggregateAverage, Icon: "heart", Target: 80, GoalType: models.GoalAtMost},
+	{Type: "Average weight", Gauge: "Weight", Unit: "kg", Aggregation: AggregateLast, Icon: "chart-bar", Target: 75, GoalType: models.GoalAtMost},
+	{Type: "com.google.weight", Gauge: "Weight", Unit: "kg", Aggregation: AggregateLast, Icon: "chart-bar", Target: 75, GoalType: models.GoalAtMost},
+}
+
+// GoogleFit reads the Fit folder of a Google Takeout export: the daily
+// aggregation CSV, "Daily activity metrics.csv", and the JSON files under
+// "All data". CSV columns are record types named by their header without
+// the unit, such as "Step count" or "Distance"; JSON data points are typed
+// by their dataTypeName, such as com.google.step_count.delta. The CSV's
+// dates have no time zone and are read in the import's.
+type GoogleFit struct{}
+
+func (GoogleFit) Name() string        { return "googlefit" }
+func (GoogleFit) ValueSource() string { return SourceGoogleFit }
+func (GoogleFit) TypePrefix() string  { return "" }
+func (GoogleFit) Mappings() []Mapping { return GoogleFitMappings }
+
+func (GoogleFit) Accepts(name string) bool {
+	name = filepath.ToSlash(name)
+	if path.Base(name) == googleFitDaily {
+		return true
+	}
+	return path.Base(path.Dir(name)) == "All data" && path.Ext(name) == ".json"
+}
+
+// Read tells the JSON files from the CSV by their first character, so a
+// file given on its own may have any name
+func (GoogleFit) Read(name string, r io.Reader, sink Sink) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("%w: the file is empty", ErrInvalidExport)
+		}
+		if err != nil {
+			return err
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		br.UnreadByte()
+		if b == '{' {
+			return readGoogleFitJSON(path.Base(filepath.ToSlash(name)), br, sink)
+		}
+		return readGoogleFitCSV(br, sink)
+	}
+}
+
+// readGoogleFitCSV reads one record per filled in cell of the daily CSV,
+// dated at noon so it lands on its day whatever the gauge's time zone
+func readGoogleFitCSV(r io.Reader, sink Sink) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	if strings.TrimSpace(header[0]) != "Date" {
+		return fmt.Errorf("%w: not a Google Fit daily activity CSV", ErrInvalidExport)
+	}
+	types := make([]string, len(header))
+	units := make([]string, len(header))
+	for i, h := range header {
+		types[i], units[i] = googleFitColumn(h)
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		day, dateErr := time.ParseInLocation("2006-01-02", strings.TrimSpace(row[0]), sink.Location())
+		for i := 1; i < len(row) && i < len(header); i++ {
+			cell := strings.TrimSpace(row[i])
+			if cell == "" {
+				continue
+			}
+			if !sink.Wants(types[i]) {
+				sink.Skip()
+				continue
+			}
+			value, err := strconv.ParseFloat(cell, 64)
+			if err != nil || dateErr != nil {
+				sink.Invalid()
+				continue
+			}
+			sink.Add(Record{Type: types[i], Value: value, Unit: units[i], Date: day.Add(12 * time.Hour), Source: googleFitDaily})
+		}
+	}
+}
+
+// googleFitColumn splits a CSV header such as "Distance (m)" into its type
+// and unit. Headers without a unit, such as "Step count", are counts.
+func googleFitColumn(header string) (typ, unit string) {
+	header = strings.TrimSpace(header)
+	if open := strings.LastIndex(header, " ("); open > 0 && strings.HasSuffix(header, ")") {
+		return header[:open], header[open+2 : len(header)-1]
+	}
+	return header, "count"
+}
+
+type googleFitPoint struct {
+	DataTypeName   string      `json:"dataTypeName"`
+	StartTimeNanos json.Number `json:"startTimeNanos"`
+	FitValue       []struct {
+		Value struct {
+			IntVal *int64   `json:"intVal"`
+			FpVal  *float64 `json:"fpVal"`
+		} `json:"value"`
+	} `json:"fitValue"`
+}
+
+// readGoogleFitJSON streams the "Data Points" of an "All data" file, one at
+// a time. Each file holds one data source, so the file name tells apart the
+// devices that recorded the same type.
+func readGoogleFitJSON(name string, r io.Reader, sink Sink) error {
+	d := json.NewDecoder(r)
+	if tok, err := d.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("%w: not a Google Fit data file", ErrInvalidExport)
+	}
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		if tok != "Data Points" {
+			var skip json.RawMessage
+			if err := d.Decode(&skip); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+			}
+			continue
+		}
+
+		if tok, err := d.Token(); err != nil || tok != json.Delim('[') {
+			return fmt.Errorf("%w: Data Points is not a list", ErrInvalidExport)
+		}
+		for d.More() {
+			var p googleFitPoint
+			if err := d.Decode(&p); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+			}
+			if !sink.Wants(p.DataTypeName) {
+				sink.Skip()
+			} else if record, err := p.record(name); err != nil {
+				sink.Invalid()
+			} else {
+				sink.Add(record)
+			}
+		}
+		if _, err := d.Token(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+	}
+	return nil
+}
+
+func (p googleFitPoint) record(source string) (Record, error) {
+	nanos, err := p.StartTimeNanos.Int64()
+	if err != nil {
+		return Record{}, err
+	}
+	if len(p.FitValue) == 0 {
+		return Record{}, fmt.Errorf("data point has no value")
+	}
+	var value float64
+	switch v := p.FitValue[0].Value; {
+	case v.FpVal != nil:
+		value = *v.FpVal
+	case v.IntVal != nil:
+		value = float64(*v.IntVal)
+	default:
+		return Record{}, fmt.Errorf("data point has no number")
+	}
+	unit, ok := googleFitUnits[p.DataTypeName]
+	if !ok {
+		unit = "count"
+	}
+	return Record{Type: p.DataTypeName, Value: value, Unit: unit, Date: time.Unix(0, nanos), Source: source}, nil
+}
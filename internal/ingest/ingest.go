@@ -0,0 +1,705 @@
+// Package ingest imports measurements exported by health apps into gauges.
+//
+// Records are read as a stream, converted to the unit of the gauge they map
+// to and combined into one value per gauge period, which is then logged
+// through the same path as a CSV import. Each value is dated at the start of
+// its period, so importing an export again skips the periods already
+// imported rather than counting them twice.
+package ingest
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+	"health-monitor/internal/period"
+	"health-monitor/internal/transfer"
+)
+
+// DefaultBatchSize is how many period values are written per transaction
+const DefaultBatchSize = 500
+
+// progressEvery is how many records are read between progress reports
+const progressEvery = 10000
+
+// ErrInvalidExport is returned when a file is not a health export
+var ErrInvalidExport = errors.New("invalid export")
+
+// Store is the storage an import reads gauges from and writes values to.
+// cmd/ingest passes an access.Store, so only the importing user's gauges
+// are matched and the gauges it creates belong to them.
+type Store interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	CreateGauge(ctx context.Context, params db.CreateGaugeParams) (db.Gauge, error)
+	ImportGaugeValues(ctx context.Context, values []db.ImportedValue, dryRun bool) (db.ImportResult, error)
+}
+
+// Aggregation says how the records in one period combine into its value
+type Aggregation string
+
+const (
+	// AggregateSum adds the records up, for counts and totals such as
+	// steps. When several devices recorded the same period, the one with
+	// the largest total is used rather than adding them together.
+	AggregateSum Aggregation = "sum"
+	// AggregateAverage takes the mean of the records, for rates such as
+	// heart rate
+	AggregateAverage Aggregation = "average"
+	// AggregateMax takes the largest record
+	AggregateMax Aggregation = "max"
+	// AggregateLast takes the latest record, for readings such as weight
+	AggregateLast Aggregation = "last"
+)
+
+// Aggregations lists the supported aggregations
+var Aggregations = []Aggregation{AggregateSum, AggregateAverage, AggregateMax, AggregateLast}
+
+// Mapping sends the records of one type to a gauge
+type Mapping struct {
+	// Type is the record type in the export, such as
+	// HKQuantityTypeIdentifierStepCount
+	Type string
+	// Gauge is the name of the gauge the records go to, matched ignoring
+	// case
+	Gauge string
+	// Unit is the unit of a gauge created for the mapping. Records are
+	// converted to the unit of the gauge they go to.
+	Unit        string
+	Aggregation Aggregation
+	// Icon, Target and GoalType describe a gauge created for the mapping.
+	// Created gauges reset daily.
+	Icon     string
+	Target   float64
+	GoalType models.GoalType
+}
+
+// Record is one measurement read from an export
+type Record struct {
+	Type   string
+	Value  float64
+	Unit   string
+	Date   time.Time
+	Source string
+}
+
+// Progress reports how far an import has got
+type Progress struct {
+	// Bytes is how much of the export has been read
+	Bytes int64
+	// Records counts the records read, and Matched those with a mapping
+	Records int
+	Matched int
+	// Periods is how many period values there are to write once reading
+	// is done, and Written how many have been written so far
+	Periods int
+	Written int
+}
+
+// Options configures an import
+type Options struct {
+	Mappings []Mapping
+	// Create adds a gauge for each mapping whose gauge does not exist.
+	// Otherwise the mapping's records are skipped.
+	Create bool
+	// Timezone is the time zone of created gauges, and of dates an export
+	// writes without one. It defaults to UTC.
+	Timezone string
+	// Since skips records dated before it, when it is set
+	Since time.Time
+	// BatchSize is how many period values are written per transaction
+	BatchSize int
+	DryRun    bool
+	// Progress, when set, is called as records are read and values written
+	Progress func(Progress)
+}
+
+// Result reports what an import did, or would have done in a dry run
+type Result struct {
+	Records int `json:"records"`
+	Matched int `json:"matched"`
+	// Invalid counts matched records whose value or unit could not be used
+	Invalid int `json:"invalid"`
+	// Imported counts the period values logged, and Duplicates the periods
+	// already imported before. Updated counts the periods among those
+	// imported that had been imported before and have changed since, such
+	// as one still in progress last time.
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+	Updated    int `json:"updated"`
+	// Created names the gauges created for mappings
+	Created []string `json:"created,omitempty"`
+	// Missing names the gauges whose records were skipped because the
+	// gauge does not exist
+	Missing []string `json:"missing,omitempty"`
+}
+
+// target is a gauge that records go to, with the period its values are
+// combined over
+type target struct {
+	mapping Mapping
+	gauge   db.Gauge
+	period  period.Period
+	// pending marks a gauge a dry run would have created
+	pending bool
+}
+
+// bucket combines the records of one gauge period
+type bucket struct {
+	target *target
+	start  time.Time
+	// sums holds each source's total, for AggregateSum
+	sums     map[string]float64
+	total    float64
+	count    int
+	max      float64
+	last     float64
+	lastDate time.Time
+}
+
+func (b *bucket) add(r Record, value float64) {
+	if b.sums == nil {
+		b.sums = make(map[string]float64)
+	}
+	b.sums[r.Source] += value
+	b.total += value
+	if b.count == 0 || value > b.max {
+		b.max = value
+	}
+	if b.count == 0 || !r.Date.Before(b.lastDate) {
+		b.last, b.lastDate = value, r.Date
+	}
+	b.count++
+}
+
+func (b *bucket) value() float64 {
+	switch b.target.mapping.Aggregation {
+	case AggregateAverage:
+		return b.total / float64(b.count)
+	case AggregateMax:
+		return b.max
+	case AggregateLast:
+		return b.last
+	}
+	var largest float64
+	for _, sum := range b.sums {
+		if sum > largest {
+			largest = sum
+		}
+	}
+	return largest
+}
+
+// mode says how the period's value is imported: totals add to the period,
+// while the other aggregations are readings the period is set to
+func (b *bucket) mode() db.ImportMode {
+	if b.target.mapping.Aggregation == AggregateSum {
+		return db.ImportTotal
+	}
+	return db.ImportReading
+}
+
+// Source reads the exports of one health app
+type Source interface {
+	// Name identifies the source on the command line
+	Name() string
+	// ValueSource is recorded as the source of the values it logs
+	ValueSource() string
+	// TypePrefix is left off record types on the command line, if the
+	// source's types share one
+	TypePrefix() string
+	// Mappings are the record types imported unless others are given
+	Mappings() []Mapping
+	// Accepts reports whether a file in an export archive or directory
+	// holds records the source reads
+	Accepts(name string) bool
+	// Read streams the records of one file to the sink
+	Read(name string, r io.Reader, sink Sink) error
+}
+
+// Sink receives the records a Source reads
+type Sink interface {
+	// Wants reports whether records of a type are imported, so a source
+	// need not parse the others
+	Wants(typ string) bool
+	// Add counts a record into its gauge period
+	Add(r Record)
+	// Skip counts a record whose type is not imported
+	Skip()
+	// Invalid counts a record whose type is imported but which could not
+	// be read
+	Invalid()
+	// Location is the time zone of dates an export writes without one
+	Location() *time.Location
+}
+
+// Sources lists the health apps exports can be imported from
+var Sources = []Source{AppleHealth{}, GoogleFit{}, Fitbit{}}
+
+// SourceByName returns the source with the given name
+func SourceByName(name string) (Source, bool) {
+	for _, src := range Sources {
+		if src.Name() == name {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+// File is one file of an export
+type File struct {
+	Name string
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// Import reads every file of an export from src and logs one value per
+// gauge period. A file that cannot be read stops the import before anything
+// is written.
+func Import(ctx context.Context, store Store, src Source, files []File, opts Options) (Result, error) {
+	imp, err := newImporter(ctx, store, opts, src.ValueSource())
+	if err != nil {
+		return Result{}, err
+	}
+
+	var read int64
+	for _, file := range files {
+		rc, err := file.Open()
+		if err != nil {
+			return imp.result, err
+		}
+		r := &countingReader{ctx: ctx, r: rc, imp: imp, offset: read}
+		err = src.Read(file.Name, r, imp)
+		rc.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return imp.result, ctxErr
+		}
+		if err != nil {
+			return imp.result, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		read = r.offset
+		imp.progress.Bytes = read
+	}
+	return imp.write(ctx)
+}
+
+// countingReader tracks how much of an export has been read for progress
+// reports, and stops reading once the import is cancelled
+type countingReader struct {
+	ctx    context.Context
+	r      io.Reader
+	imp    *importer
+	offset int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	c.imp.progress.Bytes = c.offset
+	return n, err
+}
+
+// importer collects records into periods and writes them out. It is the
+// Sink sources read into.
+type importer struct {
+	store    Store
+	opts     Options
+	source   string
+	location *time.Location
+	targets  map[string]*target
+	buckets  map[bucketKey]*bucket
+	progress Progress
+	result   Result
+}
+
+// bucketKey is a gauge period. Mappings that send several record types to
+// one gauge, such as a daily total and the raw readings it was made from,
+// share its periods, so the source with the largest total wins rather than
+// both being counted.
+type bucketKey struct {
+	gauge string
+	start int64
+}
+
+// newImporter resolves each mapping's gauge, creating those that are missing
+// when the options ask for it
+func newImporter(ctx context.Context, store Store, opts Options, source string) (*importer, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Timezone == "" {
+		opts.Timezone = "UTC"
+	}
+	location, err := time.LoadLocation(opts.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", opts.Timezone)
+	}
+	imp := &importer{
+		store:    store,
+		opts:     opts,
+		source:   source,
+		location: location,
+		targets:  make(map[string]*target),
+		buckets:  make(map[bucketKey]*bucket),
+	}
+
+	gauges, err := store.ListGauges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]db.Gauge, len(gauges))
+	for _, gauge := range gauges {
+		byName[strings.ToLower(gauge.Name)] = gauge
+	}
+
+	// pending holds the gauges a dry run would have created
+	pending := make(map[string]bool)
+	for _, m := range opts.Mappings {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(m.Gauge)
+		gauge, ok := byName[name]
+		t := &target{mapping: m, gauge: gauge, pending: pending[name]}
+		if !ok {
+			if !opts.Create {
+				if !slices.Contains(imp.result.Missing, m.Gauge) {
+					imp.result.Missing = append(imp.result.Missing, m.Gauge)
+				}
+				continue
+			}
+			params, err := m.createParams(opts.Timezone)
+			if err != nil {
+				return nil, err
+			}
+			if opts.DryRun {
+				t.gauge = db.Gauge{Name: params.Name, Unit: params.Unit, Period: params.Period, PeriodDays: params.PeriodDays, WeekStart: params.WeekStart, Timezone: params.Timezone}
+				t.pending = true
+				pending[name] = true
+			} else if t.gauge, err = store.CreateGauge(ctx, params); err != nil {
+				return nil, fmt.Errorf("creating gauge %q: %w", m.Gauge, err)
+			}
+			byName[name] = t.gauge
+			imp.result.Created = append(imp.result.Created, t.gauge.Name)
+		}
+		t.period = period.New(t.gauge.Period, t.gauge.PeriodDays, t.gauge.WeekStart, t.gauge.Timezone)
+		imp.targets[m.Type] = t
+	}
+	return imp, nil
+}
+
+func (imp *importer) Wants(typ string) bool {
+	_, ok := imp.targets[typ]
+	return ok
+}
+
+func (imp *importer) Location() *time.Location {
+	return imp.location
+}
+
+func (imp *importer) Skip() {
+	imp.result.Records++
+	imp.progress.Records++
+	if imp.progress.Records%progressEvery == 0 {
+		imp.report()
+	}
+}
+
+func (imp *importer) Invalid() {
+	imp.result.Matched++
+	imp.progress.Matched++
+	imp.result.Invalid++
+	imp.Skip()
+}
+
+func (imp *importer) Add(r Record) {
+	t, ok := imp.targets[r.Type]
+	if !ok || (!imp.opts.Since.IsZero() && r.Date.Before(imp.opts.Since)) {
+		imp.Skip()
+		return
+	}
+
+	value, err := convert(r.Value, r.Unit, t.gauge.Unit)
+	if err != nil {
+		imp.Invalid()
+		return
+	}
+
+	start, _ := t.period.Bounds(r.Date)
+	key := bucketKey{gauge: strings.ToLower(t.gauge.Name), start: start.Unix()}
+	b, ok := imp.buckets[key]
+	if !ok {
+		b = &bucket{target: t, start: start}
+		imp.buckets[key] = b
+	}
+	b.add(r, value)
+
+	imp.result.Matched++
+	imp.progress.Matched++
+	imp.Skip()
+}
+
+// report calls the progress callback, if there is one
+func (imp *importer) report() {
+	if imp.opts.Progress != nil {
+		imp.opts.Progress(imp.progress)
+	}
+}
+
+// write logs one value per gauge period, oldest first, in batches
+func (imp *importer) write(ctx context.Context) (Result, error) {
+	buckets := make([]*bucket, 0, len(imp.buckets))
+	for _, b := range imp.buckets {
+		buckets = append(buckets, b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if !buckets[i].start.Equal(buckets[j].start) {
+			return buckets[i].start.Before(buckets[j].start)
+		}
+		return buckets[i].target.gauge.Name < buckets[j].target.gauge.Name
+	})
+	imp.progress.Periods = len(buckets)
+	imp.report()
+
+	batch := make([]db.ImportedValue, 0, imp.opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := imp.store.ImportGaugeValues(ctx, batch, imp.opts.DryRun)
+		if err != nil {
+			return err
+		}
+		imp.result.Imported += result.Imported
+		imp.result.Duplicates += len(result.Duplicates)
+		imp.result.Updated += len(result.Updated)
+		imp.progress.Written += len(batch)
+		imp.report()
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, b := range buckets {
+		// A gauge a dry run would create has no values to clash with
+		if b.target.pending {
+			imp.result.Imported++
+			imp.progress.Written++
+			continue
+		}
+		batch = append(batch, db.ImportedValue{
+			GaugeID: b.target.gauge.ID,
+			Delta:   round(b.value(), b.target.gauge.Decimals),
+			Date:    b.start.UTC(),
+			Source:  imp.source,
+			Mode:    b.mode(),
+		})
+		if len(batch) == imp.opts.BatchSize {
+			if err := flush(); err != nil {
+				return imp.result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return imp.result, err
+	}
+	return imp.result, nil
+}
+
+// Validate checks a mapping names a type, a gauge and a known aggregation
+func (m Mapping) Validate() error {
+	if m.Type == "" || strings.TrimSpace(m.Gauge) == "" {
+		return fmt.Errorf("mapping %q needs a record type and a gauge", m.Type)
+	}
+	for _, a := range Aggregations {
+		if m.Aggregation == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("mapping %s: unknown aggregation %q", m.Type, m.Aggregation)
+}
+
+// createParams describes the daily gauge created for a mapping
+func (m Mapping) createParams(timezone string) (db.CreateGaugeParams, error) {
+	input := models.DefaultGaugeInput()
+	input.Name = m.Gauge
+	input.Unit = m.Unit
+	input.Icon = m.Icon
+	if input.Icon == "" {
+		input.Icon = transfer.DefaultIcon
+	}
+	input.Target = m.Target
+	if m.GoalType != "" {
+		input.GoalType = string(m.GoalType)
+	}
+	input.Period = string(period.Day)
+	input.PeriodDays = 1
+	input.Timezone = timezone
+	if errs := input.Validate(); len(errs) > 0 {
+		return db.CreateGaugeParams{}, fmt.Errorf("creating gauge %q: %s", m.Gauge, errs[0].Message)
+	}
+	return input.CreateParams(), nil
+}
+
+// round rounds a period's value to the decimal places the gauge allows
+func round(value float64, decimals int64) float64 {
+	scale := math.Pow10(int(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// convert converts a record's value to the gauge's unit. Counts, such as
+// steps or beats per minute, have no unit to convert and are used as they
+// are.
+func convert(value float64, from, to string) (float64, error) {
+	converted, err := transfer.ConvertUnit(value, from, to)
+	if err != nil && strings.HasPrefix(strings.ToLower(from), "count") {
+		return value, nil
+	}
+	return converted, err
+}
+
+// ParseMapping parses a mapping written as type=gauge[:unit[:aggregation]].
+// A type without the prefix has it added. A type among known keeps its
+// settings, with the gauge and any unit or aggregation given replacing
+// them. Any other type needs a unit, and its records are summed unless
+// another aggregation is given.
+func ParseMapping(spec, prefix string, known []Mapping) (Mapping, error) {
+	typ, rest, ok := strings.Cut(spec, "=")
+	typ = strings.TrimSpace(typ)
+	if !ok || typ == "" {
+		return Mapping{}, fmt.Errorf("mapping %q: want type=gauge[:unit[:aggregation]]", spec)
+	}
+	if !strings.HasPrefix(typ, prefix) {
+		typ = prefix + typ
+	}
+
+	m := Mapping{Type: typ, Aggregation: AggregateSum}
+	for _, k := range known {
+		if k.Type == typ {
+			m = k
+			break
+		}
+	}
+	parts := strings.Split(rest, ":")
+	m.Gauge = strings.TrimSpace(parts[0])
+	if len(parts) > 1 && parts[1] != "" {
+		m.Unit = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		m.Aggregation = Aggregation(strings.TrimSpace(parts[2]))
+	}
+	if len(parts) > 3 {
+		return Mapping{}, fmt.Errorf("mapping %q: want type=gauge[:unit[:aggregation]]", spec)
+	}
+	if m.Unit == "" {
+		return Mapping{}, fmt.Errorf("mapping %q: %s needs a unit", spec, typ)
+	}
+	return m, m.Validate()
+}
+
+// SelectMappings returns the mappings for the named types, given with or
+// without the prefix
+func SelectMappings(names []string, prefix string, known []Mapping) ([]Mapping, error) {
+	var mappings []Mapping
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !strings.HasPrefix(name, prefix) {
+			name = prefix + name
+		}
+		found := false
+		for _, k := range known {
+			if k.Type == name {
+				mappings = append(mappings, k)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no default mapping for %s; give one with type=gauge:unit", name)
+		}
+	}
+	return mappings, nil
+}
+
+// OpenExport lists the files of an export at path that src reads. A zip
+// archive or a directory is searched for the files src accepts; any other
+// file is read as it is. The closer releases an archive once the import is
+// done.
+func OpenExport(name string, src Source) ([]File, io.Closer, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []File
+	switch {
+	case info.IsDir():
+		err := filepath.WalkDir(name, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !src.Accepts(p) {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, localFile(p, info.Size()))
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	case strings.EqualFold(filepath.Ext(name), ".zip"):
+		z, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range z.File {
+			if !f.FileInfo().IsDir() && src.Accepts(f.Name) {
+				files = append(files, File{Name: f.Name, Size: int64(f.UncompressedSize64), Open: f.Open})
+			}
+		}
+		if len(files) == 0 {
+			z.Close()
+			return nil, nil, fmt.Errorf("%w: %s holds no files to import", ErrInvalidExport, name)
+		}
+		return files, z, nil
+	default:
+		files = append(files, localFile(name, info.Size()))
+	}
+
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s holds no files to import", ErrInvalidExport, name)
+	}
+	return files, io.NopCloser(nil), nil
+}
+
+func localFile(name string, size int64) File {
+	return File{Name: name, Size: size, Open: func() (io.ReadCloser, error) { return os.Open(name) }}
+}
+
+// ReaderFile wraps an export already open, such as an upload, as a file
+func ReaderFile(name string, r io.Reader) File {
+	return File{Name: name, Open: func() (io.ReadCloser, error) { return io.NopCloser(r), nil }}
+}
+
+// TotalSize adds up the sizes of the files, for progress reports
+func TotalSize(files []File) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
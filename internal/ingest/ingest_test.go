@@ -0,0 +1,423 @@
+package ingest_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"health-monitor/internal/access"
+	"health-monitor/internal/auth"
+	"health-monitor/internal/db"
+	"health-monitor/internal/ingest"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const appleExport = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE HealthData [
+<!ELEMENT HealthData (ExportDate,Me,(Record|Workout)*)>
+<!ATTLIST HealthData locale CDATA #REQUIRED>
+]>
+<HealthData locale="en_GB">
+ <ExportDate value="2025-01-12 20:00:00 +0100"/>
+ <Me HKCharacteristicTypeIdentifierDateOfBirth=""/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="iPhone" unit="count" startDate="2025-01-10 08:00:00 +0100" endDate="2025-01-10 08:10:00 +0100" value="3000"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="iPhone" unit="count" startDate="2025-01-10 18:00:00 +0100" endDate="2025-01-10 18:10:00 +0100" value="2000">
+  <MetadataEntry key="HKWasUserEntered" value="0"/>
+ </Record>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="Watch" unit="count" startDate="2025-01-10 08:00:00 +0100" endDate="2025-01-10 08:10:00 +0100" value="4000"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="iPhone" unit="count" startDate="2025-01-11 09:00:00 +0100" endDate="2025-01-11 09:10:00 +0100" value="1234"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="iPhone" unit="count" startDate="someday" value="10"/>
+ <Record type="HKQuantityTypeIdentifierBodyMass" sourceName="Scale" unit="lb" startDate="2025-01-10 07:00:00 +0100" value="160"/>
+ <Record type="HKQuantityTypeIdentifierBodyMass" sourceName="Scale" unit="lb" startDate="2025-01-10 21:00:00 +0100" value="165"/>
+ <Record type="HKQuantityTypeIdentifierDietaryWater" sourceName="App" unit="mL" startDate="2025-01-10 12:00:00 +0100" value="500"/>
+ <Record type="HKQuantityTypeIdentifierDietaryWater" sourceName="App" unit="kg" startDate="2025-01-10 13:00:00 +0100" value="1"/>
+ <Record type="HKQuantityTypeIdentifierHeartRate" sourceName="Watch" unit="count/min" startDate="2025-01-10 12:00:00 +0100" value="70"/>
+</HealthData>
+`
+
+func exportFile(content string) []ingest.File {
+	return []ingest.File{ingest.ReaderFile("export.xml", strings.NewReader(content))}
+}
+
+func mappings(t *testing.T, types ...string) []ingest.Mapping {
+	m, err := ingest.SelectMappings(types, ingest.AppleTypePrefix, ingest.AppleHealthMappings)
+	require.NoError(t, err)
+	return m
+}
+
+func TestImportAppleHealth(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	water, err := store.CreateGauge(ctx, db.CreateGaugeParams{
+		Name: "water", Target: 2, Unit: "l", Icon: "water", Period: "day", PeriodDays: 1,
+		WeekStart: 1, Timezone: "Europe/Paris", GoalType: "at_least", Step: 0.25, Decimals: 2,
+	})
+	require.NoError(t, err)
+
+	opts := ingest.Options{
+		Mappings:  mappings(t, "StepCount", "BodyMass", "DietaryWater"),
+		Create:    true,
+		Timezone:  "Europe/Paris",
+		BatchSize: 2,
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		dry := opts
+		dry.DryRun = true
+		result, err := ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(appleExport), dry)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Steps", "Weight"}, result.Created)
+		assert.Equal(t, 4, result.Imported)
+
+		gauges, err := store.ListGauges(ctx)
+		require.NoError(t, err)
+		assert.Len(t, gauges, 1, "a dry run creates nothing")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		var progress []ingest.Progress
+		withProgress := opts
+		withProgress.Progress = func(p ingest.Progress) { progress = append(progress, p) }
+
+		result, err := ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(appleExport), withProgress)
+		require.NoError(t, err)
+		assert.Equal(t, 10, result.Records)
+		assert.Equal(t, 9, result.Matched)
+		assert.Equal(t, 2, result.Invalid, "a bad date and a unit that is not a volume")
+		assert.Equal(t, 4, result.Imported)
+		assert.Equal(t, []string{"Steps", "Weight"}, result.Created)
+
+		// Reading, then the two batches of writes
+		require.Len(t, progress, 3)
+		assert.Equal(t, ingest.Progress{Bytes: progress[0].Bytes, Records: 10, Matched: 9, Periods: 4}, progress[0])
+		assert.Equal(t, 4, progress[2].Written)
+
+		gauges, err := store.ListGauges(ctx)
+		require.NoError(t, err)
+		byName := make(map[string]db.Gauge)
+		for _, g := range gauges {
+			byName[g.Name] = g
+		}
+		steps := byName["Steps"]
+		assert.Equal(t, "day", steps.Period)
+		assert.Equal(t, "Europe/Paris", steps.Timezone)
+
+		paris, err := time.LoadLocation("Europe/Paris")
+		require.NoError(t, err)
+		deltas := func(id int64) map[string]float64 {
+			values, err := store.GetGaugeValues(ctx, id)
+			require.NoError(t, err)
+			out := make(map[string]float64)
+			for _, v := range values {
+				assert.Equal(t, ingest.SourceAppleHealth, v.Source)
+				out[v.Date.In(paris).Format("2006-01-02 15:04")] = v.Delta
+			}
+			return out
+		}
+
+		// The watch recorded more steps on the 10th than the phone, so its
+		// total is used
+		assert.Equal(t, map[string]float64{"2025-01-10 00:00": 5000, "2025-01-11 00:00": 1234}, deltas(steps.ID))
+		assert.Equal(t, map[string]float64{"2025-01-10 00:00": 74.8}, deltas(byName["Weight"].ID))
+		assert.Equal(t, map[string]float64{"2025-01-10 00:00": 0.5}, deltas(water.ID))
+	})
+
+	t.Run("again", func(t *testing.T) {
+		result, err := ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(appleExport), opts)
+		require.NoError(t, err)
+		assert.Empty(t, result.Created)
+		assert.Equal(t, 0, result.Imported)
+		assert.Equal(t, 4, result.Duplicates)
+	})
+}
+
+func TestImportAppleHealth_Updated(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	heart, err := store.CreateGauge(ctx, db.CreateGaugeParams{
+		Name: "Resting Heart Rate", Target: 60, Unit: "bpm", Icon: "heart", Period: "month", PeriodDays: 1,
+		WeekStart: 1, Timezone: "UTC", GoalType: "at_most", Step: 1,
+	})
+	require.NoError(t, err)
+	_, err = store.SetGaugeValue(ctx, db.SetGaugeValueParams{GaugeID: heart.ID, Value: 50})
+	require.NoError(t, err)
+
+	now := time.Now().Add(-time.Second).Format("2006-01-02 15:04:05 -0700")
+	record := func(typ, unit, date, value string) string {
+		return `<Record type="HKQuantityTypeIdentifier` + typ + `" sourceName="iPhone" unit="` + unit + `" startDate="` + date + `" value="` + value + `"/>` + "\n"
+	}
+	export := func(records ...string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>` + "\n<HealthData>\n" + strings.Join(records, "") + "</HealthData>\n"
+	}
+	opts := ingest.Options{Mappings: mappings(t, "StepCount", "BodyMass", "RestingHeartRate"), Create: true}
+
+	first := export(
+		record("StepCount", "count", "2025-01-11 09:00:00 +0100", "1234"),
+		record("BodyMass", "lb", "2025-01-10 07:00:00 +0100", "160"),
+		record("RestingHeartRate", "count/min", now, "60"),
+	)
+	result, err := ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(first), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Imported)
+	assert.Equal(t, 0, result.Updated)
+
+	current, err := store.GetGauge(ctx, heart.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 60.0, current.Value, "a reading sets the value rather than adding to it")
+
+	// A later export holds more of the same periods
+	second := export(
+		record("StepCount", "count", "2025-01-11 09:00:00 +0100", "1234"),
+		record("StepCount", "count", "2025-01-11 20:00:00 +0100", "766"),
+		record("BodyMass", "lb", "2025-01-10 07:00:00 +0100", "160"),
+		record("BodyMass", "lb", "2025-01-10 21:00:00 +0100", "165"),
+		record("RestingHeartRate", "count/min", now, "60"),
+		record("RestingHeartRate", "count/min", now, "70"),
+	)
+	result, err = ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(second), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Imported)
+	assert.Equal(t, 3, result.Updated)
+	assert.Equal(t, 0, result.Duplicates)
+
+	gauges, err := store.ListGauges(ctx)
+	require.NoError(t, err)
+	totals := make(map[string]float64)
+	for _, g := range gauges {
+		values, err := store.GetGaugeValues(ctx, g.ID)
+		require.NoError(t, err)
+		for _, v := range values {
+			if v.Source == ingest.SourceAppleHealth {
+				totals[g.Name+" "+v.Date.UTC().Format("2006-01-02")] += v.Delta
+			}
+		}
+	}
+	assert.Equal(t, 2000.0, totals["Steps 2025-01-11"])
+	assert.InDelta(t, 74.8, totals["Weight 2025-01-10"], 1e-9)
+
+	current, err = store.GetGauge(ctx, heart.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 65.0, current.Value)
+
+	result, err = ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(second), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 3, result.Duplicates)
+}
+
+func TestImportAppleHealth_Missing(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+
+	result, err := ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(appleExport), ingest.Options{Mappings: mappings(t, "StepCount")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Steps"}, result.Missing)
+	assert.Equal(t, 0, result.Matched)
+
+	_, err = ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(`<Other/>`), ingest.Options{})
+	assert.ErrorIs(t, err, ingest.ErrInvalidExport)
+	_, err = ingest.Import(ctx, store, ingest.AppleHealth{}, exportFile(`<HealthData><Record`), ingest.Options{})
+	assert.ErrorIs(t, err, ingest.ErrInvalidExport)
+}
+
+func TestImportAppleHealth_User(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	scoped := access.NewStore(store)
+	newUser := func(name string) (db.User, context.Context) {
+		user, err := store.CreateUser(ctx, db.CreateUserParams{Username: name, PasswordHash: "-"})
+		require.NoError(t, err)
+		return user, auth.WithUser(ctx, user)
+	}
+	alice, asAlice := newUser("alice")
+	_, asBob := newUser("bob")
+	steps := db.CreateGaugeParams{
+		Name: "Steps", Target: 10000, Unit: "steps", Icon: "star", Period: "day", PeriodDays: 1,
+		WeekStart: 1, Timezone: "Europe/Paris", GoalType: "at_least", Step: 1,
+	}
+	bobs, err := scoped.CreateGauge(asBob, steps)
+	require.NoError(t, err)
+
+	opts := ingest.Options{Mappings: mappings(t, "StepCount"), Timezone: "Europe/Paris"}
+	result, err := ingest.Import(asAlice, scoped, ingest.AppleHealth{}, exportFile(appleExport), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Steps"}, result.Missing, "another user's gauge is not matched")
+
+	opts.Create = true
+	result, err = ingest.Import(asAlice, scoped, ingest.AppleHealth{}, exportFile(appleExport), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Steps"}, result.Created)
+	assert.Equal(t, 2, result.Imported)
+
+	gauges, err := scoped.ListGauges(asAlice)
+	require.NoError(t, err)
+	require.Len(t, gauges, 1)
+	assert.NotEqual(t, bobs.ID, gauges[0].ID)
+	assert.Equal(t, sql.NullInt64{Int64: alice.ID, Valid: true}, gauges[0].OwnerID)
+
+	values, err := store.GetGaugeValues(ctx, bobs.ID)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestParseMapping(t *testing.T) {
+	m, err := ingest.ParseMapping("StepCount=Walking", ingest.AppleTypePrefix, ingest.AppleHealthMappings)
+	require.NoError(t, err)
+	assert.Equal(t, "HKQuantityTypeIdentifierStepCount", m.Type)
+	assert.Equal(t, "Walking", m.Gauge)
+	assert.Equal(t, "steps", m.Unit)
+	assert.Equal(t, ingest.AggregateSum, m.Aggregation)
+
+	m, err = ingest.ParseMapping("HeartRate=Pulse:bpm:max", ingest.AppleTypePrefix, ingest.AppleHealthMappings)
+	require.NoError(t, err)
+	assert.Equal(t, ingest.Mapping{Type: "HKQuantityTypeIdentifierHeartRate", Gauge: "Pulse", Unit: "bpm", Aggregation: ingest.AggregateMax}, m)
+
+	for _, spec := range []string{"HeartRate=Pulse", "StepCount", "=Steps", "StepCount=Steps:steps:median", "StepCount=Steps:a:sum:b"} {
+		_, err := ingest.ParseMapping(spec, ingest.AppleTypePrefix, ingest.AppleHealthMappings)
+		assert.Error(t, err, spec)
+	}
+
+	_, err = ingest.SelectMappings([]string{"Sleep"}, ingest.AppleTypePrefix, ingest.AppleHealthMappings)
+	assert.Error(t, err)
+}
+
+const googleFitDaily = "\ufeffDate,Move Minutes count,Calories (kcal),Distance (m),Heart Points,Average heart rate (bpm),Average weight (kg),Step count\n" +
+	"2025-01-10,45,2100.5,4200,12,,,6000\n" +
+	"2025-01-11,,1900,,,72.5,80.2,\n" +
+	"someday,10,,,,,,100\n"
+
+// googleFitSteps holds raw step deltas from the phone, which counted fewer
+// steps on the 10th than the daily CSV
+const googleFitSteps = `{
+  "Data Source": "raw:com.google.step_count.delta:phone",
+  "Data Points": [
+    {"fitValue": [{"value": {"intVal": 2500}}], "dataTypeName": "com.google.step_count.delta", "startTimeNanos": 1736499600000000000, "endTimeNanos": 1736499660000000000},
+    {"fitValue": [{"value": {"intVal": 500}}], "dataTypeName": "com.google.step_count.delta", "startTimeNanos": 1736503200000000000, "endTimeNanos": 1736503260000000000},
+    {"fitValue": [{"value": {"intVal": 4321}}], "dataTypeName": "com.google.step_count.delta", "startTimeNanos": 1736672400000000000, "endTimeNanos": 1736672460000000000},
+    {"fitValue": [], "dataTypeName": "com.google.step_count.delta", "startTimeNanos": 1736672400000000000},
+    {"fitValue": [{"value": {"fpVal": 1.5}}], "dataTypeName": "com.google.speed", "startTimeNanos": 1736672400000000000}
+  ]
+}`
+
+func TestImportGoogleFit(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	src := ingest.GoogleFit{}
+	files := func() []ingest.File {
+		return []ingest.File{
+			ingest.ReaderFile("Takeout/Fit/Daily activity metrics/Daily activity metrics.csv", strings.NewReader(googleFitDaily)),
+			ingest.ReaderFile("Takeout/Fit/All data/raw_com.google.step_count.delta_phone.json", strings.NewReader(googleFitSteps)),
+		}
+	}
+	opts := ingest.Options{Mappings: src.Mappings(), Create: true, Timezone: "Europe/Paris"}
+
+	result, err := ingest.Import(ctx, store, src, files(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Steps", "Distance", "Calories", "Move Minutes", "Heart Rate", "Weight"}, result.Created)
+	assert.Equal(t, 15, result.Records)
+	assert.Equal(t, 3, result.Invalid, "two cells of a bad date and a point without a value")
+	assert.Equal(t, 8, result.Imported)
+
+	gauges, err := store.ListGauges(ctx)
+	require.NoError(t, err)
+	byName := make(map[string]db.Gauge)
+	for _, g := range gauges {
+		byName[g.Name] = g
+	}
+	deltas := func(name string) map[string]float64 {
+		values, err := store.GetGaugeValues(ctx, byName[name].ID)
+		require.NoError(t, err)
+		out := make(map[string]float64)
+		for _, v := range values {
+			assert.Equal(t, ingest.SourceGoogleFit, v.Source)
+			out[v.Date.Format("2006-01-02")] = v.Delta
+		}
+		return out
+	}
+	// Midnight in Paris is 23:00 UTC the day before
+	assert.Equal(t, map[string]float64{"2025-01-09": 6000, "2025-01-11": 4321}, deltas("Steps"))
+	assert.Equal(t, map[string]float64{"2025-01-09": 4.2}, deltas("Distance"))
+	assert.Equal(t, map[string]float64{"2025-01-09": 45}, deltas("Move Minutes"))
+	assert.Equal(t, map[string]float64{"2025-01-10": 80.2}, deltas("Weight"))
+
+	again, err := ingest.Import(ctx, store, src, files(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, again.Imported)
+	assert.Equal(t, 8, again.Duplicates)
+}
+
+func TestImportFitbit(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+
+	dir := t.TempDir()
+	export := map[string]string{
+		"Physical Activity/steps-2025-01-10.json": `[
+			{"dateTime": "01/10/25 08:00:00", "value": "3000"},
+			{"dateTime": "01/10/25 18:00:00", "value": "2000"},
+			{"dateTime": "01/11/25 09:00:00", "value": "many"}
+		]`,
+		"Physical Activity/resting_heart_rate-2025-01-10.json": `[
+			{"dateTime": "01/10/25 00:00:00", "value": {"date": "01/10/25", "value": 58.4, "error": 6.9}},
+			{"dateTime": "01/11/25 00:00:00", "value": {"date": "01/11/25", "value": 0.0, "error": 0.0}}
+		]`,
+		"Physical Activity/heart_rate-2025-01-10.json": `not read, as heart_rate is not mapped`,
+		"Personal & Account/weight-2025-01-10.json": `[
+			{"logId": 1, "weight": 170.0, "bmi": 24.1, "date": "01/10/25", "time": "07:00:00", "source": "Aria"}
+		]`,
+		"Sleep/sleep-2025-01-10.json": `[
+			{"logId": 2, "dateOfSleep": "2025-01-10", "startTime": "2025-01-09T23:10:00.000", "minutesAsleep": 420},
+			{"logId": 3, "dateOfSleep": "2025-01-10", "startTime": "2025-01-10T14:00:00.000", "minutesAsleep": 30}
+		]`,
+		"Sleep/sleep_score.csv": `ignored`,
+	}
+	for name, content := range export {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	src := ingest.Fitbit{WeightUnit: "lb"}
+	files, closer, err := ingest.OpenExport(dir, src)
+	require.NoError(t, err)
+	defer closer.Close()
+	assert.Len(t, files, 5, "every file of a known type, read or not")
+
+	opts := ingest.Options{Mappings: src.Mappings(), Create: true}
+	result, err := ingest.Import(ctx, store, src, files, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Invalid, "steps that are not a number and a day without a resting heart rate")
+	assert.Equal(t, 4, result.Imported)
+
+	gauges, err := store.ListGauges(ctx)
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, g := range gauges {
+		vs, err := store.GetGaugeValues(ctx, g.ID)
+		require.NoError(t, err)
+		for _, v := range vs {
+			values[g.Name+" "+v.Date.Format("2006-01-02")] = v.Delta
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"Steps 2025-01-10":              5000,
+		"Resting Heart Rate 2025-01-10": 58,
+		"Weight 2025-01-10":             77.1,
+		"Sleep 2025-01-10":              7.5,
+	}, values)
+
+	again, err := ingest.Import(ctx, store, src, files, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, again.Imported)
+	assert.Equal(t, 4, again.Duplicates)
+
+	_, _, err = ingest.OpenExport(filepath.Join(dir, "Sleep"), ingest.GoogleFit{})
+	assert.ErrorIs(t, err, ingest.ErrInvalidExport)
+}
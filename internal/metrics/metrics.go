@@ -0,0 +1,177 @@
+// Package metrics exposes the gauges and the health of the server to
+// Prometheus.
+//
+// Each gauge's current value, target and percent of its target is read from
+// the database on every scrape, labelled with the gauge's id, name and
+// unit. Alongside them are request counts and latencies by route, query
+// timings by query name, the database connection pool and the Go runtime.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+)
+
+// namespace starts the name of every metric
+const namespace = "health_monitor"
+
+// scrapeTimeout bounds how long listing the gauges may take during a scrape
+const scrapeTimeout = 5 * time.Second
+
+// GaugeLister lists the gauges to expose
+type GaugeLister interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+}
+
+// Metrics holds the collectors of one server
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	queries  *prometheus.HistogramVec
+	failures *prometheus.CounterVec
+}
+
+// New registers the gauges of store along with the server's own metrics.
+// database, when not nil, adds the statistics of its connection pool.
+func New(store GaugeLister, database *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by database queries, by query name.",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+		}, []string{"query"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_query_errors_total",
+			Help:      "Database queries that failed, by query name.",
+		}, []string{"query"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests, m.latency, m.queries, m.failures,
+		newGaugeCollector(store),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if database != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(database, "health"))
+	}
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times requests by the chi route pattern that
+// served them, such as /gauges/{id}, so each gauge does not get a series of
+// its own. Requests no route matched are counted under "unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		m.latency.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveQuery records a query's timing; it is a db.QueryObserver
+func (m *Metrics) ObserveQuery(name string, duration time.Duration, err error) {
+	m.queries.WithLabelValues(name).Observe(duration.Seconds())
+	if err != nil {
+		m.failures.WithLabelValues(name).Inc()
+	}
+}
+
+// gaugeCollector reads the gauges afresh on each scrape, so deleted gauges
+// drop out and renamed ones move to their new labels
+type gaugeCollector struct {
+	store   GaugeLister
+	value   *prometheus.Desc
+	target  *prometheus.Desc
+	percent *prometheus.Desc
+	onTrack *prometheus.Desc
+	count   *prometheus.Desc
+}
+
+func newGaugeCollector(store GaugeLister) *gaugeCollector {
+	labels := []string{"id", "name", "unit"}
+	return &gaugeCollector{
+		store:   store,
+		value:   prometheus.NewDesc(namespace+"_gauge_value", "Current value of the gauge in its active period.", labels, nil),
+		target:  prometheus.NewDesc(namespace+"_gauge_target", "Target of the gauge.", labels, nil),
+		percent: prometheus.NewDesc(namespace+"_gauge_percent", "Value of the gauge as a percentage of its target.", labels, nil),
+		onTrack: prometheus.NewDesc(namespace+"_gauge_on_track", "1 when the gauge meets its goal, otherwise 0.", labels, nil),
+		count:   prometheus.NewDesc(namespace+"_gauges", "Number of gauges.", nil, nil),
+	}
+}
+
+func (c *gaugeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.value
+	ch <- c.target
+	ch <- c.percent
+	ch <- c.onTrack
+	ch <- c.count
+}
+
+func (c *gaugeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	gauges, err := c.store.ListGauges(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.count, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, float64(len(gauges)))
+	for i := range gauges {
+		gauge := &gauges[i]
+		labels := []string{strconv.FormatInt(gauge.ID, 10), gauge.Name, gauge.Unit}
+		eval := models.EvaluateGoal(gauge, gauge.Value)
+		onTrack := 0.0
+		if eval.OnTrack() {
+			onTrack = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.value, prometheus.GaugeValue, gauge.Value, labels...)
+		ch <- prometheus.MustNewConstMetric(c.target, prometheus.GaugeValue, gauge.Target, labels...)
+		ch <- prometheus.MustNewConstMetric(c.percent, prometheus.GaugeValue, eval.Percent, labels...)
+		ch <- prometheus.MustNewConstMetric(c.onTrack, prometheus.GaugeValue, onTrack, labels...)
+	}
+}
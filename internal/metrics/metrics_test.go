@@ -0,0 +1,79 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/metrics"
+	"health-monitor/internal/testutil"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) (int, string) {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return rec.Code, string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	m := metrics.New(store, nil)
+	store.ObserveQueries(m.ObserveQuery)
+
+	gauge := testutil.CreateTestGauge(t, store)
+	_, err := store.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 25})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/gauges/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := store.GetGauge(r.Context(), 999); err != nil {
+			http.NotFound(w, r)
+		}
+	})
+	for _, path := range []string{"/gauges/1", "/gauges/2", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	code, body := scrape(t, m)
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `health_monitor_gauges 1`)
+	assert.Contains(t, body, `health_monitor_gauge_value{id="1",name="Test Gauge",unit="units"} 25`)
+	assert.Contains(t, body, `health_monitor_gauge_target{id="1",name="Test Gauge",unit="units"} 100`)
+	assert.Contains(t, body, `health_monitor_gauge_percent{id="1",name="Test Gauge",unit="units"} 25`)
+	assert.Contains(t, body, `health_monitor_gauge_on_track{id="1",name="Test Gauge",unit="units"} 1`)
+
+	// Requests are counted by route pattern, not by path
+	assert.Contains(t, body, `health_monitor_http_requests_total{method="GET",route="/gauges/{id}",status="404"} 2`)
+	assert.Contains(t, body, `health_monitor_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `health_monitor_http_request_duration_seconds_count{method="GET",route="/gauges/{id}"} 2`)
+
+	// Queries are timed in transactions too, and missing rows are not errors
+	assert.Contains(t, body, `health_monitor_db_query_duration_seconds_count{query="GetGauge"} 3`)
+	assert.Contains(t, body, `health_monitor_db_query_duration_seconds_count{query="AddGaugeValue"} 1`)
+	assert.NotContains(t, body, `health_monitor_db_query_errors_total{query="GetGauge"}`)
+	assert.Contains(t, body, `go_goroutines`)
+}
+
+type failingStore struct{}
+
+func (failingStore) ListGauges(context.Context) ([]db.Gauge, error) {
+	return nil, errors.New("database is closed")
+}
+
+func TestMetrics_ListFails(t *testing.T) {
+	code, body := scrape(t, metrics.New(failingStore{}, nil))
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Contains(t, body, "database is closed")
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"health-monitor/internal/db"
+)
+
+// GaugeValueInput is the body accepted when logging a change to a gauge
+type GaugeValueInput struct {
+	Delta float64 `json:"delta"`
+	// Date is the day the change was made (YYYY-MM-DD) in the gauge's time
+	// zone, today when empty. Changes on earlier days are added to the
+	// period they fall in.
+	Date string `json:"date,omitempty"`
+}
+
+// GaugeValueList is a page of gauge values
+type GaugeValueList struct {
+	Values []db.GaugeValue `json:"values"`
+	Total  int64           `json:"total"`
+	Limit  int64           `json:"limit"`
+	Offset int64           `json:"offset"`
+}
+
+// NewGaugeList wraps each gauge with its current status
+func NewGaugeList(gauges []db.Gauge) []*GaugeWithValue {
+	list := make([]*GaugeWithValue, len(gauges))
+	for i := range gauges {
+		list[i] = NewGaugeWithValue(&gauges[i])
+	}
+	return list
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"health-monitor/internal/db"
+)
+
+// MaxDecimals is the most decimal places a gauge can be configured with
+const MaxDecimals = 4
+
+// MaxPresets is how many quick-add amounts a gauge can have
+const MaxPresets = 6
+
+// unitDecimals suggests decimal places for common units. Units not listed
+// are counted in whole numbers.
+var unitDecimals = map[string]int64{
+	"kg":     1,
+	"lb":     1,
+	"lbs":    1,
+	"st":     1,
+	"km":     2,
+	"mi":     2,
+	"miles":  2,
+	"h":      1,
+	"hr":     1,
+	"hrs":    1,
+	"hours":  1,
+	"l":      2,
+	"litres": 2,
+	"liters": 2,
+	"%":      1,
+}
+
+// UnitDecimals returns the decimal places suggested for values in unit
+func UnitDecimals(unit string) int64 {
+	return unitDecimals[strings.ToLower(strings.TrimSpace(unit))]
+}
+
+// ParsePresets parses a comma-separated list of quick-add amounts, as stored
+// on a gauge or typed into the gauge form
+func ParsePresets(s string) ([]float64, error) {
+	var presets []float64
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimPrefix(strings.TrimSpace(field), "+")
+		if field == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount %q", field)
+		}
+		presets = append(presets, amount)
+	}
+	return presets, nil
+}
+
+// GaugePresets returns the gauge's quick-add amounts, skipping any that
+// cannot be parsed
+func GaugePresets(gauge *db.Gauge) []float64 {
+	presets, _ := ParsePresets(gauge.Presets)
+	return presets
+}
+
+// JoinPresets formats quick-add amounts for storage on a gauge
+func JoinPresets(presets []float64) string {
+	fields := make([]string, len(presets))
+	for i, amount := range presets {
+		fields[i] = formatPlain(amount)
+	}
+	return strings.Join(fields, ",")
+}
+
+// GaugeStep returns the amount the gauge's +/- buttons change it by.
+// Gauges created before steps existed step by one.
+func GaugeStep(gauge *db.Gauge) float64 {
+	if gauge.Step <= 0 {
+		return 1
+	}
+	return gauge.Step
+}
+
+// FormatAmount formats an amount entered for the gauge with the gauge's
+// decimal places, e.g. "250" or "72.5"
+func FormatAmount(gauge *db.Gauge, amount float64) string {
+	return strconv.FormatFloat(amount, 'f', int(gauge.Decimals), 64)
+}
+
+// FormatValue formats the gauge's value or target for display. Values keep
+// at least one decimal place so whole-number gauges show progress the way
+// they always have.
+func FormatValue(gauge *db.Gauge, value float64) string {
+	return strconv.FormatFloat(value, 'f', int(max(gauge.Decimals, 1)), 64)
+}
+
+// InputStep returns the step attribute for number inputs that take the
+// gauge's amounts, e.g. "0.1" for one decimal place
+func InputStep(gauge *db.Gauge) string {
+	return strconv.FormatFloat(math.Pow10(-int(gauge.Decimals)), 'f', int(gauge.Decimals), 64)
+}
+
+// ValidateValue checks a value the gauge is being set to against its bounds
+// and decimal places, returning nil when it is acceptable. Label names the
+// value in the error message.
+func ValidateValue(gauge *db.Gauge, field, label string, value float64) *FieldError {
+	b := gaugeBounds(gauge)
+	return b.check(field, label, value)
+}
+
+// ValidateAmount checks an amount being added to the gauge. Only the upper
+// bound applies, since amounts are added to the value rather than replacing
+// it and may be negative corrections. The value the amount leads to is
+// checked against both bounds when it is recorded; see OutOfBoundsMessage.
+func ValidateAmount(gauge *db.Gauge, field, label string, amount float64) *FieldError {
+	b := gaugeBounds(gauge)
+	b.min = nil
+	return b.check(field, label, amount)
+}
+
+// OutOfBoundsMessage explains a change refused for taking the gauge's value
+// past one of its bounds, or returns "" for any other error
+func OutOfBoundsMessage(gauge *db.Gauge, err error) string {
+	var bounds *db.OutOfBoundsError
+	if !errors.As(err, &bounds) {
+		return ""
+	}
+	if bounds.Above {
+		return fmt.Sprintf("This would take the value to %s, over the highest value of %s", FormatAmount(gauge, bounds.Value), FormatAmount(gauge, bounds.Bound))
+	}
+	return fmt.Sprintf("This would take the value to %s, under the lowest value of %s", FormatAmount(gauge, bounds.Value), FormatAmount(gauge, bounds.Bound))
+}
+
+func gaugeBounds(gauge *db.Gauge) bounds {
+	b := bounds{decimals: gauge.Decimals}
+	if gauge.MinValue.Valid {
+		b.min = &gauge.MinValue.Float64
+	}
+	if gauge.MaxValue.Valid {
+		b.max = &gauge.MaxValue.Float64
+	}
+	return b
+}
+
+// bounds are the limits on amounts entered for a gauge. Nil bounds are open.
+type bounds struct {
+	decimals int64
+	min, max *float64
+}
+
+func (b bounds) check(field, label string, amount float64) *FieldError {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return &FieldError{Field: field, Message: label + " must be a valid number"}
+	}
+	if b.min != nil && amount < *b.min {
+		return &FieldError{Field: field, Message: fmt.Sprintf("%s must be at least %s", label, formatPlain(*b.min))}
+	}
+	if b.max != nil && amount > *b.max {
+		return &FieldError{Field: field, Message: fmt.Sprintf("%s must be at most %s", label, formatPlain(*b.max))}
+	}
+	if !hasDecimals(amount, b.decimals) {
+		return &FieldError{Field: field, Message: decimalsMessage(label, b.decimals)}
+	}
+	return nil
+}
+
+// hasDecimals reports whether amount needs no more than decimals places
+func hasDecimals(amount float64, decimals int64) bool {
+	scaled := amount * math.Pow10(int(decimals))
+	return math.Abs(scaled-math.Round(scaled)) < 1e-6
+}
+
+func decimalsMessage(label string, decimals int64) string {
+	switch decimals {
+	case 0:
+		return label + " must be a whole number"
+	case 1:
+		return label + " must have at most 1 decimal place"
+	default:
+		return fmt.Sprintf("%s must have at most %d decimal places", label, decimals)
+	}
+}
+
+// formatPlain formats a number with as many decimal places as it needs
+func formatPlain(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
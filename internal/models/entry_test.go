@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"health-monitor/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePresets(t *testing.T) {
+	presets, err := ParsePresets(" 250, +500,,1.5 ")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{250, 500, 1.5}, presets)
+	assert.Equal(t, "250,500,1.5", JoinPresets(presets))
+
+	_, err = ParsePresets("250, a glass")
+	assert.Error(t, err)
+}
+
+func TestValidateValue(t *testing.T) {
+	gauge := &db.Gauge{
+		Decimals: 1,
+		MinValue: sql.NullFloat64{Float64: 30, Valid: true},
+		MaxValue: sql.NullFloat64{Float64: 300, Valid: true},
+	}
+
+	assert.Nil(t, ValidateValue(gauge, "value", "Value", 72.5))
+	assert.Equal(t, "Value must be at least 30", ValidateValue(gauge, "value", "Value", 29.9).Message)
+	assert.Equal(t, "Value must be at most 300", ValidateValue(gauge, "value", "Value", 300.1).Message)
+	assert.Equal(t, "Value must have at most 1 decimal place", ValidateValue(gauge, "value", "Value", 72.55).Message)
+
+	// Amounts are added to the value, so the lower bound does not apply
+	assert.Nil(t, ValidateAmount(gauge, "amount", "Amount", -0.5))
+	assert.Equal(t, "Amount must be at most 300", ValidateAmount(gauge, "amount", "Amount", 301).Message)
+}
+
+func TestFormatting(t *testing.T) {
+	whole := &db.Gauge{Decimals: 0}
+	precise := &db.Gauge{Decimals: 2}
+
+	assert.Equal(t, "250", FormatAmount(whole, 250))
+	assert.Equal(t, "250.0", FormatValue(whole, 250))
+	assert.Equal(t, "1", InputStep(whole))
+	assert.Equal(t, "1.25", FormatAmount(precise, 1.25))
+	assert.Equal(t, "1.25", FormatValue(precise, 1.25))
+	assert.Equal(t, "0.01", InputStep(precise))
+}
@@ -35,6 +35,25 @@ func NewNotFoundError(message string) *AppError {
 	}
 }
 
+// NewUnauthorizedError creates a new error for a request that is not signed
+// in
+func NewUnauthorizedError(message string) *AppError {
+	return &AppError{
+		Type:    "unauthorized",
+		Message: message,
+		Code:    http.StatusUnauthorized,
+	}
+}
+
+// NewForbiddenError creates a new error for a change the user may not make
+func NewForbiddenError(message string) *AppError {
+	return &AppError{
+		Type:    "forbidden",
+		Message: message,
+		Code:    http.StatusForbidden,
+	}
+}
+
 // NewInternalError creates a new internal server error
 func NewInternalError(message string) *AppError {
 	return &AppError{
@@ -44,6 +63,25 @@ func NewInternalError(message string) *AppError {
 	}
 }
 
+// NewConflictError creates a new conflict error
+func NewConflictError(message string) *AppError {
+	return &AppError{
+		Type:    "conflict",
+		Message: message,
+		Code:    http.StatusConflict,
+	}
+}
+
+// NewPreconditionFailedError creates a new error for a failed If-Match
+// precondition
+func NewPreconditionFailedError(message string) *AppError {
+	return &AppError{
+		Type:    "precondition_failed",
+		Message: message,
+		Code:    http.StatusPreconditionFailed,
+	}
+}
+
 // ReadJSON reads JSON from request body into target
 func ReadJSON(r *http.Request, target interface{}) error {
 	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
@@ -57,3 +95,15 @@ func WriteJSON(w http.ResponseWriter, data interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(data)
 }
+
+// WriteJSONStatus writes JSON to response writer with the given status code
+func WriteJSONStatus(w http.ResponseWriter, status int, data interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(data)
+}
+
+// WriteError writes an AppError as JSON using its status code
+func WriteError(w http.ResponseWriter, err *AppError) error {
+	return WriteJSONStatus(w, err.Code, err)
+}
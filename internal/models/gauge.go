@@ -6,11 +6,16 @@ import (
 
 // GaugeStatus represents the status of a gauge
 type GaugeStatus struct {
-	Value   float64 `json:"value"`
-	Target  float64 `json:"target"`
-	Unit    string  `json:"unit"`
-	Icon    string  `json:"icon"`
-	Percent float64 `json:"percent"`
+	Value     float64    `json:"value"`
+	Target    float64    `json:"target"`
+	TargetMin float64    `json:"target_min"`
+	GoalType  GoalType   `json:"goal_type"`
+	Unit      string     `json:"unit"`
+	Icon      string     `json:"icon"`
+	Percent   float64    `json:"percent"`
+	State     GoalStatus `json:"state"`
+	Label     string     `json:"label"`
+	Colour    string     `json:"colour"`
 }
 
 // MonthlyValue represents aggregated gauge values for a month
@@ -35,19 +40,21 @@ type GaugeHistory struct {
 
 // NewGaugeWithValue creates a new GaugeWithValue instance
 func NewGaugeWithValue(gauge *db.Gauge) *GaugeWithValue {
-	percent := 0.0
-	if gauge.Target > 0 {
-		percent = (gauge.Value / gauge.Target) * 100
-	}
+	eval := EvaluateGoal(gauge, gauge.Value)
 
 	return &GaugeWithValue{
 		Gauge: gauge,
 		Status: &GaugeStatus{
-			Value:   gauge.Value,
-			Target:  gauge.Target,
-			Unit:    gauge.Unit,
-			Icon:    gauge.Icon,
-			Percent: percent,
+			Value:     gauge.Value,
+			Target:    gauge.Target,
+			TargetMin: gauge.TargetMin,
+			GoalType:  GaugeGoalType(gauge),
+			Unit:      gauge.Unit,
+			Icon:      gauge.Icon,
+			Percent:   eval.Percent,
+			State:     eval.Status,
+			Label:     eval.Label,
+			Colour:    eval.Colour,
 		},
 	}
 }
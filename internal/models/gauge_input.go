@@ -0,0 +1,275 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/period"
+)
+
+// FieldError describes a validation problem with a single input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// GaugeInput holds the editable fields of a gauge as submitted by a form or
+// API client
+type GaugeInput struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Icon        string  `json:"icon"`
+	Unit        string  `json:"unit"`
+	Target      float64 `json:"target"`
+	TargetMin   float64 `json:"target_min"`
+	GoalType    string  `json:"goal_type"`
+	Period      string  `json:"period"`
+	PeriodDays  int64   `json:"period_days"`
+	WeekStart   int64   `json:"week_start"`
+	Timezone    string  `json:"timezone"`
+	// Step is the amount the +/- buttons change the value by
+	Step float64 `json:"step"`
+	// Presets are quick-add amounts shown alongside the +/- buttons
+	Presets []float64 `json:"presets"`
+	// MinValue and MaxValue bound the gauge's value, whether it is set or
+	// reached by adding amounts. MaxValue also caps any single amount added.
+	MinValue *float64 `json:"min_value"`
+	MaxValue *float64 `json:"max_value"`
+	// Decimals is how many decimal places amounts may have. When omitted it
+	// is suggested by the unit, or by the most precise amount given.
+	Decimals *int64 `json:"decimals"`
+}
+
+// DefaultGaugeInput returns an input with the defaults applied to fields
+// that clients may omit
+func DefaultGaugeInput() GaugeInput {
+	return GaugeInput{
+		GoalType:   string(GoalAtMost),
+		Period:     string(period.Week),
+		PeriodDays: 7,
+		WeekStart:  int64(time.Monday),
+		Timezone:   "UTC",
+		Step:       1,
+	}
+}
+
+// NewGaugeInput copies the editable fields of an existing gauge
+func NewGaugeInput(gauge *db.Gauge) GaugeInput {
+	decimals := gauge.Decimals
+	return GaugeInput{
+		Name:        gauge.Name,
+		Description: gauge.Description.String,
+		Icon:        gauge.Icon,
+		Unit:        gauge.Unit,
+		Target:      gauge.Target,
+		TargetMin:   gauge.TargetMin,
+		GoalType:    string(GaugeGoalType(gauge)),
+		Period:      gauge.Period,
+		PeriodDays:  gauge.PeriodDays,
+		WeekStart:   gauge.WeekStart,
+		Timezone:    gauge.Timezone,
+		Step:        GaugeStep(gauge),
+		Presets:     GaugePresets(gauge),
+		MinValue:    nullFloatPtr(gauge.MinValue),
+		MaxValue:    nullFloatPtr(gauge.MaxValue),
+		Decimals:    &decimals,
+	}
+}
+
+// Validate checks the input and returns one error per invalid field
+func (in GaugeInput) Validate() []FieldError {
+	var errors []FieldError
+
+	if strings.TrimSpace(in.Name) == "" {
+		errors = append(errors, FieldError{Field: "name", Message: "Name is required"})
+	}
+	if in.Icon == "" {
+		errors = append(errors, FieldError{Field: "icon", Message: "Icon is required"})
+	}
+	if in.Unit == "" {
+		errors = append(errors, FieldError{Field: "unit", Message: "Unit is required"})
+	}
+	if goal, err := ParseGoalType(in.GoalType); err != nil {
+		errors = append(errors, FieldError{Field: "goal_type", Message: "Goal must be at_most, at_least, range or exact"})
+	} else if goal == GoalRange && in.TargetMin >= in.Target {
+		errors = append(errors, FieldError{Field: "target_min", Message: "Minimum must be less than the target"})
+	}
+	if _, err := period.ParseKind(in.Period); err != nil {
+		errors = append(errors, FieldError{Field: "period", Message: "Period must be day, week, month or custom"})
+	}
+	if in.PeriodDays < 1 || in.PeriodDays > 366 {
+		errors = append(errors, FieldError{Field: "period_days", Message: "Period length must be between 1 and 366 days"})
+	}
+	if in.WeekStart < 0 || in.WeekStart > 6 {
+		errors = append(errors, FieldError{Field: "week_start", Message: "Week start must be a day of the week"})
+	}
+	if _, err := time.LoadLocation(in.Timezone); err != nil || in.Timezone == "" {
+		errors = append(errors, FieldError{Field: "timezone", Message: "Time zone must be a valid IANA name such as Europe/London"})
+	}
+
+	return append(errors, in.validateEntry()...)
+}
+
+// validateEntry checks the settings for how values are entered: the bounds
+// must be in order, and the target, step and presets must fit the decimal
+// places, with the step and presets no larger than the maximum
+func (in GaugeInput) validateEntry() []FieldError {
+	var errors []FieldError
+
+	b := bounds{decimals: in.decimals(), min: in.MinValue, max: in.MaxValue}
+	if b.decimals < 0 || b.decimals > MaxDecimals {
+		errors = append(errors, FieldError{Field: "decimals", Message: fmt.Sprintf("Decimal places must be between 0 and %d", MaxDecimals)})
+		b.decimals = MaxDecimals
+	}
+
+	if b.min != nil && b.max != nil && *b.min >= *b.max {
+		errors = append(errors, FieldError{Field: "max_value", Message: "Maximum must be greater than the minimum"})
+	}
+	if !hasDecimals(in.Target, b.decimals) {
+		errors = append(errors, FieldError{Field: "target", Message: decimalsMessage("Target", b.decimals)})
+	}
+	if in.GoalType == string(GoalRange) && !hasDecimals(in.TargetMin, b.decimals) {
+		errors = append(errors, FieldError{Field: "target_min", Message: decimalsMessage("Minimum", b.decimals)})
+	}
+
+	// Step and presets are amounts added to the value, so like other added
+	// amounts only the upper bound applies
+	b.min = nil
+	if in.Step <= 0 {
+		errors = append(errors, FieldError{Field: "step", Message: "Step must be greater than zero"})
+	} else if err := b.check("step", "Step", in.Step); err != nil {
+		errors = append(errors, *err)
+	}
+
+	if len(in.Presets) > MaxPresets {
+		errors = append(errors, FieldError{Field: "presets", Message: fmt.Sprintf("At most %d quick-add amounts are allowed", MaxPresets)})
+	}
+	for _, amount := range in.Presets {
+		if amount <= 0 {
+			errors = append(errors, FieldError{Field: "presets", Message: "Quick-add amounts must be greater than zero"})
+			break
+		}
+		if err := b.check("presets", "Quick-add amounts", amount); err != nil {
+			errors = append(errors, *err)
+			break
+		}
+	}
+
+	return errors
+}
+
+// decimals returns the decimal places the gauge will use. Unless set
+// explicitly, it is the unit's suggestion or enough for every amount given,
+// whichever is greater.
+func (in GaugeInput) decimals() int64 {
+	if in.Decimals != nil {
+		return *in.Decimals
+	}
+
+	decimals := UnitDecimals(in.Unit)
+	amounts := append([]float64{in.Target, in.TargetMin, in.Step}, in.Presets...)
+	if in.MinValue != nil {
+		amounts = append(amounts, *in.MinValue)
+	}
+	if in.MaxValue != nil {
+		amounts = append(amounts, *in.MaxValue)
+	}
+	for _, amount := range amounts {
+		for !hasDecimals(amount, decimals) && decimals < MaxDecimals {
+			decimals++
+		}
+	}
+	return decimals
+}
+
+// Gauge returns a gauge populated with the input's fields
+func (in GaugeInput) Gauge() db.Gauge {
+	gauge := db.Gauge{
+		Name:       strings.TrimSpace(in.Name),
+		Icon:       in.Icon,
+		Unit:       in.Unit,
+		Target:     in.Target,
+		GoalType:   in.GoalType,
+		Period:     in.Period,
+		PeriodDays: in.PeriodDays,
+		WeekStart:  in.WeekStart,
+		Timezone:   in.Timezone,
+		Step:       in.Step,
+		Presets:    JoinPresets(in.Presets),
+		MinValue:   floatPtrNull(in.MinValue),
+		MaxValue:   floatPtrNull(in.MaxValue),
+		Decimals:   in.decimals(),
+	}
+	if description := strings.TrimSpace(in.Description); description != "" {
+		gauge.Description = sql.NullString{String: description, Valid: true}
+	}
+	// Only ranges have a lower bound
+	if in.GoalType == string(GoalRange) {
+		gauge.TargetMin = in.TargetMin
+	}
+	return gauge
+}
+
+// CreateParams converts the input into parameters for creating a gauge
+func (in GaugeInput) CreateParams() db.CreateGaugeParams {
+	g := in.Gauge()
+	return db.CreateGaugeParams{
+		Name:        g.Name,
+		Description: g.Description,
+		Icon:        g.Icon,
+		Unit:        g.Unit,
+		Target:      g.Target,
+		Period:      g.Period,
+		PeriodDays:  g.PeriodDays,
+		WeekStart:   g.WeekStart,
+		Timezone:    g.Timezone,
+		GoalType:    g.GoalType,
+		TargetMin:   g.TargetMin,
+		Step:        g.Step,
+		Presets:     g.Presets,
+		MinValue:    g.MinValue,
+		MaxValue:    g.MaxValue,
+		Decimals:    g.Decimals,
+	}
+}
+
+// UpdateParams converts the input into parameters for updating a gauge
+func (in GaugeInput) UpdateParams(id int64) db.UpdateGaugeParams {
+	g := in.Gauge()
+	return db.UpdateGaugeParams{
+		ID:          id,
+		Name:        g.Name,
+		Description: g.Description,
+		Icon:        g.Icon,
+		Unit:        g.Unit,
+		Target:      g.Target,
+		Period:      g.Period,
+		PeriodDays:  g.PeriodDays,
+		WeekStart:   g.WeekStart,
+		Timezone:    g.Timezone,
+		GoalType:    g.GoalType,
+		TargetMin:   g.TargetMin,
+		Step:        g.Step,
+		Presets:     g.Presets,
+		MinValue:    g.MinValue,
+		MaxValue:    g.MaxValue,
+		Decimals:    g.Decimals,
+	}
+}
+
+func nullFloatPtr(n sql.NullFloat64) *float64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Float64
+}
+
+func floatPtrNull(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaugeInputValidate(t *testing.T) {
+	valid := DefaultGaugeInput()
+	valid.Name = "Water"
+	valid.Icon = "water"
+	valid.Unit = "glasses"
+	valid.Target = 8
+
+	tests := []struct {
+		name   string
+		modify func(in *GaugeInput)
+		fields []string
+	}{
+		{
+			name:   "valid input",
+			modify: func(in *GaugeInput) {},
+		},
+		{
+			name: "missing required fields",
+			modify: func(in *GaugeInput) {
+				in.Name = "  "
+				in.Icon = ""
+				in.Unit = ""
+			},
+			fields: []string{"name", "icon", "unit"},
+		},
+		{
+			name:   "unknown goal type",
+			modify: func(in *GaugeInput) { in.GoalType = "more" },
+			fields: []string{"goal_type"},
+		},
+		{
+			name: "range with minimum above target",
+			modify: func(in *GaugeInput) {
+				in.GoalType = "range"
+				in.TargetMin = 9
+			},
+			fields: []string{"target_min"},
+		},
+		{
+			name:   "unknown period",
+			modify: func(in *GaugeInput) { in.Period = "fortnight" },
+			fields: []string{"period"},
+		},
+		{
+			name: "out of range period settings",
+			modify: func(in *GaugeInput) {
+				in.PeriodDays = 0
+				in.WeekStart = 7
+			},
+			fields: []string{"period_days", "week_start"},
+		},
+		{
+			name: "entry settings",
+			modify: func(in *GaugeInput) {
+				in.Step = 0
+				in.Presets = []float64{250, -1}
+			},
+			fields: []string{"step", "presets"},
+		},
+		{
+			name: "bounds out of order",
+			modify: func(in *GaugeInput) {
+				in.MinValue = ptr(10.0)
+				in.MaxValue = ptr(5.0)
+				in.Presets = []float64{8}
+			},
+			fields: []string{"max_value", "presets"},
+		},
+		{
+			name: "amounts with too many decimal places",
+			modify: func(in *GaugeInput) {
+				in.Decimals = ptr(int64(0))
+				in.Target = 7.5
+				in.Step = 0.5
+			},
+			fields: []string{"target", "step"},
+		},
+		{
+			name:   "invalid time zone",
+			modify: func(in *GaugeInput) { in.Timezone = "Mars/Olympus" },
+			fields: []string{"timezone"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid
+			tt.modify(&in)
+
+			var fields []string
+			for _, err := range in.Validate() {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestGaugeInputGauge(t *testing.T) {
+	in := DefaultGaugeInput()
+	in.Name = " Water "
+	in.Description = "  "
+
+	gauge := in.Gauge()
+	assert.Equal(t, "Water", gauge.Name)
+	assert.False(t, gauge.Description.Valid)
+
+	in.Description = "Glasses per day"
+	gauge = in.Gauge()
+	assert.True(t, gauge.Description.Valid)
+	assert.Equal(t, "Glasses per day", gauge.Description.String)
+}
+
+func TestGaugeInputDecimals(t *testing.T) {
+	in := DefaultGaugeInput()
+	in.Unit = "glasses"
+	in.Target = 8
+	assert.Equal(t, int64(0), in.Gauge().Decimals)
+
+	// Units suggest their usual precision
+	in.Unit = "kg"
+	assert.Equal(t, int64(1), in.Gauge().Decimals)
+
+	// A more precise amount raises the suggestion
+	in.Step = 0.25
+	assert.Equal(t, int64(2), in.Gauge().Decimals)
+
+	// Explicit decimal places are kept as they are
+	in.Decimals = ptr(int64(3))
+	assert.Equal(t, int64(3), in.Gauge().Decimals)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -34,11 +34,15 @@ func TestNewGaugeWithValue(t *testing.T) {
 					Icon:   "star",
 				},
 				Status: &GaugeStatus{
-					Value:   80,
-					Target:  100,
-					Unit:    "units",
-					Icon:    "star",
-					Percent: 80,
+					Value:    80,
+					Target:   100,
+					GoalType: GoalAtMost,
+					Unit:     "units",
+					Icon:     "star",
+					Percent:  80,
+					State:    StatusOnTrack,
+					Label:    "On Track",
+					Colour:   ColourSuccess,
 				},
 			},
 		},
@@ -62,11 +66,15 @@ func TestNewGaugeWithValue(t *testing.T) {
 					Icon:   "warning",
 				},
 				Status: &GaugeStatus{
-					Value:   80,
-					Target:  0,
-					Unit:    "units",
-					Icon:    "warning",
-					Percent: 0,
+					Value:    80,
+					Target:   0,
+					GoalType: GoalAtMost,
+					Unit:     "units",
+					Icon:     "warning",
+					Percent:  0,
+					State:    StatusAbove,
+					Label:    "Above Target",
+					Colour:   ColourError,
 				},
 			},
 		},
@@ -90,11 +98,15 @@ func TestNewGaugeWithValue(t *testing.T) {
 					Icon:   "error",
 				},
 				Status: &GaugeStatus{
-					Value:   0,
-					Target:  100,
-					Unit:    "units",
-					Icon:    "error",
-					Percent: 0,
+					Value:    0,
+					Target:   100,
+					GoalType: GoalAtMost,
+					Unit:     "units",
+					Icon:     "error",
+					Percent:  0,
+					State:    StatusOnTrack,
+					Label:    "On Track",
+					Colour:   ColourSuccess,
 				},
 			},
 		},
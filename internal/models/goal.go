@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"health-monitor/internal/db"
+	"math"
+)
+
+// GoalType describes which values of a gauge count as on track
+type GoalType string
+
+const (
+	// GoalAtMost is met while the value stays at or below the target
+	GoalAtMost GoalType = "at_most"
+	// GoalAtLeast is met once the value reaches the target
+	GoalAtLeast GoalType = "at_least"
+	// GoalRange is met while the value is between TargetMin and Target
+	GoalRange GoalType = "range"
+	// GoalExact is met when the value equals the target
+	GoalExact GoalType = "exact"
+)
+
+// GoalTypes lists every supported goal type in display order
+var GoalTypes = []GoalType{GoalAtMost, GoalAtLeast, GoalRange, GoalExact}
+
+// GoalStatus is the outcome of comparing a value with a gauge's goal
+type GoalStatus string
+
+const (
+	StatusOnTrack GoalStatus = "on_track"
+	StatusBelow   GoalStatus = "below"
+	StatusAbove   GoalStatus = "above"
+)
+
+// Colours used to render a goal status. They are DaisyUI semantic colour
+// names so views can build classes such as "badge-" + colour.
+const (
+	ColourSuccess = "success"
+	ColourWarning = "warning"
+	ColourError   = "error"
+)
+
+// exactTolerance absorbs floating point noise when comparing with an exact
+// target
+const exactTolerance = 1e-9
+
+// GoalEvaluation describes how a value compares with a gauge's goal
+type GoalEvaluation struct {
+	Status  GoalStatus `json:"status"`
+	Label   string     `json:"label"`
+	Percent float64    `json:"percent"`
+	Colour  string     `json:"colour"`
+}
+
+// OnTrack reports whether the value meets the goal
+func (e GoalEvaluation) OnTrack() bool {
+	return e.Status == StatusOnTrack
+}
+
+// ParseGoalType validates a goal type string
+func ParseGoalType(s string) (GoalType, error) {
+	for _, g := range GoalTypes {
+		if string(g) == s {
+			return g, nil
+		}
+	}
+	return "", fmt.Errorf("unknown goal type %q", s)
+}
+
+// GaugeGoalType returns the gauge's goal type, treating anything unknown as
+// at most so gauges created before goal types keep their behaviour
+func GaugeGoalType(gauge *db.Gauge) GoalType {
+	g, err := ParseGoalType(gauge.GoalType)
+	if err != nil {
+		return GoalAtMost
+	}
+	return g
+}
+
+// EvaluateGoal compares a value with the gauge's goal. It is the single
+// definition of "on track" shared by every view and the API.
+//
+// Being short of an at least target only warns because the period may not
+// be over yet, while overshooting an at most, range or exact target is an
+// error. Percent is the value as a share of the target, or of the upper
+// bound for ranges.
+func EvaluateGoal(gauge *db.Gauge, value float64) GoalEvaluation {
+	e := GoalEvaluation{
+		Status:  StatusOnTrack,
+		Percent: percentOf(value, gauge.Target),
+	}
+
+	switch GaugeGoalType(gauge) {
+	case GoalAtLeast:
+		if value < gauge.Target {
+			e.Status = StatusBelow
+		}
+	case GoalRange:
+		if value < gauge.TargetMin {
+			e.Status = StatusBelow
+		} else if value > gauge.Target {
+			e.Status = StatusAbove
+		}
+	case GoalExact:
+		if math.Abs(value-gauge.Target) > exactTolerance {
+			if value < gauge.Target {
+				e.Status = StatusBelow
+			} else {
+				e.Status = StatusAbove
+			}
+		}
+	default:
+		if value > gauge.Target {
+			e.Status = StatusAbove
+		}
+	}
+
+	switch e.Status {
+	case StatusBelow:
+		e.Label = "Below Target"
+		e.Colour = ColourWarning
+	case StatusAbove:
+		e.Label = "Above Target"
+		e.Colour = ColourError
+	default:
+		e.Label = "On Track"
+		e.Colour = ColourSuccess
+	}
+
+	return e
+}
+
+// GoalLabel describes the goal type in words, e.g. "at least"
+func GoalLabel(g GoalType) string {
+	switch g {
+	case GoalAtLeast:
+		return "at least"
+	case GoalRange:
+		return "between"
+	case GoalExact:
+		return "exactly"
+	default:
+		return "at most"
+	}
+}
+
+// FormatTarget formats the gauge's target for display, showing both bounds
+// for ranges
+func FormatTarget(gauge *db.Gauge) string {
+	if GaugeGoalType(gauge) == GoalRange {
+		return FormatValue(gauge, gauge.TargetMin) + "–" + FormatValue(gauge, gauge.Target)
+	}
+	return FormatValue(gauge, gauge.Target)
+}
+
+func percentOf(value, target float64) float64 {
+	if target <= 0 {
+		return 0
+	}
+	return value / target * 100
+}
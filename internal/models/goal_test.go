@@ -0,0 +1,49 @@
+package models
+
+import (
+	"health-monitor/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateGoal(t *testing.T) {
+	tests := []struct {
+		name    string
+		gauge   db.Gauge
+		value   float64
+		status  GoalStatus
+		colour  string
+		percent float64
+	}{
+		{name: "at most under", gauge: db.Gauge{GoalType: "at_most", Target: 10}, value: 4, status: StatusOnTrack, colour: ColourSuccess, percent: 40},
+		{name: "at most on target", gauge: db.Gauge{GoalType: "at_most", Target: 10}, value: 10, status: StatusOnTrack, colour: ColourSuccess, percent: 100},
+		{name: "at most over", gauge: db.Gauge{GoalType: "at_most", Target: 10}, value: 12, status: StatusAbove, colour: ColourError, percent: 120},
+		{name: "unknown goal behaves as at most", gauge: db.Gauge{Target: 10}, value: 12, status: StatusAbove, colour: ColourError, percent: 120},
+		{name: "at least short", gauge: db.Gauge{GoalType: "at_least", Target: 8}, value: 6, status: StatusBelow, colour: ColourWarning, percent: 75},
+		{name: "at least reached", gauge: db.Gauge{GoalType: "at_least", Target: 8}, value: 9, status: StatusOnTrack, colour: ColourSuccess, percent: 112.5},
+		{name: "range below", gauge: db.Gauge{GoalType: "range", TargetMin: 7, Target: 9}, value: 6, status: StatusBelow, colour: ColourWarning},
+		{name: "range inside", gauge: db.Gauge{GoalType: "range", TargetMin: 7, Target: 9}, value: 7, status: StatusOnTrack, colour: ColourSuccess},
+		{name: "range above", gauge: db.Gauge{GoalType: "range", TargetMin: 7, Target: 9}, value: 9.5, status: StatusAbove, colour: ColourError},
+		{name: "exact hit", gauge: db.Gauge{GoalType: "exact", Target: 0.3}, value: 0.1 + 0.2, status: StatusOnTrack, colour: ColourSuccess},
+		{name: "exact short", gauge: db.Gauge{GoalType: "exact", Target: 2}, value: 1, status: StatusBelow, colour: ColourWarning, percent: 50},
+		{name: "exact over", gauge: db.Gauge{GoalType: "exact", Target: 2}, value: 3, status: StatusAbove, colour: ColourError, percent: 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateGoal(&tt.gauge, tt.value)
+			assert.Equal(t, tt.status, got.Status)
+			assert.Equal(t, tt.colour, got.Colour)
+			assert.Equal(t, tt.status == StatusOnTrack, got.OnTrack())
+			if tt.percent != 0 {
+				assert.InDelta(t, tt.percent, got.Percent, 1e-9)
+			}
+		})
+	}
+}
+
+func TestFormatTarget(t *testing.T) {
+	assert.Equal(t, "8.0", FormatTarget(&db.Gauge{GoalType: "at_least", Target: 8}))
+	assert.Equal(t, "7.0–9.0", FormatTarget(&db.Gauge{GoalType: "range", TargetMin: 7, Target: 9}))
+}
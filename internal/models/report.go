@@ -0,0 +1,260 @@
+package models
+
+import (
+	"fmt"
+	"health-monitor/internal/db"
+	"health-monitor/internal/period"
+	"math"
+	"time"
+)
+
+// ReportFrequency is how much time a digest report covers
+type ReportFrequency string
+
+const (
+	ReportWeekly  ReportFrequency = "weekly"
+	ReportMonthly ReportFrequency = "monthly"
+)
+
+// ReportFrequencies lists every report frequency in display order
+var ReportFrequencies = []ReportFrequency{ReportWeekly, ReportMonthly}
+
+// ParseReportFrequency validates a report frequency string
+func ParseReportFrequency(s string) (ReportFrequency, error) {
+	for _, f := range ReportFrequencies {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown report frequency %q", s)
+}
+
+// Noun names the time the frequency covers, e.g. "week"
+func (f ReportFrequency) Noun() string {
+	if f == ReportMonthly {
+		return "month"
+	}
+	return "week"
+}
+
+// streakLookback is how far before a report's end a streak is counted back
+const streakLookback = 365
+
+// ReportRange returns the calendar dates, both inclusive, of the last whole
+// week or month before now: the seven days up to yesterday, or last month.
+// Like a TrendQuery's, the dates are read in each gauge's time zone.
+func ReportRange(f ReportFrequency, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if f == ReportMonthly {
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+		return from, from.AddDate(0, 1, -1)
+	}
+	return today.AddDate(0, 0, -7), today.AddDate(0, 0, -1)
+}
+
+// PreviousRange returns the week or month before the one starting on from
+func PreviousRange(f ReportFrequency, from time.Time) (time.Time, time.Time) {
+	if f == ReportMonthly {
+		return from.AddDate(0, -1, 0), from.AddDate(0, 0, -1)
+	}
+	return from.AddDate(0, 0, -7), from.AddDate(0, 0, -1)
+}
+
+// ReportLabel describes a report's dates, e.g. "Week of 3 Mar 2025"
+func ReportLabel(f ReportFrequency, from time.Time) string {
+	if f == ReportMonthly {
+		return from.Format("January 2006")
+	}
+	return "Week of " + from.Format("2 Jan 2006")
+}
+
+// ReportDay is one day of a gauge report
+type ReportDay struct {
+	Date    time.Time `json:"date"`
+	Value   float64   `json:"value"`
+	OnTrack bool      `json:"on_track"`
+}
+
+// GaugeReport sums up a gauge over a week or month. The target is the
+// gauge's own, scaled to the days the report covers, so a daily gauge of
+// 2000 ml has a weekly target of 14000 ml. Each day is judged against its
+// share of the target in the same way.
+type GaugeReport struct {
+	Gauge *db.Gauge `json:"gauge"`
+	// Value is the total entered over the report's days
+	Value     float64        `json:"value"`
+	Target    float64        `json:"target"`
+	TargetMin float64        `json:"target_min"`
+	Goal      GoalEvaluation `json:"goal"`
+	// Previous is the total over the week or month before, and Change
+	// the percent change from it; Change is nil when Previous is zero
+	Previous float64  `json:"previous"`
+	Change   *float64 `json:"change"`
+	// Streak counts the days on track in a row up to the report's last
+	// day, and LongestStreak the most in a row within the report
+	Streak        int `json:"streak"`
+	LongestStreak int `json:"longest_streak"`
+	// Best and Worst are the days closest to and furthest from the goal.
+	// They are nil when the gauge did not exist on any of the days.
+	Best  *ReportDay  `json:"best"`
+	Worst *ReportDay  `json:"worst"`
+	Days  []ReportDay `json:"days"`
+}
+
+// Report sums up every gauge over a week or month
+type Report struct {
+	Frequency ReportFrequency `json:"frequency"`
+	// From and To are the calendar dates covered, both inclusive
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Label  string         `json:"label"`
+	Gauges []*GaugeReport `json:"gauges"`
+}
+
+// FormatTarget formats the report's target for display, as FormatTarget
+// does a gauge's
+func (r *GaugeReport) FormatTarget() string {
+	return FormatTarget(scaledTarget(r.Gauge, r.Target, r.TargetMin))
+}
+
+// FormatChange formats the change from the period before, e.g. "+12.5%",
+// or returns "" when there is nothing to compare with
+func (r *GaugeReport) FormatChange() string {
+	if r.Change == nil {
+		return ""
+	}
+	return fmt.Sprintf("%+.1f%%", *r.Change)
+}
+
+// ReportValuesFrom returns the first date NewGaugeReport needs values
+// from, which covers the period before and the longest streak counted
+func ReportValuesFrom(f ReportFrequency, from, to time.Time) time.Time {
+	prevFrom, _ := PreviousRange(f, from)
+	return minTime(prevFrom, to.AddDate(0, 0, -streakLookback))
+}
+
+// NewGaugeReport sums up the gauge over the calendar dates from and to.
+// Values must be sorted by date and cover the dates from ReportValuesFrom.
+func NewGaugeReport(gauge *db.Gauge, f ReportFrequency, from, to time.Time, values []db.GaugeValue) *GaugeReport {
+	loc := gaugeLocation(gauge)
+	created := time.Time{}
+	if gauge.CreatedAt.Valid {
+		created = dateIn(gauge.CreatedAt.Time, loc)
+	}
+	prevFrom, prevTo := PreviousRange(f, from)
+
+	q := TrendQuery{
+		Granularity: GranularityDay,
+		Aggregation: AggregationSum,
+		From:        ReportValuesFrom(f, from, to),
+		To:          to,
+	}
+	points := NewTrend(gauge, q, values).Points
+
+	r := &GaugeReport{Gauge: gauge}
+	var share float64
+	best, worst := -1, -1
+	var bestScore, worstScore float64
+	run := 0
+	for _, p := range points {
+		date := dateIn(p.Start, loc)
+		part := dayShare(gauge, p.Start)
+		daily := scaledGauge(gauge, part)
+		onTrack := !date.Before(created) && EvaluateGoal(daily, *p.Value).OnTrack()
+
+		// The streak runs back from the last day, so restart it at each
+		// day off track
+		if onTrack {
+			r.Streak++
+		} else {
+			r.Streak = 0
+		}
+
+		switch {
+		case date.Before(created):
+			continue
+		case !date.Before(from):
+			share += part
+			r.Value += *p.Value
+			r.Days = append(r.Days, ReportDay{Date: date, Value: *p.Value, OnTrack: onTrack})
+
+			if onTrack {
+				run++
+				r.LongestStreak = max(r.LongestStreak, run)
+			} else {
+				run = 0
+			}
+			score := goalScore(daily, *p.Value)
+			if best < 0 || score > bestScore {
+				best, bestScore = len(r.Days)-1, score
+			}
+			if worst < 0 || score < worstScore {
+				worst, worstScore = len(r.Days)-1, score
+			}
+		case !date.Before(prevFrom) && !date.After(prevTo):
+			r.Previous += *p.Value
+		}
+	}
+	if best >= 0 {
+		r.Best, r.Worst = &r.Days[best], &r.Days[worst]
+	}
+
+	scaled := scaledGauge(gauge, share)
+	r.Target, r.TargetMin = scaled.Target, scaled.TargetMin
+	r.Goal = EvaluateGoal(scaled, r.Value)
+	if r.Previous != 0 {
+		change := (r.Value - r.Previous) / math.Abs(r.Previous) * 100
+		r.Change = &change
+	}
+
+	return r
+}
+
+// dayShare is the share of the gauge's target that falls on the day
+// starting at start: one for a daily gauge, a seventh for a weekly one
+func dayShare(gauge *db.Gauge, start time.Time) float64 {
+	p := period.New(gauge.Period, gauge.PeriodDays, gauge.WeekStart, gauge.Timezone)
+	s, e := p.Bounds(start)
+	days := math.Round(e.Sub(s).Hours() / 24)
+	if days < 1 {
+		return 1
+	}
+	return 1 / days
+}
+
+// scaledGauge returns a copy of the gauge with its target multiplied by f
+func scaledGauge(gauge *db.Gauge, f float64) *db.Gauge {
+	scaled := *gauge
+	scaled.Target *= f
+	scaled.TargetMin *= f
+	return &scaled
+}
+
+// scaledTarget returns a copy of the gauge with the given target
+func scaledTarget(gauge *db.Gauge, target, targetMin float64) *db.Gauge {
+	scaled := *gauge
+	scaled.Target, scaled.TargetMin = target, targetMin
+	return &scaled
+}
+
+// goalScore ranks a day's value by the gauge's goal: more is better for at
+// least goals, less for at most, and closer to the target for the rest
+func goalScore(gauge *db.Gauge, value float64) float64 {
+	switch GaugeGoalType(gauge) {
+	case GoalAtLeast:
+		return value
+	case GoalRange:
+		return -math.Abs(value - (gauge.TargetMin+gauge.Target)/2)
+	case GoalExact:
+		return -math.Abs(value - gauge.Target)
+	default:
+		return -value
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql"
+	"health-monitor/internal/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportRange(t *testing.T) {
+	now := time.Date(2025, time.March, 10, 8, 0, 0, 0, time.UTC)
+
+	from, to := ReportRange(ReportWeekly, now)
+	assert.Equal(t, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2025, time.March, 9, 0, 0, 0, 0, time.UTC), to)
+	prevFrom, prevTo := PreviousRange(ReportWeekly, from)
+	assert.Equal(t, time.Date(2025, time.February, 24, 0, 0, 0, 0, time.UTC), prevFrom)
+	assert.Equal(t, time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC), prevTo)
+	assert.Equal(t, "Week of 3 Mar 2025", ReportLabel(ReportWeekly, from))
+
+	from, to = ReportRange(ReportMonthly, now)
+	assert.Equal(t, time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), to)
+	prevFrom, prevTo = PreviousRange(ReportMonthly, from)
+	assert.Equal(t, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), prevFrom)
+	assert.Equal(t, time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), prevTo)
+	assert.Equal(t, "February 2025", ReportLabel(ReportMonthly, from))
+}
+
+func TestNewGaugeReport(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 12, 0, 0, 0, time.UTC) }
+	from, to := ReportRange(ReportWeekly, time.Date(2025, time.March, 10, 8, 0, 0, 0, time.UTC))
+
+	t.Run("daily gauge", func(t *testing.T) {
+		gauge := &db.Gauge{
+			Name:      "Water",
+			Target:    2000,
+			Unit:      "ml",
+			Period:    "day",
+			Timezone:  "UTC",
+			GoalType:  string(GoalAtLeast),
+			CreatedAt: sql.NullTime{Time: day(time.February, 1), Valid: true},
+		}
+		var values []db.GaugeValue
+		for d := 24; d <= 28; d++ {
+			values = append(values, db.GaugeValue{Delta: 2000, Date: day(time.February, d)})
+		}
+		values = append(values, db.GaugeValue{Delta: 2000, Date: day(time.March, 1)}, db.GaugeValue{Delta: 2000, Date: day(time.March, 2)})
+		for i, amount := range []float64{2500, 2000, 1000, 2000, 2000, 2200, 2000} {
+			values = append(values, db.GaugeValue{Delta: amount, Date: day(time.March, 3+i)})
+		}
+
+		r := NewGaugeReport(gauge, ReportWeekly, from, to, values)
+
+		assert.Equal(t, 13700.0, r.Value)
+		assert.InDelta(t, 14000, r.Target, 1e-9)
+		assert.Equal(t, StatusBelow, r.Goal.Status)
+		assert.Equal(t, 14000.0, r.Previous)
+		require.NotNil(t, r.Change)
+		assert.InDelta(t, -2.14, *r.Change, 0.01)
+		assert.Equal(t, 4, r.Streak)
+		assert.Equal(t, 4, r.LongestStreak)
+		require.Len(t, r.Days, 7)
+		assert.False(t, r.Days[2].OnTrack)
+		require.NotNil(t, r.Best)
+		assert.Equal(t, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), r.Best.Date)
+		assert.Equal(t, time.Date(2025, time.March, 5, 0, 0, 0, 0, time.UTC), r.Worst.Date)
+	})
+
+	t.Run("weekly gauge created during the week", func(t *testing.T) {
+		gauge := &db.Gauge{
+			Name:      "Coffee",
+			Target:    21,
+			Unit:      "cups",
+			Period:    "week",
+			WeekStart: 1,
+			Timezone:  "UTC",
+			GoalType:  string(GoalAtMost),
+			CreatedAt: sql.NullTime{Time: day(time.March, 5), Valid: true},
+		}
+		values := []db.GaugeValue{
+			{Delta: 2, Date: day(time.March, 5)},
+			{Delta: 5, Date: day(time.March, 6)},
+			{Delta: 3, Date: day(time.March, 8)},
+		}
+
+		r := NewGaugeReport(gauge, ReportWeekly, from, to, values)
+
+		require.Len(t, r.Days, 5, "days before the gauge existed are left out")
+		assert.Equal(t, 10.0, r.Value)
+		assert.InDelta(t, 15, r.Target, 1e-9)
+		assert.True(t, r.Goal.OnTrack())
+		assert.Nil(t, r.Change)
+		assert.Equal(t, 3, r.Streak)
+		assert.Equal(t, time.Date(2025, time.March, 7, 0, 0, 0, 0, time.UTC), r.Best.Date)
+		assert.Equal(t, time.Date(2025, time.March, 6, 0, 0, 0, 0, time.UTC), r.Worst.Date)
+	})
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"fmt"
+	"health-monitor/internal/db"
+	"health-monitor/internal/period"
+	"math"
+	"time"
+)
+
+// Granularity is the size of the buckets a trend groups values into
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+	GranularityYear  Granularity = "year"
+)
+
+// Granularities lists every supported granularity in display order
+var Granularities = []Granularity{GranularityDay, GranularityWeek, GranularityMonth, GranularityYear}
+
+// Aggregation is the function applied to the amounts logged in a bucket
+type Aggregation string
+
+const (
+	AggregationSum   Aggregation = "sum"
+	AggregationAvg   Aggregation = "avg"
+	AggregationMin   Aggregation = "min"
+	AggregationMax   Aggregation = "max"
+	AggregationCount Aggregation = "count"
+)
+
+// Aggregations lists every supported aggregation in display order
+var Aggregations = []Aggregation{AggregationSum, AggregationAvg, AggregationMin, AggregationMax, AggregationCount}
+
+// maxTrendBuckets caps how many buckets a single trend may contain
+const maxTrendBuckets = 1000
+
+// TrendQuery selects the buckets and aggregation for a trend. From and To
+// are calendar dates in the gauge's time zone and are both inclusive.
+type TrendQuery struct {
+	Granularity Granularity `json:"granularity"`
+	Aggregation Aggregation `json:"aggregation"`
+	From        time.Time   `json:"from"`
+	To          time.Time   `json:"to"`
+}
+
+// TrendPoint is one bucket of a trend. Value is nil for min, max and avg
+// when nothing was logged in the bucket.
+type TrendPoint struct {
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Value *float64  `json:"value"`
+	Count int64     `json:"count"`
+}
+
+// Trend is a gauge's values aggregated into consecutive buckets
+type Trend struct {
+	Gauge  *db.Gauge    `json:"gauge"`
+	Query  TrendQuery   `json:"query"`
+	Points []TrendPoint `json:"points"`
+}
+
+// DefaultTrendQuery returns weekly totals for roughly the last quarter,
+// ending today in the gauge's time zone
+func DefaultTrendQuery(gauge *db.Gauge, now time.Time) TrendQuery {
+	q := TrendQuery{
+		Granularity: GranularityWeek,
+		Aggregation: AggregationSum,
+	}
+	q.To = dateIn(now, gaugeLocation(gauge))
+	q.From = q.DefaultFrom()
+	return q
+}
+
+// DefaultFrom returns a start date that shows a sensible number of buckets
+// before To for the query's granularity
+func (q TrendQuery) DefaultFrom() time.Time {
+	switch q.Granularity {
+	case GranularityDay:
+		return q.To.AddDate(0, 0, -29)
+	case GranularityMonth:
+		return q.To.AddDate(0, -11, 0)
+	case GranularityYear:
+		return q.To.AddDate(-4, 0, 0)
+	default:
+		return q.To.AddDate(0, 0, -7*11)
+	}
+}
+
+// Validate checks the query and returns any errors found
+func (q TrendQuery) Validate() []FieldError {
+	var errors []FieldError
+
+	if !containsValue(Granularities, q.Granularity) {
+		errors = append(errors, FieldError{Field: "granularity", Message: "Granularity must be day, week, month or year"})
+	}
+	if !containsValue(Aggregations, q.Aggregation) {
+		errors = append(errors, FieldError{Field: "aggregation", Message: "Aggregation must be sum, avg, min, max or count"})
+	}
+	if q.To.Before(q.From) {
+		errors = append(errors, FieldError{Field: "to", Message: "End date must not be before start date"})
+	} else if len(errors) == 0 && len(q.buckets(0, time.UTC)) > maxTrendBuckets {
+		errors = append(errors, FieldError{Field: "from", Message: fmt.Sprintf("Date range is too long for %s buckets; choose a coarser granularity", q.Granularity)})
+	}
+
+	return errors
+}
+
+// Range returns the instants covered by the query's buckets in the gauge's
+// time zone, suitable for ListGaugeValuesInRange
+func (q TrendQuery) Range(gauge *db.Gauge) (time.Time, time.Time) {
+	loc := gaugeLocation(gauge)
+	start, _ := q.bucket(gauge.WeekStart, q.From, loc)
+	_, end := q.bucket(gauge.WeekStart, q.To, loc)
+	return start.UTC(), end.UTC()
+}
+
+// NewTrend groups values into the query's buckets and aggregates the amount
+// logged in each one. Values must be sorted by date.
+func NewTrend(gauge *db.Gauge, q TrendQuery, values []db.GaugeValue) *Trend {
+	points := q.buckets(gauge.WeekStart, gaugeLocation(gauge))
+
+	i := 0
+	for p := range points {
+		var sum, lo, hi float64
+		var count int64
+		for ; i < len(values) && values[i].Date.Before(points[p].End); i++ {
+			if values[i].Date.Before(points[p].Start) {
+				continue
+			}
+			amount := values[i].Delta
+			if count == 0 {
+				lo, hi = amount, amount
+			}
+			sum += amount
+			lo = math.Min(lo, amount)
+			hi = math.Max(hi, amount)
+			count++
+		}
+
+		points[p].Count = count
+		switch q.Aggregation {
+		case AggregationSum:
+			points[p].Value = &sum
+		case AggregationCount:
+			n := float64(count)
+			points[p].Value = &n
+		case AggregationAvg:
+			if count > 0 {
+				avg := sum / float64(count)
+				points[p].Value = &avg
+			}
+		case AggregationMin:
+			if count > 0 {
+				points[p].Value = &lo
+			}
+		case AggregationMax:
+			if count > 0 {
+				points[p].Value = &hi
+			}
+		}
+	}
+
+	return &Trend{
+		Gauge:  gauge,
+		Query:  q,
+		Points: points,
+	}
+}
+
+// ComparesToTarget reports whether the trend's values are amounts that can be
+// compared with the gauge's target
+func (t *Trend) ComparesToTarget() bool {
+	return t.Query.Aggregation != AggregationCount
+}
+
+// buckets returns an empty point for each bucket from the one containing
+// From to the one containing To, stopping early once there are too many
+func (q TrendQuery) buckets(weekStart int64, loc *time.Location) []TrendPoint {
+	_, last := q.bucket(weekStart, q.To, loc)
+	start, end := q.bucket(weekStart, q.From, loc)
+
+	var points []TrendPoint
+	for start.Before(last) && len(points) <= maxTrendBuckets {
+		points = append(points, TrendPoint{Label: q.label(start), Start: start, End: end})
+		start, end = q.bucket(weekStart, end, loc)
+	}
+	return points
+}
+
+// bucket returns the bounds of the bucket containing the calendar date of t
+// in loc. Weeks begin on the gauge's configured week start.
+func (q TrendQuery) bucket(weekStart int64, t time.Time, loc *time.Location) (time.Time, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	if q.Granularity == GranularityYear {
+		start := time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(1, 0, 0)
+	}
+
+	p := period.New(string(q.Granularity), 1, weekStart, "")
+	p.Location = loc
+	return p.Bounds(day)
+}
+
+// label formats a bucket's start for display
+func (q TrendQuery) label(start time.Time) string {
+	switch q.Granularity {
+	case GranularityDay:
+		return start.Format("Mon 2 Jan")
+	case GranularityMonth:
+		return start.Format("Jan 2006")
+	case GranularityYear:
+		return start.Format("2006")
+	default:
+		return start.Format("2 Jan 2006")
+	}
+}
+
+// gaugeLocation loads the gauge's time zone, falling back to UTC
+func gaugeLocation(gauge *db.Gauge) *time.Location {
+	return period.New(gauge.Period, gauge.PeriodDays, gauge.WeekStart, gauge.Timezone).Location
+}
+
+// dateIn returns midnight UTC on t's calendar date in loc
+func dateIn(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func containsValue[T comparable](values []T, v T) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"health-monitor/internal/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendQueryValidate(t *testing.T) {
+	march := func(day int) time.Time { return time.Date(2025, time.March, day, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		query  TrendQuery
+		fields []string
+	}{
+		{
+			name:  "valid query",
+			query: TrendQuery{Granularity: GranularityWeek, Aggregation: AggregationSum, From: march(1), To: march(31)},
+		},
+		{
+			name:   "unknown granularity and aggregation",
+			query:  TrendQuery{Granularity: "hour", Aggregation: "median", From: march(1), To: march(31)},
+			fields: []string{"granularity", "aggregation"},
+		},
+		{
+			name:   "reversed range",
+			query:  TrendQuery{Granularity: GranularityDay, Aggregation: AggregationSum, From: march(10), To: march(1)},
+			fields: []string{"to"},
+		},
+		{
+			name:   "too many buckets",
+			query:  TrendQuery{Granularity: GranularityDay, Aggregation: AggregationSum, From: march(1).AddDate(-5, 0, 0), To: march(1)},
+			fields: []string{"from"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range tt.query.Validate() {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestNewTrend(t *testing.T) {
+	gauge := &db.Gauge{ID: 1, Target: 10, Period: "week", WeekStart: 1, Timezone: "UTC"}
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	values := []db.GaugeValue{
+		{Delta: 2, Date: at(time.March, 3, 8)},
+		{Delta: 5, Date: at(time.March, 4, 8)},
+		{Delta: -1, Date: at(time.March, 9, 23)},
+		{Delta: 4, Date: at(time.March, 17, 8)},
+	}
+
+	t.Run("weekly buckets start on the gauge's week start", func(t *testing.T) {
+		// Wednesday to Tuesday spans three Monday-based weeks
+		q := TrendQuery{Granularity: GranularityWeek, Aggregation: AggregationSum, From: at(time.March, 5, 0), To: at(time.March, 18, 0)}
+
+		from, to := q.Range(gauge)
+		assert.Equal(t, at(time.March, 3, 0), from)
+		assert.Equal(t, at(time.March, 24, 0), to)
+
+		trend := NewTrend(gauge, q, values)
+		require.Len(t, trend.Points, 3)
+		assert.Equal(t, "3 Mar 2025", trend.Points[0].Label)
+		assert.Equal(t, 6.0, *trend.Points[0].Value)
+		assert.Equal(t, int64(3), trend.Points[0].Count)
+		assert.Equal(t, 0.0, *trend.Points[1].Value)
+		assert.Equal(t, 4.0, *trend.Points[2].Value)
+	})
+
+	t.Run("aggregations", func(t *testing.T) {
+		// The first week holds 2, 5 and -1; the second week is empty
+		tests := []struct {
+			aggregation Aggregation
+			full        float64
+			empty       *float64
+		}{
+			{AggregationSum, 6, float(0)},
+			{AggregationAvg, 2, nil},
+			{AggregationMin, -1, nil},
+			{AggregationMax, 5, nil},
+			{AggregationCount, 3, float(0)},
+		}
+
+		for _, tt := range tests {
+			t.Run(string(tt.aggregation), func(t *testing.T) {
+				q := TrendQuery{Granularity: GranularityWeek, Aggregation: tt.aggregation, From: at(time.March, 3, 0), To: at(time.March, 10, 0)}
+				trend := NewTrend(gauge, q, values[:3])
+
+				require.Len(t, trend.Points, 2)
+				require.NotNil(t, trend.Points[0].Value)
+				assert.Equal(t, tt.full, *trend.Points[0].Value)
+				assert.Equal(t, tt.empty, trend.Points[1].Value)
+				assert.Equal(t, int64(0), trend.Points[1].Count)
+			})
+		}
+	})
+
+	t.Run("buckets follow the gauge's time zone", func(t *testing.T) {
+		auckland := *gauge
+		auckland.Timezone = "Pacific/Auckland"
+
+		// 8am UTC on 3 March is 9pm in Auckland, 11pm UTC on 9 March is
+		// already 10 March there
+		q := TrendQuery{Granularity: GranularityMonth, Aggregation: AggregationCount, From: at(time.March, 1, 0), To: at(time.March, 1, 0)}
+		trend := NewTrend(&auckland, q, values)
+		require.Len(t, trend.Points, 1)
+		assert.Equal(t, "Mar 2025", trend.Points[0].Label)
+		assert.Equal(t, 4.0, *trend.Points[0].Value)
+
+		q = TrendQuery{Granularity: GranularityDay, Aggregation: AggregationSum, From: at(time.March, 9, 0), To: at(time.March, 10, 0)}
+		from, _ := q.Range(&auckland)
+		assert.Equal(t, time.Date(2025, time.March, 8, 11, 0, 0, 0, time.UTC), from)
+		trend = NewTrend(&auckland, q, values[2:3])
+		assert.Equal(t, 0.0, *trend.Points[0].Value)
+		assert.Equal(t, -1.0, *trend.Points[1].Value)
+	})
+}
+
+func float(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds a whole SMTP conversation when the context has no
+// deadline of its own
+const sendTimeout = 30 * time.Second
+
+// SMTPConfig is the mail server notifications are sent through
+type SMTPConfig struct {
+	Host string
+	// Port is 587 unless set. Port 465 connects over TLS straight away;
+	// on other ports STARTTLS is used when the server offers it.
+	Port     int
+	Username string
+	Password string
+	// From is the sender address
+	From string
+}
+
+// SMTPConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME,
+// SMTP_PASSWORD and SMTP_FROM. Email is off when SMTP_HOST is not set.
+func SMTPConfigFromEnv() (SMTPConfig, error) {
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     587,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if s := os.Getenv("SMTP_PORT"); s != "" {
+		port, err := strconv.Atoi(s)
+		if err != nil || port < 1 || port > 65535 {
+			return cfg, fmt.Errorf("SMTP_PORT: %q is not a port", s)
+		}
+		cfg.Port = port
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	if cfg.Host != "" {
+		if _, err := mail.ParseAddress(cfg.From); err != nil {
+			return cfg, fmt.Errorf("SMTP_FROM: %q is not an email address", cfg.From)
+		}
+	}
+	return cfg, nil
+}
+
+// ParseAddresses reads a comma separated list of email addresses
+func ParseAddresses(s string) ([]string, error) {
+	list, err := mail.ParseAddressList(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a list of email addresses", s)
+	}
+	addresses := make([]string, len(list))
+	for i, a := range list {
+		addresses[i] = a.Address
+	}
+	return addresses, nil
+}
+
+// Email sends each notification as a plain text email
+type Email struct {
+	Config SMTPConfig
+	To     []string
+}
+
+func (e *Email) Notify(ctx context.Context, n Notification) error {
+	return e.Send(ctx, Message{Subject: n.Title, Text: n.Message, Date: n.Time})
+}
+
+// Message is an email with a plain text body and, optionally, an HTML one
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+	// Date is when the message was written, defaulting to now
+	Date time.Time
+}
+
+// Send sends a message to the email's recipients. Messages with HTML are
+// sent as multipart/alternative, so mail clients pick the part they show.
+func (e *Email) Send(ctx context.Context, m Message) error {
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	return e.Config.send(ctx, e.To, buildMessage(e.Config.From, e.To, m))
+}
+
+// buildMessage writes an email. Each part is quoted-printable so any line
+// length and character set gets through.
+func buildMessage(from string, to []string, m Message) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		writeQuotedPrintable(&b, m.Text)
+		return b.Bytes()
+	}
+
+	mw := multipart.NewWriter(&b)
+	header("Content-Type", `multipart/alternative; boundary="`+mw.Boundary()+`"`)
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(w, part.body)
+	}
+	mw.Close()
+	return b.Bytes()
+}
+
+func writeQuotedPrintable(w io.Writer, text string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+}
+
+// send delivers a message to the server, which takes it from there
+func (c SMTPConfig) send(ctx context.Context, to []string, msg []byte) error {
+	if c.Host == "" {
+		return errors.New("email is not configured; set SMTP_HOST")
+	}
+	if len(to) == 0 {
+		return errors.New("the email has no recipients")
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	tlsConfig := &tls.Config{ServerName: c.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{}
+	if c.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if c.Port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
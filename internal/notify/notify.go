@@ -0,0 +1,171 @@
+// Package notify sends notifications to people: by email over SMTP, as a
+// JSON post to a webhook, or as a push through an ntfy server.
+//
+// Each way of sending is a Notifier, so whatever raises a notification does
+// not need to know where it goes. Channels configured on the admin page are
+// turned into notifiers with ForChannel.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Channel kinds
+const (
+	KindEmail   = "email"
+	KindWebhook = "webhook"
+	KindNtfy    = "ntfy"
+)
+
+// Kinds are the channel kinds, in the order the admin page lists them
+var Kinds = []string{KindEmail, KindWebhook, KindNtfy}
+
+// requestTimeout bounds each webhook or ntfy request
+const requestTimeout = 10 * time.Second
+
+// Notification is one message to send
+type Notification struct {
+	// Title is a short summary, used as the email subject
+	Title string
+	// Message is the full text
+	Message string
+	// Tags label the notification, such as the kind of alert
+	Tags []string
+	// Time is when whatever is being notified happened
+	Time time.Time
+}
+
+// Notifier sends notifications somewhere
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// ForChannel returns the notifier for a channel of the given kind. The
+// target is a comma separated list of email addresses for email, and a URL
+// for webhooks and ntfy topics.
+func ForChannel(kind, target string, smtp SMTPConfig) (Notifier, error) {
+	if err := CheckTarget(kind, target); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case KindEmail:
+		if smtp.Host == "" {
+			return nil, errors.New("email is not configured; set SMTP_HOST")
+		}
+		to, _ := ParseAddresses(target)
+		return &Email{Config: smtp, To: to}, nil
+	case KindWebhook:
+		return &Webhook{URL: target}, nil
+	default:
+		return &Ntfy{URL: target}, nil
+	}
+}
+
+// CheckTarget reports whether target suits a channel of the given kind
+func CheckTarget(kind, target string) error {
+	switch kind {
+	case KindEmail:
+		_, err := ParseAddresses(target)
+		return err
+	case KindWebhook, KindNtfy:
+		return checkURL(target)
+	}
+	return fmt.Errorf("unknown channel kind %q", kind)
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", s)
+	}
+	return nil
+}
+
+// Webhook posts each notification as JSON to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// webhookBody is what a Webhook posts
+type webhookBody struct {
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Tags    []string  `json:"tags,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(webhookBody{Title: n.Title, Message: n.Message, Tags: n.Tags, Time: n.Time.UTC()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(w.Client, req)
+}
+
+// Ntfy publishes each notification to an ntfy topic, such as
+// https://ntfy.sh/my-topic. A user and password in the URL are sent as
+// basic auth, for servers that need it.
+type Ntfy struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *Ntfy) Notify(ctx context.Context, note Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(note.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	// Headers are ASCII, so titles with other characters go in the body
+	if isASCII(note.Title) {
+		req.Header.Set("Title", note.Title)
+	}
+	if len(note.Tags) > 0 {
+		req.Header.Set("Tags", strings.Join(note.Tags, ","))
+	}
+	return do(n.Client, req)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// do sends a request, treating any status outside 2xx as an error
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if text := strings.TrimSpace(string(excerpt)); text != "" {
+			return fmt.Errorf("%s: %s", resp.Status, text)
+		}
+		return errors.New(resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var note = Notification{
+	Title:   "Water is behind",
+	Message: "Water is at 20% of its target at 18:00.",
+	Tags:    []string{"below_percent"},
+	Time:    time.Date(2025, time.March, 14, 18, 0, 0, 0, time.UTC),
+}
+
+func TestForChannel(t *testing.T) {
+	smtp := SMTPConfig{Host: "mail.example.com", Port: 587, From: "health@example.com"}
+
+	n, err := ForChannel(KindEmail, "me@example.com, You <you@example.com>", smtp)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"me@example.com", "you@example.com"}, n.(*Email).To)
+
+	_, err = ForChannel(KindEmail, "me@example.com", SMTPConfig{})
+	assert.ErrorContains(t, err, "SMTP_HOST")
+	_, err = ForChannel(KindEmail, "not an address", smtp)
+	assert.Error(t, err)
+	_, err = ForChannel(KindNtfy, "ntfy.sh/topic", smtp)
+	assert.Error(t, err)
+	_, err = ForChannel("pager", "", smtp)
+	assert.Error(t, err)
+}
+
+func TestWebhook(t *testing.T) {
+	var got webhookBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	t.Cleanup(server.Close)
+
+	require.NoError(t, (&Webhook{URL: server.URL}).Notify(context.Background(), note))
+	assert.Equal(t, note.Title, got.Title)
+	assert.Equal(t, note.Message, got.Message)
+	assert.Equal(t, note.Tags, got.Tags)
+	assert.True(t, note.Time.Equal(got.Time))
+}
+
+func TestNtfy(t *testing.T) {
+	status := http.StatusOK
+	var header http.Header
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(status)
+		io.WriteString(w, `{"error":"topic is reserved"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	ntfy := &Ntfy{URL: server.URL + "/health"}
+	require.NoError(t, ntfy.Notify(context.Background(), note))
+	assert.Equal(t, note.Message, body)
+	assert.Equal(t, note.Title, header.Get("Title"))
+	assert.Equal(t, "below_percent", header.Get("Tags"))
+
+	status = http.StatusForbidden
+	err := ntfy.Notify(context.Background(), note)
+	assert.ErrorContains(t, err, "403")
+	assert.ErrorContains(t, err, "topic is reserved")
+}
+
+// smtpServer accepts one message and hands back what it was sent
+func smtpServer(t *testing.T) (SMTPConfig, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ready")
+		var transcript strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return SMTPConfig{Host: host, Port: p, From: "health@example.com"}, received
+}
+
+func TestEmail(t *testing.T) {
+	cfg, received := smtpServer(t)
+	email := &Email{Config: cfg, To: []string{"me@example.com"}}
+	require.NoError(t, email.Notify(context.Background(), note))
+
+	transcript := <-received
+	assert.Contains(t, transcript, "MAIL FROM:<health@example.com>")
+	assert.Contains(t, transcript, "RCPT TO:<me@example.com>")
+	assert.Contains(t, transcript, "Subject: Water is behind\r\n")
+	assert.Contains(t, transcript, "Content-Type: text/plain; charset=utf-8\r\n")
+
+	_, body, _ := strings.Cut(transcript, "\r\n\r\n")
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	assert.Contains(t, string(decoded), note.Message)
+}
+
+func TestEmailHTML(t *testing.T) {
+	cfg, received := smtpServer(t)
+	email := &Email{Config: cfg, To: []string{"me@example.com"}}
+	require.NoError(t, email.Send(context.Background(), Message{
+		Subject: "Week of 3 Mar 2025",
+		Text:    "Water: 13700 ml",
+		HTML:    "<p>Water: <b>13700</b> ml</p>",
+	}))
+
+	_, data, _ := strings.Cut(<-received, "DATA\r\n")
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		bodies = append(bodies, part.Header.Get("Content-Type")+": "+string(body))
+	}
+	assert.Equal(t, []string{
+		"text/plain; charset=utf-8: Water: 13700 ml",
+		"text/html; charset=utf-8: <p>Water: <b>13700</b> ml</p>",
+	}, bodies)
+}
+
+func TestSMTPConfigFromEnv(t *testing.T) {
+	t.Setenv("SMTP_HOST", "mail.example.com")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("SMTP_USERNAME", "health@example.com")
+	t.Setenv("SMTP_PASSWORD", "secret")
+	t.Setenv("SMTP_FROM", "")
+
+	cfg, err := SMTPConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 587, cfg.Port)
+	assert.Equal(t, "health@example.com", cfg.From, "the username is the sender unless one is set")
+
+	t.Setenv("SMTP_PORT", "smtp")
+	_, err = SMTPConfigFromEnv()
+	assert.ErrorContains(t, err, "SMTP_PORT")
+}
@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Builder assembles a Document, deriving component schemas from Go types so
+// the documentation follows the structs the handlers actually encode
+type Builder struct {
+	doc      *Document
+	names    map[reflect.Type]string
+	types    []reflect.Type
+	optional map[reflect.Type]map[string]bool
+}
+
+// NewBuilder starts a document with the given title and version
+func NewBuilder(title, version string) *Builder {
+	return &Builder{
+		doc: &Document{
+			OpenAPI:    Version,
+			Info:       Info{Title: title, Version: version},
+			Paths:      map[string]PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+		names:    map[reflect.Type]string{},
+		optional: map[reflect.Type]map[string]bool{},
+	}
+}
+
+// Register adds a named component schema derived from v. Every field is
+// required unless its JSON tag has omitempty or it is listed in optional.
+func (b *Builder) Register(name string, v any, optional ...string) *Schema {
+	t := indirect(reflect.TypeOf(v))
+	if _, ok := b.names[t]; !ok {
+		b.names[t] = name
+		b.types = append(b.types, t)
+	}
+
+	skip := map[string]bool{}
+	for _, field := range optional {
+		skip[field] = true
+	}
+	b.optional[t] = skip
+
+	return b.Ref(v)
+}
+
+// Ref returns a reference to the component schema registered for v
+func (b *Builder) Ref(v any) *Schema {
+	t := indirect(reflect.TypeOf(v))
+	name, ok := b.names[t]
+	if !ok {
+		panic(fmt.Sprintf("openapi: type %s is not registered", t))
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Add documents an operation for a method and path
+func (b *Builder) Add(method, path string, op *Operation) {
+	path = normalizePath(path)
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = PathItem{}
+		b.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Security adds a security scheme and accepts it, as an alternative to any
+// added before, on every operation
+func (b *Builder) Security(name string, scheme SecurityScheme) {
+	if b.doc.Components.SecuritySchemes == nil {
+		b.doc.Components.SecuritySchemes = map[string]SecurityScheme{}
+	}
+	b.doc.Components.SecuritySchemes[name] = scheme
+	b.doc.Security = append(b.doc.Security, SecurityRequirement{name: {}})
+}
+
+// Document returns the finished document
+func (b *Builder) Document() *Document {
+	for _, t := range b.types {
+		b.doc.Components.Schemas[b.names[t]] = b.structSchema(t)
+	}
+	return b.doc
+}
+
+// schemaFor derives a schema for a Go type, referencing registered structs
+func (b *Builder) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := b.schemaFor(t.Elem())
+		if s.Ref != "" {
+			// OpenAPI 3.0 ignores siblings of $ref, so nullable references
+			// are left as plain references
+			return s
+		}
+		s.Nullable = true
+		return s
+	}
+
+	if name, ok := b.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		return b.structSchema(t)
+	default:
+		// interface{} and friends accept any value
+		return &Schema{}
+	}
+}
+
+// structSchema derives an object schema following encoding/json's rules for
+// field names, omitempty and embedded structs
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	b.addFields(s, t, b.optional[t])
+	return s
+}
+
+func (b *Builder) addFields(s *Schema, t reflect.Type, optional map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Fields of embedded structs are promoted even when the embedded
+		// type itself is unexported
+		ft := indirect(field.Type)
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			b.addFields(s, ft, optional)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = b.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") && !optional[name] {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
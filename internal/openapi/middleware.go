@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// recorder captures the status and body of a response while passing it
+// through to the client
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// ValidateResponses returns chi middleware that checks every response from a
+// documented path prefix against the document and reports mismatches. It
+// must be installed on the root router so the full route pattern is known.
+func (d *Document) ValidateResponses(prefix string, report func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			pattern := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				pattern = rctx.RoutePattern()
+			}
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if err := d.ValidateResponse(r.Method, pattern, status, rec.Header(), rec.body.Bytes()); err != nil {
+				report(r, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package openapi
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testItem struct {
+	ID      int64          `json:"id"`
+	Name    string         `json:"name"`
+	Note    sql.NullString `json:"note"`
+	Created time.Time      `json:"created"`
+	Secret  string         `json:"-"`
+	Tags    []string       `json:"tags,omitempty"`
+}
+
+type testWrapper struct {
+	*testItem
+	Score *float64 `json:"score"`
+}
+
+func testDocument() *Document {
+	b := NewBuilder("Test", "1.0.0")
+	b.Register("Item", testItem{})
+	b.Register("Wrapper", testWrapper{})
+	b.Add(http.MethodGet, "/items/{id}/", &Operation{
+		OperationID: "getItem",
+		Responses: map[string]Response{
+			"200": JSONResponse("Item", b.Ref(testWrapper{})),
+			"204": {Description: "Nothing"},
+		},
+	})
+	return b.Document()
+}
+
+func TestBuilder(t *testing.T) {
+	doc := testDocument()
+
+	item := doc.Components.Schemas["Item"]
+	require.NotNil(t, item)
+	assert.Equal(t, "object", item.Type)
+	assert.Equal(t, []string{"id", "name", "note", "created"}, item.Required)
+	assert.Equal(t, "int64", item.Properties["id"].Format)
+	assert.Equal(t, "date-time", item.Properties["created"].Format)
+	assert.NotContains(t, item.Properties, "Secret")
+	assert.Equal(t, "array", item.Properties["tags"].Type)
+
+	// sql.NullString encodes as an object with String and Valid fields
+	note := item.Properties["note"]
+	assert.Equal(t, "object", note.Type)
+	assert.Contains(t, note.Properties, "String")
+	assert.Contains(t, note.Properties, "Valid")
+
+	// Embedded structs are flattened the way encoding/json does it
+	wrapper := doc.Components.Schemas["Wrapper"]
+	assert.Contains(t, wrapper.Properties, "name")
+	assert.True(t, wrapper.Properties["score"].Nullable)
+
+	// Trailing slashes from chi sub-routers are ignored
+	assert.NotNil(t, doc.Operation("GET", "/items/{id}/"))
+	assert.NotNil(t, doc.Operation("GET", "/items/{id}"))
+	assert.Nil(t, doc.Operation("POST", "/items/{id}"))
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc := testDocument()
+	header := http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}
+
+	valid := `{"id":1,"name":"a","note":{"String":"","Valid":false},"created":"2025-03-01T10:00:00Z","score":null}`
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		err    string
+	}{
+		{name: "valid", status: 200, body: valid},
+		{name: "missing property", status: 200, body: `{"id":1}`, err: "missing required property"},
+		{name: "wrong type", status: 200, body: `{"id":"1","name":"a","note":{"String":"","Valid":false},"created":"2025-03-01T10:00:00Z","score":1}`, err: "$.id: expected integer"},
+		{name: "non-integer", status: 200, body: `{"id":1.5,"name":"a","note":{"String":"","Valid":false},"created":"2025-03-01T10:00:00Z","score":1}`, err: "expected integer"},
+		{name: "bad date", status: 200, body: `{"id":1,"name":"a","note":{"String":"","Valid":false},"created":"yesterday","score":1}`, err: "not a date-time"},
+		{name: "undocumented property", status: 200, body: `{"id":1,"name":"a","note":{"String":"","Valid":false},"created":"2025-03-01T10:00:00Z","score":1,"extra":true}`, err: "undocumented property"},
+		{name: "undocumented status", status: 500, body: `{}`, err: "status 500 is not documented"},
+		{name: "empty response", status: 204, body: ``},
+		{name: "unexpected body", status: 204, body: `{}`, err: "should have no body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateResponse("GET", "/items/{id}", tt.status, header, []byte(tt.body))
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks a decoded JSON value against a schema
+func (d *Document) Validate(schema *Schema, value any) error {
+	return d.validate(schema, value, "$")
+}
+
+func (d *Document) validate(schema *Schema, value any, path string) error {
+	if schema == nil {
+		return nil
+	}
+
+	if schema.Ref != "" {
+		resolved, err := d.resolve(schema.Ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return d.validate(resolved, value, path)
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s, got null", path, schema.Type)
+	}
+
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, schema.Enum)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %s", path, jsonType(value))
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				if len(schema.Properties) > 0 {
+					return fmt.Errorf("%s: undocumented property %q", path, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, obj[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %s", path, jsonType(value))
+		}
+		for i, item := range items {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %s", path, jsonType(value))
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", path, str)
+			}
+		}
+	case "number", "integer":
+		num, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %s", path, schema.Type, jsonType(value))
+		}
+		if schema.Type == "integer" && num != math.Trunc(num) {
+			return fmt.Errorf("%s: expected integer, got %v", path, num)
+		}
+		if schema.Minimum != nil && num < *schema.Minimum {
+			return fmt.Errorf("%s: %v is less than minimum %v", path, num, *schema.Minimum)
+		}
+		if schema.Maximum != nil && num > *schema.Maximum {
+			return fmt.Errorf("%s: %v is greater than maximum %v", path, num, *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %s", path, jsonType(value))
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, schema.Type)
+	}
+
+	return nil
+}
+
+// ValidateResponse checks a response against the operation documented for
+// the method and route pattern
+func (d *Document) ValidateResponse(method, pattern string, status int, header http.Header, body []byte) error {
+	op := d.Operation(method, pattern)
+	if op == nil {
+		return fmt.Errorf("%s %s is not documented", method, pattern)
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s %s: status %d is not documented", method, pattern, status)
+	}
+
+	if len(resp.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return fmt.Errorf("%s %s: status %d should have no body", method, pattern, status)
+		}
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := resp.Content[contentType]
+	if !ok {
+		return fmt.Errorf("%s %s: content type %q is not documented for status %d", method, pattern, contentType, status)
+	}
+
+	// Only JSON bodies are checked against their schema; other documented
+	// content types such as CSV just need the right Content-Type
+	if contentType != "application/json" {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s %s: invalid JSON body: %w", method, pattern, err)
+	}
+
+	if err := d.Validate(media.Schema, value); err != nil {
+		return fmt.Errorf("%s %s (%d): %w", method, pattern, status, err)
+	}
+
+	return nil
+}
+
+func (d *Document) resolve(ref string) (*Schema, error) {
+	name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	schema, ok := d.Components.Schemas[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q", name)
+	}
+	return schema, nil
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,123 @@
+// Package period computes the reporting periods that gauge values roll over on.
+package period
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kind identifies how a gauge's reporting period is measured
+type Kind string
+
+const (
+	Day    Kind = "day"
+	Week   Kind = "week"
+	Month  Kind = "month"
+	Custom Kind = "custom"
+)
+
+// Kinds lists every supported period kind in display order
+var Kinds = []Kind{Day, Week, Month, Custom}
+
+// ParseKind validates a period kind string
+func ParseKind(s string) (Kind, error) {
+	for _, k := range Kinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown period %q", s)
+}
+
+// Period describes a recurring reporting window
+type Period struct {
+	Kind      Kind
+	Days      int
+	WeekStart time.Weekday
+	Location  *time.Location
+}
+
+// New builds a Period from the values stored on a gauge, falling back to
+// weekly periods in UTC for anything it does not recognise
+func New(kind string, days int64, weekStart int64, timezone string) Period {
+	k, err := ParseKind(kind)
+	if err != nil {
+		k = Week
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		loc = time.UTC
+	}
+
+	if days < 1 {
+		days = 1
+	}
+
+	return Period{
+		Kind:      k,
+		Days:      int(days),
+		WeekStart: time.Weekday(((weekStart % 7) + 7) % 7),
+		Location:  loc,
+	}
+}
+
+// Bounds returns the start (inclusive) and end (exclusive) of the period
+// containing t, expressed in the period's location
+func (p Period) Bounds(t time.Time) (time.Time, time.Time) {
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	switch p.Kind {
+	case Day:
+		return midnight, midnight.AddDate(0, 0, 1)
+	case Month:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	case Custom:
+		days := max(p.Days, 1)
+
+		// Custom periods are anchored on the first configured week start
+		// after the Unix epoch so that they line up with the calendar
+		anchor := time.Date(1970, time.January, 1, 0, 0, 0, 0, loc)
+		anchor = anchor.AddDate(0, 0, (int(p.WeekStart)-int(anchor.Weekday())+7)%7)
+
+		elapsed := daysBetween(anchor, midnight)
+		offset := elapsed % days
+		if offset < 0 {
+			offset += days
+		}
+		start := midnight.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, days)
+	default:
+		offset := (int(midnight.Weekday()) - int(p.WeekStart) + 7) % 7
+		start := midnight.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	}
+}
+
+// Label describes the active period in words, e.g. "this week"
+func (p Period) Label() string {
+	switch p.Kind {
+	case Day:
+		return "today"
+	case Month:
+		return "this month"
+	case Custom:
+		return fmt.Sprintf("this %d-day period", max(p.Days, 1))
+	default:
+		return "this week"
+	}
+}
+
+// daysBetween counts calendar days from a to b, ignoring DST shifts
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
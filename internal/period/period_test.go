@@ -0,0 +1,100 @@
+package period
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBounds(t *testing.T) {
+	// Wednesday 2025-03-12 15:30 UTC
+	now := time.Date(2025, time.March, 12, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		p     Period
+		start time.Time
+		end   time.Time
+	}{
+		{
+			name:  "day",
+			p:     New("day", 0, 1, "UTC"),
+			start: time.Date(2025, time.March, 12, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2025, time.March, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "week starting monday",
+			p:     New("week", 0, 1, "UTC"),
+			start: time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2025, time.March, 17, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "week starting sunday",
+			p:     New("week", 0, 0, "UTC"),
+			start: time.Date(2025, time.March, 9, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "month",
+			p:     New("month", 0, 1, "UTC"),
+			start: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "unknown kind falls back to week",
+			p:     New("fortnight", 0, 1, "UTC"),
+			start: time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2025, time.March, 17, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.p.Bounds(now)
+			assert.True(t, tt.start.Equal(start), "start %s, got %s", tt.start, start)
+			assert.True(t, tt.end.Equal(end), "end %s, got %s", tt.end, end)
+		})
+	}
+}
+
+func TestBounds_Custom(t *testing.T) {
+	p := New("custom", 14, 1, "UTC")
+
+	now := time.Date(2025, time.March, 12, 15, 30, 0, 0, time.UTC)
+	start, end := p.Bounds(now)
+
+	assert.Equal(t, time.Monday, start.Weekday())
+	assert.Equal(t, 14*24*time.Hour, end.Sub(start))
+	assert.False(t, now.Before(start))
+	assert.True(t, now.Before(end))
+
+	// Every instant in the window maps to the same period
+	nextStart, _ := p.Bounds(end.Add(-time.Second))
+	assert.True(t, start.Equal(nextStart))
+
+	// The following period starts where this one ends
+	nextStart, _ = p.Bounds(end)
+	assert.True(t, end.Equal(nextStart))
+}
+
+func TestBounds_TimeZone(t *testing.T) {
+	p := New("day", 0, 1, "America/New_York")
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 02:00 UTC is still the previous evening in New York
+	now := time.Date(2025, time.March, 12, 2, 0, 0, 0, time.UTC)
+	start, end := p.Bounds(now)
+
+	assert.True(t, time.Date(2025, time.March, 11, 0, 0, 0, 0, loc).Equal(start))
+	assert.True(t, time.Date(2025, time.March, 12, 0, 0, 0, 0, loc).Equal(end))
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, "today", New("day", 0, 1, "UTC").Label())
+	assert.Equal(t, "this week", New("week", 0, 1, "UTC").Label())
+	assert.Equal(t, "this month", New("month", 0, 1, "UTC").Label())
+	assert.Equal(t, "this 14-day period", New("custom", 14, 1, "UTC").Label())
+}
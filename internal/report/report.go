@@ -0,0 +1,111 @@
+// Package report builds the weekly and monthly digest of every gauge and
+// emails it on a schedule.
+//
+// A report covers the last whole week or month: each gauge's total
+// against its target, the change from the period before, streaks of days
+// on track and the best and worst days. It is rendered both as HTML, from
+// the same templ component the preview page at /admin/reports shows, and
+// as plain text, and sent as one multipart email.
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+	"health-monitor/internal/views/components"
+)
+
+// Store holds the gauges and the values a report is built from
+type Store interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	ListGaugeValuesInRange(ctx context.Context, arg db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error)
+}
+
+// Build sums up every gauge over the last whole week or month before now
+func Build(ctx context.Context, store Store, f models.ReportFrequency, now time.Time) (*models.Report, error) {
+	from, to := models.ReportRange(f, now)
+	r := &models.Report{
+		Frequency: f,
+		From:      from,
+		To:        to,
+		Label:     models.ReportLabel(f, from),
+	}
+
+	gauges, err := store.ListGauges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gauges: %w", err)
+	}
+	for i := range gauges {
+		gauge := &gauges[i]
+		q := models.TrendQuery{
+			Granularity: models.GranularityDay,
+			From:        models.ReportValuesFrom(f, from, to),
+			To:          to,
+		}
+		start, end := q.Range(gauge)
+		values, err := store.ListGaugeValuesInRange(ctx, db.ListGaugeValuesInRangeParams{
+			GaugeID:  gauge.ID,
+			FromDate: start,
+			ToDate:   end,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list values for gauge %d: %w", gauge.ID, err)
+		}
+		r.Gauges = append(r.Gauges, models.NewGaugeReport(gauge, f, from, to, values))
+	}
+	return r, nil
+}
+
+// Subject is the email subject for a report
+func Subject(r *models.Report) string {
+	return "Health Monitor: " + r.Label
+}
+
+// HTML renders a report as a whole HTML document
+func HTML(ctx context.Context, r *models.Report) (string, error) {
+	var b bytes.Buffer
+	if err := components.ReportEmail(r).Render(ctx, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Text renders a report as plain text
+func Text(r *models.Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s to %s\n", r.Label, r.From.Format("Mon 2 Jan"), r.To.Format("Mon 2 Jan 2006"))
+	if len(r.Gauges) == 0 {
+		b.WriteString("\nThere are no gauges to report on.\n")
+	}
+	noun := r.Frequency.Noun()
+
+	for _, g := range r.Gauges {
+		unit := g.Gauge.Unit
+		fmt.Fprintf(&b, "\n%s: %s\n", g.Gauge.Name, g.Goal.Label)
+		fmt.Fprintf(&b, "  %s %s of %s %s %s target, %.0f%%\n",
+			models.FormatValue(g.Gauge, g.Value), unit, models.GoalLabel(models.GaugeGoalType(g.Gauge)), g.FormatTarget(), unit, g.Goal.Percent)
+		if g.Change != nil {
+			fmt.Fprintf(&b, "  Change: %s from %s %s the %s before\n", g.FormatChange(), models.FormatValue(g.Gauge, g.Previous), unit, noun)
+		} else {
+			fmt.Fprintf(&b, "  Change: nothing to compare with the %s before\n", noun)
+		}
+		fmt.Fprintf(&b, "  Streak: %s on track, longest this %s %s\n", days(g.Streak), noun, days(g.LongestStreak))
+		if g.Best != nil {
+			fmt.Fprintf(&b, "  Best day: %s, %s %s\n", g.Best.Date.Format("Mon 2 Jan"), models.FormatValue(g.Gauge, g.Best.Value), unit)
+			fmt.Fprintf(&b, "  Worst day: %s, %s %s\n", g.Worst.Date.Format("Mon 2 Jan"), models.FormatValue(g.Gauge, g.Worst.Value), unit)
+		}
+	}
+	return b.String()
+}
+
+func days(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
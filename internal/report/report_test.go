@@ -0,0 +1,113 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	now := time.Now().UTC()
+	from, _ := models.ReportRange(models.ReportWeekly, now)
+	gauge, err := store.RestoreGauge(ctx, db.RestoreGaugeParams{
+		Name:       "Test Gauge",
+		Target:     100,
+		Unit:       "units",
+		CreatedAt:  sql.NullTime{Time: from.AddDate(0, -1, 0), Valid: true},
+		Period:     "week",
+		PeriodDays: 7,
+		Timezone:   "UTC",
+		GoalType:   "at_most",
+		Step:       1,
+	})
+	require.NoError(t, err)
+
+	// 30 units last week and 60 the week before
+	require.NoError(t, testutil.CreateTestGaugeValue(t, store, gauge.ID, 10, from.Add(12*time.Hour)))
+	require.NoError(t, testutil.CreateTestGaugeValue(t, store, gauge.ID, 20, from.AddDate(0, 0, 2).Add(12*time.Hour)))
+	require.NoError(t, testutil.CreateTestGaugeValue(t, store, gauge.ID, 60, from.AddDate(0, 0, -3).Add(12*time.Hour)))
+
+	r, err := Build(ctx, store, models.ReportWeekly, now)
+	require.NoError(t, err)
+	require.Len(t, r.Gauges, 1)
+	g := r.Gauges[0]
+	assert.Equal(t, 30.0, g.Value)
+	assert.Equal(t, 60.0, g.Previous)
+	assert.Equal(t, "-50.0%", g.FormatChange())
+
+	text := Text(r)
+	assert.Contains(t, text, r.Label)
+	assert.Contains(t, text, "Test Gauge: On Track")
+	assert.Contains(t, text, "Change: -50.0% from 60.0 units the week before")
+
+	html, err := HTML(ctx, r)
+	require.NoError(t, err)
+	assert.Contains(t, html, "<!doctype html>")
+	assert.Contains(t, html, "Test Gauge")
+	assert.Contains(t, html, "color: #16a34a")
+}
+
+type fakeSender struct {
+	sent []notify.Message
+}
+
+func (f *fakeSender) Send(ctx context.Context, m notify.Message) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	sender := &fakeSender{}
+	s := NewScheduler(testutil.NewTestDB(t), Config{To: []string{"me@example.com"}}, notify.SMTPConfig{})
+	s.sender = sender
+
+	r := &models.Report{
+		Frequency: models.ReportMonthly,
+		From:      time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC),
+		Label:     "February 2025",
+		Gauges: []*models.GaugeReport{{
+			Gauge:  &db.Gauge{Name: "Water", Unit: "ml", Target: 56000, GoalType: "at_least"},
+			Value:  50000,
+			Target: 56000,
+			Goal:   models.EvaluateGoal(&db.Gauge{Target: 56000, GoalType: "at_least"}, 50000),
+		}},
+	}
+	require.NoError(t, s.Deliver(context.Background(), r))
+
+	require.Len(t, sender.sent, 1)
+	m := sender.sent[0]
+	assert.Equal(t, "Health Monitor: February 2025", m.Subject)
+	assert.Contains(t, m.Text, "50000.0 ml of at least 56000.0 ml target, 89%")
+	assert.Contains(t, m.Text, "nothing to compare with the month before")
+	assert.Contains(t, m.HTML, "Water")
+
+	s = NewScheduler(testutil.NewTestDB(t), Config{}, notify.SMTPConfig{})
+	assert.ErrorContains(t, s.Deliver(context.Background(), r), "REPORT_EMAIL")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("REPORT_EMAIL", "me@example.com, you@example.com")
+	t.Setenv("REPORT_WEEKLY", "")
+	t.Setenv("REPORT_MONTHLY", "off")
+
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"me@example.com", "you@example.com"}, cfg.To)
+	assert.Equal(t, map[models.ReportFrequency]string{models.ReportWeekly: DefaultWeekly}, cfg.Specs)
+
+	t.Setenv("REPORT_WEEKLY", "every monday")
+	_, err = ConfigFromEnv()
+	assert.ErrorContains(t, err, "REPORT_WEEKLY")
+}
@@ -0,0 +1,161 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"health-monitor/internal/models"
+	"health-monitor/internal/notify"
+	"health-monitor/internal/snapshot"
+)
+
+// Default schedules send the weekly report on Monday morning and the
+// monthly one on the morning of the 1st, server time
+const (
+	DefaultWeekly  = "0 8 * * 1"
+	DefaultMonthly = "0 8 1 * *"
+)
+
+// Config says who reports go to and when
+type Config struct {
+	// To are the recipients. Scheduled reports are off when it is empty.
+	To []string
+	// Schedules holds when each report is sent; a report with no schedule
+	// is not sent
+	Schedules map[models.ReportFrequency]snapshot.Schedule
+	// Specs are the schedules as they were written, for display
+	Specs map[models.ReportFrequency]string
+}
+
+// ConfigFromEnv reads REPORT_EMAIL, a comma separated list of recipients,
+// and REPORT_WEEKLY and REPORT_MONTHLY, schedules in the form BACKUP_SCHEDULE
+// takes. Either schedule may be "off".
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Schedules: map[models.ReportFrequency]snapshot.Schedule{},
+		Specs:     map[models.ReportFrequency]string{},
+	}
+	if s := os.Getenv("REPORT_EMAIL"); s != "" {
+		to, err := notify.ParseAddresses(s)
+		if err != nil {
+			return cfg, fmt.Errorf("REPORT_EMAIL: %w", err)
+		}
+		cfg.To = to
+	}
+
+	for _, env := range []struct {
+		name string
+		f    models.ReportFrequency
+		spec string
+	}{
+		{"REPORT_WEEKLY", models.ReportWeekly, DefaultWeekly},
+		{"REPORT_MONTHLY", models.ReportMonthly, DefaultMonthly},
+	} {
+		spec := env.spec
+		if s := os.Getenv(env.name); s != "" {
+			spec = s
+		}
+		if strings.EqualFold(spec, "off") {
+			continue
+		}
+		schedule, err := snapshot.ParseSchedule(spec)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", env.name, err)
+		}
+		cfg.Schedules[env.f] = schedule
+		cfg.Specs[env.f] = spec
+	}
+	return cfg, nil
+}
+
+// Sender sends an email to the report's recipients
+type Sender interface {
+	Send(ctx context.Context, m notify.Message) error
+}
+
+// Scheduler emails each report when its schedule is due
+type Scheduler struct {
+	store  Store
+	cfg    Config
+	sender Sender
+	now    func() time.Time
+}
+
+// NewScheduler returns a scheduler emailing reports through smtp
+func NewScheduler(store Store, cfg Config, smtp notify.SMTPConfig) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		cfg:    cfg,
+		sender: &notify.Email{Config: smtp, To: cfg.To},
+		now:    time.Now,
+	}
+}
+
+// Recipients returns who reports are sent to
+func (s *Scheduler) Recipients() []string {
+	return s.cfg.To
+}
+
+// Run sends reports on schedule until ctx is cancelled. It returns at once
+// when there are no recipients or schedules.
+func (s *Scheduler) Run(ctx context.Context, onError func(error)) {
+	if len(s.cfg.To) == 0 || len(s.cfg.Schedules) == 0 {
+		return
+	}
+	for {
+		now := s.now()
+		var next time.Time
+		var due []models.ReportFrequency
+		for _, f := range models.ReportFrequencies {
+			schedule, ok := s.cfg.Schedules[f]
+			if !ok {
+				continue
+			}
+			switch t := schedule.Next(now); {
+			case next.IsZero() || t.Before(next):
+				next, due = t, []models.ReportFrequency{f}
+			case t.Equal(next):
+				due = append(due, f)
+			}
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, f := range due {
+			r, err := Build(ctx, s.store, f, next)
+			if err == nil {
+				err = s.Deliver(ctx, r)
+			}
+			if err != nil && onError != nil {
+				onError(fmt.Errorf("%s report: %w", f, err))
+			}
+		}
+	}
+}
+
+// Deliver emails a report to the recipients
+func (s *Scheduler) Deliver(ctx context.Context, r *models.Report) error {
+	if len(s.cfg.To) == 0 {
+		return errors.New("reports have no recipients; set REPORT_EMAIL")
+	}
+	html, err := HTML(ctx, r)
+	if err != nil {
+		return err
+	}
+	return s.sender.Send(ctx, notify.Message{
+		Subject: Subject(r),
+		Text:    Text(r),
+		HTML:    html,
+		Date:    s.now(),
+	})
+}
@@ -0,0 +1,349 @@
+// Package snapshot takes scheduled copies of the live SQLite database.
+//
+// Copies are written with VACUUM INTO, which reads a consistent snapshot
+// through an ordinary connection, so the server keeps serving while a
+// backup is taken. Each copy is checked with PRAGMA integrity_check before
+// it counts, and old copies are pruned by number and age.
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSchedule takes a backup every night at 03:00 server time
+const DefaultSchedule = "0 3 * * *"
+
+// DefaultKeep is how many backups are kept when BACKUP_KEEP is not set
+const DefaultKeep = 7
+
+const (
+	filePrefix = "health-"
+	fileSuffix = ".db"
+	// fileTime sorts in the order the copies were taken
+	fileTime = "20060102-150405"
+)
+
+// Config describes where backups go and which are kept
+type Config struct {
+	// Dir is the directory copies are written to. Scheduled backups are off
+	// when it is empty.
+	Dir string
+	// Schedule says when backups are taken
+	Schedule Schedule
+	// Spec is the schedule as it was written, for display
+	Spec string
+	// Keep is how many copies to keep; 0 keeps every copy
+	Keep int
+	// MaxAge removes copies older than this; 0 keeps them however old.
+	// The newest copy is never removed.
+	MaxAge time.Duration
+}
+
+// ConfigFromEnv reads BACKUP_DIR, BACKUP_SCHEDULE, BACKUP_KEEP and
+// BACKUP_MAX_AGE. The age is a Go duration, or a number of days such as 30d.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Dir: os.Getenv("BACKUP_DIR"), Spec: DefaultSchedule, Keep: DefaultKeep}
+	if spec := os.Getenv("BACKUP_SCHEDULE"); spec != "" {
+		cfg.Spec = spec
+	}
+	schedule, err := ParseSchedule(cfg.Spec)
+	if err != nil {
+		return cfg, fmt.Errorf("BACKUP_SCHEDULE: %w", err)
+	}
+	cfg.Schedule = schedule
+
+	if s := os.Getenv("BACKUP_KEEP"); s != "" {
+		if cfg.Keep, err = strconv.Atoi(s); err != nil || cfg.Keep < 0 {
+			return cfg, fmt.Errorf("BACKUP_KEEP: %q is not a number of backups", s)
+		}
+	}
+	if s := os.Getenv("BACKUP_MAX_AGE"); s != "" {
+		if cfg.MaxAge, err = parseAge(s); err != nil {
+			return cfg, fmt.Errorf("BACKUP_MAX_AGE: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseAge parses a Go duration, allowing a whole number of days as "30d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a duration", s)
+	}
+	return d, nil
+}
+
+// File is one backup copy on disk
+type File struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// Status is what the admin page shows about scheduled backups
+type Status struct {
+	Enabled  bool          `json:"enabled"`
+	Dir      string        `json:"dir"`
+	Schedule string        `json:"schedule"`
+	Keep     int           `json:"keep"`
+	MaxAge   time.Duration `json:"max_age"`
+	// LastRun is when the latest backup was attempted, and LastError why it
+	// failed, if it did
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	// Last is the latest copy that passed its integrity check
+	Last    *File     `json:"last,omitempty"`
+	NextRun time.Time `json:"next_run"`
+	Files   []File    `json:"files"`
+}
+
+// Scheduler takes a backup of the database whenever its schedule is due
+type Scheduler struct {
+	db  *sql.DB
+	cfg Config
+	now func() time.Time
+
+	// run serialises backups so a manual one cannot overlap the schedule
+	run    sync.Mutex
+	mu     sync.Mutex
+	status Status
+}
+
+// NewScheduler returns a scheduler backing up db as cfg describes
+func NewScheduler(db *sql.DB, cfg Config) *Scheduler {
+	return &Scheduler{
+		db:  db,
+		cfg: cfg,
+		now: time.Now,
+		status: Status{
+			Enabled:  cfg.Dir != "",
+			Dir:      cfg.Dir,
+			Schedule: cfg.Spec,
+			Keep:     cfg.Keep,
+			MaxAge:   cfg.MaxAge,
+		},
+	}
+}
+
+// Run takes backups on schedule until ctx is cancelled. It returns at once
+// when no directory is configured.
+func (s *Scheduler) Run(ctx context.Context, onError func(error)) {
+	if s.cfg.Dir == "" {
+		return
+	}
+	if files, err := s.list(); err == nil {
+		s.mu.Lock()
+		s.status.Files = files
+		if len(files) > 0 {
+			s.status.Last = &files[0]
+		}
+		s.mu.Unlock()
+	}
+
+	for {
+		next := s.cfg.Schedule.Next(s.now())
+		s.mu.Lock()
+		s.status.NextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.Backup(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Status reports the latest backup and the copies on disk
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Files = append([]File(nil), s.status.Files...)
+	return status
+}
+
+// Backup copies the database now, checks the copy and prunes old ones. A
+// copy that fails its check is deleted.
+func (s *Scheduler) Backup(ctx context.Context) (File, error) {
+	if s.cfg.Dir == "" {
+		return File{}, errors.New("no backup directory is configured")
+	}
+	s.run.Lock()
+	defer s.run.Unlock()
+
+	start := s.now()
+	file, err := s.backup(ctx, start)
+	files, listErr := s.list()
+	if err == nil && listErr != nil {
+		err = listErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastRun = start
+	s.status.LastDuration = s.now().Sub(start)
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	} else {
+		s.status.Last = &file
+	}
+	if listErr == nil {
+		s.status.Files = files
+	}
+	return file, err
+}
+
+func (s *Scheduler) backup(ctx context.Context, now time.Time) (File, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0o755); err != nil {
+		return File{}, err
+	}
+	name := filePrefix + now.UTC().Format(fileTime) + fileSuffix
+	path := filepath.Join(s.cfg.Dir, name)
+
+	// VACUUM INTO refuses to overwrite, and a copy made the same second is
+	// as good as a new one
+	if _, err := os.Stat(path); err == nil {
+		return File{}, fmt.Errorf("backup %s already exists", name)
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		os.Remove(path)
+		return File{}, fmt.Errorf("copying database: %w", err)
+	}
+	if err := Verify(ctx, s.db, path); err != nil {
+		os.Remove(path)
+		return File{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+	if err := s.prune(now); err != nil {
+		return File{}, fmt.Errorf("pruning backups: %w", err)
+	}
+	return File{Name: name, Size: info.Size(), Created: now.UTC().Truncate(time.Second)}, nil
+}
+
+// Verify runs PRAGMA integrity_check on the database file at path by
+// attaching it to a connection from db
+func Verify(ctx context.Context, db *sql.DB, path string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS snapshot", path); err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE snapshot")
+
+	rows, err := conn.QueryContext(ctx, "PRAGMA snapshot.integrity_check")
+	if err != nil {
+		return fmt.Errorf("checking backup: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup failed its integrity check: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// list returns the backups in the directory, newest first
+func (s *Scheduler) list() ([]File, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), filePrefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, fileSuffix)
+		if !ok {
+			continue
+		}
+		created, err := time.Parse(fileTime, stamp)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: entry.Name(), Size: info.Size(), Created: created})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Created.After(files[j].Created)
+	})
+	return files, nil
+}
+
+// prune removes the copies beyond the configured count and those older than
+// the configured age, always keeping the newest
+func (s *Scheduler) prune(now time.Time) error {
+	files, err := s.list()
+	if err != nil {
+		return err
+	}
+	for i, file := range files {
+		if i == 0 {
+			continue
+		}
+		tooMany := s.cfg.Keep > 0 && i >= s.cfg.Keep
+		tooOld := s.cfg.MaxAge > 0 && now.Sub(file.Created) > s.cfg.MaxAge
+		if tooMany || tooOld {
+			if err := os.Remove(filepath.Join(s.cfg.Dir, file.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2025, time.March, 14, 10, 20, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 3 * * *", time.Date(2025, time.March, 15, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{"30 9-17 * * 1-5", time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2025, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"@every 6h", from.Add(6 * time.Hour)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, schedule.Next(from), tt.spec)
+	}
+
+	for _, spec := range []string{"", "0 3 * *", "60 * * * *", "0 0 31 2 *", "*/0 * * * *", "5-1 * * * *", "@every 1s", "@yearly"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("BACKUP_DIR", "/var/backups")
+	t.Setenv("BACKUP_SCHEDULE", "")
+	t.Setenv("BACKUP_KEEP", "3")
+	t.Setenv("BACKUP_MAX_AGE", "30d")
+
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/backups", cfg.Dir)
+	assert.Equal(t, DefaultSchedule, cfg.Spec)
+	assert.Equal(t, 3, cfg.Keep)
+	assert.Equal(t, 30*24*time.Hour, cfg.MaxAge)
+
+	t.Setenv("BACKUP_MAX_AGE", "a while")
+	_, err = ConfigFromEnv()
+	assert.ErrorContains(t, err, "BACKUP_MAX_AGE")
+}
+
+func TestScheduler_Backup(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Open(db.DefaultConfig(filepath.Join(t.TempDir(), "health.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	_, err = db.Migrate(ctx, database)
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	s := NewScheduler(database, Config{Dir: dir, Spec: DefaultSchedule, Keep: 2, MaxAge: 48 * time.Hour})
+	now := time.Date(2025, time.March, 14, 3, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	t.Run("writes a verified copy", func(t *testing.T) {
+		file, err := s.Backup(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "health-20250314-030000.db", file.Name)
+		assert.Positive(t, file.Size)
+
+		status := s.Status()
+		assert.True(t, status.Enabled)
+		assert.Equal(t, now, status.LastRun)
+		assert.Empty(t, status.LastError)
+		assert.Equal(t, &file, status.Last)
+		assert.Len(t, status.Files, 1)
+
+		require.NoError(t, Verify(ctx, database, filepath.Join(dir, file.Name)))
+	})
+
+	t.Run("prunes by count and age", func(t *testing.T) {
+		old := filepath.Join(dir, "health-20250101-030000.db")
+		require.NoError(t, os.WriteFile(old, nil, 0o644))
+		for i := 0; i < 3; i++ {
+			now = now.Add(time.Hour)
+			_, err := s.Backup(ctx)
+			require.NoError(t, err)
+		}
+
+		status := s.Status()
+		require.Len(t, status.Files, 2)
+		assert.Equal(t, "health-20250314-060000.db", status.Files[0].Name)
+		assert.Equal(t, "health-20250314-050000.db", status.Files[1].Name)
+		assert.NoFileExists(t, old)
+	})
+
+	t.Run("reports a corrupt copy", func(t *testing.T) {
+		corrupt := filepath.Join(t.TempDir(), "corrupt.db")
+		require.NoError(t, os.WriteFile(corrupt, []byte("not a database at all, just some text padding it out"), 0o644))
+		assert.Error(t, Verify(ctx, database, corrupt))
+	})
+
+	t.Run("same second fails", func(t *testing.T) {
+		_, err := s.Backup(ctx)
+		require.Error(t, err)
+		status := s.Status()
+		assert.Contains(t, status.LastError, "already exists")
+		assert.Equal(t, "health-20250314-060000.db", status.Last.Name)
+	})
+}
+
+func TestScheduler_Disabled(t *testing.T) {
+	s := NewScheduler(nil, Config{})
+	assert.False(t, s.Status().Enabled)
+	_, err := s.Backup(context.Background())
+	assert.Error(t, err)
+
+	// Run returns at once without a directory
+	done := make(chan struct{})
+	go func() {
+		s.Run(context.Background(), nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return")
+	}
+}
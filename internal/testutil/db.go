@@ -8,12 +8,10 @@ import (
 	"time"
 
 	"health-monitor/internal/db"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// NewTestDB creates a new test database and returns a Queries instance
-func NewTestDB(t *testing.T) *db.Queries {
+// NewTestDB creates a new test database and returns a Store instance
+func NewTestDB(t *testing.T) *db.Store {
 	// Create a temporary database file
 	f, err := os.CreateTemp("", "test.db")
 	if err != nil {
@@ -22,33 +20,34 @@ func NewTestDB(t *testing.T) *db.Queries {
 	t.Cleanup(func() { os.Remove(f.Name()) })
 
 	// Open the database
-	database, err := sql.Open("sqlite3", f.Name())
+	database, err := db.Open(db.DefaultConfig(f.Name()))
 	if err != nil {
 		t.Fatalf("Failed to open database: %v", err)
 	}
 	t.Cleanup(func() { database.Close() })
 
 	// Create the schema
-	schema, err := os.ReadFile("../db/schema.sql")
-	if err != nil {
-		t.Fatalf("Failed to read schema: %v", err)
-	}
-
-	if _, err := database.Exec(string(schema)); err != nil {
-		t.Fatalf("Failed to create schema: %v", err)
+	if _, err := db.Migrate(context.Background(), database); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
 	}
 
-	return db.New(database)
+	return db.NewStore(database)
 }
 
 // CreateTestGauge creates a test gauge and returns it
-func CreateTestGauge(t *testing.T, q *db.Queries) db.Gauge {
+func CreateTestGauge(t *testing.T, q db.Querier) db.Gauge {
 	params := db.CreateGaugeParams{
 		Name:        "Test Gauge",
 		Description: sql.NullString{String: "Test Description", Valid: true},
 		Target:      100,
 		Unit:        "units",
 		Icon:        "star",
+		Period:      "week",
+		PeriodDays:  7,
+		WeekStart:   1,
+		Timezone:    "UTC",
+		GoalType:    "at_most",
+		Step:        1,
 	}
 
 	gauge, err := q.CreateGauge(context.Background(), params)
@@ -60,10 +59,12 @@ func CreateTestGauge(t *testing.T, q *db.Queries) db.Gauge {
 }
 
 // CreateTestGaugeValue creates a test gauge value
-func CreateTestGaugeValue(t *testing.T, q *db.Queries, gaugeID int64, value float64, date time.Time) error {
+func CreateTestGaugeValue(t *testing.T, q db.Querier, gaugeID int64, value float64, date time.Time) error {
 	params := db.CreateGaugeValueParams{
 		GaugeID: gaugeID,
-		Column2: value,
+		Value:   value,
+		Delta:   value,
+		Source:  db.SourceWeb,
 		Date:    date,
 	}
 
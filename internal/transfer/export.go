@@ -0,0 +1,163 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+)
+
+// Exporter is the part of the store an export reads from
+type Exporter interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	ListGaugeValuesInRange(ctx context.Context, params db.ListGaugeValuesInRangeParams) ([]db.GaugeValue, error)
+}
+
+// exportEnd is after any date a value can be logged at
+var exportEnd = time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Export writes every gauge, or only the gauge with gaugeID when it is not
+// zero, as the kind of file the options ask for
+func Export(ctx context.Context, store Exporter, w io.Writer, opts Options, gaugeID int64) error {
+	gauges, err := store.ListGauges(ctx)
+	if err != nil {
+		return err
+	}
+	if gaugeID != 0 {
+		gauges = filterGauge(gauges, gaugeID)
+	}
+
+	if opts.Kind == KindGauges {
+		return WriteGauges(w, gauges, opts)
+	}
+
+	values := make(map[int64][]db.GaugeValue, len(gauges))
+	for _, gauge := range gauges {
+		values[gauge.ID], err = store.ListGaugeValuesInRange(ctx, db.ListGaugeValuesInRangeParams{
+			GaugeID:  gauge.ID,
+			FromDate: time.Time{},
+			ToDate:   exportEnd,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return WriteValues(w, gauges, values, opts)
+}
+
+func filterGauge(gauges []db.Gauge, id int64) []db.Gauge {
+	for _, gauge := range gauges {
+		if gauge.ID == id {
+			return []db.Gauge{gauge}
+		}
+	}
+	return nil
+}
+
+// WriteGauges writes one row per gauge with its settings
+func WriteGauges(w io.Writer, gauges []db.Gauge, opts Options) error {
+	opts.Kind = KindGauges
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header(opts)); err != nil {
+		return err
+	}
+
+	for i := range gauges {
+		gauge := &gauges[i]
+		err := cw.Write([]string{
+			gauge.Name,
+			gauge.Description.String,
+			gauge.Icon,
+			gauge.Unit,
+			formatNumber(gauge.Target),
+			string(models.GaugeGoalType(gauge)),
+			formatNumber(gauge.TargetMin),
+			gauge.Period,
+			strconv.FormatInt(gauge.PeriodDays, 10),
+			strconv.FormatInt(gauge.WeekStart, 10),
+			gauge.Timezone,
+			formatNumber(models.GaugeStep(gauge)),
+			models.JoinPresets(models.GaugePresets(gauge)),
+			formatNullNumber(gauge.MinValue.Float64, gauge.MinValue.Valid),
+			formatNullNumber(gauge.MaxValue.Float64, gauge.MaxValue.Valid),
+			strconv.FormatInt(gauge.Decimals, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteValues writes one row per change logged to the gauges, oldest first
+// for each gauge. Amounts are converted to the options' unit when one is set.
+func WriteValues(w io.Writer, gauges []db.Gauge, values map[int64][]db.GaugeValue, opts Options) error {
+	opts.Kind = KindValues
+	layout := opts.Layout()
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header(opts)); err != nil {
+		return err
+	}
+
+	for i := range gauges {
+		gauge := &gauges[i]
+		unit := gauge.Unit
+		if opts.Unit != "" {
+			unit = opts.Unit
+		}
+		loc, err := time.LoadLocation(gauge.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		for _, value := range values[gauge.ID] {
+			amount, err := ConvertUnit(value.Delta, gauge.Unit, unit)
+			if err != nil {
+				return fmt.Errorf("gauge %s: %w", gauge.Name, err)
+			}
+			err = cw.Write([]string{
+				gauge.Name,
+				value.Date.In(loc).Format(layout),
+				formatNumber(amount),
+				unit,
+				value.Source,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// header returns the column headers for the options' fields
+func header(opts Options) []string {
+	fields := opts.Fields()
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = opts.Column(field)
+	}
+	return columns
+}
+
+// formatNumber formats a number with as many digits as it needs to be read
+// back exactly
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatNullNumber(f float64, valid bool) string {
+	if !valid {
+		return ""
+	}
+	return formatNumber(f)
+}
@@ -0,0 +1,412 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/models"
+)
+
+var (
+	// ErrInvalidFile is returned when a file cannot be read as CSV or is
+	// missing a required column
+	ErrInvalidFile = errors.New("invalid file")
+	// ErrInvalidRows is returned with the report when some rows are invalid.
+	// Nothing is imported until every row is valid.
+	ErrInvalidRows = errors.New("some rows are invalid")
+)
+
+// DefaultIcon is given to imported gauges without an icon
+const DefaultIcon = "chart-bar"
+
+// Importer is the part of the store an import writes to
+type Importer interface {
+	ListGauges(ctx context.Context) ([]db.Gauge, error)
+	ImportGauges(ctx context.Context, gauges []db.CreateGaugeParams, dryRun bool) (db.ImportResult, error)
+	ImportGaugeValues(ctx context.Context, values []db.ImportedValue, dryRun bool) (db.ImportResult, error)
+}
+
+// Status is what happened to a row of an import
+type Status string
+
+const (
+	// StatusReady rows would be imported; a dry run leaves them ready
+	StatusReady Status = "ready"
+	// StatusImported rows were imported
+	StatusImported Status = "imported"
+	// StatusDuplicate rows were skipped because they are already recorded
+	StatusDuplicate Status = "duplicate"
+	// StatusInvalid rows have errors and stop the import
+	StatusInvalid Status = "invalid"
+)
+
+// Row is one parsed row of an import file. Date and Amount are only set for
+// values, converted to the gauge's unit.
+type Row struct {
+	Line   int                 `json:"line"`
+	Gauge  string              `json:"gauge"`
+	Date   *time.Time          `json:"date,omitempty"`
+	Amount *float64            `json:"amount,omitempty"`
+	Unit   string              `json:"unit"`
+	Status Status              `json:"status"`
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// Report describes an import, or a preview of one when DryRun is set. In a
+// preview Imported counts the rows that would be imported.
+type Report struct {
+	Kind       Kind  `json:"kind"`
+	DryRun     bool  `json:"dry_run"`
+	Rows       []Row `json:"rows"`
+	Imported   int   `json:"imported"`
+	Duplicates int   `json:"duplicates"`
+	Invalid    int   `json:"invalid"`
+}
+
+// Import reads a file of the options' kind and imports its rows. Rows already
+// recorded are reported as duplicates and skipped, so a file can be imported
+// again safely. If any row is invalid nothing is imported: the report is
+// returned as a dry run with ErrInvalidRows, unless a dry run was asked for.
+func Import(ctx context.Context, store Importer, r io.Reader, opts Options, dryRun bool) (*Report, error) {
+	file, err := readFile(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	gauges, err := store.ListGauges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Kind: opts.Kind, DryRun: dryRun}
+	var result db.ImportResult
+	var imported []int
+	if opts.Kind == KindGauges {
+		var params []db.CreateGaugeParams
+		report.Rows, params, imported = parseGauges(file)
+		report.countInvalid()
+		if report.Invalid > 0 {
+			report.DryRun = true
+		}
+		result, err = store.ImportGauges(ctx, params, report.DryRun)
+	} else {
+		var values []db.ImportedValue
+		report.Rows, values, imported = parseValues(file, opts, gauges, time.Now())
+		report.countInvalid()
+		if report.Invalid > 0 {
+			report.DryRun = true
+		}
+		result, err = store.ImportGaugeValues(ctx, values, report.DryRun)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report.apply(result, imported)
+	if report.Invalid > 0 && !dryRun {
+		return report, ErrInvalidRows
+	}
+	return report, nil
+}
+
+// countInvalid counts the rows with errors
+func (r *Report) countInvalid() {
+	for _, row := range r.Rows {
+		if row.Status == StatusInvalid {
+			r.Invalid++
+		}
+	}
+}
+
+// apply marks the rows the store imported or skipped. Items are the indexes
+// of the rows passed to the store, in the order they were passed.
+func (r *Report) apply(result db.ImportResult, items []int) {
+	status := StatusImported
+	if r.DryRun {
+		status = StatusReady
+	}
+	for _, i := range items {
+		r.Rows[i].Status = status
+	}
+	for _, item := range result.Duplicates {
+		r.Rows[items[item]].Status = StatusDuplicate
+	}
+	r.Imported = result.Imported
+	r.Duplicates = len(result.Duplicates)
+}
+
+// file is a parsed CSV file with its columns resolved to fields
+type file struct {
+	columns map[string]int
+	records []record
+}
+
+type record struct {
+	line   int
+	fields []string
+}
+
+// get returns the named field of a record, or "" when the file has no column
+// for it
+func (f *file) get(rec record, field string) string {
+	i, ok := f.columns[field]
+	if !ok || i >= len(rec.fields) {
+		return ""
+	}
+	return strings.TrimSpace(rec.fields[i])
+}
+
+func (f *file) has(field string) bool {
+	_, ok := f.columns[field]
+	return ok
+}
+
+// readFile reads the header and records of a file. Headers are matched to
+// fields ignoring case.
+func readFile(r io.Reader, opts Options) (*file, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	headers := make(map[string]int, len(header))
+	for i, column := range header {
+		// Spreadsheets often start files with a byte order mark
+		column = strings.TrimPrefix(column, "\ufeff")
+		headers[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+
+	f := &file{columns: make(map[string]int)}
+	for _, field := range opts.Fields() {
+		if i, ok := headers[strings.ToLower(opts.Column(field))]; ok {
+			f.columns[field] = i
+		}
+	}
+	for _, field := range requiredFields[opts.Kind] {
+		if !f.has(field) {
+			return nil, fmt.Errorf("%w: missing the %q column", ErrInvalidFile, opts.Column(field))
+		}
+	}
+	if opts.Kind == KindValues && !f.has("gauge") && opts.Gauge == "" {
+		return nil, fmt.Errorf("%w: missing the %q column; choose a gauge for the whole file instead", ErrInvalidFile, opts.Column("gauge"))
+	}
+
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		line, _ := cr.FieldPos(0)
+		if isBlank(fields) {
+			continue
+		}
+		f.records = append(f.records, record{line: line, fields: fields})
+	}
+
+	return f, nil
+}
+
+func isBlank(fields []string) bool {
+	for _, field := range fields {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseValues turns the records of a values file into rows and the values to
+// import, returning the indexes of the rows the values came from. Amounts are
+// checked against the gauge's entry settings once converted to its unit, as
+// amounts entered on the card are; a conversion is first rounded to the
+// decimal places the gauge allows.
+func parseValues(f *file, opts Options, gauges []db.Gauge, now time.Time) ([]Row, []db.ImportedValue, []int) {
+	byName := make(map[string]*db.Gauge, len(gauges))
+	for i := range gauges {
+		byName[strings.ToLower(gauges[i].Name)] = &gauges[i]
+	}
+	layout := opts.Layout()
+
+	rows := make([]Row, len(f.records))
+	var values []db.ImportedValue
+	var indexes []int
+	for i, rec := range f.records {
+		row := Row{Line: rec.line, Gauge: f.get(rec, "gauge")}
+		if row.Gauge == "" {
+			row.Gauge = opts.Gauge
+		}
+
+		gauge := byName[strings.ToLower(row.Gauge)]
+		if gauge == nil {
+			row.addError("gauge", fmt.Sprintf("Gauge %q does not exist", row.Gauge))
+		} else {
+			row.Gauge = gauge.Name
+			row.Unit = gauge.Unit
+		}
+
+		loc := time.UTC
+		if gauge != nil {
+			if l, err := time.LoadLocation(gauge.Timezone); err == nil {
+				loc = l
+			}
+		}
+		date, err := time.ParseInLocation(layout, f.get(rec, "date"), loc)
+		if err != nil {
+			row.addError("date", fmt.Sprintf("Date must match the format %s", layout))
+		} else if date.After(now) {
+			row.addError("date", "Date cannot be in the future")
+		} else {
+			row.Date = &date
+		}
+
+		amount, err := strconv.ParseFloat(f.get(rec, "amount"), 64)
+		if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+			row.addError("amount", "Amount must be a valid number")
+		} else if gauge != nil {
+			unit := f.get(rec, "unit")
+			if unit == "" {
+				unit = opts.Unit
+			}
+			converted, err := ConvertUnit(amount, unit, gauge.Unit)
+			if err != nil {
+				row.addError("unit", fmt.Sprintf("Cannot convert %s to %s", unit, gauge.Unit))
+			} else {
+				if converted != amount {
+					scale := math.Pow10(int(gauge.Decimals))
+					converted = math.Round(converted*scale) / scale
+				}
+				if fieldErr := models.ValidateAmount(gauge, "amount", "Amount", converted); fieldErr != nil {
+					row.addError(fieldErr.Field, fieldErr.Message)
+				} else {
+					row.Amount = &converted
+				}
+			}
+		}
+
+		if len(row.Errors) == 0 {
+			values = append(values, db.ImportedValue{
+				GaugeID: gauge.ID,
+				Delta:   *row.Amount,
+				Date:    date,
+				Source:  f.get(rec, "source"),
+			})
+			indexes = append(indexes, i)
+		}
+		rows[i] = row
+	}
+
+	return rows, values, indexes
+}
+
+// parseGauges turns the records of a gauges file into rows and the gauges to
+// create, returning the indexes of the rows the gauges came from. Settings
+// left blank take the same defaults as the gauge form.
+func parseGauges(f *file) ([]Row, []db.CreateGaugeParams, []int) {
+	rows := make([]Row, len(f.records))
+	var params []db.CreateGaugeParams
+	var indexes []int
+	for i, rec := range f.records {
+		input := models.DefaultGaugeInput()
+		input.Name = f.get(rec, "name")
+		input.Description = f.get(rec, "description")
+		input.Icon = f.get(rec, "icon")
+		if input.Icon == "" {
+			input.Icon = DefaultIcon
+		}
+		input.Unit = f.get(rec, "unit")
+		row := Row{Line: rec.line, Gauge: input.Name, Unit: input.Unit}
+
+		parseFloat := func(field, label string, dst *float64) {
+			if s := f.get(rec, field); s != "" {
+				v, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					row.addError(field, label+" must be a valid number")
+				}
+				*dst = v
+			}
+		}
+		parseInt := func(field, label string, dst *int64) {
+			if s := f.get(rec, field); s != "" {
+				v, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					row.addError(field, label+" must be a whole number")
+				}
+				*dst = v
+			}
+		}
+		parseOptional := func(field, label string) *float64 {
+			if f.get(rec, field) == "" {
+				return nil
+			}
+			var v float64
+			parseFloat(field, label, &v)
+			return &v
+		}
+
+		if f.get(rec, "target") == "" {
+			row.addError("target", "Target must be a valid number")
+		}
+		parseFloat("target", "Target", &input.Target)
+		if goalType := f.get(rec, "goal_type"); goalType != "" {
+			input.GoalType = goalType
+		}
+		parseFloat("target_min", "Minimum", &input.TargetMin)
+		if p := f.get(rec, "period"); p != "" {
+			input.Period = p
+		}
+		parseInt("period_days", "Period length", &input.PeriodDays)
+		parseInt("week_start", "Week start", &input.WeekStart)
+		if tz := f.get(rec, "timezone"); tz != "" {
+			input.Timezone = tz
+		}
+		parseFloat("step", "Step", &input.Step)
+		presets, err := models.ParsePresets(f.get(rec, "presets"))
+		if err != nil {
+			row.addError("presets", "Quick-add amounts must be numbers separated by commas")
+		}
+		input.Presets = presets
+		input.MinValue = parseOptional("min_value", "Lowest value")
+		input.MaxValue = parseOptional("max_value", "Highest value")
+		if f.get(rec, "decimals") != "" {
+			var decimals int64
+			parseInt("decimals", "Decimal places", &decimals)
+			input.Decimals = &decimals
+		}
+
+		if len(row.Errors) == 0 {
+			for _, fieldErr := range input.Validate() {
+				row.addError(fieldErr.Field, fieldErr.Message)
+			}
+		}
+		if len(row.Errors) == 0 {
+			params = append(params, input.CreateParams())
+			indexes = append(indexes, i)
+		}
+		rows[i] = row
+	}
+
+	return rows, params, indexes
+}
+
+func (r *Row) addError(field, message string) {
+	r.Errors = append(r.Errors, models.FieldError{Field: field, Message: message})
+	r.Status = StatusInvalid
+}
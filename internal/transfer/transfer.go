@@ -0,0 +1,167 @@
+// Package transfer moves gauges and their history in and out of the app as
+// CSV files.
+package transfer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"health-monitor/internal/models"
+)
+
+// Kind is what the rows of a CSV file describe
+type Kind string
+
+const (
+	// KindGauges files have one gauge definition per row
+	KindGauges Kind = "gauges"
+	// KindValues files have one logged change per row
+	KindValues Kind = "values"
+)
+
+// Kinds lists every kind of file in display order
+var Kinds = []Kind{KindValues, KindGauges}
+
+// Fields of each kind, in the order they are exported
+var (
+	GaugeFields = []string{
+		"name", "description", "icon", "unit", "target", "goal_type", "target_min",
+		"period", "period_days", "week_start", "timezone",
+		"step", "presets", "min_value", "max_value", "decimals",
+	}
+	ValueFields = []string{"gauge", "date", "amount", "unit", "source"}
+)
+
+// ColumnFields lists the fields of every kind of file once each
+func ColumnFields() []string {
+	fields := append([]string{}, ValueFields...)
+	for _, field := range GaugeFields {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// requiredFields must have a column when importing. Values may leave out the
+// gauge column when Options.Gauge names the gauge instead.
+var requiredFields = map[Kind][]string{
+	KindGauges: {"name", "unit", "target"},
+	KindValues: {"date", "amount"},
+}
+
+// DateFormat is a named layout for the date column
+type DateFormat struct {
+	Name    string
+	Example string
+	Layout  string
+}
+
+// DateFormats lists the date formats offered by name. Any other Go time
+// layout can be given as well.
+var DateFormats = []DateFormat{
+	{Name: "rfc3339", Example: "2024-03-01T08:30:00Z", Layout: time.RFC3339Nano},
+	{Name: "datetime", Example: "2024-03-01 08:30:00", Layout: "2006-01-02 15:04:05"},
+	{Name: "date", Example: "2024-03-01", Layout: "2006-01-02"},
+	{Name: "dmy", Example: "01/03/2024", Layout: "02/01/2006"},
+	{Name: "mdy", Example: "03/01/2024", Layout: "01/02/2006"},
+}
+
+// DefaultDateFormat keeps timestamps exactly, so exported values are found
+// as duplicates when imported again
+const DefaultDateFormat = "rfc3339"
+
+// Options control how a file is read or written
+type Options struct {
+	Kind Kind
+	// Columns maps fields to the headers of the columns holding them.
+	// Fields left out use their own name.
+	Columns map[string]string
+	// DateFormat is the name of one of DateFormats, or a Go time layout.
+	// Dates without a time zone are in the gauge's time zone.
+	DateFormat string
+	// Unit is the unit amounts in the file are in, converted to and from
+	// each gauge's unit. Empty means each gauge's own unit. When reading, a
+	// row's unit column takes precedence.
+	Unit string
+	// Gauge names the gauge every row of a values file belongs to when the
+	// file has no gauge column
+	Gauge string
+}
+
+// DefaultOptions returns options for a values file with the default columns
+// and date format
+func DefaultOptions() Options {
+	return Options{Kind: KindValues, DateFormat: DefaultDateFormat}
+}
+
+// Fields returns the fields of the options' kind
+func (o Options) Fields() []string {
+	if o.Kind == KindGauges {
+		return GaugeFields
+	}
+	return ValueFields
+}
+
+// Column returns the header of the column holding field
+func (o Options) Column(field string) string {
+	if column := strings.TrimSpace(o.Columns[field]); column != "" {
+		return column
+	}
+	return field
+}
+
+// Layout returns the time layout for the date column
+func (o Options) Layout() string {
+	for _, format := range DateFormats {
+		if format.Name == o.DateFormat {
+			return format.Layout
+		}
+	}
+	if o.DateFormat == "" {
+		return time.RFC3339Nano
+	}
+	return o.DateFormat
+}
+
+// Validate checks the options and returns one error per invalid field
+func (o Options) Validate() []models.FieldError {
+	var errors []models.FieldError
+
+	if o.Kind != KindGauges && o.Kind != KindValues {
+		errors = append(errors, models.FieldError{Field: "kind", Message: "Kind must be values or gauges"})
+		return errors
+	}
+
+	// A layout that formats a fixed date the same way as the next day, or
+	// the same day next year, is missing part of the date and cannot be read
+	// back
+	layout := o.Layout()
+	day := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	if day.Format(layout) == day.AddDate(0, 0, 1).Format(layout) || day.Format(layout) == day.AddDate(1, 0, 0).Format(layout) {
+		errors = append(errors, models.FieldError{Field: "date_format", Message: "Date format must include the day, month and year"})
+	}
+
+	if o.Unit != "" {
+		if _, ok := unitFactors[normalizeUnit(o.Unit)]; !ok {
+			errors = append(errors, models.FieldError{Field: "unit", Message: fmt.Sprintf("Unit must be one of %s, or blank", strings.Join(KnownUnits(), ", "))})
+		}
+	}
+
+	seen := make(map[string]string)
+	for _, field := range o.Fields() {
+		column := strings.ToLower(o.Column(field))
+		if other, ok := seen[column]; ok {
+			errors = append(errors, models.FieldError{
+				Field:   "column_" + field,
+				Message: fmt.Sprintf("Columns for %s and %s must be different", other, field),
+			})
+			continue
+		}
+		seen[column] = field
+	}
+
+	return errors
+}
@@ -0,0 +1,241 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/testutil"
+	"health-monitor/internal/transfer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := testutil.NewTestDB(t)
+	// Imported amounts are checked against the gauge's decimal places, which
+	// the gauges file carries over
+	gauge, err := source.CreateGauge(ctx, db.CreateGaugeParams{
+		Name: "Test Gauge", Description: sql.NullString{String: "Test Description", Valid: true},
+		Target: 100, Unit: "units", Icon: "star", Period: "week", PeriodDays: 7,
+		WeekStart: 1, Timezone: "UTC", GoalType: "at_most", Step: 1, Decimals: 1,
+	})
+	require.NoError(t, err)
+	_, err = source.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 2.5, Source: db.SourceWeb})
+	require.NoError(t, err)
+	_, err = source.RecordGaugeValue(ctx, db.RecordGaugeValueParams{GaugeID: gauge.ID, Delta: 1, Source: db.SourceAPI})
+	require.NoError(t, err)
+
+	var gaugesCSV, valuesCSV bytes.Buffer
+	require.NoError(t, transfer.Export(ctx, source, &gaugesCSV, transfer.Options{Kind: transfer.KindGauges}, 0))
+	require.NoError(t, transfer.Export(ctx, source, &valuesCSV, transfer.DefaultOptions(), 0))
+
+	target := testutil.NewTestDB(t)
+	report, err := transfer.Import(ctx, target, bytes.NewReader(gaugesCSV.Bytes()), transfer.Options{Kind: transfer.KindGauges}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Imported)
+
+	report, err = transfer.Import(ctx, target, bytes.NewReader(valuesCSV.Bytes()), transfer.DefaultOptions(), false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, transfer.StatusImported, report.Rows[0].Status)
+
+	gauges, err := target.ListGauges(ctx)
+	require.NoError(t, err)
+	require.Len(t, gauges, 1)
+	assert.Equal(t, gauge.Name, gauges[0].Name)
+	assert.Equal(t, gauge.Description, gauges[0].Description)
+	assert.Equal(t, gauge.Icon, gauges[0].Icon)
+	assert.Equal(t, gauge.Decimals, gauges[0].Decimals)
+	assert.Equal(t, 3.5, gauges[0].Value)
+
+	// Exporting the copy gives the same file, and importing it again finds
+	// every row already recorded
+	var again bytes.Buffer
+	require.NoError(t, transfer.Export(ctx, target, &again, transfer.DefaultOptions(), 0))
+	assert.Equal(t, valuesCSV.String(), again.String())
+
+	report, err = transfer.Import(ctx, target, bytes.NewReader(valuesCSV.Bytes()), transfer.DefaultOptions(), false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, 2, report.Duplicates)
+}
+
+func TestImportValues(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	gauge, err := store.CreateGauge(ctx, db.CreateGaugeParams{
+		Name: "Water", Target: 2, Unit: "l", Icon: "water", Period: "day", PeriodDays: 1,
+		WeekStart: 1, Timezone: "Europe/London", GoalType: "at_least", Step: 0.25, Decimals: 2,
+	})
+	require.NoError(t, err)
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	today := time.Now().In(london).Format("02/01/2006")
+
+	t.Run("maps columns, dates and units", func(t *testing.T) {
+		file := "When,Millilitres\n" + today + ",250\n" + today + ",500\n"
+		opts := transfer.Options{
+			Kind:       transfer.KindValues,
+			Columns:    map[string]string{"date": "when", "amount": "millilitres"},
+			DateFormat: "dmy",
+			Unit:       "ml",
+			Gauge:      "water",
+		}
+
+		report, err := transfer.Import(ctx, store, strings.NewReader(file), opts, true)
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		require.Len(t, report.Rows, 2)
+		assert.Equal(t, 2, report.Rows[0].Line)
+		assert.Equal(t, "Water", report.Rows[0].Gauge)
+		assert.Equal(t, 0.25, *report.Rows[0].Amount)
+		assert.Equal(t, transfer.StatusReady, report.Rows[0].Status)
+		// Both rows are the same day, so the second is a duplicate
+		assert.Equal(t, transfer.StatusDuplicate, report.Rows[1].Status)
+		assert.Equal(t, 1, report.Imported)
+
+		updated, err := store.GetGauge(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, updated.Value, "a preview imports nothing")
+	})
+
+	t.Run("invalid rows stop the import", func(t *testing.T) {
+		file := "gauge,date,amount,unit\n" +
+			"Water,2024-01-01T08:00:00Z,1,l\n" +
+			"Steps,2024-01-01T08:00:00Z,1,\n" +
+			"Water,yesterday,lots,\n" +
+			"Water,2024-01-02T08:00:00Z,1,kg\n" +
+			"Water,2999-01-01T08:00:00Z,1,\n"
+
+		report, err := transfer.Import(ctx, store, strings.NewReader(file), transfer.DefaultOptions(), false)
+		require.ErrorIs(t, err, transfer.ErrInvalidRows)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 4, report.Invalid)
+		assert.Equal(t, transfer.StatusReady, report.Rows[0].Status)
+		assert.Equal(t, "gauge", report.Rows[1].Errors[0].Field)
+		require.Len(t, report.Rows[2].Errors, 2)
+		assert.Equal(t, "date", report.Rows[2].Errors[0].Field)
+		assert.Equal(t, "amount", report.Rows[2].Errors[1].Field)
+		assert.Equal(t, "unit", report.Rows[3].Errors[0].Field)
+		assert.Equal(t, "Date cannot be in the future", report.Rows[4].Errors[0].Message)
+
+		values, err := store.GetGaugeValues(ctx, gauge.ID)
+		require.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("amounts follow the entry settings", func(t *testing.T) {
+		limited, err := store.CreateGauge(ctx, db.CreateGaugeParams{
+			Name: "Coffee", Target: 3, Unit: "l", Icon: "coffee", Period: "day", PeriodDays: 1,
+			WeekStart: 1, Timezone: "UTC", GoalType: "at_most", Step: 0.25, Decimals: 2,
+			MaxValue: sql.NullFloat64{Float64: 2, Valid: true},
+		})
+		require.NoError(t, err)
+		file := "gauge,date,amount,unit\n" +
+			"Coffee,2024-01-01T08:00:00Z,8,fl oz\n" +
+			"Coffee,2024-01-02T08:00:00Z,2500,ml\n" +
+			"Coffee,2024-01-03T08:00:00Z,0.125,l\n"
+
+		report, err := transfer.Import(ctx, store, strings.NewReader(file), transfer.DefaultOptions(), true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Invalid)
+		assert.Equal(t, transfer.StatusReady, report.Rows[0].Status)
+		assert.Equal(t, 0.24, *report.Rows[0].Amount, "converted amounts are rounded")
+		require.Len(t, report.Rows[1].Errors, 1)
+		assert.Equal(t, "amount", report.Rows[1].Errors[0].Field)
+		assert.Equal(t, "Amount must be at most 2", report.Rows[1].Errors[0].Message)
+		require.Len(t, report.Rows[2].Errors, 1)
+		assert.Equal(t, "Amount must have at most 2 decimal places", report.Rows[2].Errors[0].Message)
+
+		values, err := store.GetGaugeValues(ctx, limited.ID)
+		require.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("missing columns", func(t *testing.T) {
+		_, err := transfer.Import(ctx, store, strings.NewReader("date,amount\n"), transfer.DefaultOptions(), true)
+		require.ErrorIs(t, err, transfer.ErrInvalidFile)
+		assert.Contains(t, err.Error(), `"gauge"`)
+
+		_, err = transfer.Import(ctx, store, strings.NewReader(""), transfer.DefaultOptions(), true)
+		require.ErrorIs(t, err, transfer.ErrInvalidFile)
+	})
+}
+
+func TestImportGauges(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	opts := transfer.Options{Kind: transfer.KindGauges}
+
+	file := "name,unit,target,goal_type,period\n" +
+		"Steps,steps,10000,at_least,day\n" +
+		"Sleep,hours,,at_least,day\n" +
+		"Coffee,cups,3,sometimes,day\n"
+	report, err := transfer.Import(ctx, store, strings.NewReader(file), opts, false)
+	require.ErrorIs(t, err, transfer.ErrInvalidRows)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Equal(t, "target", report.Rows[1].Errors[0].Field)
+	assert.Equal(t, "goal_type", report.Rows[2].Errors[0].Field)
+
+	report, err = transfer.Import(ctx, store, strings.NewReader("name,unit,target\nSteps,steps,10000\n"), opts, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Imported)
+
+	gauges, err := store.ListGauges(ctx)
+	require.NoError(t, err)
+	require.Len(t, gauges, 1)
+	assert.Equal(t, transfer.DefaultIcon, gauges[0].Icon)
+	assert.Equal(t, "week", gauges[0].Period)
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.Empty(t, transfer.DefaultOptions().Validate())
+
+	errs := transfer.Options{Kind: "people"}.Validate()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "kind", errs[0].Field)
+
+	errs = transfer.Options{
+		Kind:       transfer.KindValues,
+		DateFormat: "15:04",
+		Unit:       "parsecs",
+		Columns:    map[string]string{"amount": "Date"},
+	}.Validate()
+	fields := make([]string, len(errs))
+	for i, err := range errs {
+		fields[i] = err.Field
+	}
+	assert.Equal(t, []string{"date_format", "unit", "column_amount"}, fields)
+}
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		from, to string
+		want     float64
+	}{
+		{250, "ml", "l", 0.25},
+		{1.5, "L", "ml", 1500},
+		{0.1 + 0.2, "kg", "g", 300},
+		{90, "min", "hours", 1.5},
+		{5, "glasses", "Glasses", 5},
+		{5, "", "glasses", 5},
+	}
+	for _, tt := range tests {
+		got, err := transfer.ConvertUnit(tt.amount, tt.from, tt.to)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%v %s to %s", tt.amount, tt.from, tt.to)
+	}
+
+	_, err := transfer.ConvertUnit(1, "kg", "l")
+	assert.Error(t, err)
+	_, err = transfer.ConvertUnit(1, "glasses", "l")
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package transfer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// unit is a unit amounts can be converted between. Units of the same
+// dimension convert through their factor to a common base unit.
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+var unitFactors = map[string]unit{
+	"ml":      {"volume", 0.001},
+	"l":       {"volume", 1},
+	"litres":  {"volume", 1},
+	"liters":  {"volume", 1},
+	"fl oz":   {"volume", 0.0295735295625},
+	"g":       {"mass", 0.001},
+	"kg":      {"mass", 1},
+	"lb":      {"mass", 0.45359237},
+	"lbs":     {"mass", 0.45359237},
+	"st":      {"mass", 6.35029318},
+	"cm":      {"distance", 0.00001},
+	"m":       {"distance", 0.001},
+	"km":      {"distance", 1},
+	"mi":      {"distance", 1.609344},
+	"miles":   {"distance", 1.609344},
+	"ft":      {"distance", 0.0003048},
+	"kcal":    {"energy", 1},
+	"kj":      {"energy", 1 / 4.184},
+	"min":     {"time", 1.0 / 60},
+	"mins":    {"time", 1.0 / 60},
+	"minutes": {"time", 1.0 / 60},
+	"h":       {"time", 1},
+	"hr":      {"time", 1},
+	"hrs":     {"time", 1},
+	"hours":   {"time", 1},
+}
+
+// KnownUnits lists the units amounts can be converted between
+func KnownUnits() []string {
+	units := make([]string, 0, len(unitFactors))
+	for name := range unitFactors {
+		units = append(units, name)
+	}
+	sort.Strings(units)
+	return units
+}
+
+// ConvertUnit converts an amount between units, rounded to drop floating
+// point noise such as 0.30000000000000004. Amounts in the same unit, or with
+// either unit blank, are returned unchanged.
+func ConvertUnit(amount float64, from, to string) (float64, error) {
+	from, to = normalizeUnit(from), normalizeUnit(to)
+	if from == "" || to == "" || from == to {
+		return amount, nil
+	}
+
+	f, fok := unitFactors[from]
+	t, tok := unitFactors[to]
+	if !fok || !tok || f.dimension != t.dimension {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return math.Round(amount*f.factor/t.factor*1e9) / 1e9, nil
+}
+
+func normalizeUnit(u string) string {
+	return strings.ToLower(strings.TrimSpace(u))
+}
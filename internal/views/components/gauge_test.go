@@ -33,6 +33,7 @@ func TestGaugeCard(t *testing.T) {
 		assert.Contains(t, html, gauge.Unit)
 		assert.Contains(t, html, "75.0")
 		assert.Contains(t, html, "Target: 100.0")
+		assert.Contains(t, html, "this week")
 
 		// Check card structure
 		assert.Contains(t, html, `class="card bg-base-100 shadow-xl hover:shadow-2xl transition-all group"`)
@@ -51,6 +52,32 @@ func TestGaugeCard(t *testing.T) {
 		assert.Contains(t, html, `btn btn-success btn-sm w-full font-bold`)
 	})
 
+	t.Run("renders step, presets and entry forms", func(t *testing.T) {
+		water := *gauge
+		water.Unit = "ml"
+		water.Step = 250
+		water.Presets = "250,500"
+		water.MaxValue = sql.NullFloat64{Float64: 2000, Valid: true}
+		html := renderComponent(t, GaugeCard(&water))
+
+		assert.Contains(t, html, "-250")
+		assert.Contains(t, html, "+250")
+		assert.Contains(t, html, `hx-vals="{&#34;amount&#34;: &#34;500&#34;}"`)
+		assert.Contains(t, html, "+500 ml")
+		assert.Contains(t, html, `hx-post="/gauges/1/value"`)
+		assert.Contains(t, html, `hx-post="/gauges/1/log"`)
+		assert.Contains(t, html, `max="2000"`)
+	})
+
+	t.Run("shows active period", func(t *testing.T) {
+		daily := *gauge
+		daily.Period = "day"
+		html := renderComponent(t, GaugeCard(&daily))
+
+		assert.Contains(t, html, "today")
+		assert.NotContains(t, html, "this week")
+	})
+
 	t.Run("shows warning when over target", func(t *testing.T) {
 		gauge.Value = 150.0
 		component := GaugeCard(gauge)
@@ -58,6 +85,29 @@ func TestGaugeCard(t *testing.T) {
 
 		assert.Contains(t, html, `text-error animate-pulse`)
 	})
+
+	t.Run("at least goal below target is not an error", func(t *testing.T) {
+		steps := *gauge
+		steps.GoalType = "at_least"
+		steps.Value = 40
+		html := renderComponent(t, GaugeCard(&steps))
+
+		assert.Contains(t, html, "at least")
+		assert.Contains(t, html, "font-bold transition-all text-warning")
+		assert.NotContains(t, html, "animate-pulse")
+	})
+
+	t.Run("range goal shows both bounds", func(t *testing.T) {
+		sleep := *gauge
+		sleep.GoalType = "range"
+		sleep.TargetMin = 7
+		sleep.Target = 9
+		sleep.Value = 8
+		html := renderComponent(t, GaugeCard(&sleep))
+
+		assert.Contains(t, html, "Target: 7.0–9.0")
+		assert.Contains(t, html, "text-cyan-500")
+	})
 }
 
 func TestGauge(t *testing.T) {
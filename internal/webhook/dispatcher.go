@@ -0,0 +1,340 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+)
+
+const (
+	// pollInterval is how often the worker looks for deliveries that have
+	// come due, unless a new one wakes it sooner
+	pollInterval = 10 * time.Second
+	// batchSize is how many due deliveries are sent per poll
+	batchSize = 20
+	// requestTimeout bounds each attempt, including reading the response
+	requestTimeout = 10 * time.Second
+	// pruneInterval is how often finished deliveries are pruned
+	pruneInterval = time.Hour
+	// maxErrorLength is how much of a failed response's body is logged
+	maxErrorLength = 200
+)
+
+// Config says how deliveries are retried and how long they are logged
+type Config struct {
+	// MaxAttempts is how many times a delivery is tried before it fails
+	MaxAttempts int
+	// RetryDelay is the wait after the first failed attempt. It doubles
+	// after each further failure, up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// KeepFor is how long finished deliveries stay in the log
+	KeepFor time.Duration
+}
+
+// DefaultConfig tries each delivery 8 times over about a day, and keeps
+// the log for 30 days
+var DefaultConfig = Config{
+	MaxAttempts:   8,
+	RetryDelay:    30 * time.Second,
+	MaxRetryDelay: 6 * time.Hour,
+	KeepFor:       30 * 24 * time.Hour,
+}
+
+// Store holds the endpoints and the delivery queue
+type Store interface {
+	ListWebhooks(ctx context.Context) ([]db.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (db.Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, arg db.CreateWebhookDeliveryParams) (db.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error)
+	ListDueWebhookDeliveries(ctx context.Context, arg db.ListDueWebhookDeliveriesParams) ([]db.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, arg db.UpdateWebhookDeliveryParams) error
+	RetryWebhookDelivery(ctx context.Context, arg db.RetryWebhookDeliveryParams) error
+	PruneWebhookDeliveries(ctx context.Context, createdAt time.Time) error
+}
+
+// Subscriber is where the dispatcher hears about gauge events
+type Subscriber interface {
+	Subscribe() (<-chan events.Event, func())
+}
+
+// Dispatcher queues deliveries for gauge events and sends them
+type Dispatcher struct {
+	store  Store
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+	// wake tells the worker a delivery is due now
+	wake chan struct{}
+}
+
+// NewDispatcher returns a dispatcher queueing deliveries in store
+func NewDispatcher(store Store, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		cfg:    cfg,
+		client: &http.Client{Timeout: requestTimeout},
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Listen queues deliveries for the events published to sub until ctx is
+// cancelled. A subscription that falls behind is closed by the broker; the
+// events it missed are lost, and Listen subscribes again.
+func (d *Dispatcher) Listen(ctx context.Context, sub Subscriber, onError func(error)) {
+	for {
+		ch, cancel := sub.Subscribe()
+		if !d.listen(ctx, ch, onError) {
+			cancel()
+			return
+		}
+		cancel()
+		if onError != nil {
+			onError(errors.New("webhook dispatcher fell behind; some events were not delivered"))
+		}
+	}
+}
+
+// listen handles events until the channel closes, returning false once ctx
+// is cancelled
+func (d *Dispatcher) listen(ctx context.Context, ch <-chan events.Event, onError func(error)) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case e, ok := <-ch:
+			if !ok {
+				return true
+			}
+			if _, err := d.Enqueue(ctx, e); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Enqueue queues a delivery of each webhook event e stands for to every
+// active endpoint subscribed to it, returning how many were queued
+func (d *Dispatcher) Enqueue(ctx context.Context, e events.Event) (int, error) {
+	now := d.now()
+	payloads := Payloads(e, now)
+	if len(payloads) == 0 {
+		return 0, nil
+	}
+	hooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing webhooks: %w", err)
+	}
+
+	queued := 0
+	for _, p := range payloads {
+		body, err := json.Marshal(p)
+		if err != nil {
+			return queued, err
+		}
+		for _, hook := range hooks {
+			if !hook.Active || !Wants(hook, p.Event) {
+				continue
+			}
+			_, err := d.store.CreateWebhookDelivery(ctx, db.CreateWebhookDeliveryParams{
+				WebhookID:     hook.ID,
+				Event:         p.Event,
+				Payload:       string(body),
+				NextAttemptAt: now.UTC(),
+			})
+			if err != nil {
+				return queued, fmt.Errorf("queueing %s for webhook %d: %w", p.Event, hook.ID, err)
+			}
+			queued++
+		}
+	}
+	if queued > 0 {
+		d.signal()
+	}
+	return queued, nil
+}
+
+// Retry queues a delivery to be sent again straight away, giving it a
+// fresh set of attempts if it had failed
+func (d *Dispatcher) Retry(ctx context.Context, id int64) error {
+	delivery, err := d.store.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == StatusFailed {
+		delivery.Attempts = 0
+		err = d.store.UpdateWebhookDelivery(ctx, db.UpdateWebhookDeliveryParams{
+			ID:             delivery.ID,
+			Status:         StatusPending,
+			Attempts:       0,
+			NextAttemptAt:  d.now().UTC(),
+			LastAttemptAt:  delivery.LastAttemptAt,
+			ResponseStatus: delivery.ResponseStatus,
+			Error:          delivery.Error,
+		})
+	} else {
+		err = d.store.RetryWebhookDelivery(ctx, db.RetryWebhookDeliveryParams{ID: id, NextAttemptAt: d.now().UTC()})
+	}
+	if err != nil {
+		return err
+	}
+	d.signal()
+	return nil
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends deliveries as they come due until ctx is cancelled, and prunes
+// the log of finished ones
+func (d *Dispatcher) Run(ctx context.Context, onError func(error)) {
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	var pruned time.Time
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		if now := d.now(); now.Sub(pruned) >= pruneInterval {
+			pruned = now
+			if err := d.store.PruneWebhookDeliveries(ctx, now.Add(-d.cfg.KeepFor).UTC()); err != nil && onError != nil {
+				onError(fmt.Errorf("pruning webhook deliveries: %w", err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue sends the deliveries whose next attempt is due, returning how
+// many were attempted. A batch that fills up is followed by another.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		due, err := d.store.ListDueWebhookDeliveries(ctx, db.ListDueWebhookDeliveriesParams{
+			Now:   d.now().UTC(),
+			Limit: batchSize,
+		})
+		if err != nil {
+			return attempted, fmt.Errorf("listing due webhook deliveries: %w", err)
+		}
+		for _, delivery := range due {
+			if err := d.deliver(ctx, delivery); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		if len(due) < batchSize {
+			return attempted, nil
+		}
+	}
+}
+
+// deliver makes one attempt at a delivery and records the outcome. Only a
+// failure to record it is returned; a failed attempt is scheduled again.
+func (d *Dispatcher) deliver(ctx context.Context, delivery db.WebhookDelivery) error {
+	hook, err := d.store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return fmt.Errorf("loading webhook %d: %w", delivery.WebhookID, err)
+	}
+
+	now := d.now()
+	update := db.UpdateWebhookDeliveryParams{
+		ID:            delivery.ID,
+		Attempts:      delivery.Attempts + 1,
+		LastAttemptAt: sql.NullTime{Time: now.UTC(), Valid: true},
+		NextAttemptAt: delivery.NextAttemptAt,
+	}
+
+	var status int
+	var sendErr error
+	if !hook.Active {
+		sendErr = errors.New("the webhook is disabled")
+		update.Attempts = int64(d.cfg.MaxAttempts)
+	} else {
+		status, sendErr = d.send(ctx, hook, delivery, now)
+	}
+	if status != 0 {
+		update.ResponseStatus = sql.NullInt64{Int64: int64(status), Valid: true}
+	}
+
+	switch {
+	case sendErr == nil:
+		update.Status = StatusDelivered
+	case update.Attempts >= int64(d.cfg.MaxAttempts):
+		update.Status = StatusFailed
+		update.Error = sendErr.Error()
+	default:
+		update.Status = StatusPending
+		update.Error = sendErr.Error()
+		update.NextAttemptAt = now.Add(d.backoff(update.Attempts)).UTC()
+	}
+
+	if err := d.store.UpdateWebhookDelivery(ctx, update); err != nil {
+		return fmt.Errorf("recording webhook delivery %d: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// backoff is how long to wait after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int64) time.Duration {
+	delay := d.cfg.RetryDelay
+	for i := int64(1); i < attempts && delay < d.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxRetryDelay)
+}
+
+// send posts a delivery's payload, returning the response status. Any
+// status outside 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, hook db.Webhook, delivery db.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "health-monitor-webhook")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := resp.Status
+		if text := strings.TrimSpace(string(excerpt)); text != "" {
+			msg += ": " + text
+		}
+		return resp.StatusCode, errors.New(msg)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,197 @@
+// Package webhook notifies outside services of gauge events.
+//
+// An admin registers endpoints, each with the events it wants. When an
+// event happens a delivery is queued in the database for every active
+// endpoint that wants it, and a worker posts the queued deliveries as
+// signed JSON, retrying failures with exponential backoff. Because the queue
+// is in the database, deliveries survive a restart, and the admin page can
+// show each one's outcome.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/models"
+)
+
+// Event names, as sent in payloads and the X-Webhook-Event header
+const (
+	EventValueChanged  = "value.changed"
+	EventTargetCrossed = "target.crossed"
+	EventPeriodClosed  = "period.closed"
+	EventGaugeCreated  = "gauge.created"
+	EventGaugeDeleted  = "gauge.deleted"
+)
+
+// EventType describes an event an endpoint can subscribe to
+type EventType struct {
+	Name        string
+	Description string
+}
+
+// EventTypes are the events endpoints can subscribe to, in the order the
+// admin page lists them
+var EventTypes = []EventType{
+	{EventValueChanged, "A gauge's value changes"},
+	{EventTargetCrossed, "A gauge starts or stops meeting its goal"},
+	{EventPeriodClosed, "A gauge's period ends and is archived"},
+	{EventGaugeCreated, "A gauge is created"},
+	{EventGaugeDeleted, "A gauge is deleted"},
+}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// ParseEvents reads an endpoint's comma separated event list, dropping
+// names that are not events
+func ParseEvents(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if IsEvent(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// JoinEvents writes an event list as stored with an endpoint
+func JoinEvents(names []string) string {
+	return strings.Join(names, ",")
+}
+
+// IsEvent reports whether name is an event endpoints can subscribe to
+func IsEvent(name string) bool {
+	for _, t := range EventTypes {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports whether the endpoint subscribes to event
+func Wants(hook db.Webhook, event string) bool {
+	return slices.Contains(ParseEvents(hook.Events), event)
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign returns the X-Webhook-Signature of a payload: the hex HMAC-SHA256,
+// keyed with the endpoint's secret, of the X-Webhook-Timestamp, a full stop
+// and the body. Signing the timestamp lets receivers reject replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the one Sign gives for the payload
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Payload is the JSON body posted to an endpoint
+type Payload struct {
+	Event     string       `json:"event"`
+	Timestamp time.Time    `json:"timestamp"`
+	Gauge     GaugeState   `json:"gauge"`
+	Previous  *GaugeState  `json:"previous,omitempty"`
+	Period    *PeriodState `json:"period,omitempty"`
+}
+
+// GaugeState is a gauge and how it stands against its goal
+type GaugeState struct {
+	ID       int64           `json:"id"`
+	Name     string          `json:"name"`
+	Unit     string          `json:"unit"`
+	Value    float64         `json:"value"`
+	Target   float64         `json:"target"`
+	GoalType models.GoalType `json:"goal_type"`
+	Percent  float64         `json:"percent"`
+	OnTrack  bool            `json:"on_track"`
+}
+
+// PeriodState is an archived period and how it ended against its goal
+type PeriodState struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Value   float64   `json:"value"`
+	Target  float64   `json:"target"`
+	OnTrack bool      `json:"on_track"`
+}
+
+func gaugeState(gauge db.Gauge, value float64) GaugeState {
+	eval := models.EvaluateGoal(&gauge, value)
+	return GaugeState{
+		ID:       gauge.ID,
+		Name:     gauge.Name,
+		Unit:     gauge.Unit,
+		Value:    value,
+		Target:   gauge.Target,
+		GoalType: models.GaugeGoalType(&gauge),
+		Percent:  eval.Percent,
+		OnTrack:  eval.OnTrack(),
+	}
+}
+
+// Payloads turns a broker event into the webhook events it stands for. A
+// value change that moves a gauge onto or off its goal is also a target
+// crossing. Events without a webhook counterpart give none.
+func Payloads(e events.Event, now time.Time) []Payload {
+	now = now.UTC()
+	switch e.Type {
+	case events.GaugeChanged:
+		if e.Previous == e.Gauge.Value {
+			return nil
+		}
+		current, previous := gaugeState(e.Gauge, e.Gauge.Value), gaugeState(e.Gauge, e.Previous)
+		payloads := []Payload{{Event: EventValueChanged, Timestamp: now, Gauge: current, Previous: &previous}}
+		if current.OnTrack != previous.OnTrack {
+			payloads = append(payloads, Payload{Event: EventTargetCrossed, Timestamp: now, Gauge: current, Previous: &previous})
+		}
+		return payloads
+	case events.PeriodClosed:
+		if e.Period == nil {
+			return nil
+		}
+		gauge := e.Gauge
+		gauge.Target = e.Period.Target
+		return []Payload{{
+			Event:     EventPeriodClosed,
+			Timestamp: now,
+			Gauge:     gaugeState(e.Gauge, e.Gauge.Value),
+			Period: &PeriodState{
+				Start:   e.Period.PeriodStart,
+				End:     e.Period.PeriodEnd,
+				Value:   e.Period.Value,
+				Target:  e.Period.Target,
+				OnTrack: models.EvaluateGoal(&gauge, e.Period.Value).OnTrack(),
+			},
+		}}
+	case events.GaugeCreated:
+		return []Payload{{Event: EventGaugeCreated, Timestamp: now, Gauge: gaugeState(e.Gauge, e.Gauge.Value)}}
+	case events.GaugeDeleted:
+		return []Payload{{Event: EventGaugeDeleted, Timestamp: now, Gauge: gaugeState(e.Gauge, e.Gauge.Value)}}
+	}
+	return nil
+}
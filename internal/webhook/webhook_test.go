@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"health-monitor/internal/db"
+	"health-monitor/internal/events"
+	"health-monitor/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"value.changed"}`)
+	sig := Sign("secret", "1741946400", body)
+	assert.Equal(t, "sha256=", sig[:7])
+	assert.True(t, Verify("secret", "1741946400", body, sig))
+	assert.False(t, Verify("other", "1741946400", body, sig), "another secret")
+	assert.False(t, Verify("secret", "1741946401", body, sig), "another timestamp")
+	assert.False(t, Verify("secret", "1741946400", []byte(`{}`), sig), "another body")
+}
+
+func TestParseEvents(t *testing.T) {
+	assert.Equal(t, []string{EventValueChanged, EventGaugeDeleted}, ParseEvents(" value.changed,gauge.deleted,,nope,value.changed"))
+	assert.Empty(t, ParseEvents(""))
+	assert.True(t, Wants(db.Webhook{Events: "period.closed"}, EventPeriodClosed))
+	assert.False(t, Wants(db.Webhook{Events: "period.closed"}, EventValueChanged))
+}
+
+func TestPayloads(t *testing.T) {
+	now := time.Date(2025, time.March, 14, 10, 0, 0, 0, time.UTC)
+	gauge := db.Gauge{ID: 4, Name: "Coffee", Unit: "cups", Target: 3, GoalType: "at_most", Value: 4}
+
+	payloads := Payloads(events.Changed(gauge, 3), now)
+	require.Len(t, payloads, 2, "going over an at most target crosses it")
+	assert.Equal(t, EventValueChanged, payloads[0].Event)
+	assert.Equal(t, EventTargetCrossed, payloads[1].Event)
+	assert.Equal(t, 4.0, payloads[1].Gauge.Value)
+	assert.False(t, payloads[1].Gauge.OnTrack)
+	assert.True(t, payloads[1].Previous.OnTrack)
+	assert.Equal(t, now, payloads[0].Timestamp)
+
+	payloads = Payloads(events.Changed(gauge, 5), now)
+	require.Len(t, payloads, 1, "staying over the target crosses nothing")
+	assert.Equal(t, EventValueChanged, payloads[0].Event)
+
+	assert.Empty(t, Payloads(events.Changed(gauge, 4), now), "no change")
+	assert.Empty(t, Payloads(events.Updated(gauge), now))
+	assert.Empty(t, Payloads(events.Reload(), now))
+
+	closed := db.GaugePeriod{
+		GaugeID:     4,
+		PeriodStart: now.AddDate(0, 0, -7),
+		PeriodEnd:   now,
+		Value:       2,
+		Target:      3,
+	}
+	payloads = Payloads(events.Closed(gauge, closed), now)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, EventPeriodClosed, payloads[0].Event)
+	assert.Equal(t, 2.0, payloads[0].Period.Value)
+	assert.True(t, payloads[0].Period.OnTrack)
+
+	payloads = Payloads(events.Deleted(gauge), now)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, EventGaugeDeleted, payloads[0].Event)
+	assert.Equal(t, "Coffee", payloads[0].Gauge.Name)
+}
+
+// receiver is a local endpoint recording what it is sent, answering with
+// status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	w.WriteHeader(rc.status)
+	io.WriteString(w, http.StatusText(rc.status))
+}
+
+func (rc *receiver) received() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewTestDB(t)
+	gauge := testutil.CreateTestGauge(t, store)
+
+	rc := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	hook, err := store.CreateWebhook(ctx, db.CreateWebhookParams{
+		Name:   "Receiver",
+		Url:    server.URL,
+		Secret: "s3cret",
+		Events: JoinEvents([]string{EventValueChanged, EventGaugeDeleted}),
+		Active: true,
+	})
+	require.NoError(t, err)
+	// A disabled endpoint is never sent anything
+	_, err = store.CreateWebhook(ctx, db.CreateWebhookParams{
+		Name:   "Disabled",
+		Url:    server.URL,
+		Secret: "s3cret",
+		Events: EventValueChanged,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2025, time.March, 14, 10, 0, 0, 0, time.UTC)
+	cfg := Config{MaxAttempts: 3, RetryDelay: time.Minute, MaxRetryDelay: time.Hour, KeepFor: time.Hour}
+	d := NewDispatcher(store, cfg)
+	d.now = func() time.Time { return now }
+
+	t.Run("delivers a signed payload", func(t *testing.T) {
+		gauge.Value = 12
+		queued, err := d.Enqueue(ctx, events.Changed(gauge, 10))
+		require.NoError(t, err)
+		assert.Equal(t, 1, queued)
+
+		attempted, err := d.DeliverDue(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+		require.Equal(t, 1, rc.received())
+
+		req, body := rc.requests[0], rc.bodies[0]
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, EventValueChanged, req.Header.Get("X-Webhook-Event"))
+		assert.Equal(t, strconv.FormatInt(now.Unix(), 10), req.Header.Get("X-Webhook-Timestamp"))
+		assert.True(t, Verify("s3cret", req.Header.Get("X-Webhook-Timestamp"), body, req.Header.Get("X-Webhook-Signature")))
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, EventValueChanged, payload.Event)
+		assert.Equal(t, gauge.ID, payload.Gauge.ID)
+		assert.Equal(t, 12.0, payload.Gauge.Value)
+		assert.Equal(t, 10.0, payload.Previous.Value)
+
+		id, err := strconv.ParseInt(req.Header.Get("X-Webhook-Delivery"), 10, 64)
+		require.NoError(t, err)
+		delivery, err := store.GetWebhookDelivery(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, StatusDelivered, delivery.Status)
+		assert.Equal(t, int64(1), delivery.Attempts)
+		assert.Equal(t, int64(http.StatusOK), delivery.ResponseStatus.Int64)
+	})
+
+	t.Run("skips events the endpoint does not want", func(t *testing.T) {
+		queued, err := d.Enqueue(ctx, events.Created(gauge))
+		require.NoError(t, err)
+		assert.Zero(t, queued)
+	})
+
+	t.Run("retries with backoff until it fails", func(t *testing.T) {
+		rc.status = http.StatusInternalServerError
+		_, err := d.Enqueue(ctx, events.Deleted(gauge))
+		require.NoError(t, err)
+
+		_, err = d.DeliverDue(ctx)
+		require.NoError(t, err)
+		deliveries, err := store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{Limit: 1})
+		require.NoError(t, err)
+		delivery := deliveries[0]
+		assert.Equal(t, StatusPending, delivery.Status)
+		assert.Equal(t, int64(1), delivery.Attempts)
+		assert.Equal(t, int64(http.StatusInternalServerError), delivery.ResponseStatus.Int64)
+		assert.Contains(t, delivery.Error, "500")
+		assert.True(t, delivery.NextAttemptAt.Equal(now.Add(time.Minute)))
+
+		// Nothing is due until the backoff has passed
+		attempted, err := d.DeliverDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, attempted)
+
+		now = now.Add(time.Minute)
+		_, err = d.DeliverDue(ctx)
+		require.NoError(t, err)
+		delivery, err = store.GetWebhookDelivery(ctx, delivery.ID)
+		require.NoError(t, err)
+		assert.True(t, delivery.NextAttemptAt.Equal(now.Add(2*time.Minute)), "the delay doubles")
+
+		now = now.Add(2 * time.Minute)
+		_, err = d.DeliverDue(ctx)
+		require.NoError(t, err)
+		delivery, err = store.GetWebhookDelivery(ctx, delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusFailed, delivery.Status)
+		assert.Equal(t, int64(3), delivery.Attempts)
+
+		// Retrying by hand starts over
+		rc.status = http.StatusNoContent
+		require.NoError(t, d.Retry(ctx, delivery.ID))
+		_, err = d.DeliverDue(ctx)
+		require.NoError(t, err)
+		delivery, err = store.GetWebhookDelivery(ctx, delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusDelivered, delivery.Status)
+		assert.Equal(t, int64(1), delivery.Attempts)
+	})
+
+	t.Run("lists deliveries by endpoint", func(t *testing.T) {
+		deliveries, err := store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+			WebhookID: sql.NullInt64{Int64: hook.ID, Valid: true},
+			Limit:     10,
+		})
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+		deliveries, err = store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+			WebhookID: sql.NullInt64{Int64: hook.ID + 100, Valid: true},
+			Limit:     10,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(nil, DefaultConfig)
+	assert.Equal(t, 30*time.Second, d.backoff(1))
+	assert.Equal(t, time.Minute, d.backoff(2))
+	assert.Equal(t, 4*time.Minute, d.backoff(4))
+	assert.Equal(t, 6*time.Hour, d.backoff(20))
+}